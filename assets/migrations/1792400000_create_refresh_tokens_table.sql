@@ -0,0 +1,26 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Only the SHA-256 hash of the refresh token is saved, so the token can't be used when this table is leaked.
+-- Every token rotated from the same login shares the family_id, it's revoked at once when a used token is presented again.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  "id" BIGSERIAL NOT NULL PRIMARY KEY,
+  "user_id" BIGINT NOT NULL,
+  "family_id" VARCHAR NOT NULL,
+  "token_hash" VARCHAR NOT NULL,
+  "expires_at" TIMESTAMP WITH TIME ZONE NOT NULL,
+  "used_at" TIMESTAMP WITH TIME ZONE NULL,
+  "revoked_at" TIMESTAMP WITH TIME ZONE NULL,
+  "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- add foreign key check
+ALTER TABLE refresh_tokens ADD CONSTRAINT refresh_tokens_user_id_foreign FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_refresh_tokens_on_token_hash ON refresh_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_on_family_id ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_on_user_id ON refresh_tokens(user_id);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS refresh_tokens;
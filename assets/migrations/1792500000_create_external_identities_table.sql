@@ -0,0 +1,20 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Links the subject of the external OpenID Connect issuer (such as the company SSO) to the local user.
+CREATE TABLE IF NOT EXISTS external_identities (
+  "id" BIGSERIAL NOT NULL PRIMARY KEY,
+  "user_id" BIGINT NOT NULL,
+  "issuer" VARCHAR NOT NULL,
+  "subject" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- add foreign key check
+ALTER TABLE external_identities ADD CONSTRAINT external_identities_user_id_foreign FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_external_identities_on_issuer_subject ON external_identities(issuer, subject);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS external_identities;
@@ -0,0 +1,27 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Only the SHA-256 hash of the API key is saved, the key itself is shown once when it's created.
+-- The prefix is the first characters of the key, so the user can tell which key is which.
+-- The scopes are separated by space, like the scope of OAuth 2.0.
+CREATE TABLE IF NOT EXISTS api_keys (
+  "id" BIGSERIAL NOT NULL PRIMARY KEY,
+  "user_id" BIGINT NOT NULL,
+  "name" VARCHAR NOT NULL,
+  "prefix" VARCHAR NOT NULL,
+  "key_hash" VARCHAR NOT NULL,
+  "scopes" VARCHAR NOT NULL,
+  "last_used_at" TIMESTAMP WITH TIME ZONE NULL,
+  "revoked_at" TIMESTAMP WITH TIME ZONE NULL,
+  "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- add foreign key check
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_user_id_foreign FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_api_keys_on_key_hash ON api_keys(key_hash);
+CREATE INDEX IF NOT EXISTS idx_api_keys_on_user_id ON api_keys(user_id);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS api_keys;
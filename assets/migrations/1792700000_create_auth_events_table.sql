@@ -0,0 +1,22 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Log of the login attempts, the user_id is NULL when the username doesn't exist.
+-- The event is kept when the user is deleted, since it may be needed to investigate the attack.
+CREATE TABLE IF NOT EXISTS auth_events (
+  "id" BIGSERIAL NOT NULL PRIMARY KEY,
+  "user_id" BIGINT NULL,
+  "username" VARCHAR NOT NULL,
+  "ip" VARCHAR NOT NULL,
+  "event" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- add foreign key check
+ALTER TABLE auth_events ADD CONSTRAINT auth_events_user_id_foreign FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_auth_events_on_user_id ON auth_events(user_id);
+CREATE INDEX IF NOT EXISTS idx_auth_events_on_created_at ON auth_events(created_at);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS auth_events;
@@ -0,0 +1,16 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Username is unique regardless of the case, so "John_Doe" can't be registered when "john_doe" exists.
+-- This fails when such duplicate already exists, rename one of them before applying it.
+DROP INDEX IF EXISTS unique_idx_users_on_username;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_users_on_lower_username ON users(lower(username));
+
+-- The authentication token issued before the password is changed is rejected.
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP WITH TIME ZONE NULL;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE users DROP COLUMN password_changed_at;
+
+DROP INDEX IF EXISTS unique_idx_users_on_lower_username;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_users_on_username ON users(username);
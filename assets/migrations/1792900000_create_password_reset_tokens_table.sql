@@ -0,0 +1,29 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Email is optional, it's only used to send the password reset link. It's unique regardless of the case.
+ALTER TABLE users ADD COLUMN email VARCHAR NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_users_on_lower_email ON users(lower(email));
+
+-- Only the SHA-256 hash of the reset token is saved, the token can be used once before it expires.
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+  "id" BIGSERIAL NOT NULL PRIMARY KEY,
+  "user_id" BIGINT NOT NULL,
+  "token_hash" VARCHAR NOT NULL,
+  "expires_at" TIMESTAMP WITH TIME ZONE NOT NULL,
+  "used_at" TIMESTAMP WITH TIME ZONE NULL,
+  "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- add foreign key check
+ALTER TABLE password_reset_tokens ADD CONSTRAINT password_reset_tokens_user_id_foreign FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_password_reset_tokens_on_token_hash ON password_reset_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_on_user_id ON password_reset_tokens(user_id);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS password_reset_tokens;
+
+DROP INDEX IF EXISTS unique_idx_users_on_lower_email;
+ALTER TABLE users DROP COLUMN email;
@@ -0,0 +1,30 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- totp_secret is set when the user enrolls, and 2FA is only required after it's confirmed (totp_enabled_at is set).
+-- totp_last_step is the time step of the last accepted code, so the same code can't be used twice.
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR NULL;
+ALTER TABLE users ADD COLUMN totp_enabled_at TIMESTAMP WITH TIME ZONE NULL;
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NULL;
+
+-- Only the SHA-256 hash of the recovery code is saved, every code can be used once instead of the TOTP code.
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  "id" BIGSERIAL NOT NULL PRIMARY KEY,
+  "user_id" BIGINT NOT NULL,
+  "code_hash" VARCHAR NOT NULL,
+  "used_at" TIMESTAMP WITH TIME ZONE NULL,
+  "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- add foreign key check
+ALTER TABLE recovery_codes ADD CONSTRAINT recovery_codes_user_id_foreign FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_recovery_codes_on_user_id_code_hash ON recovery_codes(user_id, code_hash);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS recovery_codes;
+
+ALTER TABLE users DROP COLUMN totp_last_step;
+ALTER TABLE users DROP COLUMN totp_enabled_at;
+ALTER TABLE users DROP COLUMN totp_secret;
@@ -0,0 +1,73 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Every user has the "user" role, so only the other roles are saved in user_roles.
+-- The role and permission are identified by name, so the application can refer them as constant.
+CREATE TABLE IF NOT EXISTS roles (
+  "name" VARCHAR NOT NULL PRIMARY KEY,
+  "description" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+  "name" VARCHAR NOT NULL PRIMARY KEY,
+  "description" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+  "role" VARCHAR NOT NULL,
+  "permission" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  PRIMARY KEY ("role", "permission")
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+  "id" BIGSERIAL NOT NULL PRIMARY KEY,
+  "user_id" BIGINT NOT NULL,
+  "role" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- add foreign key check
+ALTER TABLE role_permissions ADD CONSTRAINT role_permissions_role_foreign FOREIGN KEY (role) REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE role_permissions ADD CONSTRAINT role_permissions_permission_foreign FOREIGN KEY (permission) REFERENCES permissions(name) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE user_roles ADD CONSTRAINT user_roles_user_id_foreign FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE user_roles ADD CONSTRAINT user_roles_role_foreign FOREIGN KEY (role) REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_user_roles_on_user_id_role ON user_roles(user_id, role);
+
+INSERT INTO roles(name, description) VALUES
+  ('user', 'Manage their own taxes'),
+  ('auditor', 'View every user and their taxes'),
+  ('admin', 'View every user and their taxes, and disable the account');
+
+INSERT INTO permissions(name, description) VALUES
+  ('tax:read', 'List their own taxes'),
+  ('tax:write', 'Create their own tax'),
+  ('user:read', 'List every user'),
+  ('tax:read:any', 'List the taxes of any user'),
+  ('user:disable', 'Disable the account of any user');
+
+INSERT INTO role_permissions(role, permission) VALUES
+  ('user', 'tax:read'),
+  ('user', 'tax:write'),
+  ('auditor', 'user:read'),
+  ('auditor', 'tax:read:any'),
+  ('admin', 'user:read'),
+  ('admin', 'tax:read:any'),
+  ('admin', 'user:disable');
+
+-- disabled user can't login, and every token and API key of the user is rejected
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE NULL;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE users DROP COLUMN disabled_at;
+
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
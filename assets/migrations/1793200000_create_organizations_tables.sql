@@ -0,0 +1,75 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS organizations (
+  "id" BIGSERIAL NOT NULL PRIMARY KEY,
+  "name" VARCHAR NOT NULL,
+  "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- owner manages the members and the invitations, member adds the taxes, and viewer only reads them
+CREATE TABLE IF NOT EXISTS organization_members (
+  "id" BIGSERIAL NOT NULL PRIMARY KEY,
+  "org_id" BIGINT NOT NULL,
+  "user_id" BIGINT NOT NULL,
+  "role" VARCHAR NOT NULL CHECK (role IN ('owner', 'member', 'viewer')),
+  "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Only the SHA-256 hash of the invitation token is saved, the token is sent to the email and can be used once.
+CREATE TABLE IF NOT EXISTS organization_invitations (
+  "id" BIGSERIAL NOT NULL PRIMARY KEY,
+  "org_id" BIGINT NOT NULL,
+  "email" VARCHAR NOT NULL,
+  "role" VARCHAR NOT NULL CHECK (role IN ('owner', 'member', 'viewer')),
+  "token_hash" VARCHAR NOT NULL,
+  "invited_by" BIGINT NULL,
+  "accepted_by" BIGINT NULL,
+  "expires_at" TIMESTAMP WITH TIME ZONE NOT NULL,
+  "accepted_at" TIMESTAMP WITH TIME ZONE NULL,
+  "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- add foreign key check
+ALTER TABLE organization_members ADD CONSTRAINT organization_members_org_id_foreign FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE organization_members ADD CONSTRAINT organization_members_user_id_foreign FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE organization_invitations ADD CONSTRAINT organization_invitations_org_id_foreign FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE organization_invitations ADD CONSTRAINT organization_invitations_invited_by_foreign FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE;
+ALTER TABLE organization_invitations ADD CONSTRAINT organization_invitations_accepted_by_foreign FOREIGN KEY (accepted_by) REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_organization_members_on_org_id_user_id ON organization_members(org_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_organization_members_on_user_id ON organization_members(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_organization_invitations_on_token_hash ON organization_invitations(token_hash);
+
+-- The tax is owned by either the user (personal ledger) or the organization (shared ledger).
+-- The organization tax doesn't refer to the user in user_id, so deleting the user only deletes their personal taxes.
+-- created_by is the user who added the tax, it's kept for the organization tax even after the user is deleted.
+ALTER TABLE taxes ADD COLUMN org_id BIGINT NULL;
+ALTER TABLE taxes ADD COLUMN created_by BIGINT NULL;
+ALTER TABLE taxes ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE taxes ADD CONSTRAINT taxes_org_id_foreign FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE ON UPDATE CASCADE;
+ALTER TABLE taxes ADD CONSTRAINT taxes_created_by_foreign FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE;
+ALTER TABLE taxes ADD CONSTRAINT taxes_owner_check CHECK ((user_id IS NOT NULL AND org_id IS NULL) OR (user_id IS NULL AND org_id IS NOT NULL));
+
+UPDATE taxes SET created_by = user_id;
+
+CREATE INDEX IF NOT EXISTS idx_taxes_on_org_id ON taxes(org_id);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+-- the organization taxes have no user, so they can't be kept
+DROP INDEX IF EXISTS idx_taxes_on_org_id;
+DELETE FROM taxes WHERE user_id IS NULL;
+
+ALTER TABLE taxes DROP CONSTRAINT taxes_owner_check;
+ALTER TABLE taxes DROP CONSTRAINT taxes_created_by_foreign;
+ALTER TABLE taxes DROP CONSTRAINT taxes_org_id_foreign;
+ALTER TABLE taxes ALTER COLUMN user_id SET NOT NULL;
+ALTER TABLE taxes DROP COLUMN created_by;
+ALTER TABLE taxes DROP COLUMN org_id;
+
+DROP TABLE IF EXISTS organization_invitations;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,15 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Profile of the user, every field is optional. default_currency is ISO 4217 code, and jurisdiction is
+-- ISO 3166-1 alpha-2 country code optionally followed by the subdivision, such as "ID" or "US-CA".
+ALTER TABLE users ADD COLUMN display_name VARCHAR NULL;
+ALTER TABLE users ADD COLUMN locale VARCHAR NULL;
+ALTER TABLE users ADD COLUMN default_currency VARCHAR NULL;
+ALTER TABLE users ADD COLUMN jurisdiction VARCHAR NULL;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE users DROP COLUMN jurisdiction;
+ALTER TABLE users DROP COLUMN default_currency;
+ALTER TABLE users DROP COLUMN locale;
+ALTER TABLE users DROP COLUMN display_name;
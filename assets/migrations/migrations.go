@@ -0,0 +1,11 @@
+// Package migrations contains the database migration files, they are embedded into the binary
+// so the migration doesn't depend on the working directory.
+// PostgreSQL migration is in the root of this directory, and the other dialect is in its own directory using the same migration id.
+package migrations
+
+import "embed"
+
+// FS contains every migration file of all dialects.
+//
+//go:embed *.sql sqlite/*.sql
+var FS embed.FS
@@ -0,0 +1,16 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- SQLite version of ../1539104678_create_users_table.sql, the migration id must be the same.
+CREATE TABLE IF NOT EXISTS users (
+  "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  "username" VARCHAR NOT NULL,
+  "password" VARCHAR NOT NULL,
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_users_on_username ON users(username);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,20 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- SQLite version of ../1539104697_create_taxes_table.sql, the migration id must be the same.
+-- SQLite cannot add constraint using ALTER TABLE, so the foreign key is declared in the column.
+CREATE TABLE IF NOT EXISTS taxes (
+  "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  "user_id" BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  "name" VARCHAR NOT NULL,
+  "tax_code" INTEGER NOT NULL,
+  "price" INTEGER NOT NULL CHECK (price >= 0),
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+-- add index for faster query (where user_id = ?)
+CREATE INDEX IF NOT EXISTS unique_idx_taxes_on_user_id ON taxes(user_id);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS taxes;
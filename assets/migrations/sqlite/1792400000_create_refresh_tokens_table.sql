@@ -0,0 +1,22 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- SQLite version of ../1792400000_create_refresh_tokens_table.sql, the migration id must be the same.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  "user_id" BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  "family_id" VARCHAR NOT NULL,
+  "token_hash" VARCHAR NOT NULL,
+  "expires_at" DATETIME NOT NULL,
+  "used_at" DATETIME NULL,
+  "revoked_at" DATETIME NULL,
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_refresh_tokens_on_token_hash ON refresh_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_on_family_id ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_on_user_id ON refresh_tokens(user_id);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS refresh_tokens;
@@ -0,0 +1,17 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- SQLite version of ../1792500000_create_external_identities_table.sql, the migration id must be the same.
+CREATE TABLE IF NOT EXISTS external_identities (
+  "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  "user_id" BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  "issuer" VARCHAR NOT NULL,
+  "subject" VARCHAR NOT NULL,
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_external_identities_on_issuer_subject ON external_identities(issuer, subject);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS external_identities;
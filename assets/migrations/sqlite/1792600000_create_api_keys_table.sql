@@ -0,0 +1,22 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- SQLite version of ../1792600000_create_api_keys_table.sql, the migration id must be the same.
+CREATE TABLE IF NOT EXISTS api_keys (
+  "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  "user_id" BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  "name" VARCHAR NOT NULL,
+  "prefix" VARCHAR NOT NULL,
+  "key_hash" VARCHAR NOT NULL,
+  "scopes" VARCHAR NOT NULL,
+  "last_used_at" DATETIME NULL,
+  "revoked_at" DATETIME NULL,
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_api_keys_on_key_hash ON api_keys(key_hash);
+CREATE INDEX IF NOT EXISTS idx_api_keys_on_user_id ON api_keys(user_id);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS api_keys;
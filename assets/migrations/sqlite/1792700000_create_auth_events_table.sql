@@ -0,0 +1,18 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- SQLite version of ../1792700000_create_auth_events_table.sql, the migration id must be the same.
+CREATE TABLE IF NOT EXISTS auth_events (
+  "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  "user_id" BIGINT NULL REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+  "username" VARCHAR NOT NULL,
+  "ip" VARCHAR NOT NULL,
+  "event" VARCHAR NOT NULL,
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_events_on_user_id ON auth_events(user_id);
+CREATE INDEX IF NOT EXISTS idx_auth_events_on_created_at ON auth_events(created_at);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS auth_events;
@@ -0,0 +1,14 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- SQLite version of ../1792800000_add_username_rules_to_users_table.sql, the migration id must be the same.
+DROP INDEX IF EXISTS unique_idx_users_on_username;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_users_on_lower_username ON users(lower(username));
+
+ALTER TABLE users ADD COLUMN password_changed_at DATETIME NULL;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE users DROP COLUMN password_changed_at;
+
+DROP INDEX IF EXISTS unique_idx_users_on_lower_username;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_users_on_username ON users(username);
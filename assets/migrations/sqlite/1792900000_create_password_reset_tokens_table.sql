@@ -0,0 +1,25 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- SQLite version of ../1792900000_create_password_reset_tokens_table.sql, the migration id must be the same.
+ALTER TABLE users ADD COLUMN email VARCHAR NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_users_on_lower_email ON users(lower(email));
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+  "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  "user_id" BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  "token_hash" VARCHAR NOT NULL,
+  "expires_at" DATETIME NOT NULL,
+  "used_at" DATETIME NULL,
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_password_reset_tokens_on_token_hash ON password_reset_tokens(token_hash);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_on_user_id ON password_reset_tokens(user_id);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS password_reset_tokens;
+
+DROP INDEX IF EXISTS unique_idx_users_on_lower_email;
+ALTER TABLE users DROP COLUMN email;
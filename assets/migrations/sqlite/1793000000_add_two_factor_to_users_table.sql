@@ -0,0 +1,25 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- SQLite version of ../1793000000_add_two_factor_to_users_table.sql, the migration id must be the same.
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR NULL;
+ALTER TABLE users ADD COLUMN totp_enabled_at DATETIME NULL;
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NULL;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  "user_id" BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  "code_hash" VARCHAR NOT NULL,
+  "used_at" DATETIME NULL,
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_recovery_codes_on_user_id_code_hash ON recovery_codes(user_id, code_hash);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS recovery_codes;
+
+ALTER TABLE users DROP COLUMN totp_last_step;
+ALTER TABLE users DROP COLUMN totp_enabled_at;
+ALTER TABLE users DROP COLUMN totp_secret;
@@ -0,0 +1,65 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- SQLite version of ../1793100000_create_roles_tables.sql, the migration id must be the same.
+CREATE TABLE IF NOT EXISTS roles (
+  "name" VARCHAR NOT NULL PRIMARY KEY,
+  "description" VARCHAR NOT NULL,
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+  "name" VARCHAR NOT NULL PRIMARY KEY,
+  "description" VARCHAR NOT NULL,
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+  "role" VARCHAR NOT NULL REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE,
+  "permission" VARCHAR NOT NULL REFERENCES permissions(name) ON DELETE CASCADE ON UPDATE CASCADE,
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  PRIMARY KEY ("role", "permission")
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+  "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  "user_id" BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  "role" VARCHAR NOT NULL REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE,
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_user_roles_on_user_id_role ON user_roles(user_id, role);
+
+INSERT INTO roles(name, description) VALUES
+  ('user', 'Manage their own taxes'),
+  ('auditor', 'View every user and their taxes'),
+  ('admin', 'View every user and their taxes, and disable the account');
+
+INSERT INTO permissions(name, description) VALUES
+  ('tax:read', 'List their own taxes'),
+  ('tax:write', 'Create their own tax'),
+  ('user:read', 'List every user'),
+  ('tax:read:any', 'List the taxes of any user'),
+  ('user:disable', 'Disable the account of any user');
+
+INSERT INTO role_permissions(role, permission) VALUES
+  ('user', 'tax:read'),
+  ('user', 'tax:write'),
+  ('auditor', 'user:read'),
+  ('auditor', 'tax:read:any'),
+  ('admin', 'user:read'),
+  ('admin', 'tax:read:any'),
+  ('admin', 'user:disable');
+
+ALTER TABLE users ADD COLUMN disabled_at DATETIME NULL;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE users DROP COLUMN disabled_at;
+
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,84 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- SQLite version of ../1793200000_create_organizations_tables.sql, the migration id must be the same.
+CREATE TABLE IF NOT EXISTS organizations (
+  "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  "name" VARCHAR NOT NULL,
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+  "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  "org_id" BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  "user_id" BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  "role" VARCHAR NOT NULL CHECK (role IN ('owner', 'member', 'viewer')),
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE IF NOT EXISTS organization_invitations (
+  "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  "org_id" BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  "email" VARCHAR NOT NULL,
+  "role" VARCHAR NOT NULL CHECK (role IN ('owner', 'member', 'viewer')),
+  "token_hash" VARCHAR NOT NULL,
+  "invited_by" BIGINT NULL REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+  "accepted_by" BIGINT NULL REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+  "expires_at" DATETIME NOT NULL,
+  "accepted_at" DATETIME NULL,
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_organization_members_on_org_id_user_id ON organization_members(org_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_organization_members_on_user_id ON organization_members(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS unique_idx_organization_invitations_on_token_hash ON organization_invitations(token_hash);
+
+-- SQLite cannot change the column or add constraint using ALTER TABLE, so the taxes table is rebuilt.
+CREATE TABLE taxes_new (
+  "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  "user_id" BIGINT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  "name" VARCHAR NOT NULL,
+  "tax_code" INTEGER NOT NULL,
+  "price" INTEGER NOT NULL CHECK (price >= 0),
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "org_id" BIGINT NULL REFERENCES organizations(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  "created_by" BIGINT NULL REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+  CHECK ((user_id IS NOT NULL AND org_id IS NULL) OR (user_id IS NULL AND org_id IS NOT NULL))
+);
+
+INSERT INTO taxes_new (id, user_id, name, tax_code, price, created_at, updated_at, created_by)
+  SELECT id, user_id, name, tax_code, price, created_at, updated_at, user_id FROM taxes;
+
+DROP TABLE taxes;
+ALTER TABLE taxes_new RENAME TO taxes;
+
+CREATE INDEX IF NOT EXISTS unique_idx_taxes_on_user_id ON taxes(user_id);
+CREATE INDEX IF NOT EXISTS idx_taxes_on_org_id ON taxes(org_id);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+CREATE TABLE taxes_old (
+  "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+  "user_id" BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+  "name" VARCHAR NOT NULL,
+  "tax_code" INTEGER NOT NULL,
+  "price" INTEGER NOT NULL CHECK (price >= 0),
+  "created_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+  "updated_at" DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+-- the organization taxes have no user, so they can't be kept
+INSERT INTO taxes_old (id, user_id, name, tax_code, price, created_at, updated_at)
+  SELECT id, user_id, name, tax_code, price, created_at, updated_at FROM taxes WHERE user_id IS NOT NULL;
+
+DROP TABLE taxes;
+ALTER TABLE taxes_old RENAME TO taxes;
+
+CREATE INDEX IF NOT EXISTS unique_idx_taxes_on_user_id ON taxes(user_id);
+
+DROP TABLE IF EXISTS organization_invitations;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,14 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- SQLite version of ../1793300000_add_profile_to_users_table.sql, the migration id must be the same.
+ALTER TABLE users ADD COLUMN display_name VARCHAR NULL;
+ALTER TABLE users ADD COLUMN locale VARCHAR NULL;
+ALTER TABLE users ADD COLUMN default_currency VARCHAR NULL;
+ALTER TABLE users ADD COLUMN jurisdiction VARCHAR NULL;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE users DROP COLUMN jurisdiction;
+ALTER TABLE users DROP COLUMN default_currency;
+ALTER TABLE users DROP COLUMN locale;
+ALTER TABLE users DROP COLUMN display_name;
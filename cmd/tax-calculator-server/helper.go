@@ -48,12 +48,20 @@ func httpGet(appUrl string, authToken string, params *url.Values) (map[string]in
 }
 
 func httpPost(endpoint string, authToken string, params *url.Values) (map[string]interface{}, int, error) {
+	return httpSendForm(http.MethodPost, endpoint, authToken, params)
+}
+
+func httpPut(endpoint string, authToken string, params *url.Values) (map[string]interface{}, int, error) {
+	return httpSendForm(http.MethodPut, endpoint, authToken, params)
+}
+
+func httpSendForm(method, endpoint string, authToken string, params *url.Values) (map[string]interface{}, int, error) {
 	if params == nil {
 		params = &url.Values{}
 	}
 
 	contentType := "application/x-www-form-urlencoded"
-	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewBufferString(params.Encode()))
+	req, err := http.NewRequest(method, endpoint, bytes.NewBufferString(params.Encode()))
 	if err != nil {
 		return nil, -1, err
 	}
@@ -78,3 +86,82 @@ func httpPost(endpoint string, authToken string, params *url.Values) (map[string
 
 	return payload, resp.StatusCode, nil
 }
+
+func httpDelete(endpoint string, authToken string) (map[string]interface{}, int, error) {
+	req, err := http.NewRequest(http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return nil, -1, err
+	}
+	req.Header.Set("Authentication-Token", authToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	var payload map[string]interface{}
+	err = json.Unmarshal(respBody, &payload)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	return payload, resp.StatusCode, nil
+}
+
+func httpSendJSON(method, endpoint string, authToken string, params interface{}) (map[string]interface{}, int, error) {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	req, err := http.NewRequest(method, endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, -1, err
+	}
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("Authentication-Token", authToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	var payload map[string]interface{}
+	err = json.Unmarshal(respBody, &payload)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	return payload, resp.StatusCode, nil
+}
+
+// httpDownload returns the raw body, since the response is not json.
+func httpDownload(endpoint string, authToken string) ([]byte, http.Header, int, error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, nil, -1, err
+	}
+	req.Header.Set("Authentication-Token", authToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, -1, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, -1, err
+	}
+
+	return respBody, resp.Header, resp.StatusCode, nil
+}
@@ -1,133 +1,189 @@
 package main
 
 import (
-	"database/sql"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/ory/dockertest"
+	"github.com/namsral/flag"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/smartystreets/goconvey/convey"
 	"github.com/yusufsyaifudin/tax-calculator-example/internal/app/restapi"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth/oidctest"
 	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/organization"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/refreshtoken"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/role"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/tax"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/user"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/cache"
 	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
-)
-
-const (
-	PostgreImage = "bitnami/postgresql"
-	PostgreTag   = "10.5.0-debian-9"
-
-	PostgresDB                 = "my_database"
-	PostgresUser               = "my_user"
-	PostgresPassword           = "my_password"
-	PostgreReplicationUser     = "repl_user"
-	PostgreReplicationPassword = "repl_password"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/mail"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/metrics"
 )
 
 var (
-	dbDSNMaster       string
-	conf              *db.Config
+	metricsURL string
+	jwksURL    string
+	healthzURL string
+	readyzURL  string
+	healthURL  string
+
 	apiV1RegisterURL  string
 	apiV1LoginURL     string
+	apiV1RefreshURL   string
+	apiV1LogoutURL    string
+	apiV1LogoutAllURL string
 	apiV1CreateTaxURL string
 	apiV1GetTaxURL    string
+	apiV1APIKeysURL   string
+	apiV1PasswordURL  string
+	apiV1ForgotURL    string
+	apiV1ResetURL     string
+	apiV1TwoFactorURL string
+	apiV1Login2FAURL  string
+	apiV1AdminUserURL string
+	apiV1OrgsURL      string
+	apiV1MeURL        string
+	apiV1AcceptURL    string
 )
 
-var refreshDB = func(dbURL string) {
-	conn.MigrateReset(dbURL)
-	conn.MigrateSync(dbURL)
-}
+// schemaErr and redisErr are returned by the schema check and the optional redis check of the health endpoints.
+var schemaErr, redisErr error
 
-func TestMain(m *testing.M) {
-	logger.Level(zerolog.Disabled)
+// tokens is created once from the jwt flags, so the token is still valid after the server is replaced.
+var tokens *auth.Tokens
 
-	dockerPool, err := dockertest.NewPool("")
-	if err != nil {
-		log.Error().Msgf("Failed to create docker connection: %v", err)
-	}
+// passwordPolicy is created once from the password flags.
+var passwordPolicy *auth.PasswordPolicy
 
-	// pulls an image, creates a container based on it and runs it
-	postgreMaster, err := dockerPool.Run(PostgreImage, PostgreTag, []string{
-		"POSTGRESQL_REPLICATION_MODE=master",
-		fmt.Sprintf("POSTGRESQL_USERNAME=%s", PostgresUser),
-		fmt.Sprintf("POSTGRESQL_PASSWORD=%s", PostgresPassword),
-		fmt.Sprintf("POSTGRESQL_DATABASE=%s", PostgresDB),
-		fmt.Sprintf("POSTGRESQL_REPLICATION_USER=%s", PostgreReplicationUser),
-		fmt.Sprintf("POSTGRESQL_REPLICATION_PASSWORD=%s", PostgreReplicationPassword),
-	})
-	if err != nil {
-		log.Error().Msgf("Could not start postgre master: %s", err)
-	}
+// ssoIssuer is the stub external issuer configured through the oidc flags.
+var ssoIssuer *oidctest.Issuer
 
-	dbDSNMaster = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		PostgresUser, PostgresPassword, postgreMaster.GetBoundIP(PostgreImage), postgreMaster.GetPort("5432/tcp"), PostgresDB)
+// mailer keeps the email sent by the current server.
+var mailer *mail.MemoryMailer
 
-	// exponential backoff-retry, because the application in the container might not be ready to accept connections yet
-	if err := dockerPool.Retry(func() error {
-		var err error
-		c, err := sql.Open("postgres", dbDSNMaster)
-		if err != nil {
-			return err
-		}
-		return c.Ping()
-	}); err != nil {
-		log.Error().Msgf("Could not connect to docker: %s", err)
+// dbConn and server are replaced by useDB, the httptest server always forwards the request to the current server.
+var (
+	dbConn db.SQL
+	server *restapi.Server
+)
+
+// useDB creates a new server which repositories use c, and closes the previous database connection.
+func useDB(c db.SQL) {
+	if dbConn != nil {
+		dbConn.Close()
 	}
 
-	conf = &db.Config{
-		Master: &db.Conf{
-			URL:   dbDSNMaster,
-			Debug: false,
+	dbConn = c
+	mailer = mail.NewMemoryMailer()
+	server = restapi.NewServer(&restapi.Config{
+		Address:         *serverAddr,
+		Test:            true,
+		Tokens:          tokens,
+		PasswordPolicy:  passwordPolicy,
+		LoginThrottle:   newLoginThrottleConfig(),
+		Mailer:          mailer,
+		PasswordReset:   auth.PasswordResetConfig{Lifetime: time.Hour, URL: "https://example.com/reset"},
+		Invitation:      auth.InvitationConfig{Lifetime: time.Hour, URL: "https://example.com/invitation"},
+		TwoFactorIssuer: *totpIssuer,
+		DB:              c,
+		CheckSchema:     func() error { return schemaErr },
+		HealthChecks: map[string]func(ctx context.Context) error{
+			"redis": func(ctx context.Context) error { return redisErr },
 		},
-		Slaves: []*db.Conf{},
+	}, newRepositories(c, cache.NewLRU(100)))
+}
+
+// refreshDB replaces the database connection with a new in-memory database, so every test starts with empty tables.
+var refreshDB = func() {
+	c, err := db.NewMemoryConnection()
+	if err == nil {
+		err = conn.MigrateUp(context.Background(), c)
 	}
 
-	// Do migration here
-	c, err := db.NewConnection(conf)
-	defer c.Close()
 	if err != nil {
-		log.Error().Msgf("error creating connection %s", err.Error())
-		return
+		log.Error().Err(err).Msg("fail to migrate in-memory db")
+		panic(err)
 	}
 
-	// set connection
-	conn.SetDBConnection(c)
+	useDB(c)
+}
+
+func TestMain(m *testing.M) {
+	logger.Level(zerolog.Disabled)
 
-	// migrate sync
-	if err := conn.MigrateSync(dbDSNMaster); err != nil {
-		log.Error().Err(err).Msg("fail to sync db")
-		return
+	var err error
+	if ssoIssuer, err = oidctest.NewIssuer(); err != nil {
+		panic(err)
 	}
 
-	serverConfig := &restapi.Config{
-		Address: *serverAddr,
-		Test:    true,
+	*jwtSecret = "test-secret-which-is-at-least-32-bytes"
+	*oidcIssuerURL = ssoIssuer.URL
+	*oidcAudience = "tax-calculator"
+	if tokens, err = newTokens(); err != nil {
+		panic(err)
 	}
 
-	// now, start the server
-	restapi.Configure(serverConfig)
+	if passwordPolicy, err = newPasswordPolicy(); err != nil {
+		panic(err)
+	}
 
-	s := httptest.NewServer(restapi.Router)
+	// set connection and migrate it
+	refreshDB()
+
+	// query and HTTP metrics are shared by all connections and servers, so they're registered once
+	metrics.DefaultRegistry.Register(db.Metrics(dbConn), restapi.Metrics())
+
+	// now, start the server
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.Handler().ServeHTTP(w, r)
+	}))
+	metricsURL = fmt.Sprintf("%s/metrics", s.URL)
+	jwksURL = fmt.Sprintf("%s/.well-known/jwks.json", s.URL)
+	healthzURL = fmt.Sprintf("%s/healthz", s.URL)
+	readyzURL = fmt.Sprintf("%s/readyz", s.URL)
+	healthURL = fmt.Sprintf("%s/health", s.URL)
 	apiV1BaseURL := fmt.Sprintf("%s/api/v1", s.URL)
 
 	// list routes
 	apiV1RegisterURL = fmt.Sprintf("%s/register", apiV1BaseURL)
 	apiV1LoginURL = fmt.Sprintf("%s/login", apiV1BaseURL)
+	apiV1RefreshURL = fmt.Sprintf("%s/token/refresh", apiV1BaseURL)
+	apiV1LogoutURL = fmt.Sprintf("%s/logout", apiV1BaseURL)
+	apiV1LogoutAllURL = fmt.Sprintf("%s/logout/all", apiV1BaseURL)
 	apiV1CreateTaxURL = fmt.Sprintf("%s/tax", apiV1BaseURL)
 	apiV1GetTaxURL = fmt.Sprintf("%s/tax", apiV1BaseURL)
+	apiV1APIKeysURL = fmt.Sprintf("%s/api-keys", apiV1BaseURL)
+	apiV1PasswordURL = fmt.Sprintf("%s/me/password", apiV1BaseURL)
+	apiV1ForgotURL = fmt.Sprintf("%s/password/forgot", apiV1BaseURL)
+	apiV1ResetURL = fmt.Sprintf("%s/password/reset", apiV1BaseURL)
+	apiV1TwoFactorURL = fmt.Sprintf("%s/me/2fa", apiV1BaseURL)
+	apiV1Login2FAURL = fmt.Sprintf("%s/login/2fa", apiV1BaseURL)
+	apiV1AdminUserURL = fmt.Sprintf("%s/admin/users", apiV1BaseURL)
+	apiV1OrgsURL = fmt.Sprintf("%s/orgs", apiV1BaseURL)
+	apiV1MeURL = fmt.Sprintf("%s/me", apiV1BaseURL)
+	apiV1AcceptURL = fmt.Sprintf("%s/invitations/accept", apiV1BaseURL)
 
 	code := m.Run()
 	s.Close() // shutdown the server after done
+	ssoIssuer.Close()
 
 	// You can't defer this because os.Exit doesn't care for defer
-	if err := dockerPool.Purge(postgreMaster); err != nil {
-		log.Error().Msgf("Could not purge postgreMaster: %s", err)
-	}
-
+	dbConn.Close()
 	os.Exit(code)
 }
 
@@ -165,7 +221,55 @@ func TestRegisterEndpoint(t *testing.T) {
 			})
 		})
 
-		refreshDB(dbDSNMaster)
+		convey.Convey("Username should follow the rules", func() {
+			form := &url.Values{}
+			form.Set("username", "john doe")
+			form.Set("password", "correct horse")
+			res, status, err := httpPost(apiV1RegisterURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 400)
+			convey.So(res["message"], convey.ShouldStartWith, "username: ")
+		})
+
+		convey.Convey("Password should follow the policy", func() {
+			form := &url.Values{}
+			form.Set("username", "john_doe")
+			form.Set("password", "John_Doe!")
+			res, status, err := httpPost(apiV1RegisterURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 400)
+			convey.So(res["message"], convey.ShouldEqual, "password: must not contain the username")
+
+			form.Set("password", "short")
+			res, status, err = httpPost(apiV1RegisterURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 400)
+			convey.So(res["message"], convey.ShouldEqual, "password: must be at least 8 characters")
+		})
+
+		refreshDB()
+		convey.Convey("Username is unique regardless of the case", func() {
+			form := &url.Values{}
+			form.Set("username", "john_doe")
+			form.Set("password", " password with spaces ")
+			_, status, err := httpPost(apiV1RegisterURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			form.Set("username", "John_Doe")
+			res, status, err := httpPost(apiV1RegisterURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 422)
+			convey.So(res["message"], convey.ShouldEqual, "username is already taken")
+
+			// the password is not trimmed
+			form.Set("password", "password with spaces")
+			_, status, err = httpPost(apiV1LoginURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 401)
+		})
+
+		refreshDB()
 		convey.Convey("Should success register", func() {
 			const (
 				username = "john_doe"
@@ -220,7 +324,36 @@ func TestLoginEndpoint(t *testing.T) {
 			})
 		})
 
-		refreshDB(dbDSNMaster)
+		refreshDB()
+		convey.Convey("Unknown user gets the same error as wrong password", func() {
+			form := &url.Values{}
+			form.Set("username", "not_exist")
+			form.Set("password", "password")
+			res, status, err := httpPost(apiV1LoginURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 401)
+
+			convey.So(res, convey.ShouldResemble, map[string]interface{}{
+				"error_code":       "1_0003",
+				"http_status_code": float64(401),
+				"message":          "username or password is wrong",
+			})
+
+			var events []*model.AuthEvent
+			err = dbConn.Reader().Query(context.Background(), &events, `SELECT * FROM auth_events;`)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(events), convey.ShouldEqual, 1)
+			convey.So(events[0].Event, convey.ShouldEqual, model.AuthEventLoginFailed)
+			convey.So(events[0].UserID, convey.ShouldBeNil)
+			convey.So(events[0].Username, convey.ShouldEqual, "not_exist")
+
+			// the next attempt must wait
+			_, status, err = httpPost(apiV1LoginURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 429)
+		})
+
+		refreshDB()
 		convey.Convey("User can login after register", func() {
 			const (
 				username = "john_doe"
@@ -251,9 +384,81 @@ func TestLoginEndpoint(t *testing.T) {
 	})
 }
 
+func TestRefreshTokenEndpoint(t *testing.T) {
+	convey.Convey("Test Refresh Token and Logout Endpoint", t, func() {
+		refreshDB()
+
+		formRegister := &url.Values{}
+		formRegister.Set("username", "john_doe")
+		formRegister.Set("password", "password")
+		res, status, err := httpPost(apiV1RegisterURL, "", formRegister)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 200)
+
+		authToken := res["authentication_token"].(string)
+		refreshToken := res["refresh_token"].(string)
+		convey.So(res["expires_in"], convey.ShouldEqual, float64(jwtLifetime.Seconds()))
+
+		refresh := func(token string) (map[string]interface{}, int) {
+			form := &url.Values{}
+			form.Set("refresh_token", token)
+			res, status, err := httpPost(apiV1RefreshURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			return res, status
+		}
+
+		convey.Convey("Refresh token is rotated and can't be used twice", func() {
+			res, status := refresh(refreshToken)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["user"].(map[string]interface{})["username"], convey.ShouldEqual, "john_doe")
+
+			newRefreshToken := res["refresh_token"].(string)
+			convey.So(newRefreshToken, convey.ShouldNotEqual, refreshToken)
+
+			res, status = refresh(refreshToken)
+			convey.So(status, convey.ShouldEqual, 401)
+			convey.So(res["error_code"], convey.ShouldEqual, "1_0006")
+
+			// the reuse revokes the token rotated from it too
+			res, status = refresh(newRefreshToken)
+			convey.So(status, convey.ShouldEqual, 401)
+			convey.So(res["error_code"], convey.ShouldEqual, "1_0005")
+		})
+
+		convey.Convey("Logout revokes the refresh token", func() {
+			form := &url.Values{}
+			form.Set("refresh_token", refreshToken)
+			_, status, err := httpPost(apiV1LogoutURL, authToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			_, status = refresh(refreshToken)
+			convey.So(status, convey.ShouldEqual, 401)
+		})
+
+		convey.Convey("Logout all revokes every session", func() {
+			formLogin := &url.Values{}
+			formLogin.Set("username", "john_doe")
+			formLogin.Set("password", "password")
+			res, status, err := httpPost(apiV1LoginURL, "", formLogin)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			_, status, err = httpPost(apiV1LogoutAllURL, authToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			_, status = refresh(refreshToken)
+			convey.So(status, convey.ShouldEqual, 401)
+			_, status = refresh(res["refresh_token"].(string))
+			convey.So(status, convey.ShouldEqual, 401)
+		})
+	})
+}
+
 func TestCreateAndGetTax(t *testing.T) {
 	convey.Convey("Create and Get Tax Test", t, func() {
-		refreshDB(dbDSNMaster)
+		refreshDB()
 		const (
 			username = "john_doe"
 			password = "password"
@@ -371,3 +576,1258 @@ func TestCreateAndGetTax(t *testing.T) {
 
 	})
 }
+
+func TestMetricsEndpoint(t *testing.T) {
+	convey.Convey("Test Metrics Endpoint", t, func() {
+		refreshDB()
+
+		formRegister := &url.Values{}
+		formRegister.Set("username", "john_doe")
+		formRegister.Set("password", "password")
+		_, status, err := httpPost(apiV1RegisterURL, "", formRegister)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 200)
+
+		res, status, err := httpPost(apiV1LoginURL, "", formRegister)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 200)
+
+		taxParam := &url.Values{}
+		taxParam.Set("name", "Lucky Stretch")
+		taxParam.Set("tax_code", "2")
+		taxParam.Set("price", "1000")
+		_, status, err = httpPost(apiV1CreateTaxURL, res["authentication_token"].(string), taxParam)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 200)
+
+		// the id in the path is labelled by the route template
+		_, status, err = httpGet(fmt.Sprintf("%s/12345/members", apiV1OrgsURL), "", nil)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 401)
+
+		formRegister.Set("username", "jane_doe")
+		_, status, err = httpPost(apiV1LoginURL, "", formRegister)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 401)
+
+		resp, err := http.Get(metricsURL)
+		convey.So(err, convey.ShouldBeNil)
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(resp.StatusCode, convey.ShouldEqual, 200)
+		convey.So(string(body), convey.ShouldContainSubstring, `db_query_duration_seconds_count{query="user_create",role="master"}`)
+		// the in-memory database has no slave, so the reads go to the master
+		convey.So(string(body), convey.ShouldContainSubstring, `db_query_rows_bucket{query="user_find_by_username",role="master",le="0"}`)
+		convey.So(string(body), convey.ShouldContainSubstring, `http_requests_total{method="POST",route="/api/v1/register",status="200"}`)
+		convey.So(string(body), convey.ShouldContainSubstring, `http_request_duration_seconds_count{method="GET",route="/api/v1/orgs/:id/members",status="401"}`)
+		convey.So(string(body), convey.ShouldContainSubstring, `http_requests_in_flight{method="GET",route="/metrics"} 1`)
+		convey.So(string(body), convey.ShouldContainSubstring, `tax_items_created_total{tax_code="2"}`)
+		convey.So(string(body), convey.ShouldContainSubstring, `tax_amount_calculated_total{tax_code="2"}`)
+		convey.So(string(body), convey.ShouldContainSubstring, `logins_total{result="succeeded"}`)
+		convey.So(string(body), convey.ShouldContainSubstring, `logins_total{result="failed"}`)
+		convey.So(string(body), convey.ShouldNotContainSubstring, `route="/api/v1/orgs/12345/members"`)
+	})
+}
+
+func TestChangePassword(t *testing.T) {
+	convey.Convey("Test Change Password", t, func() {
+		refreshDB()
+
+		formRegister := &url.Values{}
+		formRegister.Set("username", "john_doe")
+		formRegister.Set("password", "password")
+		res, status, err := httpPost(apiV1RegisterURL, "", formRegister)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 200)
+
+		authToken := res["authentication_token"].(string)
+		refreshToken := res["refresh_token"].(string)
+
+		// the token issued in the same second as the password change is still valid, since "iat" is in seconds
+		time.Sleep(time.Second)
+
+		convey.Convey("Current password is required", func() {
+			form := &url.Values{}
+			form.Set("current_password", "wrong password")
+			form.Set("new_password", "correct horse battery")
+			res, status, err := httpPut(apiV1PasswordURL, authToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 401)
+			convey.So(res["error_code"], convey.ShouldEqual, "1_0003")
+		})
+
+		convey.Convey("Password is not changed when revoking the sessions fails", func() {
+			c := dbConn
+			dbConn = nil
+			useDB(queryFailsDB{SQL: c, prefix: "UPDATE refresh_tokens SET revoked_at"})
+			defer func() {
+				dbConn = nil
+				useDB(c)
+			}()
+
+			form := &url.Values{}
+			form.Set("current_password", "password")
+			form.Set("new_password", "correct horse battery")
+			_, status, err := httpPut(apiV1PasswordURL, authToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 422)
+
+			formLogin := &url.Values{}
+			formLogin.Set("username", "john_doe")
+			formLogin.Set("password", "password")
+			_, status, err = httpPost(apiV1LoginURL, "", formLogin)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+		})
+
+		convey.Convey("Changed password invalidates the existing tokens", func() {
+			form := &url.Values{}
+			form.Set("current_password", "password")
+			form.Set("new_password", "correct horse battery")
+			res, status, err := httpPut(apiV1PasswordURL, authToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			newAuthToken := res["authentication_token"].(string)
+			_, status, err = httpGet(apiV1GetTaxURL, newAuthToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			_, status, err = httpGet(apiV1GetTaxURL, authToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 401)
+
+			refreshForm := &url.Values{}
+			refreshForm.Set("refresh_token", refreshToken)
+			_, status, err = httpPost(apiV1RefreshURL, "", refreshForm)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 401)
+
+			formLogin := &url.Values{}
+			formLogin.Set("username", "john_doe")
+			formLogin.Set("password", "correct horse battery")
+			_, status, err = httpPost(apiV1LoginURL, "", formLogin)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+		})
+	})
+}
+
+func TestPasswordReset(t *testing.T) {
+	convey.Convey("Test Password Reset", t, func() {
+		refreshDB()
+
+		formRegister := &url.Values{}
+		formRegister.Set("username", "john_doe")
+		formRegister.Set("password", "password")
+		formRegister.Set("email", "John@Example.com")
+		res, status, err := httpPost(apiV1RegisterURL, "", formRegister)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 200)
+		convey.So(res["user"].(map[string]interface{})["email"], convey.ShouldEqual, "John@Example.com")
+
+		authToken := res["authentication_token"].(string)
+
+		// the token issued in the same second as the password reset is still valid, since "iat" is in seconds
+		time.Sleep(time.Second)
+
+		convey.Convey("Email must be unique regardless of the case", func() {
+			formRegister.Set("username", "jane_doe")
+			formRegister.Set("email", "john@example.com")
+			res, status, err := httpPost(apiV1RegisterURL, "", formRegister)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 422)
+			convey.So(res["message"], convey.ShouldEqual, "email is already taken")
+		})
+
+		convey.Convey("Unknown email gets the same response without sending email", func() {
+			form := &url.Values{}
+			form.Set("email", "nobody@example.com")
+			res, status, err := httpPost(apiV1ForgotURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["message"], convey.ShouldEqual, "if the email is registered, the password reset link is sent to it")
+			convey.So(mailer.Messages(), convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("Reset token sets the new password once", func() {
+			form := &url.Values{}
+			form.Set("email", "john@example.com")
+			_, status, err := httpPost(apiV1ForgotURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(mailer.Messages(), convey.ShouldHaveLength, 1)
+
+			body := mailer.Messages()[0].Body
+			link, err := url.Parse(body[strings.Index(body, "https://"):strings.Index(body, "\n\nIgnore")])
+			convey.So(err, convey.ShouldBeNil)
+			token := link.Query().Get("token")
+
+			formReset := &url.Values{}
+			formReset.Set("token", token)
+			formReset.Set("new_password", "short")
+			res, status, err := httpPost(apiV1ResetURL, "", formReset)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 400)
+			convey.So(res["error_code"], convey.ShouldEqual, "0_0001")
+
+			// the token can still be used after the new password is rejected by the policy
+			formReset.Set("new_password", "correct horse battery")
+
+			// nor is it used up when updating the password fails, since both are in one transaction
+			func() {
+				c := dbConn
+				dbConn = nil
+				useDB(queryFailsDB{SQL: c, prefix: "UPDATE users SET password"})
+				defer func() {
+					dbConn = nil
+					useDB(c)
+				}()
+
+				_, status, err := httpPost(apiV1ResetURL, "", formReset)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(status, convey.ShouldEqual, 422)
+			}()
+
+			_, status, err = httpPost(apiV1ResetURL, "", formReset)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			res, status, err = httpPost(apiV1ResetURL, "", formReset)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 400)
+			convey.So(res["error_code"], convey.ShouldEqual, "1_0009")
+
+			_, status, err = httpGet(apiV1GetTaxURL, authToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 401)
+
+			formLogin := &url.Values{}
+			formLogin.Set("username", "john_doe")
+			formLogin.Set("password", "correct horse battery")
+			_, status, err = httpPost(apiV1LoginURL, "", formLogin)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+		})
+	})
+}
+
+func TestTwoFactor(t *testing.T) {
+	convey.Convey("Test Two Factor Authentication", t, func() {
+		refreshDB()
+
+		formRegister := &url.Values{}
+		formRegister.Set("username", "john_doe")
+		formRegister.Set("password", "password")
+		res, status, err := httpPost(apiV1RegisterURL, "", formRegister)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 200)
+
+		authToken := res["authentication_token"].(string)
+
+		formEnroll := &url.Values{}
+		formEnroll.Set("password", "password")
+		res, status, err = httpPost(apiV1TwoFactorURL, authToken, formEnroll)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 200)
+		convey.So(res["otpauth_uri"], convey.ShouldStartWith, "otpauth://totp/")
+
+		secret := res["secret"].(string)
+		now := time.Now()
+		code := func() string {
+			code, err := auth.GenerateTOTPCode(secret, now)
+			convey.So(err, convey.ShouldBeNil)
+			return code
+		}
+
+		formLogin := &url.Values{}
+		formLogin.Set("username", "john_doe")
+		formLogin.Set("password", "password")
+
+		convey.Convey("2FA is not required before it's confirmed", func() {
+			res, status, err := httpPost(apiV1LoginURL, "", formLogin)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["authentication_token"], convey.ShouldNotBeEmpty)
+		})
+
+		convey.Convey("2FA is not enabled when creating the recovery codes fails", func() {
+			c := dbConn
+			dbConn = nil
+			useDB(queryFailsDB{SQL: c, prefix: "INSERT INTO recovery_codes"})
+			defer func() {
+				dbConn = nil
+				useDB(c)
+			}()
+
+			formConfirm := &url.Values{}
+			formConfirm.Set("code", code())
+			_, status, err := httpPost(apiV1TwoFactorURL+"/confirm", authToken, formConfirm)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 422)
+
+			res, status, err := httpPost(apiV1LoginURL, "", formLogin)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["authentication_token"], convey.ShouldNotBeEmpty)
+		})
+
+		convey.Convey("Confirmed 2FA is required to login", func() {
+			formConfirm := &url.Values{}
+			formConfirm.Set("code", code())
+			res, status, err := httpPost(apiV1TwoFactorURL+"/confirm", authToken, formConfirm)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["recovery_codes"], convey.ShouldHaveLength, 10)
+
+			recoveryCode := res["recovery_codes"].([]interface{})[0].(string)
+
+			res, status, err = httpPost(apiV1LoginURL, "", formLogin)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["two_factor_required"], convey.ShouldEqual, true)
+			convey.So(res["authentication_token"], convey.ShouldBeNil)
+
+			twoFactorToken := res["two_factor_token"].(string)
+
+			// the pending token is not an authentication token
+			_, status, err = httpGet(apiV1GetTaxURL, twoFactorToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 401)
+
+			form2FA := &url.Values{}
+			form2FA.Set("two_factor_token", twoFactorToken)
+
+			convey.Convey("The code used to confirm can't be used again", func() {
+				form2FA.Set("code", code())
+				res, status, err := httpPost(apiV1Login2FAURL, "", form2FA)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(status, convey.ShouldEqual, 401)
+				convey.So(res["error_code"], convey.ShouldEqual, "1_0010")
+			})
+
+			convey.Convey("Recovery code completes the login once", func() {
+				form2FA.Set("code", recoveryCode)
+				res, status, err := httpPost(apiV1Login2FAURL, "", form2FA)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(status, convey.ShouldEqual, 200)
+
+				_, status, err = httpGet(apiV1GetTaxURL, res["authentication_token"].(string), nil)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(status, convey.ShouldEqual, 200)
+
+				_, status, err = httpPost(apiV1Login2FAURL, "", form2FA)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(status, convey.ShouldEqual, 401)
+			})
+
+			convey.Convey("Disable requires the password and the code", func() {
+				formDisable := &url.Values{}
+				formDisable.Set("password", "password")
+				formDisable.Set("code", "wrong-code")
+				res, status, err := httpPost(apiV1TwoFactorURL+"/disable", authToken, formDisable)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(status, convey.ShouldEqual, 401)
+				convey.So(res["error_code"], convey.ShouldEqual, "1_0010")
+
+				// wrong code is counted like failed login, so the next attempt must wait
+				formDisable.Set("code", recoveryCode)
+				_, status, err = httpPost(apiV1TwoFactorURL+"/disable", authToken, formDisable)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(status, convey.ShouldEqual, 429)
+
+				time.Sleep(*loginBaseDelay)
+				_, status, err = httpPost(apiV1TwoFactorURL+"/disable", authToken, formDisable)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(status, convey.ShouldEqual, 200)
+
+				res, status, err = httpPost(apiV1LoginURL, "", formLogin)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(status, convey.ShouldEqual, 200)
+				convey.So(res["authentication_token"], convey.ShouldNotBeEmpty)
+			})
+		})
+	})
+}
+
+func TestBearerToken(t *testing.T) {
+	convey.Convey("Test Authorization Bearer Header", t, func() {
+		refreshDB()
+
+		formRegister := &url.Values{}
+		formRegister.Set("username", "john_doe")
+		formRegister.Set("password", "password")
+		res, status, err := httpPost(apiV1RegisterURL, "", formRegister)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 200)
+
+		req, err := http.NewRequest(http.MethodGet, apiV1GetTaxURL, nil)
+		convey.So(err, convey.ShouldBeNil)
+		req.Header.Set("Authorization", "Bearer "+res["authentication_token"].(string))
+
+		resp, err := http.DefaultClient.Do(req)
+		convey.So(err, convey.ShouldBeNil)
+		defer resp.Body.Close()
+		convey.So(resp.StatusCode, convey.ShouldEqual, 200)
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	convey.Convey("Test API Keys", t, func() {
+		refreshDB()
+
+		formRegister := &url.Values{}
+		formRegister.Set("username", "john_doe")
+		formRegister.Set("password", "password")
+		res, status, err := httpPost(apiV1RegisterURL, "", formRegister)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 200)
+
+		authToken := res["authentication_token"].(string)
+
+		form := &url.Values{}
+		form.Set("name", "accounting")
+		form.Set("scopes", "tax:read")
+		res, status, err = httpPost(apiV1APIKeysURL, authToken, form)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 200)
+
+		apiKey := res["key"].(string)
+		apiKeyID := int64(res["api_key"].(map[string]interface{})["id"].(float64))
+		convey.So(apiKey, convey.ShouldStartWith, "tce_")
+
+		convey.Convey("Key can only be used within its scopes", func() {
+			_, status, err := httpGet(apiV1GetTaxURL, apiKey, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			taxParam := &url.Values{}
+			taxParam.Set("name", "Big Mac")
+			taxParam.Set("tax_code", "1")
+			taxParam.Set("price", "1000")
+			res, status, err := httpPost(apiV1CreateTaxURL, apiKey, taxParam)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 403)
+			convey.So(res["error_code"], convey.ShouldEqual, "3_0004")
+
+			// and it can't create another key
+			_, status, err = httpPost(apiV1APIKeysURL, apiKey, form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 403)
+		})
+
+		convey.Convey("Listed key has last used time but not the key", func() {
+			_, status, err := httpGet(apiV1GetTaxURL, apiKey, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			res, status, err := httpGet(apiV1APIKeysURL, authToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			apiKeys := res["api_keys"].([]interface{})
+			convey.So(len(apiKeys), convey.ShouldEqual, 1)
+			convey.So(apiKeys[0].(map[string]interface{})["last_used_at"], convey.ShouldNotBeNil)
+			convey.So(apiKeys[0].(map[string]interface{})["key"], convey.ShouldBeNil)
+		})
+
+		convey.Convey("Revoked key can't be used", func() {
+			_, status, err := httpDelete(fmt.Sprintf("%s/%d", apiV1APIKeysURL, apiKeyID), authToken)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			_, status, err = httpGet(apiV1GetTaxURL, apiKey, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 401)
+
+			_, status, err = httpDelete(fmt.Sprintf("%s/%d", apiV1APIKeysURL, apiKeyID), authToken)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 404)
+		})
+
+		convey.Convey("Unknown scope is rejected", func() {
+			form.Set("scopes", "tax:read,admin")
+			_, status, err := httpPost(apiV1APIKeysURL, authToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 400)
+		})
+	})
+}
+
+func TestAdminEndpoints(t *testing.T) {
+	convey.Convey("Test Admin Endpoints", t, func() {
+		refreshDB()
+
+		// register returns the authentication token and the id of the new user
+		register := func(username string) (string, int64) {
+			form := &url.Values{}
+			form.Set("username", username)
+			form.Set("password", "password")
+			res, status, err := httpPost(apiV1RegisterURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			return res["authentication_token"].(string), int64(res["user"].(map[string]interface{})["id"].(float64))
+		}
+
+		adminToken, adminID := register("admin")
+		auditorToken, auditorID := register("auditor")
+		userToken, userID := register("john_doe")
+
+		roles := role.NewRepository(dbConn)
+		convey.So(roles.Assign(context.Background(), adminID, model.RoleAdmin), convey.ShouldBeNil)
+		convey.So(roles.Assign(context.Background(), auditorID, model.RoleAuditor), convey.ShouldBeNil)
+
+		taxParam := &url.Values{}
+		taxParam.Set("name", "Big Mac")
+		taxParam.Set("tax_code", "1")
+		taxParam.Set("price", "1000")
+		_, status, err := httpPost(apiV1CreateTaxURL, userToken, taxParam)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 200)
+
+		userTaxesURL := fmt.Sprintf("%s/%d/taxes", apiV1AdminUserURL, userID)
+		disableUserURL := fmt.Sprintf("%s/%d/disable", apiV1AdminUserURL, userID)
+
+		convey.Convey("User without the permission is rejected", func() {
+			res, status, err := httpGet(apiV1AdminUserURL, userToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 403)
+			convey.So(res["error_code"], convey.ShouldEqual, "1_0014")
+
+			_, status, err = httpGet(userTaxesURL, userToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 403)
+		})
+
+		convey.Convey("Auditor can list users and view their taxes, but can't disable them", func() {
+			params := &url.Values{}
+			params.Set("limit", "2")
+			params.Set("offset", "1")
+			res, status, err := httpGet(apiV1AdminUserURL, auditorToken, params)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			users := res["users"].([]interface{})
+			convey.So(len(users), convey.ShouldEqual, 2)
+			convey.So(users[0].(map[string]interface{})["username"], convey.ShouldEqual, "auditor")
+			convey.So(users[1].(map[string]interface{})["username"], convey.ShouldEqual, "john_doe")
+
+			res, status, err = httpGet(userTaxesURL, auditorToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["price_sub_total"], convey.ShouldEqual, 1000)
+
+			_, status, err = httpGet(fmt.Sprintf("%s/%d/taxes", apiV1AdminUserURL, 100), auditorToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 404)
+
+			_, status, err = httpPost(disableUserURL, auditorToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 403)
+		})
+
+		convey.Convey("User is not disabled when revoking the sessions fails", func() {
+			c := dbConn
+			dbConn = nil
+			useDB(queryFailsDB{SQL: c, prefix: "UPDATE refresh_tokens SET revoked_at"})
+			defer func() {
+				dbConn = nil
+				useDB(c)
+			}()
+
+			_, status, err := httpPost(disableUserURL, adminToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 422)
+
+			_, status, err = httpGet(apiV1GetTaxURL, userToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+		})
+
+		convey.Convey("Disabled user can't use the token or login", func() {
+			form := &url.Values{}
+			form.Set("username", "john_doe")
+			form.Set("password", "password")
+			res, status, err := httpPost(apiV1LoginURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			refreshToken := res["refresh_token"].(string)
+
+			res, status, err = httpPost(disableUserURL, adminToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["user"].(map[string]interface{})["disabled_at"], convey.ShouldNotBeNil)
+
+			// the refresh token is refused before it's rotated
+			refreshForm := &url.Values{}
+			refreshForm.Set("refresh_token", refreshToken)
+			res, status, err = httpPost(apiV1RefreshURL, "", refreshForm)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 401)
+			convey.So(res["error_code"], convey.ShouldEqual, "1_0013")
+
+			RefreshToken, err := refreshtoken.NewRepository(dbConn).FindByTokenHash(context.Background(), auth.HashRefreshToken(refreshToken))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(RefreshToken.UsedAt, convey.ShouldBeNil)
+
+			res, status, err = httpGet(apiV1GetTaxURL, userToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 403)
+			convey.So(res["error_code"], convey.ShouldEqual, "1_0013")
+
+			res, status, err = httpPost(apiV1LoginURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 403)
+			convey.So(res["error_code"], convey.ShouldEqual, "1_0013")
+
+			// the taxes are still readable by the admin
+			_, status, err = httpGet(userTaxesURL, adminToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+		})
+
+		convey.Convey("Admin can't disable their own account", func() {
+			_, status, err := httpPost(fmt.Sprintf("%s/%d/disable", apiV1AdminUserURL, adminID), adminToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 400)
+		})
+	})
+}
+
+func TestOrganizations(t *testing.T) {
+	convey.Convey("Test Organizations", t, func() {
+		refreshDB()
+
+		// register returns the authentication token and the id of the new user
+		register := func(username string) (string, int64) {
+			form := &url.Values{}
+			form.Set("username", username)
+			form.Set("password", "password")
+			res, status, err := httpPost(apiV1RegisterURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			return res["authentication_token"].(string), int64(res["user"].(map[string]interface{})["id"].(float64))
+		}
+
+		ownerToken, ownerID := register("john_doe")
+		memberToken, memberID := register("jane_doe")
+		viewerToken, _ := register("richard_roe")
+		strangerToken, _ := register("mallory")
+
+		form := &url.Values{}
+		form.Set("name", "Finance")
+		res, status, err := httpPost(apiV1OrgsURL, ownerToken, form)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(status, convey.ShouldEqual, 200)
+		convey.So(res["role"], convey.ShouldEqual, model.OrgRoleOwner)
+
+		orgID := int64(res["id"].(float64))
+		orgURL := fmt.Sprintf("%s/%d", apiV1OrgsURL, orgID)
+
+		// invite sends the invitation as the owner and accepts it using the token from the email
+		invite := func(authToken, email, role string) {
+			form := &url.Values{}
+			form.Set("email", email)
+			form.Set("role", role)
+			_, status, err := httpPost(orgURL+"/invitations", ownerToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			messages := mailer.Messages()
+			body := messages[len(messages)-1].Body
+			link, err := url.Parse(body[strings.Index(body, "https://"):strings.Index(body, "\n\nIgnore")])
+			convey.So(err, convey.ShouldBeNil)
+
+			formAccept := &url.Values{}
+			formAccept.Set("token", link.Query().Get("token"))
+
+			// the invitation is not used up when adding the member fails
+			func() {
+				c := dbConn
+				dbConn = nil
+				useDB(queryFailsDB{SQL: c, prefix: "INSERT INTO organization_members"})
+				defer func() {
+					dbConn = nil
+					useDB(c)
+				}()
+
+				_, status, err := httpPost(apiV1AcceptURL, authToken, formAccept)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(status, convey.ShouldEqual, 422)
+			}()
+
+			res, status, err := httpPost(apiV1AcceptURL, authToken, formAccept)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["role"], convey.ShouldEqual, role)
+
+			// the invitation can only be used once
+			res, status, err = httpPost(apiV1AcceptURL, strangerToken, formAccept)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 400)
+			convey.So(res["error_code"], convey.ShouldEqual, "4_0005")
+		}
+
+		invite(memberToken, "jane@example.com", model.OrgRoleMember)
+		invite(viewerToken, "richard@example.com", model.OrgRoleViewer)
+
+		taxParam := &url.Values{}
+		taxParam.Set("name", "Big Mac")
+		taxParam.Set("tax_code", "1")
+		taxParam.Set("price", "1000")
+		taxParam.Set("org_id", fmt.Sprintf("%d", orgID))
+
+		orgTaxParam := &url.Values{}
+		orgTaxParam.Set("org_id", fmt.Sprintf("%d", orgID))
+
+		convey.Convey("Member adds the tax to the shared ledger, viewer can only see it", func() {
+			res, status, err := httpPost(apiV1CreateTaxURL, memberToken, taxParam)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["org_id"], convey.ShouldEqual, orgID)
+
+			res, status, err = httpPost(apiV1CreateTaxURL, viewerToken, taxParam)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 403)
+			convey.So(res["error_code"], convey.ShouldEqual, "4_0004")
+
+			res, status, err = httpGet(apiV1GetTaxURL, viewerToken, orgTaxParam)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["price_sub_total"], convey.ShouldEqual, 1000)
+
+			// the personal ledger doesn't include the tax of the organization
+			res, status, err = httpGet(apiV1GetTaxURL, memberToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["price_sub_total"], convey.ShouldEqual, 0)
+		})
+
+		convey.Convey("Non member can't see the organization", func() {
+			res, status, err := httpGet(apiV1GetTaxURL, strangerToken, orgTaxParam)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 404)
+			convey.So(res["error_code"], convey.ShouldEqual, "4_0002")
+
+			_, status, err = httpPost(apiV1CreateTaxURL, strangerToken, taxParam)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 404)
+
+			_, status, err = httpGet(orgURL+"/members", strangerToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 404)
+		})
+
+		convey.Convey("Only owner can invite or remove other member", func() {
+			form := &url.Values{}
+			form.Set("email", "mallory@example.com")
+			form.Set("role", model.OrgRoleOwner)
+			res, status, err := httpPost(orgURL+"/invitations", memberToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 403)
+			convey.So(res["error_code"], convey.ShouldEqual, "4_0004")
+
+			_, status, err = httpDelete(fmt.Sprintf("%s/members/%d", orgURL, ownerID), memberToken)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 403)
+
+			_, status, err = httpDelete(fmt.Sprintf("%s/members/%d", orgURL, memberID), ownerToken)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			res, status, err = httpGet(orgURL+"/members", ownerToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["members"], convey.ShouldHaveLength, 2)
+		})
+
+		convey.Convey("Last owner can't leave, but other member can", func() {
+			res, status, err := httpDelete(fmt.Sprintf("%s/members/%d", orgURL, ownerID), ownerToken)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 409)
+			convey.So(res["error_code"], convey.ShouldEqual, "4_0007")
+
+			_, status, err = httpDelete(fmt.Sprintf("%s/members/%d", orgURL, memberID), memberToken)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			res, status, err = httpGet(apiV1OrgsURL, memberToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["organizations"], convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("Member can't accept another invitation of the same organization", func() {
+			form := &url.Values{}
+			form.Set("email", "jane@example.com")
+			form.Set("role", model.OrgRoleOwner)
+			_, status, err := httpPost(orgURL+"/invitations", ownerToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			messages := mailer.Messages()
+			body := messages[len(messages)-1].Body
+			link, err := url.Parse(body[strings.Index(body, "https://"):strings.Index(body, "\n\nIgnore")])
+			convey.So(err, convey.ShouldBeNil)
+
+			formAccept := &url.Values{}
+			formAccept.Set("token", link.Query().Get("token"))
+			res, status, err := httpPost(apiV1AcceptURL, memberToken, formAccept)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 409)
+			convey.So(res["error_code"], convey.ShouldEqual, "4_0006")
+		})
+	})
+}
+
+func TestProfileAndAccountDeletion(t *testing.T) {
+	convey.Convey("Test Profile And Account Deletion", t, func() {
+		refreshDB()
+
+		// register returns the authentication token and the id of the new user
+		register := func(username, email string) (string, int64) {
+			form := &url.Values{}
+			form.Set("username", username)
+			form.Set("password", "password")
+			form.Set("email", email)
+			res, status, err := httpPost(apiV1RegisterURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			return res["authentication_token"].(string), int64(res["user"].(map[string]interface{})["id"].(float64))
+		}
+
+		authToken, _ := register("john_doe", "john@example.com")
+		otherToken, otherID := register("jane_doe", "jane@example.com")
+
+		convey.Convey("Only the sent field of the profile is changed", func() {
+			form := &url.Values{}
+			form.Set("display_name", " John Doe ")
+			form.Set("locale", "id-ID")
+			form.Set("default_currency", "idr")
+			form.Set("jurisdiction", "id")
+			res, status, err := httpSendForm(http.MethodPatch, apiV1MeURL, authToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["display_name"], convey.ShouldEqual, "John Doe")
+			convey.So(res["default_currency"], convey.ShouldEqual, "IDR")
+			convey.So(res["jurisdiction"], convey.ShouldEqual, "ID")
+			convey.So(res["email"], convey.ShouldEqual, "john@example.com")
+
+			form = &url.Values{}
+			form.Set("locale", "")
+			_, status, err = httpSendForm(http.MethodPatch, apiV1MeURL, authToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			res, status, err = httpGet(apiV1MeURL, authToken, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["locale"], convey.ShouldBeNil)
+			convey.So(res["display_name"], convey.ShouldEqual, "John Doe")
+			convey.So(res["roles"], convey.ShouldResemble, []interface{}{model.RoleUser})
+		})
+
+		convey.Convey("Invalid or taken value is rejected", func() {
+			form := &url.Values{}
+			form.Set("jurisdiction", "IDN")
+			form.Set("default_currency", "RUPIAH")
+			res, status, err := httpSendForm(http.MethodPatch, apiV1MeURL, authToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 400)
+			convey.So(res["message"], convey.ShouldContainSubstring, "jurisdiction: iso3166")
+			convey.So(res["message"], convey.ShouldContainSubstring, "default_currency: len")
+
+			form = &url.Values{}
+			form.Set("email", "Jane@Example.com")
+			res, status, err = httpSendForm(http.MethodPatch, apiV1MeURL, authToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 422)
+			convey.So(res["error_code"], convey.ShouldEqual, "1_0015")
+		})
+
+		convey.Convey("Export has the profile and every tax added by the user", func() {
+			form := &url.Values{}
+			form.Set("name", "Finance")
+			res, _, err := httpPost(apiV1OrgsURL, otherToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			orgID := int64(res["id"].(float64))
+
+			_, err = organization.NewRepository(dbConn).AddMember(context.Background(), orgID, 1, model.OrgRoleMember)
+			convey.So(err, convey.ShouldBeNil)
+
+			taxParam := &url.Values{}
+			taxParam.Set("name", "Big Mac")
+			taxParam.Set("tax_code", "1")
+			taxParam.Set("price", "1000")
+			_, status, err := httpPost(apiV1CreateTaxURL, authToken, taxParam)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			taxParam.Set("org_id", fmt.Sprintf("%d", orgID))
+			_, status, err = httpPost(apiV1CreateTaxURL, authToken, taxParam)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			body, header, status, err := httpDownload(apiV1MeURL+"/export", authToken)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(header.Get("Content-Type"), convey.ShouldEqual, "application/zip")
+			convey.So(header.Get("Content-Disposition"), convey.ShouldStartWith, "attachment; filename=tax-calculator-john_doe-")
+
+			archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+			convey.So(err, convey.ShouldBeNil)
+
+			var names []string
+			for _, f := range archive.File {
+				names = append(names, f.Name)
+				if f.Name != "taxes.csv" {
+					continue
+				}
+
+				r, err := f.Open()
+				convey.So(err, convey.ShouldBeNil)
+				rows, err := ioutil.ReadAll(r)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(strings.Count(string(rows), "Big Mac"), convey.ShouldEqual, 2)
+			}
+
+			convey.So(names, convey.ShouldResemble, []string{"profile.json", "taxes.json", "taxes.csv"})
+
+			convey.Convey("Deleted user is removed, but the tax of the organization is kept", func() {
+				res, status, err := httpSendJSON(http.MethodDelete, apiV1MeURL, authToken, map[string]string{"password": "wrong"})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(status, convey.ShouldEqual, 401)
+				convey.So(res["error_code"], convey.ShouldEqual, "1_0003")
+
+				// wait for the delay after the failed password
+				time.Sleep(*loginBaseDelay)
+
+				res, status, err = httpSendJSON(http.MethodDelete, apiV1MeURL, authToken, map[string]string{"password": "password"})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(status, convey.ShouldEqual, 200)
+				convey.So(res["message"], convey.ShouldEqual, "account is deleted")
+
+				_, status, err = httpGet(apiV1MeURL, authToken, nil)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(status, convey.ShouldEqual, 401)
+
+				Taxes, err := tax.NewRepository(dbConn).GetTaxesByOrganizationID(context.Background(), orgID)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(Taxes, convey.ShouldHaveLength, 1)
+				convey.So(Taxes[0].CreatedBy, convey.ShouldBeNil)
+
+				_, err = user.NewRepository(dbConn).FindByID(context.Background(), 1)
+				convey.So(err, convey.ShouldEqual, db.ErrNoRows)
+			})
+
+			convey.Convey("Last owner of organization with other member can't delete the account", func() {
+				res, status, err := httpSendJSON(http.MethodDelete, apiV1MeURL, otherToken, map[string]string{"password": "password"})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(status, convey.ShouldEqual, 409)
+				convey.So(res["error_code"], convey.ShouldEqual, "4_0007")
+
+				_, err = user.NewRepository(dbConn).FindByID(context.Background(), otherID)
+				convey.So(err, convey.ShouldBeNil)
+			})
+		})
+
+		convey.Convey("Organization without other member is deleted along with the owner", func() {
+			form := &url.Values{}
+			form.Set("name", "Personal")
+			res, _, err := httpPost(apiV1OrgsURL, authToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			orgID := int64(res["id"].(float64))
+
+			_, status, err := httpSendJSON(http.MethodDelete, apiV1MeURL, authToken, map[string]string{"password": "password"})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			_, err = organization.NewRepository(dbConn).FindByID(context.Background(), orgID)
+			convey.So(err, convey.ShouldEqual, db.ErrNoRows)
+		})
+
+		convey.Convey("Organization is kept when deleting the user fails", func() {
+			form := &url.Values{}
+			form.Set("name", "Personal")
+			res, _, err := httpPost(apiV1OrgsURL, authToken, form)
+			convey.So(err, convey.ShouldBeNil)
+			orgID := int64(res["id"].(float64))
+
+			// the wrapper is replaced without closing the database, so the rows are kept
+			c := dbConn
+			dbConn = nil
+			useDB(queryFailsDB{SQL: c, prefix: "DELETE FROM users"})
+			defer func() {
+				dbConn = nil
+				useDB(c)
+			}()
+
+			_, status, err := httpSendJSON(http.MethodDelete, apiV1MeURL, authToken, map[string]string{"password": "password"})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 422)
+
+			_, err = organization.NewRepository(c).FindByID(context.Background(), orgID)
+			convey.So(err, convey.ShouldBeNil)
+		})
+	})
+}
+
+// queryFailsDB runs the queries using SQL, but the query in the transaction which starts with prefix fails.
+type queryFailsDB struct {
+	db.SQL
+	prefix string
+}
+
+// Close does nothing, the wrapped database is still used after the test.
+func (queryFailsDB) Close() error { return nil }
+
+func (d queryFailsDB) NewTransaction(ctx context.Context) (db.Transaction, error) {
+	tx, err := d.SQL.NewTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryFailsTx{Transaction: tx, prefix: d.prefix}, nil
+}
+
+type queryFailsTx struct {
+	db.Transaction
+	prefix string
+}
+
+func (tx queryFailsTx) Query(ctx context.Context, out interface{}, query string, args ...interface{}) error {
+	if strings.HasPrefix(query, tx.prefix) {
+		return fmt.Errorf("query %q fails", tx.prefix)
+	}
+
+	return tx.Transaction.Query(ctx, out, query, args...)
+}
+
+func (tx queryFailsTx) Exec(ctx context.Context, query string, args ...interface{}) error {
+	if strings.HasPrefix(query, tx.prefix) {
+		return fmt.Errorf("query %q fails", tx.prefix)
+	}
+
+	return tx.Transaction.Exec(ctx, query, args...)
+}
+
+func TestJWKSEndpoint(t *testing.T) {
+	convey.Convey("Test JWKS Endpoint", t, func() {
+		resp, err := http.Get(jwksURL)
+		convey.So(err, convey.ShouldBeNil)
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(resp.StatusCode, convey.ShouldEqual, 200)
+
+		// HS256 secret must never be published
+		convey.So(string(body), convey.ShouldEqual, `{"keys":[]}`)
+	})
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	convey.Convey("Test Health Endpoints", t, func() {
+		refreshDB()
+		schemaErr, redisErr = nil, errors.New("connection refused")
+
+		// checks returns the status of each check in the response
+		checks := func(res map[string]interface{}) map[string]string {
+			statuses := map[string]string{}
+			list, _ := res["checks"].([]interface{})
+			for _, check := range list {
+				check := check.(map[string]interface{})
+				statuses[check["name"].(string)] = check["status"].(string)
+			}
+
+			return statuses
+		}
+
+		convey.Convey("Liveness only tells the process is alive", func() {
+			res, status, err := httpGet(healthzURL, "", nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["status"], convey.ShouldEqual, "ok")
+			convey.So(res, convey.ShouldNotContainKey, "checks")
+		})
+
+		convey.Convey("Readiness reports the optional dependency without its error, only the required checks decide the status", func() {
+			res, status, err := httpGet(readyzURL, "", nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["status"], convey.ShouldEqual, "ok")
+			convey.So(checks(res), convey.ShouldResemble, map[string]string{"shutdown": "ok", "db_master": "ok", "db_schema": "ok", "redis": "unavailable"})
+
+			redis := res["checks"].([]interface{})[3].(map[string]interface{})
+			convey.So(redis["required"], convey.ShouldBeFalse)
+			convey.So(redis, convey.ShouldNotContainKey, "error")
+		})
+
+		convey.Convey("Health lists the optional dependency with its error, it doesn't affect the status", func() {
+			res, status, err := httpGet(healthURL, "", nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(res["status"], convey.ShouldEqual, "ok")
+			convey.So(checks(res)["redis"], convey.ShouldEqual, "unavailable")
+
+			redis := res["checks"].([]interface{})[3].(map[string]interface{})
+			convey.So(redis["required"], convey.ShouldBeFalse)
+			convey.So(redis["error"], convey.ShouldEqual, "connection refused")
+			convey.So(redis, convey.ShouldContainKey, "latency_ms")
+		})
+
+		convey.Convey("Older schema is not ready", func() {
+			schemaErr = errors.New("database schema is older than expected")
+			defer func() { schemaErr = nil }()
+
+			res, status, err := httpGet(readyzURL, "", nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 503)
+			convey.So(res["status"], convey.ShouldEqual, "unavailable")
+			convey.So(checks(res)["db_schema"], convey.ShouldEqual, "unavailable")
+		})
+
+		convey.Convey("Closed database is not ready", func() {
+			convey.So(dbConn.Close(), convey.ShouldBeNil)
+			defer refreshDB()
+
+			res, status, err := httpGet(readyzURL, "", nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 503)
+			convey.So(checks(res)["db_master"], convey.ShouldEqual, "unavailable")
+		})
+
+		convey.Convey("Shutting down server is not ready, but it's still alive", func() {
+			convey.So(server.Shutdown(context.Background()), convey.ShouldBeNil)
+			defer refreshDB()
+
+			res, status, err := httpGet(readyzURL, "", nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 503)
+			convey.So(checks(res)["shutdown"], convey.ShouldEqual, "unavailable")
+
+			_, status, err = httpGet(healthzURL, "", nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			resp, err := http.Get(jwksURL)
+			convey.So(err, convey.ShouldBeNil)
+			resp.Body.Close()
+			convey.So(resp.StatusCode, convey.ShouldEqual, 503)
+		})
+	})
+}
+
+func TestSSOToken(t *testing.T) {
+	convey.Convey("Test Token From External Issuer", t, func() {
+		refreshDB()
+
+		convey.Convey("Valid token creates the user once", func() {
+			token, err := ssoIssuer.Token("employee-1", "tax-calculator", map[string]interface{}{"preferred_username": "jane_doe"})
+			convey.So(err, convey.ShouldBeNil)
+
+			taxParam := &url.Values{}
+			taxParam.Set("name", "Big Mac")
+			taxParam.Set("tax_code", "1")
+			taxParam.Set("price", "1000")
+			_, status, err := httpPost(apiV1CreateTaxURL, token, taxParam)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+
+			// new token of the same subject is mapped to the same user
+			token, err = ssoIssuer.Token("employee-1", "tax-calculator", nil)
+			convey.So(err, convey.ShouldBeNil)
+
+			res, status, err := httpGet(apiV1GetTaxURL, token, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 200)
+			convey.So(len(res["taxes"].([]interface{})), convey.ShouldEqual, 1)
+
+			// and the created user has no password to login with
+			formLogin := &url.Values{}
+			formLogin.Set("username", "jane_doe")
+			formLogin.Set("password", "")
+			_, status, err = httpPost(apiV1LoginURL, "", formLogin)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldNotEqual, 200)
+		})
+
+		convey.Convey("Token for another audience is rejected", func() {
+			token, err := ssoIssuer.Token("employee-1", "another-app", nil)
+			convey.So(err, convey.ShouldBeNil)
+
+			_, status, err := httpGet(apiV1GetTaxURL, token, nil)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 401)
+		})
+	})
+}
+
+// circuitOpenDB behaves like a database which circuit breaker is open.
+type circuitOpenDB struct{}
+
+func (circuitOpenDB) Close() error           { return nil }
+func (circuitOpenDB) Writer() db.SQLExecutor { return circuitOpenDB{} }
+func (circuitOpenDB) Reader() db.SQLExecutor { return circuitOpenDB{} }
+func (circuitOpenDB) NewTransaction(ctx context.Context) (db.Transaction, error) {
+	return nil, db.ErrCircuitOpen
+}
+
+func (circuitOpenDB) Query(ctx context.Context, out interface{}, query string, args ...interface{}) error {
+	return db.ErrCircuitOpen
+}
+
+func (circuitOpenDB) Exec(ctx context.Context, query string, args ...interface{}) error {
+	return db.ErrCircuitOpen
+}
+
+func TestDatabaseUnavailable(t *testing.T) {
+	convey.Convey("Test Database Unavailable", t, func() {
+		useDB(circuitOpenDB{})
+		defer refreshDB()
+
+		unavailable := map[string]interface{}{
+			"error_code":       "0_0003",
+			"http_status_code": float64(503),
+			"message":          "database is unavailable, please try again later",
+		}
+
+		form := &url.Values{}
+		form.Set("username", "john_doe")
+		form.Set("password", "password")
+
+		convey.Convey("Register should return 503", func() {
+			res, status, err := httpPost(apiV1RegisterURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 503)
+			convey.So(res, convey.ShouldResemble, unavailable)
+		})
+
+		convey.Convey("Login should return 503", func() {
+			res, status, err := httpPost(apiV1LoginURL, "", form)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(status, convey.ShouldEqual, 503)
+			convey.So(res, convey.ShouldResemble, unavailable)
+		})
+	})
+}
+
+func TestNewSlaveConfs(t *testing.T) {
+	convey.Convey("Test Slave Database Config", t, func() {
+		convey.Convey("No slave is configured by default", func() {
+			convey.So(newSlaveConfs(flag.Lookup("db-slaves-url").DefValue), convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("Empty url is skipped", func() {
+			confs := newSlaveConfs("postgres://slave-1/tax; ;postgres://slave-2/tax;")
+			convey.So(confs, convey.ShouldHaveLength, 2)
+			convey.So(confs[0].URL, convey.ShouldEqual, "postgres://slave-1/tax")
+			convey.So(confs[1].URL, convey.ShouldEqual, "postgres://slave-2/tax")
+		})
+	})
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/namsral/flag"
+	"github.com/rubenv/sql-migrate"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+// Exit codes of the migrate subcommand.
+const (
+	exitOK              = 0 // success, or status with every migration applied
+	exitError           = 1 // the command failed, such as cannot connect or migration query error
+	exitUsage           = 2 // wrong command or argument
+	exitPending         = 3 // status found migration which is not applied yet
+	exitUnknownMigrated = 4 // status found applied migration which file doesn't exist, the binary is older than the database
+)
+
+const migrateUsage = `Usage: tax-calculator-server [flags] migrate [-dry-run] [-dir dir] <command>
+
+Commands:
+  up [n]         apply all or n pending migrations
+  down [n]       roll back the last n applied migrations, default 1
+  redo           roll back the last applied migration and apply it again
+  status         print applied and pending migrations
+  create <name>  create new migration file for PostgreSQL and SQLite
+
+Exit codes:
+  0  success, or every migration is applied
+  1  the command failed
+  2  wrong command or argument
+  3  status found pending migration
+  4  status found applied migration which file doesn't exist
+`
+
+var migrationNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// migrateCommand is the "migrate" subcommand.
+type migrateCommand struct {
+	out         io.Writer
+	url         string                 // master url, used by the migrator when the driver is not database/sql
+	connect     func() (db.SQL, error) // not called by create, so new migration can be created without database
+	lockTimeout time.Duration          // how long to wait for the migration lock held by another instance
+	dryRun      bool
+	dir         string // migration directory used by create
+}
+
+// run executes the subcommand and returns the process exit code.
+func (c *migrateCommand) run(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(c.out)
+	flags.Usage = func() {
+		fmt.Fprint(c.out, migrateUsage)
+	}
+
+	flags.BoolVar(&c.dryRun, "dry-run", false, "Print the SQL of up, down and redo instead of executing it")
+	flags.StringVar(&c.dir, "dir", "assets/migrations", "Migration directory used by create")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	command, args := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "up":
+		return c.withConnection(args, 0, 1, func(dbConn db.SQL, n int) int {
+			return c.exec(dbConn, migrate.Up, n)
+		})
+	case "down":
+		return c.withConnection(args, 1, 1, func(dbConn db.SQL, n int) int {
+			return c.exec(dbConn, migrate.Down, n)
+		})
+	case "redo":
+		return c.withConnection(args, 0, 0, c.redo)
+	case "status":
+		return c.withConnection(args, 0, 0, func(dbConn db.SQL, n int) int {
+			return c.status(dbConn)
+		})
+	case "create":
+		if len(args) != 1 {
+			flags.Usage()
+			return exitUsage
+		}
+
+		return c.create(args[0])
+	default:
+		fmt.Fprintf(c.out, "unknown migrate command %q\n\n", command)
+		flags.Usage()
+		return exitUsage
+	}
+}
+
+// withConnection parses the optional count argument, which default is defaultN, then calls fn with new connection.
+// maxArgs is the number of accepted arguments, 0 means the command doesn't accept any argument.
+func (c *migrateCommand) withConnection(args []string, defaultN, maxArgs int, fn func(dbConn db.SQL, n int) int) int {
+	if len(args) > maxArgs {
+		fmt.Fprint(c.out, migrateUsage)
+		return exitUsage
+	}
+
+	n := defaultN
+	if len(args) == 1 {
+		var err error
+		n, err = strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			fmt.Fprintf(c.out, "migration count must be a positive number, got %q\n", args[0])
+			return exitUsage
+		}
+	}
+
+	dbConn, err := c.connect()
+	if err != nil {
+		fmt.Fprintf(c.out, "cannot connect to database: %s\n", err.Error())
+		return exitError
+	}
+	defer dbConn.Close()
+
+	return fn(dbConn, n)
+}
+
+func (c *migrateCommand) exec(dbConn db.SQL, direction migrate.MigrationDirection, n int) int {
+	planned, err := conn.MigratePlan(dbConn, c.url, direction, n)
+	if err != nil {
+		fmt.Fprintf(c.out, "cannot plan migration: %s\n", err.Error())
+		return exitError
+	}
+
+	if len(planned) == 0 {
+		fmt.Fprintln(c.out, "no migration to apply")
+		return exitOK
+	}
+
+	if c.dryRun {
+		for _, migration := range planned {
+			c.printQueries(migration.Id, direction, migration.Queries)
+		}
+
+		return exitOK
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.lockTimeout)
+	defer cancel()
+
+	applied, err := conn.MigrateExec(ctx, dbConn, c.url, direction, n)
+
+	// another process may add the migrations after they're planned, only the planned ones are known by name
+	if applied > len(planned) {
+		applied = len(planned)
+	}
+
+	for _, migration := range planned[:applied] {
+		fmt.Fprintf(c.out, "%s %s\n", directionName(direction), migration.Id)
+	}
+
+	if err != nil {
+		fmt.Fprintf(c.out, "migration failed: %s\n", err.Error())
+		return exitError
+	}
+
+	return exitOK
+}
+
+func (c *migrateCommand) redo(dbConn db.SQL, _ int) int {
+	planned, err := conn.MigratePlan(dbConn, c.url, migrate.Down, 1)
+	if err != nil {
+		fmt.Fprintf(c.out, "cannot plan migration: %s\n", err.Error())
+		return exitError
+	}
+
+	if len(planned) == 0 {
+		fmt.Fprintln(c.out, "no applied migration to redo")
+		return exitOK
+	}
+
+	if c.dryRun {
+		c.printQueries(planned[0].Id, migrate.Down, planned[0].Down)
+		c.printQueries(planned[0].Id, migrate.Up, planned[0].Up)
+		return exitOK
+	}
+
+	if code := c.exec(dbConn, migrate.Down, 1); code != exitOK {
+		return code
+	}
+
+	return c.exec(dbConn, migrate.Up, 1)
+}
+
+func (c *migrateCommand) status(dbConn db.SQL) int {
+	statuses, err := conn.MigrateStatus(dbConn, c.url)
+	if err != nil {
+		fmt.Fprintf(c.out, "cannot get migration status: %s\n", err.Error())
+		return exitError
+	}
+
+	code := exitOK
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tAPPLIED AT")
+	for _, status := range statuses {
+		switch {
+		case status.Unknown:
+			fmt.Fprintf(w, "%s\t%s (file not found)\n", status.ID, status.AppliedAt.Format(time.RFC3339))
+			code = exitUnknownMigrated
+		case status.AppliedAt == nil:
+			fmt.Fprintf(w, "%s\tpending\n", status.ID)
+			if code == exitOK {
+				code = exitPending
+			}
+		default:
+			fmt.Fprintf(w, "%s\t%s\n", status.ID, status.AppliedAt.Format(time.RFC3339))
+		}
+	}
+
+	w.Flush()
+	return code
+}
+
+// create writes the same empty migration for PostgreSQL in dir and for SQLite in dir/sqlite,
+// because both dialects must have the same migration id.
+func (c *migrateCommand) create(name string) int {
+	if !migrationNamePattern.MatchString(name) {
+		fmt.Fprintf(c.out, "migration name must only contain lowercase letter, number and underscore, got %q\n", name)
+		return exitUsage
+	}
+
+	fileName := fmt.Sprintf("%d_%s.sql", time.Now().Unix(), name)
+	template := []byte("-- +migrate Up\n\n-- +migrate Down\n")
+
+	for _, dir := range []string{c.dir, filepath.Join(c.dir, "sqlite")} {
+		path := filepath.Join(dir, fileName)
+		if _, err := os.Stat(path); err == nil {
+			fmt.Fprintf(c.out, "migration %s already exists\n", path)
+			return exitError
+		}
+
+		if err := ioutil.WriteFile(path, template, 0644); err != nil {
+			fmt.Fprintf(c.out, "cannot create migration: %s\n", err.Error())
+			return exitError
+		}
+
+		fmt.Fprintf(c.out, "created %s\n", path)
+	}
+
+	return exitOK
+}
+
+func (c *migrateCommand) printQueries(id string, direction migrate.MigrationDirection, queries []string) {
+	fmt.Fprintf(c.out, "-- %s %s\n", directionName(direction), id)
+	for _, query := range queries {
+		fmt.Fprintln(c.out, strings.TrimSpace(query))
+	}
+
+	fmt.Fprintln(c.out)
+}
+
+func directionName(direction migrate.MigrationDirection) string {
+	if direction == migrate.Down {
+		return "down"
+	}
+
+	return "up"
+}
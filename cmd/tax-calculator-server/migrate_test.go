@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+// statusOf returns the applied at column of the migration in the status output.
+func statusOf(output, id string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == id {
+			return fields[1]
+		}
+	}
+
+	return ""
+}
+
+func TestMigrateCommand(t *testing.T) {
+	convey.Convey("Test Migrate Command", t, func() {
+		dir, err := ioutil.TempDir("", "migrate")
+		convey.So(err, convey.ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		out := &bytes.Buffer{}
+		cmd := &migrateCommand{
+			out:         out,
+			lockTimeout: time.Second,
+			connect: func() (db.SQL, error) {
+				return db.NewConnection(&db.Config{
+					Driver: db.DriverSQLite,
+					Master: &db.Conf{URL: filepath.Join(dir, "migrate.db")},
+				})
+			},
+		}
+
+		run := func(args ...string) int {
+			out.Reset()
+			return cmd.run(args)
+		}
+
+		convey.Convey("Every migration is pending in new database", func() {
+			convey.So(run("status"), convey.ShouldEqual, exitPending)
+			convey.So(statusOf(out.String(), "1539104678_create_users_table.sql"), convey.ShouldEqual, "pending")
+		})
+
+		convey.Convey("Dry run prints the SQL without applying it", func() {
+			convey.So(run("-dry-run", "up"), convey.ShouldEqual, exitOK)
+			convey.So(out.String(), convey.ShouldContainSubstring, "-- up 1539104678_create_users_table.sql")
+			convey.So(out.String(), convey.ShouldContainSubstring, "CREATE TABLE")
+			convey.So(run("status"), convey.ShouldEqual, exitPending)
+		})
+
+		convey.Convey("Up, down and redo change the status", func() {
+			convey.So(run("up", "1"), convey.ShouldEqual, exitOK)
+			convey.So(out.String(), convey.ShouldEqual, "up 1539104678_create_users_table.sql\n")
+
+			convey.So(run("up", "1"), convey.ShouldEqual, exitOK)
+			convey.So(out.String(), convey.ShouldEqual, "up 1539104697_create_taxes_table.sql\n")
+
+			convey.So(run("redo"), convey.ShouldEqual, exitOK)
+			convey.So(out.String(), convey.ShouldEqual, "down 1539104697_create_taxes_table.sql\nup 1539104697_create_taxes_table.sql\n")
+
+			convey.So(run("down"), convey.ShouldEqual, exitOK)
+			convey.So(out.String(), convey.ShouldEqual, "down 1539104697_create_taxes_table.sql\n")
+			convey.So(run("status"), convey.ShouldEqual, exitPending)
+			convey.So(statusOf(out.String(), "1539104697_create_taxes_table.sql"), convey.ShouldEqual, "pending")
+
+			convey.So(run("up"), convey.ShouldEqual, exitOK)
+			convey.So(out.String(), convey.ShouldStartWith, "up 1539104697_create_taxes_table.sql\n")
+			convey.So(run("status"), convey.ShouldEqual, exitOK)
+			convey.So(out.String(), convey.ShouldNotContainSubstring, "pending")
+		})
+
+		convey.Convey("Schema check fails until every migration is applied", func() {
+			dbConn, err := cmd.connect()
+			convey.So(err, convey.ShouldBeNil)
+			defer dbConn.Close()
+
+			convey.So(run("up", "1"), convey.ShouldEqual, exitOK)
+			err = conn.CheckSchema(dbConn, "")
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "pending migration: 1539104697_create_taxes_table.sql")
+
+			convey.So(run("up"), convey.ShouldEqual, exitOK)
+			convey.So(conn.CheckSchema(dbConn, ""), convey.ShouldBeNil)
+		})
+
+		convey.Convey("Create writes the migration for both dialects", func() {
+			convey.So(os.Mkdir(filepath.Join(dir, "sqlite"), 0755), convey.ShouldBeNil)
+			convey.So(run("-dir", dir, "create", "add_email_to_users"), convey.ShouldEqual, exitOK)
+
+			files, err := filepath.Glob(filepath.Join(dir, "*_add_email_to_users.sql"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(files, convey.ShouldHaveLength, 1)
+
+			files, err = filepath.Glob(filepath.Join(dir, "sqlite", "*_add_email_to_users.sql"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(files, convey.ShouldHaveLength, 1)
+		})
+
+		convey.Convey("Wrong usage returns usage exit code", func() {
+			convey.So(run(), convey.ShouldEqual, exitUsage)
+			convey.So(run("sideways"), convey.ShouldEqual, exitUsage)
+			convey.So(run("down", "zero"), convey.ShouldEqual, exitUsage)
+			convey.So(run("status", "1"), convey.ShouldEqual, exitUsage)
+			convey.So(run("create", "Bad Name"), convey.ShouldEqual, exitUsage)
+		})
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/namsral/flag"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/role"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/tax"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/user"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/seed"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+const seedUsage = `Usage: tax-calculator-server [flags] seed [-generate n] [-seed s] [-password p] [fixture.yaml|fixture.json ...]
+
+Load users and their taxes from the fixture files, and generate n synthetic users when -generate is set.
+It can be run many times, existing user is not changed and only its missing taxes are inserted.
+
+Flags:
+`
+
+// seedCommand is the "seed" subcommand.
+type seedCommand struct {
+	out     io.Writer
+	url     string                 // master url, used to check the schema when the driver is not database/sql
+	connect func() (db.SQL, error) // called after the fixtures are read, so invalid fixture doesn't need database
+}
+
+// run executes the subcommand and returns the process exit code, which is the same as the migrate subcommand.
+func (c *seedCommand) run(args []string) int {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	flags.SetOutput(c.out)
+	flags.Usage = func() {
+		fmt.Fprint(c.out, seedUsage)
+		flags.PrintDefaults()
+	}
+
+	generate := flags.Int("generate", 0, "Number of synthetic users to generate")
+	randomSeed := flags.Int64("seed", 1, "Random seed of the generated users, the same seed generates the same users")
+	password := flags.String("password", "password", "Password of the generated users")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if flags.NArg() == 0 && *generate <= 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	var fixtures []*seed.Fixture
+	for _, path := range flags.Args() {
+		fixture, err := seed.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(c.out, err.Error())
+			return exitUsage
+		}
+
+		fixtures = append(fixtures, fixture)
+	}
+
+	if *generate > 0 {
+		fixtures = append(fixtures, seed.Generate(*generate, *randomSeed, *password))
+	}
+
+	dbConn, err := c.connect()
+	if err != nil {
+		fmt.Fprintf(c.out, "cannot connect to database: %s\n", err.Error())
+		return exitError
+	}
+	defer dbConn.Close()
+
+	if err := conn.CheckSchema(dbConn, c.url); err != nil {
+		fmt.Fprintln(c.out, err.Error())
+		return exitError
+	}
+
+	loader := seed.NewLoader(user.NewRepository(dbConn), tax.NewRepository(dbConn), role.NewRepository(dbConn))
+
+	total := &seed.Result{}
+	for _, fixture := range fixtures {
+		result, err := loader.Load(context.Background(), fixture)
+		total.UsersCreated += result.UsersCreated
+		total.UsersSkipped += result.UsersSkipped
+		total.TaxesCreated += result.TaxesCreated
+		total.TaxesSkipped += result.TaxesSkipped
+
+		if err != nil {
+			c.printResult(total)
+			fmt.Fprintf(c.out, "seed failed: %s\n", err.Error())
+			return exitError
+		}
+	}
+
+	c.printResult(total)
+	return exitOK
+}
+
+func (c *seedCommand) printResult(result *seed.Result) {
+	fmt.Fprintf(c.out, "users: %d created, %d already exist\n", result.UsersCreated, result.UsersSkipped)
+	fmt.Fprintf(c.out, "taxes: %d created, %d already exist\n", result.TaxesCreated, result.TaxesSkipped)
+}
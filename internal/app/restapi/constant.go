@@ -8,6 +8,7 @@ import (
 const (
 	ContentTypeJSON     = "application/json"
 	ContentTypePostForm = "application/x-www-form-urlencoded"
+	ContentTypeZip      = "application/zip"
 )
 
 // Handler represents an api handler
@@ -46,8 +46,9 @@ func WrapGin(parent context.Context, h Handler) gin.HandlerFunc {
 }
 
 type ginRequest struct {
-	gCtx *gin.Context
-	user *model.User
+	gCtx   *gin.Context
+	user   *model.User
+	apiKey *model.APIKey
 }
 
 func newGinRequest(gCtx *gin.Context) Request {
@@ -91,3 +92,13 @@ func (r *ginRequest) User() *model.User {
 func (r *ginRequest) SetUser(user *model.User) {
 	r.user = user
 }
+
+// APIKey get the API key of this request, it's nil when the request uses authentication token.
+func (r *ginRequest) APIKey() *model.APIKey {
+	return r.apiKey
+}
+
+// SetAPIKey sets the API key used to authenticate this request. This usually set in middleware.
+func (r *ginRequest) SetAPIKey(apiKey *model.APIKey) {
+	r.apiKey = apiKey
+}
@@ -0,0 +1,150 @@
+package restapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/respayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+const (
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+
+	// the orchestrator treats the slow probe as failed, so the checks give up before it
+	healthCheckTimeout = 2 * time.Second
+
+	// the schema only changes by migration, so the successful check is reused instead of reading the migrations table on every probe
+	schemaCheckInterval = time.Minute
+)
+
+// healthPaths are called by the orchestrator every few seconds, so they're not logged,
+// and they still answer while shutting down to report it.
+var healthPaths = []string{"/healthz", "/readyz", "/health"}
+
+var errShuttingDown = errors.New("server is shutting down")
+
+func isHealthPath(path string) bool {
+	for _, p := range healthPaths {
+		if p == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// healthz tells that the process is alive. It doesn't check the dependencies,
+// so the process is not restarted when only the database is down.
+func (s *Server) healthz(parent context.Context, req Request) Response {
+	return newJSONResponse(http.StatusOK, respayload.Health{Status: healthStatusOK})
+}
+
+// readyz tells whether the server can handle the request: it's not shutting down, the master database is reachable,
+// and the database schema is not older than this binary. The replicas and the optional dependencies are reported
+// but they don't affect the readiness, since the reader falls back to the master. The error is only shown in the health endpoint.
+func (s *Server) readyz(parent context.Context, req Request) Response {
+	checks := s.checkHealth(parent)
+	for _, check := range checks {
+		check.Error = ""
+	}
+
+	return newHealthResponse(checks)
+}
+
+// health lists every dependency with its latency and error, including the optional one such as the Redis cache.
+func (s *Server) health(parent context.Context, req Request) Response {
+	return newHealthResponse(s.checkHealth(parent))
+}
+
+func (s *Server) checkHealth(parent context.Context) []*respayload.HealthCheck {
+	ctx, cancel := context.WithTimeout(parent, healthCheckTimeout)
+	defer cancel()
+
+	var shutdownErr error
+	if !s.Ready() {
+		shutdownErr = errShuttingDown
+	}
+
+	checks := []*respayload.HealthCheck{newHealthCheck("shutdown", true, 0, shutdownErr)}
+	if s.conf.DB != nil {
+		for _, node := range db.Ping(ctx, s.conf.DB) {
+			name := "db_master"
+			if node.Role == db.RoleReplica {
+				name = fmt.Sprintf("db_replica_%d", node.Node)
+			}
+
+			checks = append(checks, newHealthCheck(name, node.Role == db.RoleMaster, node.Latency, node.Err))
+		}
+	}
+
+	if s.conf.CheckSchema != nil {
+		start := time.Now()
+		err := s.checkSchema()
+		checks = append(checks, newHealthCheck("db_schema", true, time.Since(start), err))
+	}
+
+	names := make([]string, 0, len(s.conf.HealthChecks))
+	for name := range s.conf.HealthChecks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		start := time.Now()
+		err := s.conf.HealthChecks[name](ctx)
+		checks = append(checks, newHealthCheck(name, false, time.Since(start), err))
+	}
+
+	return checks
+}
+
+// checkSchema runs Config.CheckSchema, the success is remembered for schemaCheckInterval.
+func (s *Server) checkSchema() error {
+	s.schemaMu.Lock()
+	defer s.schemaMu.Unlock()
+
+	if !s.schemaCheckedAt.IsZero() && time.Since(s.schemaCheckedAt) < schemaCheckInterval {
+		return nil
+	}
+
+	if err := s.conf.CheckSchema(); err != nil {
+		s.schemaCheckedAt = time.Time{}
+		return err
+	}
+
+	s.schemaCheckedAt = time.Now()
+	return nil
+}
+
+func newHealthCheck(name string, required bool, latency time.Duration, err error) *respayload.HealthCheck {
+	check := &respayload.HealthCheck{
+		Name:      name,
+		Status:    healthStatusOK,
+		Required:  required,
+		LatencyMs: float64(latency.Nanoseconds()) / float64(time.Millisecond),
+	}
+
+	if err != nil {
+		check.Status = healthStatusUnavailable
+		check.Error = err.Error()
+	}
+
+	return check
+}
+
+// newHealthResponse returns 503 when one of the required checks fails, so the orchestrator stops sending the request.
+func newHealthResponse(checks []*respayload.HealthCheck) Response {
+	for _, check := range checks {
+		if check.Required && check.Status != healthStatusOK {
+			return newJSONResponse(http.StatusServiceUnavailable, respayload.Health{Status: healthStatusUnavailable, Checks: checks})
+		}
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.Health{Status: healthStatusOK, Checks: checks})
+}
@@ -27,6 +27,8 @@ func LoggerWithWriter(out io.Writer, notlogged ...string) gin.HandlerFunc {
 		}
 	}
 
+	reqLogger := logger.Output(out)
+
 	return func(c *gin.Context) {
 		// Start timer
 		start := time.Now()
@@ -51,7 +53,7 @@ func LoggerWithWriter(out io.Writer, notlogged ...string) gin.HandlerFunc {
 				path = path + "?" + raw
 			}
 
-			logger.Debug().
+			reqLogger.Debug().
 				Str("requestTime", end.Format("2006/01/02 - 15:04:05")).
 				Int("code", statusCode).
 				Str("latency", fmt.Sprintf("%13v", latency)).
@@ -0,0 +1,163 @@
+package restapi
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/metrics"
+)
+
+// Result of the login, used as label in the metrics.
+const (
+	loginResultSucceeded = "succeeded"
+	loginResultFailed    = "failed"
+)
+
+// unmatchedRoute is the route label of the request which doesn't match any route,
+// so the random path requested by the scanner doesn't create a new label.
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = metrics.NewCounterVec(
+		"http_requests_total",
+		"Total of handled HTTP requests.",
+		"method", "route", "status",
+	)
+
+	httpRequestDuration = metrics.NewHistogramVec(
+		"http_request_duration_seconds",
+		"HTTP request latency in seconds.",
+		nil,
+		"method", "route", "status",
+	)
+
+	httpRequestsInFlight = metrics.NewGaugeVec(
+		"http_requests_in_flight",
+		"Number of HTTP requests which are being handled.",
+		"method", "route",
+	)
+
+	taxItemsCreated = metrics.NewCounterVec(
+		"tax_items_created_total",
+		"Total of created tax items.",
+		"tax_code",
+	)
+
+	taxAmountCalculated = metrics.NewCounterVec(
+		"tax_amount_calculated_total",
+		"Sum of the tax calculated for the created tax items.",
+		"tax_code",
+	)
+
+	logins = metrics.NewCounterVec(
+		"logins_total",
+		"Total of login by the result, the wrong 2FA code and the disabled account are counted as failed.",
+		"result",
+	)
+)
+
+// Metrics returns a collector of the HTTP request and business metrics.
+// The metrics are shared by all servers, so register only one collector to a registry.
+func Metrics() metrics.Collector {
+	return metrics.CollectorFunc(func() []*metrics.Family {
+		families := append(httpRequests.Collect(), httpRequestDuration.Collect()...)
+		families = append(families, httpRequestsInFlight.Collect()...)
+		families = append(families, taxItemsCreated.Collect()...)
+		families = append(families, taxAmountCalculated.Collect()...)
+		return append(families, logins.Collect()...)
+	})
+}
+
+// httpMetrics records the HTTP request metrics. It must be the first middleware,
+// so the status set by the panic recovery of the other middleware is recorded.
+func (s *Server) httpMetrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		method := ctx.Request.Method
+		route := s.routes.match(method, ctx.Request.URL.Path)
+
+		inFlight := httpRequestsInFlight.WithLabelValues(method, route)
+		inFlight.Inc()
+		start := time.Now()
+
+		ctx.Next()
+
+		inFlight.Dec()
+		status := strconv.Itoa(ctx.Writer.Status())
+		httpRequests.WithLabelValues(method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// observeTaxCreated counts the created tax item and its tax amount.
+func observeTaxCreated(Tax *model.Tax) {
+	taxCode := strconv.Itoa(int(Tax.TaxCode))
+	taxItemsCreated.WithLabelValues(taxCode).Inc()
+	taxAmountCalculated.WithLabelValues(taxCode).Add(Tax.GetTaxValue())
+}
+
+// observeLogin counts the login by its result.
+func observeLogin(result string) {
+	logins.WithLabelValues(result).Inc()
+}
+
+// routeMatcher finds the route template of the request path, such as /api/v1/orgs/:id/members,
+// so the ids in the path don't create a new label. The router doesn't allow the param and the static segment
+// at the same position, so at most one template matches the path.
+type routeMatcher map[string][]routeTemplate // by method
+
+type routeTemplate struct {
+	path     string
+	segments []string
+}
+
+func newRouteMatcher(routes gin.RoutesInfo) routeMatcher {
+	m := routeMatcher{}
+	for _, route := range routes {
+		m[route.Method] = append(m[route.Method], routeTemplate{path: route.Path, segments: splitPath(route.Path)})
+	}
+
+	return m
+}
+
+// match returns the template of the route matching the path, or unmatchedRoute.
+func (m routeMatcher) match(method, path string) string {
+	segments := splitPath(path)
+	for _, template := range m[method] {
+		if template.match(segments) {
+			return template.path
+		}
+	}
+
+	return unmatchedRoute
+}
+
+func (t routeTemplate) match(segments []string) bool {
+	for i, segment := range t.segments {
+		// catch-all param matches the rest of the path
+		if strings.HasPrefix(segment, "*") {
+			return true
+		}
+
+		if i >= len(segments) {
+			return false
+		}
+
+		if !strings.HasPrefix(segment, ":") && segment != segments[i] {
+			return false
+		}
+	}
+
+	return len(t.segments) == len(segments)
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,33 @@
+package restapi
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRouteMatcher(t *testing.T) {
+	convey.Convey("Test Route Matcher", t, func() {
+		m := newRouteMatcher(gin.RoutesInfo{
+			{Method: "GET", Path: "/swagger/*any"},
+			{Method: "GET", Path: "/api/v1/me"},
+			{Method: "GET", Path: "/api/v1/me/export"},
+			{Method: "GET", Path: "/api/v1/orgs/:id/members"},
+			{Method: "DELETE", Path: "/api/v1/orgs/:id/members/:user_id"},
+		})
+
+		convey.So(m.match("GET", "/api/v1/me"), convey.ShouldEqual, "/api/v1/me")
+		convey.So(m.match("GET", "/api/v1/me/export"), convey.ShouldEqual, "/api/v1/me/export")
+		convey.So(m.match("GET", "/api/v1/orgs/7/members"), convey.ShouldEqual, "/api/v1/orgs/:id/members")
+		convey.So(m.match("DELETE", "/api/v1/orgs/7/members/7"), convey.ShouldEqual, "/api/v1/orgs/:id/members/:user_id")
+		convey.So(m.match("GET", "/swagger/index.html"), convey.ShouldEqual, "/swagger/*any")
+
+		convey.Convey("Path which doesn't match any route shares the same label", func() {
+			convey.So(m.match("POST", "/api/v1/me"), convey.ShouldEqual, unmatchedRoute)
+			convey.So(m.match("GET", "/api/v1/orgs/7"), convey.ShouldEqual, unmatchedRoute)
+			convey.So(m.match("GET", "/api/v1/me/export/all"), convey.ShouldEqual, unmatchedRoute)
+			convey.So(m.match("GET", "/wp-login.php"), convey.ShouldEqual, unmatchedRoute)
+		})
+	})
+}
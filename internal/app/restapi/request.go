@@ -22,6 +22,8 @@ type Request interface {
 	/* helper methods */
 	User() *model.User
 	SetUser(*model.User)
+	APIKey() *model.APIKey
+	SetAPIKey(*model.APIKey)
 }
 
 // DummyRequest is for testing purpose. So instead using gin context, it will use http.Request.
@@ -29,7 +31,8 @@ type DummyRequest struct {
 	encodedBody []byte
 	req         *http.Request
 
-	user *model.User
+	user   *model.User
+	apiKey *model.APIKey
 }
 
 // NewDummyRequest creates a new dummy request. This implements the Request interface.
@@ -73,6 +76,16 @@ func (r *DummyRequest) SetUser(user *model.User) {
 	r.user = user
 }
 
+// APIKey get the API key of this request, it's nil when the request uses authentication token.
+func (r *DummyRequest) APIKey() *model.APIKey {
+	return r.apiKey
+}
+
+// SetAPIKey sets the API key used to authenticate this request. This usually set in middleware.
+func (r *DummyRequest) SetAPIKey(apiKey *model.APIKey) {
+	r.apiKey = apiKey
+}
+
 // setReqBody set the request body so it can be read using
 func (r *DummyRequest) setReqBody() {
 	r.req.Body = ioutil.NopCloser(bytes.NewBuffer(r.encodedBody))
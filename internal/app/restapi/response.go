@@ -2,7 +2,10 @@ package restapi
 
 import (
 	"encoding/json"
+	"mime"
 	"net/http"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/respayload"
 )
 
 // Response represents an api response
@@ -28,6 +31,16 @@ func newJSONResponse(code int, data interface{}) Response {
 	}
 }
 
+// newDatabaseUnavailableResponse is returned when the database circuit breaker is open (db.ErrCircuitOpen),
+// so the client gets the error immediately instead of waiting for the connection timeout.
+func newDatabaseUnavailableResponse() Response {
+	return newJSONResponse(http.StatusServiceUnavailable, respayload.Error{
+		HttpStatusCode: http.StatusServiceUnavailable,
+		ErrorCode:      respayload.ErrorDatabaseUnavailable,
+		Message:        "database is unavailable, please try again later",
+	})
+}
+
 func (r *jsonResponse) StatusCode() int {
 	return r.statusCode
 }
@@ -48,6 +61,40 @@ func (r *jsonResponse) ContentType() string {
 	return ContentTypeJSON
 }
 
+type fileResponse struct {
+	body        []byte
+	header      http.Header
+	contentType string
+}
+
+// newFileResponse creates a response which is downloaded by the browser as the file name.
+func newFileResponse(contentType, fileName string, body []byte) Response {
+	header := http.Header{}
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+
+	return &fileResponse{
+		body:        body,
+		header:      header,
+		contentType: contentType,
+	}
+}
+
+func (r *fileResponse) StatusCode() int {
+	return http.StatusOK
+}
+
+func (r *fileResponse) Body() ([]byte, error) {
+	return r.body, nil
+}
+
+func (r *fileResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *fileResponse) ContentType() string {
+	return r.contentType
+}
+
 type dummyResponse struct {
 	statusCode  int
 	err         error
@@ -0,0 +1,203 @@
+package restapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/reqpayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/respayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/validator"
+)
+
+// defaultListUsersLimit is the number of users returned when the limit is not set.
+const defaultListUsersLimit = 20
+
+// AdminListUsers
+// @Summary List every user
+// @Description List every user ordered by id, including the disabled one. It needs user:read permission.
+// @ID admin-list-users
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param limit query int false "number of users, max 100" default(20)
+// @Param offset query int false "number of users to skip" default(0)
+// @Produce  json
+// @Success 200 {object} respayload.AdminUsers
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /admin/users [get]
+func (s *Server) adminListUsers(parent context.Context, req Request) Response {
+	form := &reqpayload.ListUsers{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	if form.Limit == 0 {
+		form.Limit = defaultListUsersLimit
+	}
+
+	Users, err := s.users.List(parent, form.Limit, form.Offset)
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
+			Message:        fmt.Sprintf("db error when listing users %s", err.Error()),
+		})
+	}
+
+	users := []*respayload.AdminUser{}
+	for _, User := range Users {
+		users = append(users, newAdminUserResponse(User))
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.AdminUsers{
+		Users:  users,
+		Limit:  form.Limit,
+		Offset: form.Offset,
+	})
+}
+
+// AdminGetUserTaxes
+// @Summary Get taxes of any user
+// @Description Get personal taxes of the user, the same as GET /tax of that user. It needs tax:read:any permission.
+// @ID admin-get-user-taxes
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param id path int true "user id"
+// @Produce  json
+// @Success 200 {object} respayload.TaxesForCurrentUser
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 404 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /admin/users/{id}/taxes [get]
+func (s *Server) adminGetUserTaxes(parent context.Context, req Request) Response {
+	User, res := s.findUserByParam(parent, req)
+	if res != nil {
+		return res
+	}
+
+	return s.taxesResponse(s.taxes.GetTaxesByUserID(parent, User.ID))
+}
+
+// AdminDisableUser
+// @Summary Disable the account of any user
+// @Description The user can't login anymore, and every session and API key of the user is rejected. It needs user:disable permission.
+// @ID admin-disable-user
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param id path int true "user id"
+// @Produce  json
+// @Success 200 {object} respayload.DisableUser
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 404 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /admin/users/{id}/disable [post]
+func (s *Server) adminDisableUser(parent context.Context, req Request) Response {
+	User, res := s.findUserByParam(parent, req)
+	if res != nil {
+		return res
+	}
+
+	// otherwise the last admin can lock everyone out of the admin endpoints
+	if User.ID == req.User().ID {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        "can't disable your own account",
+		})
+	}
+
+	// the authentication token is rejected by the middleware, and the refresh token is revoked here
+	// in the same transaction, so the user is not disabled while the refresh token still works
+	err := s.inTransaction(parent, func(tx Repositories) error {
+		var err error
+		if User, err = tx.Users.Disable(parent, User.ID, time.Now()); err != nil {
+			return err
+		}
+
+		return auth.NewSessions(s.conf.Tokens, tx.RefreshTokens).RevokeAll(parent, User.ID)
+	})
+
+	switch {
+	case err == db.ErrCircuitOpen:
+		return newDatabaseUnavailableResponse()
+	case err != nil:
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserSessionDBError,
+			Message:        fmt.Sprintf("db error when disabling user %s", err.Error()),
+		})
+	}
+
+	s.logAuthEvent(parent, model.AuthEventUserDisabled, &User.ID, User.Username, s.clientIP(req.RawRequest()))
+	return newJSONResponse(http.StatusOK, respayload.DisableUser{
+		User: newAdminUserResponse(User),
+	})
+}
+
+// findUserByParam returns the user of the id in the URL, or the 404 response when it doesn't exist.
+func (s *Server) findUserByParam(parent context.Context, req Request) (*model.User, Response) {
+	id, err := strconv.ParseInt(req.GetParam("id"), 10, 64)
+	if err != nil {
+		return nil, newJSONResponse(http.StatusNotFound, respayload.Error{
+			HttpStatusCode: http.StatusNotFound,
+			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
+			Message:        fmt.Sprintf("invalid user id %s", req.GetParam("id")),
+		})
+	}
+
+	User, err := s.users.FindByID(parent, id)
+	switch {
+	case err == db.ErrCircuitOpen:
+		return nil, newDatabaseUnavailableResponse()
+	case err == db.ErrNoRows:
+		return nil, newJSONResponse(http.StatusNotFound, respayload.Error{
+			HttpStatusCode: http.StatusNotFound,
+			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
+			Message:        fmt.Sprintf("user %d is not found", id),
+		})
+	case err != nil:
+		return nil, newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
+			Message:        fmt.Sprintf("db error when find user %s", err.Error()),
+		})
+	}
+
+	return User, nil
+}
+
+func newAdminUserResponse(User *model.User) *respayload.AdminUser {
+	return &respayload.AdminUser{
+		User:       *newUserResponse(User),
+		CreatedAt:  User.CreatedAt,
+		DisabledAt: User.DisabledAt,
+	}
+}
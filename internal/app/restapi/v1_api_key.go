@@ -0,0 +1,178 @@
+package restapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/reqpayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/respayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/validator"
+)
+
+// Create API key
+// @Summary Create API key
+// @Description Create long-lived API key for server-to-server integration, valid scopes are tax:read and tax:write.
+// @Description The key is only shown in this response, save it since it can't be retrieved later.
+// @ID api-key-create
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param api_key body reqpayload.CreateAPIKey true "name and scopes of the key"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.CreateAPIKey
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /api-keys [post]
+func (s *Server) createAPIKey(parent context.Context, req Request) Response {
+	form := &reqpayload.CreateAPIKey{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	form.Name = strings.TrimSpace(form.Name)
+
+	// scopes in form request may also be sent as comma separated string
+	var scopes []string
+	for _, scope := range form.Scopes {
+		for _, s := range strings.Split(scope, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				scopes = append(scopes, s)
+			}
+		}
+	}
+	form.Scopes = scopes
+
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	APIKey, key, err := s.apiKeys.Create(parent, req.User().ID, form.Name, form.Scopes)
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
+	if err != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorCodeAPIKeyCantBeCreated,
+			Message:        fmt.Sprintf("fail to create api key %s", err.Error()),
+		})
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.CreateAPIKey{
+		Key:    key,
+		APIKey: newAPIKeyResponse(APIKey),
+	})
+}
+
+// List API keys
+// @Summary List API keys of current user
+// @Description List API keys of current user including the revoked one, the key itself is never returned.
+// @ID api-key-list
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.APIKeys
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /api-keys [get]
+func (s *Server) listAPIKeys(parent context.Context, req Request) Response {
+	APIKeys, err := s.apiKeys.List(parent, req.User().ID)
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeAPIKeyDBError,
+			Message:        fmt.Sprintf("db error when get api keys %s", err.Error()),
+		})
+	}
+
+	apiKeysResponse := []*respayload.APIKey{}
+	for _, APIKey := range APIKeys {
+		apiKeysResponse = append(apiKeysResponse, newAPIKeyResponse(APIKey))
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.APIKeys{
+		APIKeys: apiKeysResponse,
+	})
+}
+
+// Revoke API key
+// @Summary Revoke API key
+// @Description Revoke API key of current user, the key can't be used anymore.
+// @ID api-key-revoke
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param id path int true "id of the api key"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.APIKey
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 404 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /api-keys/{id} [delete]
+func (s *Server) revokeAPIKey(parent context.Context, req Request) Response {
+	id, err := strconv.ParseInt(req.GetParam("id"), 10, 64)
+	if err != nil {
+		return newJSONResponse(http.StatusNotFound, respayload.Error{
+			HttpStatusCode: http.StatusNotFound,
+			ErrorCode:      respayload.ErrorCodeAPIKeyCantBeFound,
+			Message:        fmt.Sprintf("invalid api key id %s", req.GetParam("id")),
+		})
+	}
+
+	APIKey, err := s.apiKeys.Revoke(parent, req.User().ID, id)
+	switch {
+	case err == db.ErrCircuitOpen:
+		return newDatabaseUnavailableResponse()
+	case err == auth.ErrAPIKeyNotFound:
+		return newJSONResponse(http.StatusNotFound, respayload.Error{
+			HttpStatusCode: http.StatusNotFound,
+			ErrorCode:      respayload.ErrorCodeAPIKeyCantBeFound,
+			Message:        err.Error(),
+		})
+	case err != nil:
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeAPIKeyDBError,
+			Message:        fmt.Sprintf("db error when revoking api key %s", err.Error()),
+		})
+	}
+
+	return newJSONResponse(http.StatusOK, newAPIKeyResponse(APIKey))
+}
+
+func newAPIKeyResponse(APIKey *model.APIKey) *respayload.APIKey {
+	return &respayload.APIKey{
+		ID:         APIKey.ID,
+		Name:       APIKey.Name,
+		Prefix:     APIKey.Prefix,
+		Scopes:     APIKey.ScopeList(),
+		LastUsedAt: APIKey.LastUsedAt,
+		RevokedAt:  APIKey.RevokedAt,
+		CreatedAt:  APIKey.CreatedAt,
+	}
+}
@@ -0,0 +1,509 @@
+package restapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/export"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/reqpayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/respayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/validator"
+)
+
+var (
+	// localePattern is BCP 47 language tag, such as "id" or "en-US".
+	localePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+	// jurisdictionPattern is ISO 3166-1 alpha-2 country code optionally followed by the subdivision, such as "ID" or "US-CA".
+	jurisdictionPattern = regexp.MustCompile(`^[A-Z]{2}(-[A-Z0-9]{1,3})?$`)
+)
+
+// Get profile
+// @Summary Get profile of current user
+// @Description Get profile of current user, the unset field is null.
+// @ID me-get
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.Profile
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Router /me [get]
+func (s *Server) getProfile(parent context.Context, req Request) Response {
+	return newJSONResponse(http.StatusOK, newProfileResponse(req.User()))
+}
+
+// Update profile
+// @Summary Update profile of current user
+// @Description Only the sent field is changed, and empty value removes it. default_currency is ISO 4217 code,
+// @Description and jurisdiction is ISO 3166-1 alpha-2 country code optionally followed by the subdivision, such as ID or US-CA.
+// @ID me-update
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param profile body reqpayload.UpdateProfile true "the changed profile"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.Profile
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /me [patch]
+func (s *Server) updateProfile(parent context.Context, req Request) Response {
+	form := &reqpayload.UpdateProfile{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	form.Email = normalizeProfileField(form.Email, strings.TrimSpace)
+	form.DisplayName = normalizeProfileField(form.DisplayName, strings.TrimSpace)
+	form.Locale = normalizeProfileField(form.Locale, strings.TrimSpace)
+	form.DefaultCurrency = normalizeProfileField(form.DefaultCurrency, strings.ToUpper)
+	form.Jurisdiction = normalizeProfileField(form.Jurisdiction, strings.ToUpper)
+
+	errs := validator.Validate(form)
+	if errs == nil {
+		errs = &validator.Errors{}
+	}
+
+	// the format is only checked when the length is valid, so each field gets one error
+	if _, invalid := errs.Data["locale"]; !invalid && form.Locale != nil && *form.Locale != "" && !localePattern.MatchString(*form.Locale) {
+		validator.AddError(errs, "locale", "bcp47")
+	}
+
+	if _, invalid := errs.Data["jurisdiction"]; !invalid && form.Jurisdiction != nil && *form.Jurisdiction != "" && !jurisdictionPattern.MatchString(*form.Jurisdiction) {
+		validator.AddError(errs, "jurisdiction", "iso3166")
+	}
+
+	if len(errs.Data) > 0 {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	User := req.User()
+	profile := User.Profile()
+	setProfileField(&profile.Email, form.Email)
+	setProfileField(&profile.DisplayName, form.DisplayName)
+	setProfileField(&profile.Locale, form.Locale)
+	setProfileField(&profile.DefaultCurrency, form.DefaultCurrency)
+	setProfileField(&profile.Jurisdiction, form.Jurisdiction)
+
+	if profile.Email != nil {
+		Other, err := s.users.FindByEmail(parent, *profile.Email)
+		switch {
+		case err == db.ErrCircuitOpen:
+			return newDatabaseUnavailableResponse()
+		case err == nil && Other.ID != User.ID:
+			return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+				HttpStatusCode: http.StatusUnprocessableEntity,
+				ErrorCode:      respayload.ErrorCodeUserCantBeUpdated,
+				Message:        "email is already taken",
+			})
+		}
+	}
+
+	Updated, err := s.users.UpdateProfile(parent, User.ID, profile, time.Now())
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserCantBeUpdated,
+			Message:        fmt.Sprintf("db error when updating profile %s", err.Error()),
+		})
+	}
+
+	// roles are not columns, so they are copied from the current user
+	Updated.Roles, Updated.Permissions = User.Roles, User.Permissions
+	return newJSONResponse(http.StatusOK, newProfileResponse(Updated))
+}
+
+// Export data
+// @Summary Download every data of current user
+// @Description Download zip archive containing the profile (profile.json), and every tax added by the user
+// @Description including the one added to the organizations, as both JSON (taxes.json) and CSV (taxes.csv).
+// @ID me-export
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Produce  application/zip
+// @Success 200 {file} file
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /me/export [get]
+func (s *Server) exportData(parent context.Context, req Request) Response {
+	User := req.User()
+	Taxes, err := s.taxes.GetTaxesByCreatorID(parent, User.ID)
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeTaxDBError,
+			Message:        fmt.Sprintf("db error when get taxes %s", err.Error()),
+		})
+	}
+
+	now := time.Now()
+	archive := &bytes.Buffer{}
+	if err = export.Write(archive, User, Taxes, now); err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeTaxDBError,
+			Message:        fmt.Sprintf("fail to write the archive %s", err.Error()),
+		})
+	}
+
+	fileName := fmt.Sprintf("tax-calculator-%s-%s.zip", User.Username, now.UTC().Format("20060102"))
+	return newFileResponse(ContentTypeZip, fileName, archive.Bytes())
+}
+
+// Delete account
+// @Summary Delete account of current user
+// @Description Delete the account along with the personal taxes, sessions and API keys, download the data first using GET /me/export.
+// @Description The password is required, and also the TOTP code or a recovery code when 2FA is enabled.
+// @Description The taxes added to the organizations are kept, and the organization where the user is the only member is deleted.
+// @Description The last owner of the organization which has other member must invite another owner first.
+// @ID me-delete
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param account body reqpayload.DeleteAccount true "current password and 2FA code"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.DeleteAccount
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 409 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 429 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /me [delete]
+func (s *Server) deleteAccount(parent context.Context, req Request) Response {
+	form := &reqpayload.DeleteAccount{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	ip := s.clientIP(req.RawRequest())
+	User, res := s.verifyCurrentPassword(parent, req, form.Password)
+	if res != nil {
+		return res
+	}
+
+	if User.TwoFactorEnabled() {
+		err = s.twoFactor.Verify(parent, User, form.Code)
+		switch {
+		case err == db.ErrCircuitOpen:
+			return newDatabaseUnavailableResponse()
+		case err == auth.ErrTwoFactorCodeInvalid:
+			s.countLoginFailure(parent, model.AuthEventTwoFactorFailed, &User.ID, User.Username, ip)
+			return newJSONResponse(http.StatusUnauthorized, respayload.Error{
+				HttpStatusCode: http.StatusUnauthorized,
+				ErrorCode:      respayload.ErrorCodeUserWrongTwoFactorCode,
+				Message:        err.Error(),
+			})
+		case err != nil:
+			return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+				HttpStatusCode: http.StatusUnprocessableEntity,
+				ErrorCode:      respayload.ErrorCodeUserCantBeDeleted,
+				Message:        fmt.Sprintf("db error when verifying 2FA code %s", err.Error()),
+			})
+		}
+	}
+
+	// the organizations are deleted along with the user, or nothing is deleted
+	err = s.inTransaction(parent, func(tx Repositories) error {
+		var orphans []int64
+		orphans, res = s.organizationsLeftByDeletion(parent, tx.Organizations, User.ID)
+		if res != nil {
+			return errResponseReturned
+		}
+
+		for _, orgID := range orphans {
+			if _, err := tx.Organizations.Delete(parent, orgID); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Users.Delete(parent, User.ID)
+		return err
+	})
+
+	if res != nil {
+		return res
+	}
+
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserCantBeDeleted,
+			Message:        fmt.Sprintf("db error when deleting account %s", err.Error()),
+		})
+	}
+
+	s.logAuthEvent(parent, model.AuthEventUserDeleted, nil, User.Username, ip)
+	return newJSONResponse(http.StatusOK, respayload.DeleteAccount{
+		Message: "account is deleted",
+	})
+}
+
+// organizationsLeftByDeletion returns the organizations where the user is the only member, they are deleted along with the user.
+// It returns the 409 response when the user is the last owner of the organization which has other member.
+func (s *Server) organizationsLeftByDeletion(parent context.Context, organizations repo.OrganizationRepository, userID int64) ([]int64, Response) {
+	Memberships, err := organizations.GetMembershipsByUserID(parent, userID)
+	if err != nil {
+		return nil, newOrganizationDBErrorResponse(err, respayload.ErrorCodeOrgDBError, "get organizations")
+	}
+
+	var orphans []int64
+	for _, Membership := range Memberships {
+		if !Membership.CanManage() {
+			continue
+		}
+
+		Members, err := organizations.GetMembers(parent, Membership.OrgID)
+		if err != nil {
+			return nil, newOrganizationDBErrorResponse(err, respayload.ErrorCodeOrgDBError, "get members")
+		}
+
+		owners := 0
+		for _, Member := range Members {
+			if Member.CanManage() {
+				owners++
+			}
+		}
+
+		switch {
+		case len(Members) == 1:
+			orphans = append(orphans, Membership.OrgID)
+		case owners == 1:
+			return nil, newJSONResponse(http.StatusConflict, respayload.Error{
+				HttpStatusCode: http.StatusConflict,
+				ErrorCode:      respayload.ErrorCodeOrgLastOwnerRequired,
+				Message:        fmt.Sprintf("you are the last owner of organization %d, invite another owner first", Membership.OrgID),
+			})
+		}
+	}
+
+	return orphans, nil
+}
+
+// normalizeProfileField applies fn to the sent field, nil means the field is not sent.
+func normalizeProfileField(field *string, fn func(string) string) *string {
+	if field == nil {
+		return nil
+	}
+
+	value := fn(strings.TrimSpace(*field))
+	return &value
+}
+
+// setProfileField replaces the profile field with the sent one, empty value removes it.
+func setProfileField(profileField **string, field *string) {
+	switch {
+	case field == nil:
+		return
+	case *field == "":
+		*profileField = nil
+	default:
+		*profileField = field
+	}
+}
+
+func newProfileResponse(User *model.User) *respayload.Profile {
+	return &respayload.Profile{
+		User:             *newUserResponse(User),
+		DisplayName:      User.DisplayName,
+		Locale:           User.Locale,
+		DefaultCurrency:  User.DefaultCurrency,
+		Jurisdiction:     User.Jurisdiction,
+		TwoFactorEnabled: User.TwoFactorEnabled(),
+		Roles:            User.Roles,
+		CreatedAt:        User.CreatedAt,
+	}
+}
+
+// Change password
+// @Summary Change password of current user
+// @Description Change the password, the current password is required. Every session is logged out and every authentication
+// @Description token issued before is rejected, so the response has the new session for the current client.
+// @Description Wrong current password is counted like failed login.
+// @ID me-change-password
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param password body reqpayload.ChangePassword true "current and new password"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.ChangePassword
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 429 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /me/password [put]
+func (s *Server) changePassword(parent context.Context, req Request) Response {
+	form := &reqpayload.ChangePassword{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	User, res := s.verifyCurrentPassword(parent, req, form.CurrentPassword)
+	if res != nil {
+		return res
+	}
+
+	if err := s.conf.PasswordPolicy.Validate(User.Username, form.NewPassword); err != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        fmt.Sprintf("new_password: %s", err.Error()),
+		})
+	}
+
+	password, err := auth.HashPassword(form.NewPassword)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserCantBeCreated,
+			Message:        fmt.Sprintf("fail when hashing your password %s", err.Error()),
+		})
+	}
+
+	// the old sessions are revoked in the same transaction, so they can't be used when the password is not changed,
+	// and the new session is only created after both are committed
+	err = s.inTransaction(parent, func(tx Repositories) error {
+		if User, err = tx.Users.UpdatePassword(parent, User.ID, password, time.Now()); err != nil {
+			return err
+		}
+
+		return auth.NewSessions(s.conf.Tokens, tx.RefreshTokens).RevokeAll(parent, User.ID)
+	})
+
+	var session *auth.Session
+	if err == nil {
+		session, err = s.sessions.Create(parent, User.ID)
+	}
+
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserSessionDBError,
+			Message:        fmt.Sprintf("db error when changing password %s", err.Error()),
+		})
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.ChangePassword{
+		AuthenticationToken: session.AccessToken,
+		RefreshToken:        session.RefreshToken,
+		ExpiresIn:           int64(session.ExpiresIn.Seconds()),
+		User:                newUserResponse(User),
+	})
+}
+
+// verifyCurrentPassword checks the password of the current user before changing the security settings,
+// so the stolen authentication token is not enough to take over the account. Wrong password is counted like failed login.
+// It returns the current user with the credentials, see userWithCredentials.
+func (s *Server) verifyCurrentPassword(parent context.Context, req Request, password string) (*model.User, Response) {
+	User := req.User()
+	ip := s.clientIP(req.RawRequest())
+	if res := s.checkLoginThrottle(User.Username, ip); res != nil {
+		return nil, res
+	}
+
+	User, res := s.userWithCredentials(parent, req)
+	if res != nil {
+		return nil, res
+	}
+
+	if !checkPassword(password, User.Password) {
+		s.countLoginFailure(parent, model.AuthEventLoginFailed, &User.ID, User.Username, ip)
+		return nil, newJSONResponse(http.StatusUnauthorized, respayload.Error{
+			HttpStatusCode: http.StatusUnauthorized,
+			ErrorCode:      respayload.ErrorCodeUserWrongPassword,
+			Message:        "current password is wrong",
+		})
+	}
+
+	return User, nil
+}
+
+// userWithCredentials reads the current user again with the password hash and the TOTP secret,
+// since the user set by the middleware may be cached without them. The roles of the current user are kept.
+func (s *Server) userWithCredentials(parent context.Context, req Request) (*model.User, Response) {
+	current := req.User()
+	User, err := s.users.FindCredentialsByID(parent, current.ID)
+	switch {
+	case err == db.ErrCircuitOpen:
+		return nil, newDatabaseUnavailableResponse()
+	case err == db.ErrNoRows, err == nil && (User == nil || User.ID == 0):
+		return nil, newJSONResponse(http.StatusUnauthorized, respayload.Error{
+			HttpStatusCode: http.StatusUnauthorized,
+			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
+			Message:        "related user is not exist in db anymore",
+		})
+	case err != nil:
+		return nil, newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
+			Message:        fmt.Sprintf("db error when find user %s", err.Error()),
+		})
+	}
+
+	User.Roles, User.Permissions = current.Roles, current.Permissions
+	return User, nil
+}
@@ -4,18 +4,30 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"fmt"
 
 	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
-	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/user"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
 	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/respayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
 )
 
-func middlewareAuthTokenCheck(next Handler) Handler {
+// accessToken reads the token from the standard "Authorization: Bearer" header,
+// or from the "Authentication-Token" header which is used by the older clients.
+func accessToken(r *http.Request) string {
+	authorization := strings.TrimSpace(r.Header.Get("Authorization"))
+	if len(authorization) > len("Bearer ") && strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(authorization[len("Bearer "):])
+	}
+
+	return strings.TrimSpace(r.Header.Get("Authentication-Token"))
+}
+
+func (s *Server) middlewareAuthTokenCheck(next Handler) Handler {
 	return func(parent context.Context, req Request) Response {
-		accessToken := req.RawRequest().Header.Get("Authentication-Token")
-		accessToken = strings.TrimSpace(accessToken)
+		accessToken := accessToken(req.RawRequest())
 
 		if accessToken == "" {
 			return newJSONResponse(http.StatusUnauthorized, respayload.Error{
@@ -25,29 +37,84 @@ func middlewareAuthTokenCheck(next Handler) Handler {
 			})
 		}
 
-		userID, err := auth.ValidateJWTToken(parent, secretKey, accessToken)
-		if err != nil {
-			return newJSONResponse(http.StatusUnauthorized, respayload.Error{
-				HttpStatusCode: http.StatusUnauthorized,
-				ErrorCode:      respayload.ErrorCodeUserWrongAuthToken,
-				Message:        fmt.Sprintf("wrong auth token, %s", err.Error()),
-			})
-		}
+		var User *model.User
+		var err error
+		if auth.IsAPIKey(accessToken) {
+			var APIKey *model.APIKey
+			APIKey, err = s.apiKeys.Authenticate(parent, accessToken)
+			if err == db.ErrCircuitOpen {
+				return newDatabaseUnavailableResponse()
+			}
+
+			if err != nil {
+				return newJSONResponse(http.StatusUnauthorized, respayload.Error{
+					HttpStatusCode: http.StatusUnauthorized,
+					ErrorCode:      respayload.ErrorCodeUserWrongAuthToken,
+					Message:        fmt.Sprintf("wrong api key, %s", err.Error()),
+				})
+			}
 
-		User, err := user.FindByID(parent, userID)
-		if User == nil || User.ID == 0 {
+			req.SetAPIKey(APIKey)
+			User, err = s.users.FindByID(parent, APIKey.UserID)
+		} else {
+			var claims *auth.Claims
+			claims, err = s.conf.Tokens.ValidateJWTToken(parent, accessToken)
+			if err != nil {
+				return newJSONResponse(http.StatusUnauthorized, respayload.Error{
+					HttpStatusCode: http.StatusUnauthorized,
+					ErrorCode:      respayload.ErrorCodeUserWrongAuthToken,
+					Message:        fmt.Sprintf("wrong auth token, %s", err.Error()),
+				})
+			}
+
+			// user from the external issuer is mapped into local user, which is created on the first request
+			if claims.External() {
+				User, err = s.identities.Resolve(parent, claims)
+			} else {
+				User, err = s.users.FindByID(parent, claims.UserID)
+			}
+
+			// token issued before the password is changed is rejected, "iat" is in seconds so the time is truncated
+			if err == nil && User != nil && !claims.External() && User.PasswordChangedAt != nil &&
+				claims.IssuedAt.Before(User.PasswordChangedAt.Truncate(time.Second)) {
+				return newJSONResponse(http.StatusUnauthorized, respayload.Error{
+					HttpStatusCode: http.StatusUnauthorized,
+					ErrorCode:      respayload.ErrorCodeUserWrongAuthToken,
+					Message:        "wrong auth token, it's issued before the password is changed",
+				})
+			}
+		}
+		switch {
+		case err == db.ErrCircuitOpen:
+			return newDatabaseUnavailableResponse()
+		case err == db.ErrNoRows, err == nil && (User == nil || User.ID == 0):
 			return newJSONResponse(http.StatusUnauthorized, respayload.Error{
 				HttpStatusCode: http.StatusUnauthorized,
 				ErrorCode:      respayload.ErrorCodeUserCantBeFound,
 				Message:        "related user is not exist in db anymore",
 			})
+		case err != nil:
+			return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+				HttpStatusCode: http.StatusUnprocessableEntity,
+				ErrorCode:      respayload.ErrorCodeUserSessionDBError,
+				Message:        fmt.Sprintf("db error when get user from token, %s", err.Error()),
+			})
+		}
+
+		if User.Disabled() {
+			return newUserDisabledResponse()
+		}
+
+		err = s.roles.Load(parent, User)
+		if err == db.ErrCircuitOpen {
+			return newDatabaseUnavailableResponse()
 		}
 
 		if err != nil {
-			return newJSONResponse(http.StatusUnauthorized, respayload.Error{
-				HttpStatusCode: http.StatusUnauthorized,
+			return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+				HttpStatusCode: http.StatusUnprocessableEntity,
 				ErrorCode:      respayload.ErrorCodeUserCantBeFound,
-				Message:        fmt.Sprintf("db error when get user from token, %s", err.Error()),
+				Message:        fmt.Sprintf("db error when get roles of user, %s", err.Error()),
 			})
 		}
 
@@ -58,3 +125,60 @@ func middlewareAuthTokenCheck(next Handler) Handler {
 		return next(parent, req)
 	}
 }
+
+// middlewareRequireScope rejects the request using API key which is not granted the scope.
+// Request using authentication token is allowed to do anything the user can do, so it's not checked.
+// It must be chained after middlewareAuthTokenCheck.
+func (s *Server) middlewareRequireScope(scope string) Middleware {
+	return func(next Handler) Handler {
+		return func(parent context.Context, req Request) Response {
+			if APIKey := req.APIKey(); APIKey != nil && !APIKey.HasScope(scope) {
+				return newJSONResponse(http.StatusForbidden, respayload.Error{
+					HttpStatusCode: http.StatusForbidden,
+					ErrorCode:      respayload.ErrorCodeAPIKeyScopeDenied,
+					Message:        fmt.Sprintf("api key is not granted %s scope", scope),
+				})
+			}
+
+			return next(parent, req)
+		}
+	}
+}
+
+// RequirePermission rejects the user which roles don't grant every given permission.
+// It must be chained after middlewareAuthTokenCheck, which loads the permissions of the user.
+func RequirePermission(permissions ...string) Middleware {
+	return func(next Handler) Handler {
+		return func(parent context.Context, req Request) Response {
+			User := req.User()
+			for _, permission := range permissions {
+				if User == nil || !User.HasPermission(permission) {
+					return newJSONResponse(http.StatusForbidden, respayload.Error{
+						HttpStatusCode: http.StatusForbidden,
+						ErrorCode:      respayload.ErrorCodeUserPermissionDenied,
+						Message:        fmt.Sprintf("user is not granted %s permission", permission),
+					})
+				}
+			}
+
+			return next(parent, req)
+		}
+	}
+}
+
+// middlewareRejectAPIKey only allows the request using authentication token, such as to manage the API keys,
+// so a leaked API key can't be used to create another key or to logout the user.
+// It must be chained after middlewareAuthTokenCheck.
+func (s *Server) middlewareRejectAPIKey(next Handler) Handler {
+	return func(parent context.Context, req Request) Response {
+		if req.APIKey() != nil {
+			return newJSONResponse(http.StatusForbidden, respayload.Error{
+				HttpStatusCode: http.StatusForbidden,
+				ErrorCode:      respayload.ErrorCodeAPIKeyScopeDenied,
+				Message:        "api key can't be used for this endpoint, use the authentication token from login",
+			})
+		}
+
+		return next(parent, req)
+	}
+}
@@ -0,0 +1,425 @@
+package restapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/reqpayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/respayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/validator"
+)
+
+// Create organization
+// @Summary Create organization
+// @Description Create organization to share the tax ledger, the current user becomes its owner.
+// @ID org-create
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param organization body reqpayload.CreateOrganization true "name of the organization"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.Organization
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /orgs [post]
+func (s *Server) createOrganization(parent context.Context, req Request) Response {
+	form := &reqpayload.CreateOrganization{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	form.Name = strings.TrimSpace(form.Name)
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	Organization, err := s.organizations.Create(parent, form.Name)
+	if err != nil {
+		return newOrganizationDBErrorResponse(err, respayload.ErrorCodeOrgCantBeCreated, "creating organization")
+	}
+
+	Member, err := s.organizations.AddMember(parent, Organization.ID, req.User().ID, model.OrgRoleOwner)
+	if err != nil {
+		return newOrganizationDBErrorResponse(err, respayload.ErrorCodeOrgCantBeCreated, "adding the owner")
+	}
+
+	return newJSONResponse(http.StatusOK, newOrganizationResponse(Organization, Member))
+}
+
+// List organizations
+// @Summary List organizations of current user
+// @Description List organizations the current user belongs to, including the role of the user in each of them.
+// @ID org-list
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.Organizations
+// @Failure 401 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /orgs [get]
+func (s *Server) listOrganizations(parent context.Context, req Request) Response {
+	Members, err := s.organizations.GetMembershipsByUserID(parent, req.User().ID)
+	if err != nil {
+		return newOrganizationDBErrorResponse(err, respayload.ErrorCodeOrgDBError, "get organizations")
+	}
+
+	organizations := []*respayload.Organization{}
+	for _, Member := range Members {
+		Organization, err := s.organizations.FindByID(parent, Member.OrgID)
+		if err != nil {
+			return newOrganizationDBErrorResponse(err, respayload.ErrorCodeOrgDBError, "get organizations")
+		}
+
+		organizations = append(organizations, newOrganizationResponse(Organization, Member))
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.Organizations{
+		Organizations: organizations,
+	})
+}
+
+// List organization members
+// @Summary List members of organization
+// @Description List members of organization, every member can see it.
+// @ID org-members-list
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param id path int true "id of the organization"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.OrganizationMembers
+// @Failure 401 {object} respayload.Error
+// @Failure 404 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /orgs/{id}/members [get]
+func (s *Server) listOrganizationMembers(parent context.Context, req Request) Response {
+	Member, res := s.organizationMemberByParam(parent, req)
+	if res != nil {
+		return res
+	}
+
+	Members, err := s.organizations.GetMembers(parent, Member.OrgID)
+	if err != nil {
+		return newOrganizationDBErrorResponse(err, respayload.ErrorCodeOrgDBError, "get members")
+	}
+
+	members := []*respayload.OrganizationMember{}
+	for _, Member := range Members {
+		User, err := s.users.FindByID(parent, Member.UserID)
+		if err != nil {
+			return newOrganizationDBErrorResponse(err, respayload.ErrorCodeOrgDBError, "get members")
+		}
+
+		members = append(members, &respayload.OrganizationMember{
+			User:     newUserResponse(User),
+			Role:     Member.Role,
+			JoinedAt: Member.CreatedAt,
+		})
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.OrganizationMembers{
+		Members: members,
+	})
+}
+
+// Remove organization member
+// @Summary Remove member of organization
+// @Description Owner can remove any member, and every member can leave the organization by removing themself.
+// @Description The last owner can't be removed, so the organization is always managed by someone.
+// @ID org-members-remove
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param id path int true "id of the organization"
+// @Param user_id path int true "id of the user"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.RemoveMember
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 404 {object} respayload.Error
+// @Failure 409 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /orgs/{id}/members/{user_id} [delete]
+func (s *Server) removeOrganizationMember(parent context.Context, req Request) Response {
+	Member, res := s.organizationMemberByParam(parent, req)
+	if res != nil {
+		return res
+	}
+
+	userID, err := strconv.ParseInt(req.GetParam("user_id"), 10, 64)
+	if err != nil {
+		return newJSONResponse(http.StatusNotFound, respayload.Error{
+			HttpStatusCode: http.StatusNotFound,
+			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
+			Message:        fmt.Sprintf("invalid user id %s", req.GetParam("user_id")),
+		})
+	}
+
+	if userID != Member.UserID && !Member.CanManage() {
+		return newOrganizationPermissionDeniedResponse(Member)
+	}
+
+	// the members are locked while counting the owners, so two owners removing each other at the same time
+	// can't leave the organization without owner
+	err = s.inTransaction(parent, func(tx Repositories) error {
+		Members, err := tx.Organizations.LockMembers(parent, Member.OrgID)
+		if err != nil {
+			return err
+		}
+
+		var Target *model.OrganizationMember
+		owners := 0
+		for _, m := range Members {
+			if m.UserID == userID {
+				Target = m
+			}
+
+			if m.CanManage() {
+				owners++
+			}
+		}
+
+		if Target == nil {
+			return db.ErrNoRows
+		}
+
+		if Target.CanManage() && owners <= 1 {
+			res = newJSONResponse(http.StatusConflict, respayload.Error{
+				HttpStatusCode: http.StatusConflict,
+				ErrorCode:      respayload.ErrorCodeOrgLastOwnerRequired,
+				Message:        "the last owner can't be removed, invite another owner first",
+			})
+			return errResponseReturned
+		}
+
+		_, err = tx.Organizations.RemoveMember(parent, Member.OrgID, userID)
+		return err
+	})
+
+	switch {
+	case res != nil:
+		return res
+	case err == db.ErrNoRows:
+		return newJSONResponse(http.StatusNotFound, respayload.Error{
+			HttpStatusCode: http.StatusNotFound,
+			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
+			Message:        fmt.Sprintf("user %d is not the member of the organization", userID),
+		})
+	case err != nil:
+		return newOrganizationDBErrorResponse(err, respayload.ErrorCodeOrgDBError, "removing member")
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.RemoveMember{
+		Message: "member is removed",
+	})
+}
+
+// Invite organization member
+// @Summary Invite user into organization
+// @Description Send the invitation token to the email, any logged in user who has the token can join the organization once.
+// @Description Only the owner can invite.
+// @ID org-invitations-create
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param id path int true "id of the organization"
+// @Param invitation body reqpayload.InviteMember true "email and role of the invited user"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.InviteMember
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 404 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /orgs/{id}/invitations [post]
+func (s *Server) inviteMember(parent context.Context, req Request) Response {
+	form := &reqpayload.InviteMember{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	form.Email = strings.TrimSpace(form.Email)
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	Member, res := s.organizationMemberByParam(parent, req)
+	if res != nil {
+		return res
+	}
+
+	if !Member.CanManage() {
+		return newOrganizationPermissionDeniedResponse(Member)
+	}
+
+	Organization, err := s.organizations.FindByID(parent, Member.OrgID)
+	if err != nil {
+		return newOrganizationDBErrorResponse(err, respayload.ErrorCodeOrgDBError, "find organization")
+	}
+
+	Invitation, err := s.invitations.Invite(parent, Organization, req.User(), form.Email, form.Role)
+	if err != nil {
+		return newOrganizationDBErrorResponse(err, respayload.ErrorCodeOrgDBError, "sending invitation")
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.InviteMember{
+		ID:        Invitation.ID,
+		Email:     Invitation.Email,
+		Role:      Invitation.Role,
+		ExpiresAt: Invitation.ExpiresAt,
+	})
+}
+
+// Accept organization invitation
+// @Summary Accept invitation into organization
+// @Description Join the organization using the token sent to the email, the token can only be used once before it expires.
+// @ID org-invitations-accept
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param invitation body reqpayload.AcceptInvitation true "token from the email"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.Organization
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 409 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /invitations/accept [post]
+func (s *Server) acceptInvitation(parent context.Context, req Request) Response {
+	form := &reqpayload.AcceptInvitation{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	// the invitation is used and the member is added in one transaction, so the invitation is not used up
+	// when adding the member fails
+	var Member *model.OrganizationMember
+	err = s.inTransaction(parent, func(tx Repositories) error {
+		invitations := auth.NewInvitations(tx.Organizations, tx.Invitations, s.conf.Mailer, s.conf.Invitation)
+		Member, err = invitations.Accept(parent, form.Token, req.User())
+		return err
+	})
+	switch {
+	case err == auth.ErrInvitationInvalid:
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorCodeOrgWrongInvitation,
+			Message:        err.Error(),
+		})
+	case err == auth.ErrAlreadyMember:
+		return newJSONResponse(http.StatusConflict, respayload.Error{
+			HttpStatusCode: http.StatusConflict,
+			ErrorCode:      respayload.ErrorCodeOrgAlreadyMember,
+			Message:        err.Error(),
+		})
+	case err != nil:
+		return newOrganizationDBErrorResponse(err, respayload.ErrorCodeOrgDBError, "accepting invitation")
+	}
+
+	Organization, err := s.organizations.FindByID(parent, Member.OrgID)
+	if err != nil {
+		return newOrganizationDBErrorResponse(err, respayload.ErrorCodeOrgDBError, "find organization")
+	}
+
+	return newJSONResponse(http.StatusOK, newOrganizationResponse(Organization, Member))
+}
+
+// organizationMemberByParam returns the membership of current user in the organization of the id in the URL.
+func (s *Server) organizationMemberByParam(parent context.Context, req Request) (*model.OrganizationMember, Response) {
+	orgID, err := strconv.ParseInt(req.GetParam("id"), 10, 64)
+	if err != nil {
+		return nil, newJSONResponse(http.StatusNotFound, respayload.Error{
+			HttpStatusCode: http.StatusNotFound,
+			ErrorCode:      respayload.ErrorCodeOrgCantBeFound,
+			Message:        fmt.Sprintf("invalid organization id %s", req.GetParam("id")),
+		})
+	}
+
+	return s.organizationMember(parent, orgID, req.User().ID)
+}
+
+// organizationMember returns the membership of the user in the organization.
+// Non member gets 404 instead of 403, so the id of other organization can't be guessed.
+func (s *Server) organizationMember(parent context.Context, orgID, userID int64) (*model.OrganizationMember, Response) {
+	Member, err := s.organizations.FindMember(parent, orgID, userID)
+	switch {
+	case err == db.ErrNoRows:
+		return nil, newJSONResponse(http.StatusNotFound, respayload.Error{
+			HttpStatusCode: http.StatusNotFound,
+			ErrorCode:      respayload.ErrorCodeOrgCantBeFound,
+			Message:        fmt.Sprintf("organization %d is not found", orgID),
+		})
+	case err != nil:
+		return nil, newOrganizationDBErrorResponse(err, respayload.ErrorCodeOrgDBError, "find organization member")
+	}
+
+	return Member, nil
+}
+
+func newOrganizationPermissionDeniedResponse(Member *model.OrganizationMember) Response {
+	return newJSONResponse(http.StatusForbidden, respayload.Error{
+		HttpStatusCode: http.StatusForbidden,
+		ErrorCode:      respayload.ErrorCodeOrgPermissionDenied,
+		Message:        fmt.Sprintf("%s of the organization is not allowed to do this", Member.Role),
+	})
+}
+
+func newOrganizationDBErrorResponse(err error, code respayload.ErrorCode, action string) Response {
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
+	return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+		HttpStatusCode: http.StatusUnprocessableEntity,
+		ErrorCode:      code,
+		Message:        fmt.Sprintf("db error when %s %s", action, err.Error()),
+	})
+}
+
+func newOrganizationResponse(Organization *model.Organization, Member *model.OrganizationMember) *respayload.Organization {
+	return &respayload.Organization{
+		ID:        Organization.ID,
+		Name:      Organization.Name,
+		Role:      Member.Role,
+		CreatedAt: Organization.CreatedAt,
+	}
+}
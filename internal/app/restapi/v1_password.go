@@ -0,0 +1,178 @@
+package restapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/reqpayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/respayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/validator"
+)
+
+// Forgot password
+// @Summary Send password reset link
+// @Description Send the password reset link to the email, the token in the link can be used once before it expires.
+// @Description The response is the same whether the email is registered or not.
+// @ID password-forgot
+// @Param email body reqpayload.ForgotPassword true "email of the user"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.ForgotPassword
+// @Failure 400 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /password/forgot [post]
+func (s *Server) forgotPassword(parent context.Context, req Request) Response {
+	form := &reqpayload.ForgotPassword{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	form.Email = strings.TrimSpace(form.Email)
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	err = s.passwordResets.Request(parent, form.Email)
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
+	// the other error, such as failing to send the email, is only logged since it only happens for registered email
+	if err != nil {
+		logger.Error().Err(err).Msg("fail to send password reset token")
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.ForgotPassword{
+		Message: "if the email is registered, the password reset link is sent to it",
+	})
+}
+
+// Reset password
+// @Summary Reset password using the reset token
+// @Description Set a new password using the token sent to the email, the new password must follow the password policy.
+// @Description Every session is logged out and every authentication token issued before is rejected.
+// @ID password-reset
+// @Param password body reqpayload.ResetPassword true "reset token and new password"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.ResetPassword
+// @Failure 400 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /password/reset [post]
+func (s *Server) resetPassword(parent context.Context, req Request) Response {
+	form := &reqpayload.ResetPassword{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	form.Token = strings.TrimSpace(form.Token)
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	var User *model.User
+	PasswordResetToken, err := s.passwordResets.Verify(parent, form.Token)
+	if err == nil {
+		User, err = s.users.FindByID(parent, PasswordResetToken.UserID)
+	}
+
+	switch {
+	case err == db.ErrCircuitOpen:
+		return newDatabaseUnavailableResponse()
+	case err == auth.ErrPasswordResetTokenInvalid:
+		return newInvalidResetTokenResponse()
+	case err != nil:
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserSessionDBError,
+			Message:        fmt.Sprintf("db error when checking reset token %s", err.Error()),
+		})
+	}
+
+	if err := s.conf.PasswordPolicy.Validate(User.Username, form.NewPassword); err != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        fmt.Sprintf("new_password: %s", err.Error()),
+		})
+	}
+
+	password, err := auth.HashPassword(form.NewPassword)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserCantBeCreated,
+			Message:        fmt.Sprintf("fail when hashing your password %s", err.Error()),
+		})
+	}
+
+	// the token is consumed after the new password passes the policy, so the user can retry using the same link.
+	// It's done in one transaction, so the token is not used up when the password is not changed.
+	err = s.inTransaction(parent, func(tx Repositories) error {
+		passwordResets := auth.NewPasswordResets(tx.Users, tx.PasswordResets, s.conf.Mailer, s.conf.PasswordReset)
+		if err := passwordResets.Consume(parent, PasswordResetToken); err != nil {
+			return err
+		}
+
+		if _, err := tx.Users.UpdatePassword(parent, User.ID, password, time.Now()); err != nil {
+			return err
+		}
+
+		return auth.NewSessions(s.conf.Tokens, tx.RefreshTokens).RevokeAll(parent, User.ID)
+	})
+
+	switch {
+	case err == db.ErrCircuitOpen:
+		return newDatabaseUnavailableResponse()
+	case err == auth.ErrPasswordResetTokenInvalid:
+		return newInvalidResetTokenResponse()
+	case err != nil:
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserSessionDBError,
+			Message:        fmt.Sprintf("db error when resetting password %s", err.Error()),
+		})
+	}
+
+	// the user proves the ownership of the email, so the username is not locked anymore
+	s.loginThrottle.Succeed(User.Username)
+	s.logAuthEvent(parent, model.AuthEventPasswordReset, &User.ID, User.Username, s.clientIP(req.RawRequest()))
+
+	return newJSONResponse(http.StatusOK, respayload.ResetPassword{
+		Message: "password is reset, login using the new password",
+	})
+}
+
+func newInvalidResetTokenResponse() Response {
+	return newJSONResponse(http.StatusBadRequest, respayload.Error{
+		HttpStatusCode: http.StatusBadRequest,
+		ErrorCode:      respayload.ErrorCodeUserWrongResetToken,
+		Message:        auth.ErrPasswordResetTokenInvalid.Error(),
+	})
+}
@@ -2,8 +2,14 @@ package restapi
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,6 +17,12 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/swaggo/gin-swagger"
 	"github.com/swaggo/gin-swagger/swaggerFiles"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/mail"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/metrics"
 
 	// This package must be imported to make swaggerFiles working.
 	_ "github.com/yusufsyaifudin/tax-calculator-example/docs"
@@ -20,16 +32,102 @@ import (
 type Config struct {
 	Address string
 	Test    bool
+	Tokens  *auth.Tokens // generates and validates the authentication token
+
+	// PasswordPolicy checks the password when the user registers or changes the password.
+	PasswordPolicy *auth.PasswordPolicy
+
+	// LoginThrottle limits the failed login per username and per IP address.
+	LoginThrottle auth.LoginThrottleConfig
+
+	// TwoFactorIssuer is the name of this application shown in the authenticator app.
+	TwoFactorIssuer string
+
+	// Mailer sends the password reset token and the organization invitation.
+	Mailer mail.Mailer
+
+	// PasswordReset is the lifetime of the password reset token and the link sent to the user.
+	PasswordReset auth.PasswordResetConfig
+
+	// Invitation is the lifetime of the organization invitation and the link sent to the invited user.
+	Invitation auth.InvitationConfig
+
+	// TrustedProxyHeader is the header containing the client IP address set by the reverse proxy, such as X-Real-IP.
+	// When it's empty, the IP address of the connection is used, since the header can be forged by the client.
+	TrustedProxyHeader string
+
+	// DB is pinged by the readiness and health endpoints, the check is skipped when it's nil.
+	DB db.SQL
+
+	// CheckSchema returns error when the database schema is older than this binary, it's checked by the readiness endpoint.
+	CheckSchema func() error
+
+	// HealthChecks are the optional dependencies listed by the health endpoint, such as the Redis cache.
+	// The server is still ready when they're down, since it works without them.
+	HealthChecks map[string]func(ctx context.Context) error
+
+	// ShutdownDelay is how long the server keeps serving after the readiness endpoint turns 503 on Shutdown,
+	// so the load balancer stops sending the request before the listener is closed.
+	ShutdownDelay time.Duration
+
+	// AccessLog is where every request except the health probes is logged, it's gin.DefaultWriter when it's nil.
+	// The request is not logged in Test mode unless it's set.
+	AccessLog io.Writer
+}
+
+// Repositories are the data sources used by the handlers.
+type Repositories struct {
+	Users          repo.UserRepository
+	Taxes          repo.TaxRepository
+	RefreshTokens  repo.RefreshTokenRepository
+	Identities     repo.IdentityRepository
+	APIKeys        repo.APIKeyRepository
+	AuthEvents     repo.AuthEventRepository
+	PasswordResets repo.PasswordResetRepository
+	RecoveryCodes  repo.RecoveryCodeRepository
+	Roles          repo.RoleRepository
+	Organizations  repo.OrganizationRepository
+	Invitations    repo.InvitationRepository
+
+	// Transaction runs fn in a database transaction, the repositories given to fn use that transaction.
+	// It's committed when fn returns nil, otherwise it's rolled back. The handler needing it fails when it's nil.
+	Transaction func(ctx context.Context, fn func(tx Repositories) error) error
 }
 
-var conf *Config
 var logger = log.With().Str("pkg", "restapi").Caller().Logger()
-var stopped = false
 
-// Router is a gin.Engine type. This exported because we need to run a httptest server in integration test (main_test.go).
-var Router *gin.Engine
+func init() {
+	gin.SetMode(gin.ReleaseMode)
+}
+
+// Server is the REST API server. Every dependency is passed into NewServer,
+// so many servers with different configuration can run in one process.
+type Server struct {
+	conf           *Config
+	router         *gin.Engine
+	httpServer     *http.Server
+	routes         routeMatcher
+	users          repo.UserRepository
+	taxes          repo.TaxRepository
+	authEvents     repo.AuthEventRepository
+	organizations  repo.OrganizationRepository
+	sessions       *auth.Sessions
+	identities     *auth.Identities
+	apiKeys        *auth.APIKeys
+	passwordResets *auth.PasswordResets
+	twoFactor      *auth.TwoFactor
+	roles          *auth.Roles
+	invitations    *auth.Invitations
+	loginThrottle  *auth.LoginThrottle
+	transaction    func(ctx context.Context, fn func(tx Repositories) error) error
+	notReady       int32 // set to 1 when Shutdown is called, it's read by every request so use atomic
+	stopped        int32 // set to 1 when Shutdown stops receiving requests after the ShutdownDelay
+
+	schemaMu        sync.Mutex
+	schemaCheckedAt time.Time // last successful CheckSchema, zero when it's not checked yet or failed
+}
 
-// init will initiating some logic when this file is called.
+// NewServer creates the server and registers its routes.
 // @title Tax Calculator Example
 // @version 1.0
 // @description This is a sample API to generate tax foreach user.
@@ -37,24 +135,48 @@ var Router *gin.Engine
 // @license.url http://www.apache.org/licenses/LICENSE-2.0.html
 // @host localhost:8080
 // @BasePath /api/v1
-func init() {
-	gin.SetMode(gin.ReleaseMode)
-	Router = gin.New()
-	Router.Use(middleware())
-}
-
-// Configure will start a listener to HTTP.
-func Configure(config *Config) {
-	conf = config
+func NewServer(config *Config, repos Repositories) *Server {
+	s := &Server{
+		conf:           config,
+		router:         gin.New(),
+		users:          repos.Users,
+		taxes:          repos.Taxes,
+		authEvents:     repos.AuthEvents,
+		organizations:  repos.Organizations,
+		transaction:    repos.Transaction,
+		sessions:       auth.NewSessions(config.Tokens, repos.RefreshTokens),
+		identities:     auth.NewIdentities(repos.Users, repos.Identities),
+		apiKeys:        auth.NewAPIKeys(repos.APIKeys),
+		passwordResets: auth.NewPasswordResets(repos.Users, repos.PasswordResets, config.Mailer, config.PasswordReset),
+		twoFactor:      auth.NewTwoFactor(repos.Users, repos.RecoveryCodes, config.TwoFactorIssuer),
+		roles:          auth.NewRoles(repos.Roles),
+		invitations:    auth.NewInvitations(repos.Organizations, repos.Invitations, config.Mailer, config.Invitation),
+		loginThrottle:  auth.NewLoginThrottle(config.LoginThrottle),
+	}
 
-	registerRoute()
+	s.httpServer = &http.Server{
+		Addr:    config.Address,
+		Handler: s.router,
+	}
 
 	if !config.Test {
 		logger.Level(zerolog.Disabled)
-		Router.Use(Logger())
 	}
 
-	for _, route := range Router.Routes() {
+	// the middleware must be added before the routes are registered, gin doesn't run it for the routes registered earlier
+	s.router.Use(s.httpMetrics(), s.middleware())
+	if accessLog := config.AccessLog; accessLog != nil || !config.Test {
+		if accessLog == nil {
+			accessLog = gin.DefaultWriter
+		}
+
+		s.router.Use(LoggerWithWriter(accessLog, healthPaths...))
+	}
+
+	s.registerRoute()
+	s.routes = newRouteMatcher(s.router.Routes())
+
+	for _, route := range s.router.Routes() {
 		if config.Test {
 			continue
 		}
@@ -65,37 +187,157 @@ func Configure(config *Config) {
 			Str("handler", route.Handler).
 			Msg("")
 	}
+
+	return s
 }
 
-// Run will start the server
-func Run() error {
-	return Router.Run(conf.Address)
+// Handler returns the http.Handler of this server. This is used to run a httptest server in integration test (main_test.go).
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+// Run will start the server, it returns nil once Shutdown is called.
+func (s *Server) Run() error {
+	listener, err := net.Listen("tcp", s.conf.Address)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+// Serve accepts the connections from the listener, it returns nil once Shutdown is called.
+func (s *Server) Serve(listener net.Listener) error {
+	err := s.httpServer.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+
+	return err
 }
 
 // registerRoute will register all route for this application.
-func registerRoute() {
+func (s *Server) registerRoute() {
 	parent := context.Background()
 
-	Router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	s.router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	s.router.GET("/metrics", gin.WrapH(metrics.DefaultRegistry.Handler()))
+	s.router.GET("/.well-known/jwks.json", WrapGin(parent, s.jwks))
+
+	// health endpoints are called by the orchestrator, they don't need authentication
+	s.router.GET("/healthz", WrapGin(parent, s.healthz))
+	s.router.GET("/readyz", WrapGin(parent, s.readyz))
+	s.router.GET("/health", WrapGin(parent, s.health))
+
+	v1 := s.router.Group("/api/v1")
+
+	// endpoint which accepts API key must tell which scope is needed, the others only accept the authentication token
+	sessionEndpointMiddleware := ChainMiddleware(s.middlewareAuthTokenCheck, s.middlewareRejectAPIKey)
+	scopedEndpointMiddleware := func(scope string) Middleware {
+		return ChainMiddleware(s.middlewareAuthTokenCheck, s.middlewareRequireScope(scope))
+	}
+
+	v1.POST("/register", WrapGin(parent, s.register))
+	v1.POST("/login", WrapGin(parent, s.login))
+	v1.POST("/login/2fa", WrapGin(parent, s.loginTwoFactor))
+	v1.POST("/token/refresh", WrapGin(parent, s.refreshToken))
+	v1.POST("/logout", WrapGin(parent, sessionEndpointMiddleware(s.logout)))
+	v1.POST("/logout/all", WrapGin(parent, sessionEndpointMiddleware(s.logoutAll)))
+
+	v1.POST("/password/forgot", WrapGin(parent, s.forgotPassword))
+	v1.POST("/password/reset", WrapGin(parent, s.resetPassword))
+	v1.GET("/me", WrapGin(parent, sessionEndpointMiddleware(s.getProfile)))
+	v1.PATCH("/me", WrapGin(parent, sessionEndpointMiddleware(s.updateProfile)))
+	v1.DELETE("/me", WrapGin(parent, sessionEndpointMiddleware(s.deleteAccount)))
+	v1.GET("/me/export", WrapGin(parent, sessionEndpointMiddleware(s.exportData)))
+	v1.PUT("/me/password", WrapGin(parent, sessionEndpointMiddleware(s.changePassword)))
+	v1.POST("/me/2fa", WrapGin(parent, sessionEndpointMiddleware(s.enrollTwoFactor)))
+	v1.POST("/me/2fa/confirm", WrapGin(parent, sessionEndpointMiddleware(s.confirmTwoFactor)))
+	v1.POST("/me/2fa/disable", WrapGin(parent, sessionEndpointMiddleware(s.disableTwoFactor)))
 
-	v1 := Router.Group("/api/v1")
+	v1.POST("/api-keys", WrapGin(parent, sessionEndpointMiddleware(s.createAPIKey)))
+	v1.GET("/api-keys", WrapGin(parent, sessionEndpointMiddleware(s.listAPIKeys)))
+	v1.DELETE("/api-keys/:id", WrapGin(parent, sessionEndpointMiddleware(s.revokeAPIKey)))
 
-	protectedEndpointMiddleware := ChainMiddleware(middlewareAuthTokenCheck)
+	// endpoint which needs permission other than the one every user has, the API key can't be granted these permissions
+	adminEndpointMiddleware := func(permission string) Middleware {
+		return ChainMiddleware(sessionEndpointMiddleware, RequirePermission(permission))
+	}
 
-	v1.POST("/register", WrapGin(parent, register))
-	v1.POST("/login", WrapGin(parent, login))
+	v1.POST("/tax", WrapGin(parent, ChainMiddleware(scopedEndpointMiddleware(model.ScopeTaxWrite), RequirePermission(model.PermissionTaxWrite))(s.createNewTax)))
+	v1.GET("/tax", WrapGin(parent, ChainMiddleware(scopedEndpointMiddleware(model.ScopeTaxRead), RequirePermission(model.PermissionTaxRead))(s.getTaxes)))
 
-	v1.POST("/tax", WrapGin(parent, protectedEndpointMiddleware(createNewTax)))
-	v1.GET("/tax", WrapGin(parent, protectedEndpointMiddleware(getTaxes)))
+	v1.POST("/orgs", WrapGin(parent, sessionEndpointMiddleware(s.createOrganization)))
+	v1.GET("/orgs", WrapGin(parent, sessionEndpointMiddleware(s.listOrganizations)))
+	v1.GET("/orgs/:id/members", WrapGin(parent, sessionEndpointMiddleware(s.listOrganizationMembers)))
+	v1.DELETE("/orgs/:id/members/:user_id", WrapGin(parent, sessionEndpointMiddleware(s.removeOrganizationMember)))
+	v1.POST("/orgs/:id/invitations", WrapGin(parent, sessionEndpointMiddleware(s.inviteMember)))
+	v1.POST("/invitations/accept", WrapGin(parent, sessionEndpointMiddleware(s.acceptInvitation)))
+
+	v1.GET("/admin/users", WrapGin(parent, adminEndpointMiddleware(model.PermissionUserRead)(s.adminListUsers)))
+	v1.GET("/admin/users/:id/taxes", WrapGin(parent, adminEndpointMiddleware(model.PermissionTaxReadAny)(s.adminGetUserTaxes)))
+	v1.POST("/admin/users/:id/disable", WrapGin(parent, adminEndpointMiddleware(model.PermissionUserDisable)(s.adminDisableUser)))
+}
+
+var (
+	// errNoTransaction is returned by inTransaction when Repositories.Transaction is not set.
+	errNoTransaction = errors.New("database transaction is not configured")
+
+	// errResponseReturned is returned by fn of inTransaction to roll back, when fn sets the error response to return.
+	errResponseReturned = errors.New("error response is returned")
+)
+
+// inTransaction runs fn with the repositories bound to one database transaction, see Repositories.Transaction.
+func (s *Server) inTransaction(parent context.Context, fn func(tx Repositories) error) error {
+	if s.transaction == nil {
+		return errNoTransaction
+	}
+
+	return s.transaction(parent, fn)
+}
+
+// clientIP returns the IP address of the client, it's read from TrustedProxyHeader when it's configured.
+// For list header such as X-Forwarded-For, the last address is used since it's appended by the proxy itself.
+func (s *Server) clientIP(r *http.Request) string {
+	if s.conf.TrustedProxyHeader != "" {
+		values := strings.Split(r.Header.Get(s.conf.TrustedProxyHeader), ",")
+		if ip := strings.TrimSpace(values[len(values)-1]); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
 }
 
 // Shutdown gracefully when some signal from OS tell that system should be down.
-func Shutdown() {
-	log.Info().Msg("not receiving requests anymore")
-	stopped = true
+// It marks the server not ready, and keeps serving for Config.ShutdownDelay while the load balancer notices it.
+// Then it stops accepting new connections and waits the in-flight requests until they're finished or ctx is done,
+// the error of ctx is returned when some requests are still running. Close the database after it returns.
+func (s *Server) Shutdown(ctx context.Context) error {
+	log.Info().Dur("delay", s.conf.ShutdownDelay).Msg("not ready anymore, still receiving requests until the delay passes")
+	atomic.StoreInt32(&s.notReady, 1)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(s.conf.ShutdownDelay):
+	}
+
+	log.Info().Msg("not receiving requests anymore, draining in-flight requests")
+	atomic.StoreInt32(&s.stopped, 1)
+	return s.httpServer.Shutdown(ctx)
+}
+
+// Ready returns false once Shutdown is called, so the load balancer stops sending requests before the drain.
+func (s *Server) Ready() bool {
+	return atomic.LoadInt32(&s.notReady) == 0
 }
 
-func middleware() gin.HandlerFunc {
+func (s *Server) middleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// handle panic
 		defer func() {
@@ -110,10 +352,16 @@ func middleware() gin.HandlerFunc {
 			}
 		}()
 
+		// health endpoints still answer while shutting down, and they're too frequent to be logged
+		if isHealthPath(ctx.Request.URL.Path) {
+			ctx.Next()
+			return
+		}
+
 		// check if flash is shutting down
 		// if it's the case then don't receive anymore requests
-		if stopped {
-			ctx.Status(http.StatusServiceUnavailable)
+		if atomic.LoadInt32(&s.stopped) == 1 {
+			ctx.AbortWithStatus(http.StatusServiceUnavailable)
 			return
 		}
 
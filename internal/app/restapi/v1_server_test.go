@@ -0,0 +1,123 @@
+package restapi
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestShutdown(t *testing.T) {
+	convey.Convey("Test Shutdown", t, func() {
+		s := NewServer(&Config{Test: true, ShutdownDelay: 200 * time.Millisecond}, Repositories{})
+
+		// the slow request is running until release is closed
+		started, release := make(chan struct{}), make(chan struct{})
+		s.router.GET("/slow", func(ctx *gin.Context) {
+			close(started)
+			<-release
+			ctx.Status(http.StatusOK)
+		})
+
+		s.router.GET("/fast", func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+		})
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		convey.So(err, convey.ShouldBeNil)
+
+		addr := "http://" + listener.Addr().String()
+		served := make(chan error, 1)
+		go func() {
+			served <- s.Serve(listener)
+		}()
+
+		status := make(chan int, 1)
+		go func() {
+			res, err := http.Get(addr + "/slow")
+			if err != nil {
+				status <- 0
+				return
+			}
+
+			res.Body.Close()
+			status <- res.StatusCode
+		}()
+
+		<-started
+		convey.So(s.Ready(), convey.ShouldBeTrue)
+
+		convey.Convey("In-flight request is finished before it returns", func() {
+			shutdown := make(chan error, 1)
+			go func() {
+				shutdown <- s.Shutdown(context.Background())
+			}()
+
+			// readiness fails while the slow request is still running, and the new request is still served during the delay
+			time.Sleep(50 * time.Millisecond)
+			convey.So(s.Ready(), convey.ShouldBeFalse)
+
+			res, err := http.Get(addr + "/readyz")
+			convey.So(err, convey.ShouldBeNil)
+			res.Body.Close()
+			convey.So(res.StatusCode, convey.ShouldEqual, http.StatusServiceUnavailable)
+
+			res, err = http.Get(addr + "/fast")
+			convey.So(err, convey.ShouldBeNil)
+			res.Body.Close()
+			convey.So(res.StatusCode, convey.ShouldEqual, http.StatusOK)
+			convey.So(shutdown, convey.ShouldHaveLength, 0)
+
+			// the drain waits for the slow request after the delay
+			time.Sleep(250 * time.Millisecond)
+			convey.So(shutdown, convey.ShouldHaveLength, 0)
+
+			close(release)
+			convey.So(<-shutdown, convey.ShouldBeNil)
+			convey.So(<-status, convey.ShouldEqual, http.StatusOK)
+			convey.So(<-served, convey.ShouldBeNil)
+		})
+
+		convey.Convey("Timeout returns the error of the context", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			err := s.Shutdown(ctx)
+			convey.So(err == context.DeadlineExceeded, convey.ShouldBeTrue)
+			convey.So(s.Ready(), convey.ShouldBeFalse)
+
+			close(release)
+			convey.So(<-status, convey.ShouldEqual, http.StatusOK)
+			convey.So(<-served, convey.ShouldBeNil)
+		})
+	})
+}
+
+func TestAccessLog(t *testing.T) {
+	convey.Convey("Test Access Log", t, func() {
+		accessLog := &bytes.Buffer{}
+		s := NewServer(&Config{Test: true, AccessLog: accessLog}, Repositories{})
+
+		get := func(path string) int {
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			return w.Code
+		}
+
+		convey.Convey("Registered route is logged", func() {
+			convey.So(get("/metrics"), convey.ShouldEqual, http.StatusOK)
+			convey.So(accessLog.String(), convey.ShouldContainSubstring, "/metrics")
+		})
+
+		convey.Convey("Health probe is not logged", func() {
+			convey.So(get("/healthz"), convey.ShouldEqual, http.StatusOK)
+			convey.So(accessLog.String(), convey.ShouldBeEmpty)
+		})
+	})
+}
@@ -6,15 +6,16 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/tax"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
 	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/reqpayload"
 	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/respayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
 	"github.com/yusufsyaifudin/tax-calculator-example/pkg/validator"
 )
 
 // Create new tax
 // @Summary Add tax record to your account
-// @Description Add tax record to your account
+// @Description Add tax record to your account, or to the organization when org_id is set. Viewer of the organization can't add the tax.
 // @ID create-tax
 //
 // @Param Authentication-Token header string true "Authentication-Token your-token"
@@ -23,9 +24,12 @@ import (
 // @Produce  json
 // @Success 200 {object} respayload.Tax
 // @Failure 400 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 404 {object} respayload.Error
 // @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
 // @Router /tax [post]
-func createNewTax(parent context.Context, req Request) Response {
+func (s *Server) createNewTax(parent context.Context, req Request) Response {
 	form := &reqpayload.CreateNewTax{}
 	err := req.Bind(form)
 	if err != nil {
@@ -48,7 +52,26 @@ func createNewTax(parent context.Context, req Request) Response {
 	}
 
 	// try inserting new tax to DB
-	Tax, err := tax.Create(parent, req.User().ID, form.Name, form.TaxCode, form.Price)
+	var Tax *model.Tax
+	if form.OrgID > 0 {
+		Member, res := s.organizationMember(parent, form.OrgID, req.User().ID)
+		if res != nil {
+			return res
+		}
+
+		if !Member.CanWriteTax() {
+			return newOrganizationPermissionDeniedResponse(Member)
+		}
+
+		Tax, err = s.taxes.CreateForOrganization(parent, form.OrgID, req.User().ID, form.Name, form.TaxCode, form.Price)
+	} else {
+		Tax, err = s.taxes.Create(parent, req.User().ID, form.Name, form.TaxCode, form.Price)
+	}
+
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
 	if err != nil {
 		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
 			HttpStatusCode: http.StatusUnprocessableEntity,
@@ -57,15 +80,8 @@ func createNewTax(parent context.Context, req Request) Response {
 		})
 	}
 
-	return newJSONResponse(http.StatusOK, respayload.Tax{
-		Name:       Tax.Name,
-		TaxCode:    int(Tax.TaxCode),
-		Type:       Tax.GetTaxCodeString(),
-		Price:      Tax.Price,
-		Tax:        fmt.Sprintf("%2f", Tax.GetTaxValue()),
-		Amount:     fmt.Sprintf("%2f", Tax.GetAmount()),
-		Refundable: Tax.IsRefundable(),
-	})
+	observeTaxCreated(Tax)
+	return newJSONResponse(http.StatusOK, newTaxResponse(Tax))
 }
 
 // TODO: sorry for long inline description, swag doesn't support multi-line description yet. https://github.com/swaggo/swag/issues/191
@@ -76,14 +92,52 @@ func createNewTax(parent context.Context, req Request) Response {
 // @ID get-taxes
 //
 // @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param org_id query int false "get the taxes of the organization the user belongs to instead of the personal one"
 // @Accept  json
 // @Produce  json
 // @Success 200 {object} respayload.TaxesForCurrentUser
 // @Failure 400 {object} respayload.Error
+// @Failure 404 {object} respayload.Error
 // @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
 // @Router /tax [get]
-func getTaxes(parent context.Context, req Request) Response {
-	Taxes, err := tax.GetTaxesByUserID(parent, req.User().ID)
+func (s *Server) getTaxes(parent context.Context, req Request) Response {
+	form := &reqpayload.GetTaxes{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	if form.OrgID == 0 {
+		return s.taxesResponse(s.taxes.GetTaxesByUserID(parent, req.User().ID))
+	}
+
+	// every role of the member can read the taxes
+	if _, res := s.organizationMember(parent, form.OrgID, req.User().ID); res != nil {
+		return res
+	}
+
+	return s.taxesResponse(s.taxes.GetTaxesByOrganizationID(parent, form.OrgID))
+}
+
+// taxesResponse returns the taxes and their total.
+func (s *Server) taxesResponse(Taxes []*model.Tax, err error) Response {
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
 	if err != nil {
 		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
 			HttpStatusCode: http.StatusUnprocessableEntity,
@@ -102,15 +156,7 @@ func getTaxes(parent context.Context, req Request) Response {
 		taxSubTotal += float64(Tax.GetTaxValue())
 		grandTotal += Tax.GetAmount()
 
-		taxesResponse = append(taxesResponse, respayload.Tax{
-			Name:       Tax.Name,
-			TaxCode:    int(Tax.TaxCode),
-			Type:       Tax.GetTaxCodeString(),
-			Price:      Tax.Price,
-			Tax:        fmt.Sprintf("%2f", Tax.GetTaxValue()),
-			Amount:     fmt.Sprintf("%2f", Tax.GetAmount()),
-			Refundable: Tax.IsRefundable(),
-		})
+		taxesResponse = append(taxesResponse, newTaxResponse(Tax))
 	}
 
 	return newJSONResponse(http.StatusOK, respayload.TaxesForCurrentUser{
@@ -120,3 +166,16 @@ func getTaxes(parent context.Context, req Request) Response {
 		Taxes:         taxesResponse,
 	})
 }
+
+func newTaxResponse(Tax *model.Tax) respayload.Tax {
+	return respayload.Tax{
+		Name:       Tax.Name,
+		TaxCode:    int(Tax.TaxCode),
+		Type:       Tax.GetTaxCodeString(),
+		Price:      Tax.Price,
+		Tax:        fmt.Sprintf("%2f", Tax.GetTaxValue()),
+		Amount:     fmt.Sprintf("%2f", Tax.GetAmount()),
+		Refundable: Tax.IsRefundable(),
+		OrgID:      Tax.OrgID,
+	}
+}
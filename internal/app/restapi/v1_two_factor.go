@@ -0,0 +1,341 @@
+package restapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/reqpayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/respayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/validator"
+)
+
+// twoFactorRequired returns the short-lived token which can only be exchanged with the session using the TOTP code.
+func (s *Server) twoFactorRequired(parent context.Context, User *model.User) Response {
+	token, err := s.conf.Tokens.GenerateTwoFactorToken(parent, User.ID)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
+			Message:        fmt.Sprintf("error when generating 2FA token %s", err.Error()),
+		})
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.LoginTwoFactorRequired{
+		TwoFactorRequired: true,
+		TwoFactorToken:    token,
+		ExpiresIn:         int64(auth.TwoFactorTokenLifetime.Seconds()),
+	})
+}
+
+// Login 2FA
+// @Summary Complete login using 2FA
+// @Description Exchange the two_factor_token from login with the authentication token, using the TOTP code
+// @Description from the authenticator app or one of the recovery codes. Every code can only be used once.
+// @Description Wrong code is counted like failed login.
+// @ID user-login-2fa
+// @Param user body reqpayload.LoginTwoFactor true "2FA token and code"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.Login
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 429 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /login/2fa [post]
+func (s *Server) loginTwoFactor(parent context.Context, req Request) Response {
+	form := &reqpayload.LoginTwoFactor{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	claims, err := s.conf.Tokens.ValidateTwoFactorToken(parent, form.TwoFactorToken)
+	if err != nil {
+		return newInvalidTwoFactorTokenResponse()
+	}
+
+	User, err := s.users.FindCredentialsByID(parent, claims.UserID)
+	switch {
+	case err == db.ErrCircuitOpen:
+		return newDatabaseUnavailableResponse()
+	case err == db.ErrNoRows:
+		return newInvalidTwoFactorTokenResponse()
+	case err != nil:
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
+			Message:        fmt.Sprintf("db error when find user %s", err.Error()),
+		})
+	}
+
+	ip := s.clientIP(req.RawRequest())
+	if res := s.checkLoginThrottle(User.Username, ip); res != nil {
+		return res
+	}
+
+	// the account may be disabled after the password is checked
+	if User.Disabled() {
+		return s.loginDisabled(parent, User, User.Username, ip)
+	}
+
+	err = s.twoFactor.Verify(parent, User, form.Code)
+	switch {
+	case err == db.ErrCircuitOpen:
+		return newDatabaseUnavailableResponse()
+	case err == auth.ErrTwoFactorNotEnabled:
+		// 2FA is disabled after the password is checked, login again
+		return newInvalidTwoFactorTokenResponse()
+	case err == auth.ErrTwoFactorCodeInvalid:
+		s.countLoginFailure(parent, model.AuthEventTwoFactorFailed, &User.ID, User.Username, ip)
+		observeLogin(loginResultFailed)
+		return newJSONResponse(http.StatusUnauthorized, respayload.Error{
+			HttpStatusCode: http.StatusUnauthorized,
+			ErrorCode:      respayload.ErrorCodeUserWrongTwoFactorCode,
+			Message:        err.Error(),
+		})
+	case err != nil:
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserSessionDBError,
+			Message:        fmt.Sprintf("db error when checking 2FA code %s", err.Error()),
+		})
+	}
+
+	return s.loginSucceeded(parent, User, User.Username, ip)
+}
+
+// Enroll 2FA
+// @Summary Enroll 2FA of current user
+// @Description Generate the TOTP secret, add it into the authenticator app by scanning otpauth_uri as QR code,
+// @Description then confirm it using the code. 2FA is not required to login until it's confirmed.
+// @Description The password is required, wrong password is counted like failed login.
+// @ID me-2fa-enroll
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param password body reqpayload.EnrollTwoFactor true "current password"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.EnrollTwoFactor
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 429 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /me/2fa [post]
+func (s *Server) enrollTwoFactor(parent context.Context, req Request) Response {
+	form := &reqpayload.EnrollTwoFactor{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	User, res := s.verifyCurrentPassword(parent, req, form.Password)
+	if res != nil {
+		return res
+	}
+
+	secret, uri, err := s.twoFactor.Enroll(parent, User)
+	switch {
+	case err == db.ErrCircuitOpen:
+		return newDatabaseUnavailableResponse()
+	case err == auth.ErrTwoFactorAlreadyEnabled:
+		return newTwoFactorStateResponse(err)
+	case err != nil:
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserSessionDBError,
+			Message:        fmt.Sprintf("db error when enrolling 2FA %s", err.Error()),
+		})
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.EnrollTwoFactor{
+		Secret:     secret,
+		OTPAuthURI: uri,
+	})
+}
+
+// Confirm 2FA
+// @Summary Confirm 2FA of current user
+// @Description Enable 2FA using the code from the authenticator app, the response has the recovery codes
+// @Description which are only shown once. Every recovery code can be used once instead of the TOTP code.
+// @ID me-2fa-confirm
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param code body reqpayload.ConfirmTwoFactor true "TOTP code"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.ConfirmTwoFactor
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /me/2fa/confirm [post]
+func (s *Server) confirmTwoFactor(parent context.Context, req Request) Response {
+	form := &reqpayload.ConfirmTwoFactor{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	User, res := s.userWithCredentials(parent, req)
+	if res != nil {
+		return res
+	}
+
+	// enabling 2FA, saving the used step and creating the recovery codes are done in one transaction,
+	// so 2FA is never enabled without the recovery codes
+	var recoveryCodes []string
+	err = s.inTransaction(parent, func(tx Repositories) error {
+		recoveryCodes, err = auth.NewTwoFactor(tx.Users, tx.RecoveryCodes, s.conf.TwoFactorIssuer).Confirm(parent, User, form.Code)
+		return err
+	})
+	switch {
+	case err == db.ErrCircuitOpen:
+		return newDatabaseUnavailableResponse()
+	case err == auth.ErrTwoFactorCodeInvalid:
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorCodeUserWrongTwoFactorCode,
+			Message:        err.Error(),
+		})
+	case err == auth.ErrTwoFactorAlreadyEnabled, err == auth.ErrTwoFactorNotEnrolled:
+		return newTwoFactorStateResponse(err)
+	case err != nil:
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserSessionDBError,
+			Message:        fmt.Sprintf("db error when confirming 2FA %s", err.Error()),
+		})
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.ConfirmTwoFactor{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// Disable 2FA
+// @Summary Disable 2FA of current user
+// @Description Disable 2FA and remove the recovery codes. The password and the TOTP code (or a recovery code) are required,
+// @Description wrong password or code is counted like failed login.
+// @ID me-2fa-disable
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param code body reqpayload.DisableTwoFactor true "current password and TOTP code"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.DisableTwoFactor
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 403 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 429 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /me/2fa/disable [post]
+func (s *Server) disableTwoFactor(parent context.Context, req Request) Response {
+	form := &reqpayload.DisableTwoFactor{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	User, res := s.verifyCurrentPassword(parent, req, form.Password)
+	if res != nil {
+		return res
+	}
+
+	// the secret and the recovery codes are removed in one transaction, so no recovery code is left to login with
+	err = s.inTransaction(parent, func(tx Repositories) error {
+		return auth.NewTwoFactor(tx.Users, tx.RecoveryCodes, s.conf.TwoFactorIssuer).Disable(parent, User, form.Code)
+	})
+	switch {
+	case err == db.ErrCircuitOpen:
+		return newDatabaseUnavailableResponse()
+	case err == auth.ErrTwoFactorNotEnabled:
+		return newTwoFactorStateResponse(err)
+	case err == auth.ErrTwoFactorCodeInvalid:
+		s.countLoginFailure(parent, model.AuthEventTwoFactorFailed, &User.ID, User.Username, s.clientIP(req.RawRequest()))
+		return newJSONResponse(http.StatusUnauthorized, respayload.Error{
+			HttpStatusCode: http.StatusUnauthorized,
+			ErrorCode:      respayload.ErrorCodeUserWrongTwoFactorCode,
+			Message:        err.Error(),
+		})
+	case err != nil:
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserSessionDBError,
+			Message:        fmt.Sprintf("db error when disabling 2FA %s", err.Error()),
+		})
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.DisableTwoFactor{
+		Message: "2FA is disabled",
+	})
+}
+
+func newInvalidTwoFactorTokenResponse() Response {
+	return newJSONResponse(http.StatusUnauthorized, respayload.Error{
+		HttpStatusCode: http.StatusUnauthorized,
+		ErrorCode:      respayload.ErrorCodeUserWrongTwoFactorAuth,
+		Message:        "2FA token is invalid or expired, login again",
+	})
+}
+
+func newTwoFactorStateResponse(err error) Response {
+	return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+		HttpStatusCode: http.StatusUnprocessableEntity,
+		ErrorCode:      respayload.ErrorCodeUserTwoFactorState,
+		Message:        err.Error(),
+	})
+}
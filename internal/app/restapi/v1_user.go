@@ -3,22 +3,24 @@ package restapi
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
-	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/user"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
 	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/reqpayload"
 	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/respayload"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
 	"github.com/yusufsyaifudin/tax-calculator-example/pkg/validator"
 )
 
-// this should not be here, we must set it from env variable
-const secretKey = "my-secret"
-
 // Register
 // @Summary Register new account
-// @Description Register new account
+// @Description Register new account. Username must be 3 to 32 characters of letters, digits, underscore, dot or hyphen,
+// @Description starting with a letter, and unique regardless of the case. Password must follow the password policy.
+// @Description Email is optional and unique regardless of the case, it's needed to reset the forgotten password.
 // @ID user-register
 // @Param user body reqpayload.Register true "user info"
 // @Accept  json
@@ -26,8 +28,9 @@ const secretKey = "my-secret"
 // @Success 200 {object} respayload.Register
 // @Failure 400 {object} respayload.Error
 // @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
 // @Router /register [post]
-func register(parent context.Context, req Request) Response {
+func (s *Server) register(parent context.Context, req Request) Response {
 	form := &reqpayload.Register{}
 	err := req.Bind(form)
 	if err != nil {
@@ -38,9 +41,9 @@ func register(parent context.Context, req Request) Response {
 		})
 	}
 
-	// trim head and tail spaces
+	// trim head and tail spaces of the username and email, the password is kept as the user typed it
 	form.Username = strings.TrimSpace(form.Username)
-	form.Password = strings.TrimSpace(form.Password)
+	form.Email = strings.TrimSpace(form.Email)
 
 	if errs := validator.Validate(form); errs != nil {
 		return newJSONResponse(http.StatusBadRequest, respayload.Error{
@@ -50,6 +53,52 @@ func register(parent context.Context, req Request) Response {
 		})
 	}
 
+	if err := auth.ValidateUsername(form.Username); err != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        fmt.Sprintf("username: %s", err.Error()),
+		})
+	}
+
+	if err := s.conf.PasswordPolicy.Validate(form.Username, form.Password); err != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        fmt.Sprintf("password: %s", err.Error()),
+		})
+	}
+
+	// the unique index also rejects it, this is only to give a clear message
+	_, err = s.users.FindByUsername(parent, form.Username)
+	switch {
+	case err == db.ErrCircuitOpen:
+		return newDatabaseUnavailableResponse()
+	case err == nil:
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserCantBeCreated,
+			Message:        "username is already taken",
+		})
+	}
+
+	var email *string
+	if form.Email != "" {
+		email = &form.Email
+
+		_, err = s.users.FindByEmail(parent, form.Email)
+		switch {
+		case err == db.ErrCircuitOpen:
+			return newDatabaseUnavailableResponse()
+		case err == nil:
+			return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+				HttpStatusCode: http.StatusUnprocessableEntity,
+				ErrorCode:      respayload.ErrorCodeUserCantBeCreated,
+				Message:        "email is already taken",
+			})
+		}
+	}
+
 	// generate password using bcrypt
 	password, err := auth.HashPassword(form.Password)
 	if err != nil {
@@ -60,7 +109,11 @@ func register(parent context.Context, req Request) Response {
 		})
 	}
 
-	User, err := user.Create(parent, form.Username, password)
+	User, err := s.users.Create(parent, form.Username, password, email)
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
 	if err != nil {
 		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
 			HttpStatusCode: http.StatusUnprocessableEntity,
@@ -69,7 +122,11 @@ func register(parent context.Context, req Request) Response {
 		})
 	}
 
-	authToken, err := auth.GenerateJWTToken(parent, secretKey, User.ID)
+	session, err := s.sessions.Create(parent, User.ID)
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
 	if err != nil {
 		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
 			HttpStatusCode: http.StatusUnprocessableEntity,
@@ -79,17 +136,20 @@ func register(parent context.Context, req Request) Response {
 	}
 
 	return newJSONResponse(http.StatusOK, respayload.Register{
-		AuthenticationToken: authToken,
-		User: &respayload.User{
-			ID:       User.ID,
-			Username: User.Username,
-		},
+		AuthenticationToken: session.AccessToken,
+		RefreshToken:        session.RefreshToken,
+		ExpiresIn:           int64(session.ExpiresIn.Seconds()),
+		User:                newUserResponse(User),
 	})
 }
 
 // Login
 // @Summary Login account
-// @Description Login using username and password
+// @Description Login using username and password. Unknown username and wrong password get the same 401 response.
+// @Description After every failed login the next attempt must wait longer, and after too many failures
+// @Description the username or the IP address is locked temporarily (429 with Retry-After header).
+// @Description When the user has 2FA enabled, the response is respayload.LoginTwoFactorRequired instead,
+// @Description and the authentication token is returned by POST /login/2fa.
 // @ID user-login
 // @Param user body reqpayload.Login true "user info"
 // @Accept  json
@@ -97,10 +157,11 @@ func register(parent context.Context, req Request) Response {
 // @Success 200 {object} respayload.Login
 // @Failure 400 {object} respayload.Error
 // @Failure 401 {object} respayload.Error
-// @Failure 404 {object} respayload.Error
 // @Failure 422 {object} respayload.Error
+// @Failure 429 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
 // @Router /login [post]
-func login(parent context.Context, req Request) Response {
+func (s *Server) login(parent context.Context, req Request) Response {
 	form := &reqpayload.Login{}
 	err := req.Bind(form)
 	if err != nil {
@@ -111,9 +172,8 @@ func login(parent context.Context, req Request) Response {
 		})
 	}
 
-	// trim head and tail spaces
+	// trim head and tail spaces of the username, the password is kept as the user typed it
 	form.Username = strings.TrimSpace(form.Username)
-	form.Password = strings.TrimSpace(form.Password)
 
 	if errs := validator.Validate(form); errs != nil {
 		return newJSONResponse(http.StatusBadRequest, respayload.Error{
@@ -123,16 +183,238 @@ func login(parent context.Context, req Request) Response {
 		})
 	}
 
-	User, err := user.FindByUsername(parent, form.Username)
+	ip := s.clientIP(req.RawRequest())
+	if res := s.checkLoginThrottle(form.Username, ip); res != nil {
+		return res
+	}
+
+	User, err := s.users.FindByUsername(parent, form.Username)
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
+	if err != nil && err != db.ErrNoRows {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
+			Message:        fmt.Sprintf("db error when find user %s", err.Error()),
+		})
+	}
+
+	// unknown username and wrong password get the same response in about the same time,
+	// so the login can't be used to find out which username exists
 	if User == nil || User.ID == 0 {
-		return newJSONResponse(http.StatusNotFound, respayload.Error{
-			HttpStatusCode: http.StatusNotFound,
+		auth.SimulatePasswordCheck(form.Password)
+		return s.loginFailed(parent, nil, form.Username, ip)
+	}
+
+	if !checkPassword(form.Password, User.Password) {
+		return s.loginFailed(parent, &User.ID, form.Username, ip)
+	}
+
+	if User.Disabled() {
+		return s.loginDisabled(parent, User, form.Username, ip)
+	}
+
+	// the failures are not reset until the TOTP code is correct too, so logging in again doesn't allow more guesses of the code
+	if User.TwoFactorEnabled() {
+		return s.twoFactorRequired(parent, User)
+	}
+
+	return s.loginSucceeded(parent, User, form.Username, ip)
+}
+
+// loginSucceeded resets the failed login and returns the new session of the user.
+func (s *Server) loginSucceeded(parent context.Context, User *model.User, username, ip string) Response {
+	s.loginThrottle.Succeed(username)
+	s.logAuthEvent(parent, model.AuthEventLoginSucceeded, &User.ID, username, ip)
+	observeLogin(loginResultSucceeded)
+
+	session, err := s.sessions.Create(parent, User.ID)
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
 			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
-			Message:        "cannot find user",
+			Message:        fmt.Sprintf("db error when generating auth token %s", err.Error()),
+		})
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.Login{
+		AuthenticationToken: session.AccessToken,
+		RefreshToken:        session.RefreshToken,
+		ExpiresIn:           int64(session.ExpiresIn.Seconds()),
+		User:                newUserResponse(User),
+	})
+}
+
+// loginDisabled rejects the disabled user. It's only checked after the password is correct,
+// so the response doesn't tell whether the username exists.
+func (s *Server) loginDisabled(parent context.Context, User *model.User, username, ip string) Response {
+	s.logAuthEvent(parent, model.AuthEventLoginDisabled, &User.ID, username, ip)
+	observeLogin(loginResultFailed)
+	return newUserDisabledResponse()
+}
+
+// newUserDisabledResponse is returned when the account is disabled by the admin.
+func newUserDisabledResponse() Response {
+	return newJSONResponse(http.StatusForbidden, respayload.Error{
+		HttpStatusCode: http.StatusForbidden,
+		ErrorCode:      respayload.ErrorCodeUserDisabled,
+		Message:        "account is disabled",
+	})
+}
+
+// newRefreshTokenErrorResponse returns the response of the error from refreshing the session.
+func newRefreshTokenErrorResponse(err error) Response {
+	switch err {
+	case db.ErrCircuitOpen:
+		return newDatabaseUnavailableResponse()
+	case auth.ErrRefreshTokenReused:
+		return newJSONResponse(http.StatusUnauthorized, respayload.Error{
+			HttpStatusCode: http.StatusUnauthorized,
+			ErrorCode:      respayload.ErrorCodeUserRefreshTokenReused,
+			Message:        err.Error(),
+		})
+	case auth.ErrRefreshTokenInvalid:
+		return newJSONResponse(http.StatusUnauthorized, respayload.Error{
+			HttpStatusCode: http.StatusUnauthorized,
+			ErrorCode:      respayload.ErrorCodeUserWrongRefreshToken,
+			Message:        err.Error(),
+		})
+	default:
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserSessionDBError,
+			Message:        fmt.Sprintf("db error when refreshing auth token %s", err.Error()),
+		})
+	}
+}
+
+// checkPassword checks the password as the user typed it. Older version trimmed the password before hashing it,
+// so the trimmed password is also checked for the user registered by that version.
+func checkPassword(password, hash string) bool {
+	if auth.CheckPasswordHash(password, hash) {
+		return true
+	}
+
+	trimmed := strings.TrimSpace(password)
+	return trimmed != password && trimmed != "" && auth.CheckPasswordHash(trimmed, hash)
+}
+
+// checkLoginThrottle returns the 429 response when the username or the IP address must wait before trying again.
+func (s *Server) checkLoginThrottle(username, ip string) Response {
+	wait := s.loginThrottle.Check(username, ip)
+	if wait <= 0 {
+		return nil
+	}
+
+	res := newJSONResponse(http.StatusTooManyRequests, respayload.Error{
+		HttpStatusCode: http.StatusTooManyRequests,
+		ErrorCode:      respayload.ErrorCodeUserLoginThrottled,
+		Message:        "too many failed login attempts, try again later",
+	})
+
+	res.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return res
+}
+
+// loginFailed counts the failed login and returns the uniform error response.
+func (s *Server) loginFailed(parent context.Context, userID *int64, username, ip string) Response {
+	s.countLoginFailure(parent, model.AuthEventLoginFailed, userID, username, ip)
+	observeLogin(loginResultFailed)
+	return newJSONResponse(http.StatusUnauthorized, respayload.Error{
+		HttpStatusCode: http.StatusUnauthorized,
+		ErrorCode:      respayload.ErrorCodeUserWrongPassword,
+		Message:        "username or password is wrong",
+	})
+}
+
+// countLoginFailure counts the failure in the login throttle, and writes it into the auth event log along with the lock.
+func (s *Server) countLoginFailure(parent context.Context, event string, userID *int64, username, ip string) {
+	locked := s.loginThrottle.Fail(username, ip)
+
+	s.logAuthEvent(parent, event, userID, username, ip)
+	if locked {
+		s.logAuthEvent(parent, model.AuthEventLoginLocked, userID, username, ip)
+	}
+}
+
+// logAuthEvent writes the event into the auth event log, the failure is only logged since it must not fail the login.
+func (s *Server) logAuthEvent(parent context.Context, event string, userID *int64, username, ip string) {
+	if _, err := s.authEvents.Create(parent, event, userID, username, ip); err != nil {
+		logger.Error().Err(err).Str("event", event).Str("username", username).Msg("fail to write auth event")
+	}
+}
+
+// newUserResponse converts the user into the response model.
+func newUserResponse(User *model.User) *respayload.User {
+	res := &respayload.User{
+		ID:       User.ID,
+		Username: User.Username,
+	}
+
+	if User.Email != nil {
+		res.Email = *User.Email
+	}
+
+	return res
+}
+
+// RefreshToken
+// @Summary Exchange refresh token
+// @Description Exchange the refresh token with new authentication token and refresh token, the old refresh token can't be used anymore.
+// @Description Using the old refresh token again logs out every session from the same login.
+// @ID user-refresh-token
+// @Param token body reqpayload.RefreshToken true "refresh token"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.RefreshToken
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /token/refresh [post]
+func (s *Server) refreshToken(parent context.Context, req Request) Response {
+	form := &reqpayload.RefreshToken{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	form.RefreshToken = strings.TrimSpace(form.RefreshToken)
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
 		})
 	}
 
+	// the disabled user can't get a new session, so the token is not rotated for them
+	userID, err := s.sessions.UserID(parent, form.RefreshToken)
 	if err != nil {
+		return newRefreshTokenErrorResponse(err)
+	}
+
+	User, err := s.users.FindByID(parent, userID)
+	switch {
+	case err == db.ErrCircuitOpen:
+		return newDatabaseUnavailableResponse()
+	case err == db.ErrNoRows, err == nil && (User == nil || User.ID == 0):
+		return newJSONResponse(http.StatusUnauthorized, respayload.Error{
+			HttpStatusCode: http.StatusUnauthorized,
+			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
+			Message:        "related user is not exist in db anymore",
+		})
+	case err != nil:
 		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
 			HttpStatusCode: http.StatusUnprocessableEntity,
 			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
@@ -140,29 +422,111 @@ func login(parent context.Context, req Request) Response {
 		})
 	}
 
-	ok := auth.CheckPasswordHash(form.Password, User.Password)
-	if !ok {
+	if User.Disabled() {
+		return newJSONResponse(http.StatusUnauthorized, respayload.Error{
+			HttpStatusCode: http.StatusUnauthorized,
+			ErrorCode:      respayload.ErrorCodeUserDisabled,
+			Message:        "account is disabled",
+		})
+	}
+
+	session, err := s.sessions.Refresh(parent, form.RefreshToken)
+	if err != nil {
+		return newRefreshTokenErrorResponse(err)
+	}
+
+	return newJSONResponse(http.StatusOK, respayload.RefreshToken{
+		AuthenticationToken: session.AccessToken,
+		RefreshToken:        session.RefreshToken,
+		ExpiresIn:           int64(session.ExpiresIn.Seconds()),
+		User:                newUserResponse(User),
+	})
+}
+
+// Logout
+// @Summary Logout the session
+// @Description Revoke the refresh token and every refresh token rotated from the same login.
+// @Description The authentication token is still valid until it expires, so keep its lifetime short.
+// @ID user-logout
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Param token body reqpayload.Logout true "refresh token"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} respayload.Logout
+// @Failure 400 {object} respayload.Error
+// @Failure 401 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /logout [post]
+func (s *Server) logout(parent context.Context, req Request) Response {
+	form := &reqpayload.Logout{}
+	err := req.Bind(form)
+	if err != nil {
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorBindingBodyRequest,
+			Message:        fmt.Sprintf("error while binding request body %s", err.Error()),
+		})
+	}
+
+	form.RefreshToken = strings.TrimSpace(form.RefreshToken)
+	if errs := validator.Validate(form); errs != nil {
+		return newJSONResponse(http.StatusBadRequest, respayload.Error{
+			HttpStatusCode: http.StatusBadRequest,
+			ErrorCode:      respayload.ErrorGeneralValidationError,
+			Message:        errs.String(),
+		})
+	}
+
+	err = s.sessions.Revoke(parent, req.User().ID, form.RefreshToken)
+	switch {
+	case err == db.ErrCircuitOpen:
+		return newDatabaseUnavailableResponse()
+	case err == auth.ErrRefreshTokenInvalid:
 		return newJSONResponse(http.StatusUnauthorized, respayload.Error{
 			HttpStatusCode: http.StatusUnauthorized,
-			ErrorCode:      respayload.ErrorCodeUserWrongPassword,
-			Message:        "your password is mismatch",
+			ErrorCode:      respayload.ErrorCodeUserWrongRefreshToken,
+			Message:        err.Error(),
+		})
+	case err != nil:
+		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
+			HttpStatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:      respayload.ErrorCodeUserSessionDBError,
+			Message:        fmt.Sprintf("db error when revoking refresh token %s", err.Error()),
 		})
 	}
 
-	authToken, err := auth.GenerateJWTToken(parent, secretKey, User.ID)
+	return newJSONResponse(http.StatusOK, respayload.Logout{
+		Message: "logged out",
+	})
+}
+
+// LogoutAll
+// @Summary Logout every session
+// @Description Revoke every refresh token of the current user, such as when the device is lost.
+// @ID user-logout-all
+// @Param Authentication-Token header string true "Authentication-Token your-token"
+// @Produce  json
+// @Success 200 {object} respayload.Logout
+// @Failure 401 {object} respayload.Error
+// @Failure 422 {object} respayload.Error
+// @Failure 503 {object} respayload.Error
+// @Router /logout/all [post]
+func (s *Server) logoutAll(parent context.Context, req Request) Response {
+	err := s.sessions.RevokeAll(parent, req.User().ID)
+	if err == db.ErrCircuitOpen {
+		return newDatabaseUnavailableResponse()
+	}
+
 	if err != nil {
 		return newJSONResponse(http.StatusUnprocessableEntity, respayload.Error{
 			HttpStatusCode: http.StatusUnprocessableEntity,
-			ErrorCode:      respayload.ErrorCodeUserCantBeFound,
-			Message:        fmt.Sprintf("db error when generating auth token %s", err.Error()),
+			ErrorCode:      respayload.ErrorCodeUserSessionDBError,
+			Message:        fmt.Sprintf("db error when revoking refresh token %s", err.Error()),
 		})
 	}
 
-	return newJSONResponse(http.StatusOK, respayload.Login{
-		AuthenticationToken: authToken,
-		User: &respayload.User{
-			ID:       User.ID,
-			Username: User.Username,
-		},
+	return newJSONResponse(http.StatusOK, respayload.Logout{
+		Message: "logged out from every session",
 	})
 }
@@ -0,0 +1,364 @@
+package restapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+// fakeUserRepository keeps users in a map, err is returned by every method when it's set.
+type fakeUserRepository struct {
+	users map[string]*model.User
+	err   error
+}
+
+func (r *fakeUserRepository) Create(parent context.Context, username, password string, email *string) (*model.User, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	User := &model.User{ID: int64(len(r.users) + 1), Username: username, Password: password, Email: email}
+	r.users[username] = User
+	return User, nil
+}
+
+func (r *fakeUserRepository) FindByID(parent context.Context, id int64) (*model.User, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	for _, User := range r.users {
+		if User.ID == id {
+			return User, nil
+		}
+	}
+
+	return nil, db.ErrNoRows
+}
+
+func (r *fakeUserRepository) FindCredentialsByID(parent context.Context, id int64) (*model.User, error) {
+	return r.FindByID(parent, id)
+}
+
+func (r *fakeUserRepository) FindByUsername(parent context.Context, username string) (*model.User, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	User, ok := r.users[username]
+	if !ok {
+		return nil, db.ErrNoRows
+	}
+
+	return User, nil
+}
+
+func (r *fakeUserRepository) FindByEmail(parent context.Context, email string) (*model.User, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	for _, User := range r.users {
+		if User.Email != nil && strings.EqualFold(*User.Email, email) {
+			return User, nil
+		}
+	}
+
+	return nil, db.ErrNoRows
+}
+
+func (r *fakeUserRepository) UpdatePassword(parent context.Context, id int64, password string, changedAt time.Time) (*model.User, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	User, err := r.FindByID(parent, id)
+	if err != nil {
+		return nil, err
+	}
+
+	User.Password, User.PasswordChangedAt = password, &changedAt
+	return User, nil
+}
+
+func (r *fakeUserRepository) UpdateTOTP(parent context.Context, id int64, secret *string, enabledAt *time.Time, updatedAt time.Time) (*model.User, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	User, err := r.FindByID(parent, id)
+	if err != nil {
+		return nil, err
+	}
+
+	User.TOTPSecret, User.TOTPEnabledAt, User.TOTPLastStep = secret, enabledAt, nil
+	return User, nil
+}
+
+func (r *fakeUserRepository) UseTOTPStep(parent context.Context, id int64, step int64, updatedAt time.Time) (*model.User, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	User, err := r.FindByID(parent, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if User.TOTPLastStep != nil && *User.TOTPLastStep >= step {
+		return nil, db.ErrNoRows
+	}
+
+	User.TOTPLastStep = &step
+	return User, nil
+}
+
+func (r *fakeUserRepository) List(parent context.Context, limit, offset int) ([]*model.User, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	Users := []*model.User{}
+	for _, User := range r.users {
+		Users = append(Users, User)
+	}
+
+	sort.Slice(Users, func(i, j int) bool { return Users[i].ID < Users[j].ID })
+	if offset >= len(Users) {
+		return []*model.User{}, nil
+	}
+
+	Users = Users[offset:]
+	if limit < len(Users) {
+		Users = Users[:limit]
+	}
+
+	return Users, nil
+}
+
+func (r *fakeUserRepository) Disable(parent context.Context, id int64, disabledAt time.Time) (*model.User, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	User, err := r.FindByID(parent, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if User.DisabledAt == nil {
+		User.DisabledAt = &disabledAt
+	}
+
+	return User, nil
+}
+
+func (r *fakeUserRepository) UpdateProfile(parent context.Context, id int64, profile model.UserProfile, updatedAt time.Time) (*model.User, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	User, err := r.FindByID(parent, id)
+	if err != nil {
+		return nil, err
+	}
+
+	User.Email, User.DisplayName, User.Locale = profile.Email, profile.DisplayName, profile.Locale
+	User.DefaultCurrency, User.Jurisdiction = profile.DefaultCurrency, profile.Jurisdiction
+	return User, nil
+}
+
+func (r *fakeUserRepository) Delete(parent context.Context, id int64) (*model.User, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	User, err := r.FindByID(parent, id)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(r.users, User.Username)
+	return User, nil
+}
+
+// fakeRefreshTokenRepository only keeps the created tokens, since the handler test doesn't refresh the token.
+type fakeRefreshTokenRepository struct {
+	tokens []*model.RefreshToken
+}
+
+func (r *fakeRefreshTokenRepository) Create(parent context.Context, userID int64, familyID, tokenHash string, expiresAt time.Time) (*model.RefreshToken, error) {
+	RefreshToken := &model.RefreshToken{ID: int64(len(r.tokens) + 1), UserID: userID, FamilyID: familyID, TokenHash: tokenHash, ExpiresAt: expiresAt}
+	r.tokens = append(r.tokens, RefreshToken)
+	return RefreshToken, nil
+}
+
+func (r *fakeRefreshTokenRepository) FindByTokenHash(parent context.Context, tokenHash string) (*model.RefreshToken, error) {
+	return nil, db.ErrNoRows
+}
+
+func (r *fakeRefreshTokenRepository) MarkUsed(parent context.Context, id int64, usedAt time.Time) (*model.RefreshToken, error) {
+	return nil, db.ErrNoRows
+}
+
+func (r *fakeRefreshTokenRepository) RevokeFamily(parent context.Context, familyID string, revokedAt time.Time) error {
+	return nil
+}
+
+func (r *fakeRefreshTokenRepository) RevokeByUserID(parent context.Context, userID int64, revokedAt time.Time) error {
+	return nil
+}
+
+// fakeAuthEventRepository keeps the logged events.
+type fakeAuthEventRepository struct {
+	events []*model.AuthEvent
+}
+
+func (r *fakeAuthEventRepository) Create(parent context.Context, event string, userID *int64, username, ip string) (*model.AuthEvent, error) {
+	AuthEvent := &model.AuthEvent{ID: int64(len(r.events) + 1), UserID: userID, Username: username, IP: ip, Event: event}
+	r.events = append(r.events, AuthEvent)
+	return AuthEvent, nil
+}
+
+func decodeResponse(res Response) (map[string]interface{}, error) {
+	body, err := res.Body()
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[string]interface{}{}
+	err = json.Unmarshal(body, &out)
+	return out, err
+}
+
+func TestLoginHandler(t *testing.T) {
+	convey.Convey("Test Login Handler", t, func() {
+		password, err := auth.HashPassword("secret")
+		convey.So(err, convey.ShouldBeNil)
+
+		users := &fakeUserRepository{
+			users: map[string]*model.User{
+				"john_doe": {ID: 1, Username: "john_doe", Password: password},
+			},
+		}
+
+		key, err := auth.NewKey("", "HS256", []byte("test-secret-which-is-at-least-32-bytes"))
+		convey.So(err, convey.ShouldBeNil)
+
+		tokens, err := auth.NewTokens(auth.TokenConfig{SigningKey: key, Lifetime: time.Hour, RefreshLifetime: time.Hour})
+		convey.So(err, convey.ShouldBeNil)
+
+		authEvents := &fakeAuthEventRepository{}
+		throttle := auth.LoginThrottleConfig{MaxFailures: 3, BaseDelay: time.Nanosecond, MaxDelay: time.Nanosecond, LockDuration: time.Hour}
+		s := NewServer(&Config{Test: true, Tokens: tokens, LoginThrottle: throttle}, Repositories{
+			Users:         users,
+			RefreshTokens: &fakeRefreshTokenRepository{},
+			AuthEvents:    authEvents,
+		})
+		login := func(username, password string) Response {
+			req := NewDummyRequest().(*DummyRequest).
+				AddPOSTParam("username", username).
+				AddPOSTParam("password", password)
+
+			return s.login(context.Background(), req)
+		}
+
+		convey.Convey("Correct password returns the token", func() {
+			res := login("john_doe", "secret")
+			convey.So(res.StatusCode(), convey.ShouldEqual, http.StatusOK)
+
+			body, err := decodeResponse(res)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(body["authentication_token"], convey.ShouldNotBeEmpty)
+			convey.So(body["refresh_token"], convey.ShouldNotBeEmpty)
+			convey.So(body["expires_in"], convey.ShouldEqual, 3600)
+			convey.So(authEvents.events[0].Event, convey.ShouldEqual, model.AuthEventLoginSucceeded)
+		})
+
+		convey.Convey("Unknown user and wrong password get the same response", func() {
+			wrongPassword, err := decodeResponse(login("john_doe", "wrong"))
+			convey.So(err, convey.ShouldBeNil)
+
+			unknownUser, err := decodeResponse(login("jane_doe", "secret"))
+			convey.So(err, convey.ShouldBeNil)
+
+			convey.So(wrongPassword["http_status_code"], convey.ShouldEqual, http.StatusUnauthorized)
+			convey.So(unknownUser, convey.ShouldResemble, wrongPassword)
+
+			convey.So(*authEvents.events[0].UserID, convey.ShouldEqual, 1)
+			convey.So(authEvents.events[1].UserID, convey.ShouldBeNil)
+		})
+
+		convey.Convey("Too many failures lock the username", func() {
+			for i := 0; i < 3; i++ {
+				convey.So(login("john_doe", "wrong").StatusCode(), convey.ShouldEqual, http.StatusUnauthorized)
+			}
+
+			res := login("john_doe", "secret")
+			convey.So(res.StatusCode(), convey.ShouldEqual, http.StatusTooManyRequests)
+			convey.So(res.Header().Get("Retry-After"), convey.ShouldEqual, "3600")
+
+			last := authEvents.events[len(authEvents.events)-1]
+			convey.So(last.Event, convey.ShouldEqual, model.AuthEventLoginLocked)
+		})
+
+		convey.Convey("Open circuit breaker is service unavailable", func() {
+			users.err = db.ErrCircuitOpen
+			res := login("john_doe", "secret")
+			convey.So(res.StatusCode(), convey.ShouldEqual, http.StatusServiceUnavailable)
+		})
+	})
+}
+
+func TestAuthTokenMiddleware(t *testing.T) {
+	convey.Convey("Test Auth Token Middleware", t, func() {
+		key, err := auth.NewKey("", "HS256", []byte("test-secret-which-is-at-least-32-bytes"))
+		convey.So(err, convey.ShouldBeNil)
+
+		tokens, err := auth.NewTokens(auth.TokenConfig{SigningKey: key, Lifetime: time.Hour, RefreshLifetime: time.Hour})
+		convey.So(err, convey.ShouldBeNil)
+
+		users := &fakeUserRepository{users: map[string]*model.User{}}
+		s := NewServer(&Config{Test: true, Tokens: tokens}, Repositories{Users: users})
+
+		token, err := tokens.GenerateJWTToken(context.Background(), 1)
+		convey.So(err, convey.ShouldBeNil)
+
+		check := func() Response {
+			handler := s.middlewareAuthTokenCheck(func(parent context.Context, req Request) Response {
+				return newJSONResponse(http.StatusOK, nil)
+			})
+
+			return handler(context.Background(), NewDummyRequest().(*DummyRequest).AddHeader("Authentication-Token", token))
+		}
+
+		convey.Convey("Unknown user is unauthorized", func() {
+			convey.So(check().StatusCode(), convey.ShouldEqual, http.StatusUnauthorized)
+		})
+
+		convey.Convey("Open circuit breaker is service unavailable", func() {
+			users.err = db.ErrCircuitOpen
+			convey.So(check().StatusCode(), convey.ShouldEqual, http.StatusServiceUnavailable)
+		})
+
+		convey.Convey("Database error is not reported as unknown user", func() {
+			users.err = errors.New("connection reset by peer")
+			res := check()
+			convey.So(res.StatusCode(), convey.ShouldEqual, http.StatusUnprocessableEntity)
+
+			body, err := decodeResponse(res)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(body["message"], convey.ShouldContainSubstring, "connection reset by peer")
+		})
+	})
+}
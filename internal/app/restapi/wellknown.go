@@ -0,0 +1,16 @@
+package restapi
+
+import (
+	"context"
+	"net/http"
+)
+
+// jwks publishes the JSON Web Key Set of the RS256, ES256 or EdDSA signing key and verification keys,
+// so other services can verify the token without the secret. HS256 key is never published.
+func (s *Server) jwks(parent context.Context, req Request) Response {
+	res := newJSONResponse(http.StatusOK, s.conf.Tokens.JWKS())
+
+	// the keys only change when the server is restarted with new configuration
+	res.Header().Set("Cache-Control", "public, max-age=300")
+	return res
+}
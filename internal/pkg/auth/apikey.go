@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+const (
+	// APIKeyPrefix starts every API key, so it can be told apart from the JWT token in the same header
+	// and secret scanners can find the leaked key.
+	APIKeyPrefix = "tce_"
+
+	// apiKeyDisplayLength is the length of the key prefix saved in plain text, so the user can tell which key is which.
+	apiKeyDisplayLength = len(APIKeyPrefix) + 8
+
+	// apiKeyTouchInterval limits how often the last used time is updated, so a busy integration doesn't write on every request.
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	// ErrAPIKeyInvalid is returned when the API key is unknown or revoked.
+	ErrAPIKeyInvalid = errors.New("api key is invalid or revoked")
+	// ErrAPIKeyNotFound is returned when the API key to revoke doesn't belong to the user or is already revoked.
+	ErrAPIKeyNotFound = errors.New("api key is not found or already revoked")
+)
+
+// APIKeys issues the long-lived API key for server-to-server integration.
+// Unlike the session, the key doesn't expire, it's only limited by its scopes and valid until it's revoked.
+type APIKeys struct {
+	apiKeys repo.APIKeyRepository
+	now     func() time.Time
+}
+
+// NewAPIKeys creates the API key issuer.
+func NewAPIKeys(apiKeys repo.APIKeyRepository) *APIKeys {
+	return &APIKeys{
+		apiKeys: apiKeys,
+		now:     time.Now,
+	}
+}
+
+// IsAPIKey returns true when the token looks like an API key instead of a JWT token.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// Create issues a new API key of the user. The returned key is never saved, so it can only be shown once.
+func (a *APIKeys) Create(ctx context.Context, userID int64, name string, scopes []string) (*model.APIKey, string, error) {
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return nil, "", fmt.Errorf("unknown scope %s, valid scopes are %s", scope, strings.Join(model.Scopes, ", "))
+		}
+	}
+
+	random, err := randomString(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := APIKeyPrefix + random
+	APIKey, err := a.apiKeys.Create(ctx, userID, name, key[:apiKeyDisplayLength], hashAPIKey(key), strings.Join(scopes, " "))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return APIKey, key, nil
+}
+
+// Authenticate returns the API key, the last used time is updated at most once per minute.
+func (a *APIKeys) Authenticate(ctx context.Context, key string) (*model.APIKey, error) {
+	APIKey, err := a.apiKeys.FindByKeyHash(ctx, hashAPIKey(key))
+	if err == db.ErrNoRows {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if APIKey.RevokedAt != nil {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	now := a.now()
+	if APIKey.LastUsedAt == nil || now.Sub(*APIKey.LastUsedAt) >= apiKeyTouchInterval {
+		if err := a.apiKeys.Touch(ctx, APIKey.ID, now); err != nil {
+			return nil, err
+		}
+
+		APIKey.LastUsedAt = &now
+	}
+
+	return APIKey, nil
+}
+
+// List returns the API keys of the user.
+func (a *APIKeys) List(ctx context.Context, userID int64) ([]*model.APIKey, error) {
+	return a.apiKeys.GetByUserID(ctx, userID)
+}
+
+// Revoke revokes the API key, it must belong to the user.
+func (a *APIKeys) Revoke(ctx context.Context, userID, id int64) (*model.APIKey, error) {
+	APIKey, err := a.apiKeys.Revoke(ctx, id, userID, a.now())
+	if err == db.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	return APIKey, err
+}
+
+// hashAPIKey returns the hash of API key saved in the database, it's random like the refresh token so SHA-256 is enough.
+func hashAPIKey(key string) string {
+	return HashRefreshToken(key)
+}
+
+func validScope(scope string) bool {
+	for _, s := range model.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
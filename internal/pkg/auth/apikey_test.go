@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/apikey"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/user"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+func TestAPIKeys(t *testing.T) {
+	convey.Convey("Test API Keys", t, func() {
+		ctx := context.Background()
+		dbConn, err := db.NewMemoryConnection()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(conn.MigrateUp(ctx, dbConn), convey.ShouldBeNil)
+		defer dbConn.Close()
+
+		User, err := user.NewRepository(dbConn).Create(ctx, "john_doe", "secret", nil)
+		convey.So(err, convey.ShouldBeNil)
+
+		apiKeys := NewAPIKeys(apikey.NewRepository(dbConn))
+
+		APIKey, key, err := apiKeys.Create(ctx, User.ID, "accounting", []string{model.ScopeTaxRead})
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(IsAPIKey(key), convey.ShouldBeTrue)
+		convey.So(strings.HasPrefix(key, APIKey.Prefix), convey.ShouldBeTrue)
+		convey.So(APIKey.KeyHash, convey.ShouldNotContainSubstring, key)
+
+		convey.Convey("Key is authenticated with its scopes", func() {
+			found, err := apiKeys.Authenticate(ctx, key)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(found.UserID, convey.ShouldEqual, User.ID)
+			convey.So(found.HasScope(model.ScopeTaxRead), convey.ShouldBeTrue)
+			convey.So(found.HasScope(model.ScopeTaxWrite), convey.ShouldBeFalse)
+			convey.So(found.LastUsedAt, convey.ShouldNotBeNil)
+
+			listed, err := apiKeys.List(ctx, User.ID)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(listed), convey.ShouldEqual, 1)
+			convey.So(listed[0].LastUsedAt, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Last used time is not updated on every request", func() {
+			first, err := apiKeys.Authenticate(ctx, key)
+			convey.So(err, convey.ShouldBeNil)
+
+			apiKeys.now = func() time.Time { return first.LastUsedAt.Add(time.Second) }
+			second, err := apiKeys.Authenticate(ctx, key)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(second.LastUsedAt.Equal(*first.LastUsedAt), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Unknown scope is rejected", func() {
+			_, _, err := apiKeys.Create(ctx, User.ID, "admin", []string{"admin"})
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Unknown key is invalid", func() {
+			_, err := apiKeys.Authenticate(ctx, APIKeyPrefix+"unknown")
+			convey.So(err, convey.ShouldEqual, ErrAPIKeyInvalid)
+		})
+
+		convey.Convey("Revoked key is invalid", func() {
+			_, err := apiKeys.Revoke(ctx, User.ID+1, APIKey.ID)
+			convey.So(err, convey.ShouldEqual, ErrAPIKeyNotFound)
+
+			_, err = apiKeys.Revoke(ctx, User.ID, APIKey.ID)
+			convey.So(err, convey.ShouldBeNil)
+
+			_, err = apiKeys.Authenticate(ctx, key)
+			convey.So(err, convey.ShouldEqual, ErrAPIKeyInvalid)
+
+			_, err = apiKeys.Revoke(ctx, User.ID, APIKey.ID)
+			convey.So(err, convey.ShouldEqual, ErrAPIKeyNotFound)
+		})
+	})
+}
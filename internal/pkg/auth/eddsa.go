@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+)
+
+// MethodEdDSA is the "alg" header of token signed using Ed25519, it's not supported by the jwt package.
+const MethodEdDSA = "EdDSA"
+
+var (
+	errEdDSANilPrivKey   = errors.New("jwt: Ed25519 private key is nil")
+	errEdDSANilPubKey    = errors.New("jwt: Ed25519 public key is nil")
+	errEdDSAVerification = errors.New("jwt: Ed25519 verification failed")
+)
+
+// eddsa implements jwt.Signer using Ed25519.
+type eddsa struct {
+	priv ed25519.PrivateKey
+	pub  ed25519.PublicKey
+}
+
+func newEdDSA(priv ed25519.PrivateKey, pub ed25519.PublicKey) *eddsa {
+	return &eddsa{priv: priv, pub: pub}
+}
+
+func (e *eddsa) Sign(payload []byte) ([]byte, error) {
+	if e.priv == nil {
+		return nil, errEdDSANilPrivKey
+	}
+
+	sig := ed25519.Sign(e.priv, payload)
+	token := make([]byte, len(payload)+1+base64.RawURLEncoding.EncodedLen(len(sig)))
+	n := copy(token, payload)
+	token[n] = '.'
+	base64.RawURLEncoding.Encode(token[n+1:], sig)
+	return token, nil
+}
+
+func (e *eddsa) String() string {
+	return MethodEdDSA
+}
+
+func (e *eddsa) Verify(payload, sig []byte) error {
+	if e.pub == nil {
+		return errEdDSANilPubKey
+	}
+
+	decoded := make([]byte, base64.RawURLEncoding.DecodedLen(len(sig)))
+	if _, err := base64.RawURLEncoding.Decode(decoded, sig); err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(e.pub, payload, decoded) {
+		return errEdDSAVerification
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+// Identities maps the subject of the external issuer to the local user, since the taxes belong to the local user.
+type Identities struct {
+	users      repo.UserRepository
+	identities repo.IdentityRepository
+}
+
+// NewIdentities creates the mapper using the repositories.
+func NewIdentities(users repo.UserRepository, identities repo.IdentityRepository) *Identities {
+	return &Identities{
+		users:      users,
+		identities: identities,
+	}
+}
+
+// Resolve returns the local user of the external token, the user is created when the subject is seen for the first time.
+// The created user has no password, so it can only login through the external issuer.
+func (i *Identities) Resolve(ctx context.Context, claims *Claims) (*model.User, error) {
+	Identity, err := i.identities.FindByIssuerSubject(ctx, claims.Issuer, claims.Subject)
+	if err == nil {
+		return i.users.FindByID(ctx, Identity.UserID)
+	}
+
+	if err != db.ErrNoRows {
+		return nil, err
+	}
+
+	User, err := i.users.Create(ctx, i.username(ctx, claims), "", nil)
+	if err != nil {
+		// the same subject may be created by concurrent request, which uses the same username
+		if Identity, findErr := i.identities.FindByIssuerSubject(ctx, claims.Issuer, claims.Subject); findErr == nil {
+			return i.users.FindByID(ctx, Identity.UserID)
+		}
+
+		return nil, err
+	}
+
+	if _, err = i.identities.Create(ctx, User.ID, claims.Issuer, claims.Subject); err != nil {
+		if Identity, findErr := i.identities.FindByIssuerSubject(ctx, claims.Issuer, claims.Subject); findErr == nil {
+			return i.users.FindByID(ctx, Identity.UserID)
+		}
+
+		return nil, err
+	}
+
+	return User, nil
+}
+
+// username uses the preferred_username claim when it follows the username rules and it's not taken by other user,
+// otherwise it's derived from the issuer and the subject so it's still the same when it's created concurrently.
+func (i *Identities) username(ctx context.Context, claims *Claims) string {
+	if ValidateUsername(claims.Username) == nil {
+		if _, err := i.users.FindByUsername(ctx, claims.Username); err == db.ErrNoRows {
+			return claims.Username
+		}
+	}
+
+	sum := sha256.Sum256([]byte(claims.Issuer + "\n" + claims.Subject))
+	return "sso_" + hex.EncodeToString(sum[:8])
+}
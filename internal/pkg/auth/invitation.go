@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/mail"
+)
+
+var (
+	// ErrInvitationInvalid is returned when the invitation token is unknown, expired or already accepted.
+	ErrInvitationInvalid = errors.New("invitation is invalid, expired or already accepted")
+
+	// ErrAlreadyMember is returned when the user who accepts the invitation is already the member of the organization.
+	ErrAlreadyMember = errors.New("user is already a member of the organization")
+)
+
+// InvitationConfig is the configuration of the organization invitation.
+type InvitationConfig struct {
+	// Lifetime is how long the invitation can be accepted after it's sent.
+	Lifetime time.Duration
+
+	// URL is the page of the client which accepts the invitation, the token is added as "token" query parameter.
+	// When it's empty, the email only contains the token.
+	URL string
+}
+
+// Invitations sends the invitation token to the email, the token can be used once by any logged in user to join the organization.
+// Only the hash of the token is saved, like the password reset token.
+type Invitations struct {
+	organizations repo.OrganizationRepository
+	invitations   repo.InvitationRepository
+	mailer        mail.Mailer
+	conf          InvitationConfig
+	now           func() time.Time
+}
+
+// NewInvitations creates the invitation using the repositories and the mailer.
+func NewInvitations(organizations repo.OrganizationRepository, invitations repo.InvitationRepository, mailer mail.Mailer, conf InvitationConfig) *Invitations {
+	return &Invitations{
+		organizations: organizations,
+		invitations:   invitations,
+		mailer:        mailer,
+		conf:          conf,
+		now:           time.Now,
+	}
+}
+
+// Invite sends the invitation token to the email, the user who accepts it joins the organization using the role.
+func (i *Invitations) Invite(ctx context.Context, Organization *model.Organization, Inviter *model.User, email, role string) (*model.OrganizationInvitation, error) {
+	token, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := i.now().Add(i.conf.Lifetime)
+	Invitation, err := i.invitations.Create(ctx, Organization.ID, email, role, HashRefreshToken(token), Inviter.ID, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = i.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: fmt.Sprintf("Join %s on Tax Calculator", Organization.Name),
+		Body:    i.body(Organization, Inviter, token),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return Invitation, nil
+}
+
+// Accept adds the user into the organization of the invitation. The invitation is not used
+// when the user is already the member, so it can still be given to the right person.
+func (i *Invitations) Accept(ctx context.Context, token string, User *model.User) (*model.OrganizationMember, error) {
+	Invitation, err := i.invitations.FindByTokenHash(ctx, HashRefreshToken(token))
+	if err == db.ErrNoRows {
+		return nil, ErrInvitationInvalid
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := i.now()
+	if Invitation.AcceptedAt != nil || !now.Before(Invitation.ExpiresAt) {
+		return nil, ErrInvitationInvalid
+	}
+
+	_, err = i.organizations.FindMember(ctx, Invitation.OrgID, User.ID)
+	if err == nil {
+		return nil, ErrAlreadyMember
+	}
+
+	if err != db.ErrNoRows {
+		return nil, err
+	}
+
+	// the update only succeeds once, so the same invitation can't be accepted by two requests at the same time
+	_, err = i.invitations.Accept(ctx, Invitation.ID, User.ID, now)
+	if err == db.ErrNoRows {
+		return nil, ErrInvitationInvalid
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return i.organizations.AddMember(ctx, Invitation.OrgID, User.ID, Invitation.Role)
+}
+
+func (i *Invitations) body(Organization *model.Organization, Inviter *model.User, token string) string {
+	lifetime := i.conf.Lifetime.String()
+	if i.conf.URL == "" {
+		return fmt.Sprintf("Hi,\n\n%s invites you to join %s. Login and use this token to accept it within %s:\n\n%s\n\n"+
+			"Ignore this email if you don't know them.\n", Inviter.Username, Organization.Name, lifetime, token)
+	}
+
+	link, err := url.Parse(i.conf.URL)
+	if err != nil {
+		link = &url.URL{Path: i.conf.URL}
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return fmt.Sprintf("Hi,\n\n%s invites you to join %s. Open this link to accept it within %s:\n\n%s\n\n"+
+		"Ignore this email if you don't know them.\n", Inviter.Username, Organization.Name, lifetime, link.String())
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/invitation"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/organization"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/user"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/mail"
+)
+
+func TestInvitations(t *testing.T) {
+	convey.Convey("Test Invitations", t, func() {
+		ctx := context.Background()
+		dbConn, err := db.NewMemoryConnection()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(conn.MigrateUp(ctx, dbConn), convey.ShouldBeNil)
+		defer dbConn.Close()
+
+		users := user.NewRepository(dbConn)
+		Owner, err := users.Create(ctx, "john_doe", "secret", nil)
+		convey.So(err, convey.ShouldBeNil)
+		Invitee, err := users.Create(ctx, "jane_doe", "secret", nil)
+		convey.So(err, convey.ShouldBeNil)
+
+		organizations := organization.NewRepository(dbConn)
+		Organization, err := organizations.Create(ctx, "Finance")
+		convey.So(err, convey.ShouldBeNil)
+		_, err = organizations.AddMember(ctx, Organization.ID, Owner.ID, model.OrgRoleOwner)
+		convey.So(err, convey.ShouldBeNil)
+
+		now := time.Now()
+		mailer := mail.NewMemoryMailer()
+		invitations := NewInvitations(organizations, invitation.NewRepository(dbConn), mailer, InvitationConfig{
+			Lifetime: time.Hour,
+			URL:      "https://example.com/invitation",
+		})
+		invitations.now = func() time.Time { return now }
+
+		Invitation, err := invitations.Invite(ctx, Organization, Owner, "jane@example.com", model.OrgRoleViewer)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(Invitation.InvitedBy, convey.ShouldResemble, &Owner.ID)
+		convey.So(mailer.Messages(), convey.ShouldHaveLength, 1)
+		convey.So(mailer.Messages()[0].To, convey.ShouldEqual, "jane@example.com")
+		convey.So(mailer.Messages()[0].Body, convey.ShouldContainSubstring, "john_doe invites you to join Finance")
+
+		link, err := url.Parse(resetLinkPattern.FindString(mailer.Messages()[0].Body))
+		convey.So(err, convey.ShouldBeNil)
+		token := link.Query().Get("token")
+
+		convey.Convey("Invitation adds the user with the role once", func() {
+			Member, err := invitations.Accept(ctx, token, Invitee)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(Member.OrgID, convey.ShouldEqual, Organization.ID)
+			convey.So(Member.Role, convey.ShouldEqual, model.OrgRoleViewer)
+
+			_, err = invitations.Accept(ctx, token, Invitee)
+			convey.So(err, convey.ShouldEqual, ErrInvitationInvalid)
+		})
+
+		convey.Convey("Member can't use the invitation", func() {
+			_, err := invitations.Accept(ctx, token, Owner)
+			convey.So(err, convey.ShouldEqual, ErrAlreadyMember)
+
+			// so it can still be accepted by the invited user
+			_, err = invitations.Accept(ctx, token, Invitee)
+			convey.So(err, convey.ShouldBeNil)
+		})
+
+		convey.Convey("Expired or unknown invitation is rejected", func() {
+			now = now.Add(time.Hour)
+			_, err := invitations.Accept(ctx, token, Invitee)
+			convey.So(err, convey.ShouldEqual, ErrInvitationInvalid)
+
+			_, err = invitations.Accept(ctx, "unknown", Invitee)
+			convey.So(err, convey.ShouldEqual, ErrInvitationInvalid)
+		})
+	})
+}
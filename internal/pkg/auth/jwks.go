@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"github.com/gbrlsnchs/jwt"
+)
+
+// JWKS is the JSON Web Key Set (RFC 7517), which publishes the public keys to verify the token.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a public key in JSON Web Key format, only RSA, P-256 EC and Ed25519 keys are supported.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA public key
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC (crv P-256, x and y) and OKP (crv Ed25519 and x) public key
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS returns the public keys of the signing key and the verification keys.
+// HS256 key is never published, so other services can only verify the token signed using RS256, ES256 or EdDSA.
+func (t *Tokens) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range append([]*Key{t.signingKey}, t.verificationKeys...) {
+		if key.Public == nil {
+			continue
+		}
+
+		jwk, err := newJWK(key)
+		if err != nil {
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+func newJWK(key *Key) (JWK, error) {
+	jwk := JWK{Use: "sig", Kid: key.ID, Alg: key.Algorithm()}
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeBigInt(pub.N)
+		jwk.E = encodeBigInt(big.NewInt(int64(pub.E)))
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JWK{}, fmt.Errorf("key type %T cannot be published", key.Public)
+	}
+
+	return jwk, nil
+}
+
+// Key converts the JWK into the verification key.
+// When "alg" is empty, it's taken from the key type, since some issuers don't publish it.
+func (jwk JWK) Key() (*Key, error) {
+	var public interface{}
+	var algorithm string
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+
+		public, algorithm = &rsa.PublicKey{N: n, E: int(e.Int64())}, jwt.MethodRS256
+
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported EC curve %s", jwk.Crv)
+		}
+
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, fmt.Errorf("EC key %s is not on P-256 curve", jwk.Kid)
+		}
+
+		public, algorithm = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, jwt.MethodES256
+
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+
+		if jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("unsupported OKP key %s", jwk.Crv)
+		}
+
+		public, algorithm = ed25519.PublicKey(x), MethodEdDSA
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", jwk.Kty)
+	}
+
+	if jwk.Alg != "" && jwk.Alg != algorithm {
+		return nil, fmt.Errorf("unsupported algorithm %s of %s key", jwk.Alg, jwk.Kty)
+	}
+
+	signer, err := newSigner(algorithm, nil, public)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{ID: jwk.Kid, Signer: signer, Public: public}, nil
+}
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/gbrlsnchs/jwt"
+)
+
+func TestJWKS(t *testing.T) {
+	ctx := context.Background()
+	for _, algorithm := range []string{jwt.MethodRS256, jwt.MethodES256, MethodEdDSA} {
+		private, _ := newKeyPair(t, algorithm)
+		signingKey, err := NewKey("", algorithm, private)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tokens := newTokens(t, signingKey, newSecretKey(t, "old", secretKey))
+		token, err := tokens.GenerateJWTToken(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// published as JSON, then read by other service
+		content, err := json.Marshal(tokens.JWKS())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		jwks := JWKS{}
+		if err := json.Unmarshal(content, &jwks); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(jwks.Keys) != 1 {
+			t.Fatalf("%s: HS256 key must not be published, got %d keys", algorithm, len(jwks.Keys))
+		}
+
+		key, err := jwks.Keys[0].Key()
+		if err != nil {
+			t.Fatalf("%s: %s", algorithm, err)
+		}
+
+		verifier := newTokens(t, newSecretKey(t, "", secretKey), key)
+		if _, err := verifier.ValidateJWTToken(ctx, token); err != nil {
+			t.Errorf("%s: %s", algorithm, err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/gbrlsnchs/jwt"
+)
+
+// minSecretLength is the minimum HS256 secret length, it's the same as the SHA-256 output size as suggested by RFC 7518.
+const minSecretLength = 32
+
+// Algorithms are the supported "alg" of the token.
+var Algorithms = []string{jwt.MethodHS256, jwt.MethodRS256, jwt.MethodES256, MethodEdDSA}
+
+// Key is a key to sign and verify the token, it's identified by the "kid" header of the token.
+type Key struct {
+	ID     string
+	Signer jwt.Signer
+
+	// Public is the public key of RS256, ES256 or EdDSA key, it's nil for HS256.
+	Public interface{}
+
+	// canSign is false when only the public key is known.
+	canSign bool
+}
+
+// Algorithm returns the "alg" of the token signed by this key.
+func (k *Key) Algorithm() string {
+	return k.Signer.String()
+}
+
+// LoadKey reads the key of the algorithm from a file.
+// The file of HS256 key contains the secret, trailing new line is removed.
+// The file of other algorithms contains the PEM encoded private or public key.
+func LoadKey(id, algorithm, path string) (*Key, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if algorithm == jwt.MethodHS256 {
+		content = []byte(strings.TrimRight(string(content), "\r\n"))
+	}
+
+	key, err := NewKey(id, algorithm, content)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load key %s: %s", path, err.Error())
+	}
+
+	return key, nil
+}
+
+// NewKey creates the key of the algorithm, data is the secret for HS256 or the PEM encoded private or public key otherwise.
+// When id is empty, it's derived from the secret or the public key, so every instance using the same key has the same kid.
+func NewKey(id, algorithm string, data []byte) (*Key, error) {
+	if algorithm == jwt.MethodHS256 {
+		if len(data) < minSecretLength {
+			return nil, fmt.Errorf("HS256 secret must be at least %d bytes", minSecretLength)
+		}
+
+		if id == "" {
+			id = deriveKeyID(data)
+		}
+
+		return &Key{ID: id, Signer: jwt.NewHS256(string(data)), canSign: true}, nil
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s key must be PEM encoded", algorithm)
+	}
+
+	private, public, err := parsePEMBlock(block)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := newSigner(algorithm, private, public)
+	if err != nil {
+		return nil, err
+	}
+
+	if id == "" {
+		der, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return nil, err
+		}
+
+		id = deriveKeyID(der)
+	}
+
+	return &Key{ID: id, Signer: signer, Public: public, canSign: private != nil}, nil
+}
+
+// parsePEMBlock returns the private key (nil when the block is a public key) and the public key.
+func parsePEMBlock(block *pem.Block) (private, public interface{}, err error) {
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+		return nil, public, err
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		return nil, public, err
+	default:
+		return nil, nil, fmt.Errorf("unsupported PEM block %s", block.Type)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch key := private.(type) {
+	case *rsa.PrivateKey:
+		public = &key.PublicKey
+	case *ecdsa.PrivateKey:
+		public = &key.PublicKey
+	case ed25519.PrivateKey:
+		public = key.Public()
+	}
+
+	return private, public, nil
+}
+
+// newSigner creates the signer of the algorithm, the key type must match the algorithm.
+func newSigner(algorithm string, private, public interface{}) (jwt.Signer, error) {
+	switch algorithm {
+	case jwt.MethodRS256:
+		pub, ok := public.(*rsa.PublicKey)
+		if !ok {
+			break
+		}
+
+		priv, _ := private.(*rsa.PrivateKey)
+		return jwt.NewRS256(priv, pub), nil
+
+	case jwt.MethodES256:
+		pub, ok := public.(*ecdsa.PublicKey)
+		if !ok {
+			break
+		}
+
+		if pub.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 key must use P-256 curve, got %s", pub.Curve.Params().Name)
+		}
+
+		priv, _ := private.(*ecdsa.PrivateKey)
+		return jwt.NewES256(priv, pub), nil
+
+	case MethodEdDSA:
+		pub, ok := public.(ed25519.PublicKey)
+		if !ok {
+			break
+		}
+
+		priv, _ := private.(ed25519.PrivateKey)
+		return newEdDSA(priv, pub), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %s, must be one of %s", algorithm, strings.Join(Algorithms, ", "))
+	}
+
+	return nil, fmt.Errorf("key type %T cannot be used for %s", public, algorithm)
+}
+
+func deriveKeyID(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// oidcKeysRefreshInterval limits how often the keys are fetched again when the token has unknown kid,
+	// so random kid in forged tokens can't make us flood the issuer.
+	oidcKeysRefreshInterval = time.Minute
+
+	// oidcClockSkew is the tolerated clock difference between the issuer and this server.
+	oidcClockSkew = time.Minute
+)
+
+var (
+	// ErrAudienceMismatch is returned when the "aud" claim of the external token doesn't contain the configured audience.
+	ErrAudienceMismatch = errors.New("jwt: aud claim is invalid")
+	// ErrTokenExpired is returned when the external token is expired or not valid yet.
+	ErrTokenExpired = errors.New("jwt: token is expired or not valid yet")
+)
+
+// OIDCConfig is the configuration of the external OpenID Connect issuer.
+type OIDCConfig struct {
+	// IssuerURL is the "iss" claim of the token, the keys are discovered from IssuerURL/.well-known/openid-configuration.
+	IssuerURL string
+
+	// Audience must be one of the "aud" claim, it's usually the client id of this application in the issuer.
+	Audience string
+
+	// HTTPClient fetches the discovery document and the keys, http.Client with 10 seconds timeout is used when it's nil.
+	HTTPClient *http.Client
+}
+
+// OIDCIssuer verifies the token issued by an external OpenID Connect issuer, such as the company SSO.
+// The keys are fetched when the first token is verified and every time the token has unknown kid,
+// so the issuer doesn't need to be up when this server starts and its key rotation is followed.
+type OIDCIssuer struct {
+	conf   OIDCConfig
+	client *http.Client
+	now    func() time.Time
+
+	sync.Mutex
+	keys      map[string]*Key
+	fetchedAt time.Time
+}
+
+// NewOIDCIssuer creates the verifier of the external issuer.
+func NewOIDCIssuer(conf OIDCConfig) (*OIDCIssuer, error) {
+	if conf.IssuerURL == "" {
+		return nil, fmt.Errorf("oidc issuer url is required")
+	}
+
+	if conf.Audience == "" {
+		return nil, fmt.Errorf("oidc audience is required")
+	}
+
+	client := conf.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &OIDCIssuer{
+		conf:   conf,
+		client: client,
+		now:    time.Now,
+		keys:   map[string]*Key{},
+	}, nil
+}
+
+// oidcClaims are the claims of the external token. Audience can be a string or an array of string.
+type oidcClaims struct {
+	Issuer            string          `json:"iss"`
+	Subject           string          `json:"sub"`
+	Audience          json.RawMessage `json:"aud"`
+	ExpirationTime    int64           `json:"exp"`
+	NotBefore         int64           `json:"nbf"`
+	IssuedAt          int64           `json:"iat"`
+	PreferredUsername string          `json:"preferred_username"`
+}
+
+// verify verifies the signature and the claims of the token, payload is the header and claims part of the token.
+func (o *OIDCIssuer) verify(ctx context.Context, header *tokenHeader, payload, sig []byte, rawClaims []byte) (*Claims, error) {
+	key, err := o.key(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Algorithm != key.Algorithm() {
+		return nil, ErrAlgorithmMismatch
+	}
+
+	if err := key.Signer.Verify(payload, sig); err != nil {
+		return nil, err
+	}
+
+	claims := &oidcClaims{}
+	if err := json.Unmarshal(rawClaims, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("jwt: sub claim is required")
+	}
+
+	if !containsAudience(claims.Audience, o.conf.Audience) {
+		return nil, ErrAudienceMismatch
+	}
+
+	now := o.now()
+	if claims.ExpirationTime == 0 || !now.Before(time.Unix(claims.ExpirationTime, 0).Add(oidcClockSkew)) {
+		return nil, ErrTokenExpired
+	}
+
+	if claims.NotBefore != 0 && now.Add(oidcClockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, ErrTokenExpired
+	}
+
+	var issuedAt time.Time
+	if claims.IssuedAt != 0 {
+		issuedAt = time.Unix(claims.IssuedAt, 0)
+	}
+
+	return &Claims{
+		Issuer:   claims.Issuer,
+		Subject:  claims.Subject,
+		Username: claims.PreferredUsername,
+		IssuedAt: issuedAt,
+	}, nil
+}
+
+// key returns the key of the kid, token without kid can only be verified when the issuer has one key.
+func (o *OIDCIssuer) key(ctx context.Context, kid string) (*Key, error) {
+	o.Lock()
+	defer o.Unlock()
+
+	if key, ok := o.findKey(kid); ok {
+		return key, nil
+	}
+
+	if !o.fetchedAt.IsZero() && o.now().Sub(o.fetchedAt) < oidcKeysRefreshInterval {
+		return nil, ErrUnknownKeyID
+	}
+
+	keys, err := o.fetchKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch keys of oidc issuer %s: %s", o.conf.IssuerURL, err.Error())
+	}
+
+	o.keys = keys
+	o.fetchedAt = o.now()
+
+	if key, ok := o.findKey(kid); ok {
+		return key, nil
+	}
+
+	return nil, ErrUnknownKeyID
+}
+
+func (o *OIDCIssuer) findKey(kid string) (*Key, bool) {
+	if kid == "" && len(o.keys) == 1 {
+		for _, key := range o.keys {
+			return key, true
+		}
+	}
+
+	key, ok := o.keys[kid]
+	return key, ok
+}
+
+// fetchKeys reads the jwks_uri from the discovery document, then fetches the keys from it.
+// Key which is not supported, such as encryption key, is skipped.
+func (o *OIDCIssuer) fetchKeys(ctx context.Context) (map[string]*Key, error) {
+	discovery := struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}{}
+
+	discoveryURL := strings.TrimSuffix(o.conf.IssuerURL, "/") + "/.well-known/openid-configuration"
+	if err := o.getJSON(ctx, discoveryURL, &discovery); err != nil {
+		return nil, err
+	}
+
+	if discovery.Issuer != o.conf.IssuerURL {
+		return nil, fmt.Errorf("discovery document is for issuer %s", discovery.Issuer)
+	}
+
+	jwks := JWKS{}
+	if err := o.getJSON(ctx, discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*Key{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.Key()
+		if err != nil {
+			continue
+		}
+
+		keys[key.ID] = key
+	}
+
+	return keys, nil
+}
+
+func (o *OIDCIssuer) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := o.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returns status %d", url, res.StatusCode)
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func containsAudience(raw json.RawMessage, audience string) bool {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return single == audience
+	}
+
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return false
+	}
+
+	for _, aud := range many {
+		if aud == audience {
+			return true
+		}
+	}
+
+	return false
+}
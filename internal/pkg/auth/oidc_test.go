@@ -0,0 +1,133 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth/oidctest"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/identity"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/user"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+const audience = "tax-calculator"
+
+func TestOIDCIssuer(t *testing.T) {
+	convey.Convey("Test OIDC Issuer", t, func() {
+		ctx := context.Background()
+		issuer, err := oidctest.NewIssuer()
+		convey.So(err, convey.ShouldBeNil)
+		defer issuer.Close()
+
+		external, err := auth.NewOIDCIssuer(auth.OIDCConfig{IssuerURL: issuer.URL, Audience: audience})
+		convey.So(err, convey.ShouldBeNil)
+
+		key, err := auth.NewKey("", "HS256", []byte("my-secret-which-is-at-least-32-bytes"))
+		convey.So(err, convey.ShouldBeNil)
+
+		tokens, err := auth.NewTokens(auth.TokenConfig{
+			SigningKey:      key,
+			Lifetime:        time.Hour,
+			RefreshLifetime: time.Hour,
+			Issuers:         []*auth.OIDCIssuer{external},
+		})
+		convey.So(err, convey.ShouldBeNil)
+
+		convey.Convey("Token of the issuer is accepted", func() {
+			token, err := issuer.Token("user-1", audience, map[string]interface{}{"preferred_username": "john_doe"})
+			convey.So(err, convey.ShouldBeNil)
+
+			claims, err := tokens.ValidateJWTToken(ctx, token)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(claims.External(), convey.ShouldBeTrue)
+			convey.So(claims.IssuedAt.IsZero(), convey.ShouldBeFalse)
+
+			claims.IssuedAt = time.Time{}
+			convey.So(claims, convey.ShouldResemble, &auth.Claims{Issuer: issuer.URL, Subject: "user-1", Username: "john_doe"})
+		})
+
+		convey.Convey("Audience can be a string", func() {
+			token, err := issuer.Token("user-1", audience, map[string]interface{}{"aud": audience})
+			convey.So(err, convey.ShouldBeNil)
+
+			_, err = tokens.ValidateJWTToken(ctx, token)
+			convey.So(err, convey.ShouldBeNil)
+		})
+
+		convey.Convey("Token for other audience is rejected", func() {
+			token, err := issuer.Token("user-1", "other-service", nil)
+			convey.So(err, convey.ShouldBeNil)
+
+			_, err = tokens.ValidateJWTToken(ctx, token)
+			convey.So(err, convey.ShouldEqual, auth.ErrAudienceMismatch)
+		})
+
+		convey.Convey("Expired token is rejected", func() {
+			token, err := issuer.Token("user-1", audience, map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})
+			convey.So(err, convey.ShouldBeNil)
+
+			_, err = tokens.ValidateJWTToken(ctx, token)
+			convey.So(err, convey.ShouldEqual, auth.ErrTokenExpired)
+		})
+
+		convey.Convey("Token signed by other key using the same issuer is rejected", func() {
+			forger, err := oidctest.NewIssuer()
+			convey.So(err, convey.ShouldBeNil)
+			defer forger.Close()
+
+			token, err := forger.Token("user-1", audience, map[string]interface{}{"iss": issuer.URL})
+			convey.So(err, convey.ShouldBeNil)
+
+			_, err = tokens.ValidateJWTToken(ctx, token)
+			convey.So(err, convey.ShouldEqual, auth.ErrUnknownKeyID)
+		})
+
+		convey.Convey("Token of unknown issuer is verified as local token", func() {
+			other, err := oidctest.NewIssuer()
+			convey.So(err, convey.ShouldBeNil)
+			defer other.Close()
+
+			token, err := other.Token("user-1", audience, nil)
+			convey.So(err, convey.ShouldBeNil)
+
+			_, err = tokens.ValidateJWTToken(ctx, token)
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
+
+func TestIdentities(t *testing.T) {
+	convey.Convey("Test Identities", t, func() {
+		ctx := context.Background()
+		dbConn, err := db.NewMemoryConnection()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(conn.MigrateUp(ctx, dbConn), convey.ShouldBeNil)
+		defer dbConn.Close()
+
+		users := user.NewRepository(dbConn)
+		identities := auth.NewIdentities(users, identity.NewRepository(dbConn))
+
+		claims := &auth.Claims{Issuer: "https://sso.example.com", Subject: "user-1", Username: "john_doe"}
+		User, err := identities.Resolve(ctx, claims)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(User.Username, convey.ShouldEqual, "john_doe")
+		convey.So(auth.CheckPasswordHash("", User.Password), convey.ShouldBeFalse)
+
+		convey.Convey("The same subject is the same user", func() {
+			again, err := identities.Resolve(ctx, &auth.Claims{Issuer: claims.Issuer, Subject: claims.Subject, Username: "renamed"})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(again.ID, convey.ShouldEqual, User.ID)
+		})
+
+		convey.Convey("Taken username is not used", func() {
+			other, err := identities.Resolve(ctx, &auth.Claims{Issuer: claims.Issuer, Subject: "user-2", Username: "john_doe"})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(other.ID, convey.ShouldNotEqual, User.ID)
+			convey.So(other.Username, convey.ShouldStartWith, "sso_")
+		})
+	})
+}
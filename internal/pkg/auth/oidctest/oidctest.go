@@ -0,0 +1,100 @@
+// Package oidctest runs a stub OpenID Connect issuer, so the verification of the external token can be tested
+// without a real SSO. It's like net/http/httptest, it must only be used in test.
+package oidctest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
+)
+
+// Issuer is the stub issuer which publishes the discovery document and the keys, and signs the token using ES256.
+type Issuer struct {
+	*httptest.Server
+
+	key *auth.Key
+}
+
+// NewIssuer starts the stub issuer, call Close when it's done.
+func NewIssuer() (*Issuer, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := auth.NewKey("", "ES256", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := &Issuer{key: key}
+
+	// the tokens are issued by this server, so only its public key is published
+	public, err := auth.NewTokens(auth.TokenConfig{
+		SigningKey:      key,
+		Lifetime:        time.Hour,
+		RefreshLifetime: time.Hour,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   issuer.URL,
+			"jwks_uri": issuer.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(public.JWKS())
+	})
+
+	issuer.Server = httptest.NewServer(mux)
+	return issuer, nil
+}
+
+// Token signs the token of the subject for the audience, it's valid for an hour.
+// The extra claims, such as preferred_username, overwrite the default claims.
+func (i *Issuer) Token(subject, audience string, extra map[string]interface{}) (string, error) {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": i.URL,
+		"sub": subject,
+		"aud": []string{audience},
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+
+	for name, value := range extra {
+		claims[name] = value
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": i.key.Algorithm(), "kid": i.key.ID, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := i.key.Signer.Sign([]byte(base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)))
+	return string(token), err
+}
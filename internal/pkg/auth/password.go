@@ -1,9 +1,16 @@
 package auth
 
 import (
+	"sync"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	dummyPasswordHash     []byte
+	dummyPasswordHashOnce sync.Once
+)
+
 // HashPassword will hash the user password using bcrypt algorithm with cost 10
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -15,3 +22,13 @@ func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// SimulatePasswordCheck takes as long as CheckPasswordHash, it's called when the user is not found,
+// so the response time doesn't tell whether the username exists.
+func SimulatePasswordCheck(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	})
+
+	bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxPasswordLength is the limit of bcrypt, the bytes after it are ignored so longer password gives false sense of security.
+const maxPasswordLength = 72
+
+// usernamePattern starts with a letter, followed by letters, digits, underscore, dot or hyphen, 3 to 32 characters in total.
+// The generated usernames such as sso_<hash> and demo_user_<n> follow it too.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]{2,31}$`)
+
+var (
+	// ErrUsernameInvalid is returned when the username doesn't follow the username rules.
+	ErrUsernameInvalid = errors.New("must be 3 to 32 characters of letters, digits, underscore, dot or hyphen, starting with a letter")
+	// ErrPasswordTooLong is returned when the password is longer than bcrypt can hash.
+	ErrPasswordTooLong = fmt.Errorf("must not be longer than %d bytes", maxPasswordLength)
+	// ErrPasswordContainsUsername is returned when the username is part of the password.
+	ErrPasswordContainsUsername = errors.New("must not contain the username")
+	// ErrPasswordBreached is returned when the password is in the breached password list.
+	ErrPasswordBreached = errors.New("is found in the list of breached passwords, choose another one")
+)
+
+// ValidateUsername checks the username rules. The case is kept, but the username is unique regardless of the case.
+func ValidateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return ErrUsernameInvalid
+	}
+
+	return nil
+}
+
+// PasswordPolicyConfig is the configuration of the password rules.
+type PasswordPolicyConfig struct {
+	// MinLength is the minimum number of characters of the password.
+	MinLength int
+
+	// BreachedPasswordsFile is the file containing the breached passwords, one per line, empty to skip the check.
+	// The line is either the password itself, or its SHA-1 hash in hex optionally followed by ":count"
+	// like the downloaded Have I Been Pwned list.
+	BreachedPasswordsFile string
+}
+
+// PasswordPolicy checks the password when the user registers or changes the password.
+type PasswordPolicy struct {
+	minLength int
+	breached  map[string]struct{} // upper case hex of SHA-1 hash of the password
+}
+
+// NewPasswordPolicy creates the policy, the breached password list is loaded into memory.
+func NewPasswordPolicy(conf PasswordPolicyConfig) (*PasswordPolicy, error) {
+	if conf.MinLength > maxPasswordLength {
+		return nil, fmt.Errorf("password min length must not be longer than %d", maxPasswordLength)
+	}
+
+	policy := &PasswordPolicy{
+		minLength: conf.MinLength,
+		breached:  map[string]struct{}{},
+	}
+
+	if conf.BreachedPasswordsFile == "" {
+		return policy, nil
+	}
+
+	f, err := os.Open(conf.BreachedPasswordsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if hash := strings.SplitN(line, ":", 2)[0]; isSHA1Hex(hash) {
+			policy.breached[strings.ToUpper(hash)] = struct{}{}
+			continue
+		}
+
+		policy.breached[sha1Hex(line)] = struct{}{}
+	}
+
+	return policy, scanner.Err()
+}
+
+// Validate returns the first rule which the password breaks, the error message is meant to be shown to the user.
+func (p *PasswordPolicy) Validate(username, password string) error {
+	if utf8.RuneCountInString(password) < p.minLength {
+		return fmt.Errorf("must be at least %d characters", p.minLength)
+	}
+
+	if len(password) > maxPasswordLength {
+		return ErrPasswordTooLong
+	}
+
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return ErrPasswordContainsUsername
+	}
+
+	if _, ok := p.breached[sha1Hex(password)]; ok {
+		return ErrPasswordBreached
+	}
+
+	return nil
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != sha1.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestValidateUsername(t *testing.T) {
+	for _, username := range []string{"john_doe", "John.Doe", "demo_user_12", "sso_0123456789abcdef"} {
+		if err := ValidateUsername(username); err != nil {
+			t.Errorf("%s must be valid: %s", username, err)
+		}
+	}
+
+	for _, username := range []string{"", "jo", "1john", "_john", "john doe", "john@example.com", "a23456789012345678901234567890123"} {
+		if err := ValidateUsername(username); err == nil {
+			t.Errorf("%s must be invalid", username)
+		}
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	f, err := ioutil.TempFile("", "breached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	// "password1" in plain text, and SHA-1 of "letmein123" in lower case like the pwned list with count
+	f.WriteString("password1\n\ne286977b13f1a89e20d0459207545d15fe1eba08:42\n")
+	f.Close()
+
+	policy, err := NewPasswordPolicy(PasswordPolicyConfig{MinLength: 8, BreachedPasswordsFile: f.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]error{
+		"correct horse battery":  nil,
+		"  spaced password  ":    nil,
+		"xJohn_Doe123":           ErrPasswordContainsUsername,
+		"password1":              ErrPasswordBreached,
+		"letmein123":             ErrPasswordBreached,
+		string(make([]byte, 73)): ErrPasswordTooLong,
+	}
+
+	for password, expected := range cases {
+		if err := policy.Validate("john_doe", password); err != expected {
+			t.Errorf("%q: expected %v, got %v", password, expected, err)
+		}
+	}
+
+	if err := policy.Validate("john_doe", "short"); err == nil {
+		t.Error("short password must be rejected")
+	}
+
+	if _, err := NewPasswordPolicy(PasswordPolicyConfig{BreachedPasswordsFile: "/not/exist"}); err == nil {
+		t.Error("missing breached password file must be an error")
+	}
+}
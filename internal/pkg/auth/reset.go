@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/mail"
+)
+
+// ErrPasswordResetTokenInvalid is returned when the reset token is unknown, expired or already used.
+var ErrPasswordResetTokenInvalid = errors.New("password reset token is invalid, expired or already used")
+
+// PasswordResetConfig is the configuration of the password reset.
+type PasswordResetConfig struct {
+	// Lifetime is how long the reset token can be used after it's sent.
+	Lifetime time.Duration
+
+	// URL is the page of the client which asks the new password, the token is added as "token" query parameter.
+	// When it's empty, the email only contains the token.
+	URL string
+}
+
+// PasswordResets sends the reset token to the email of the user, the token can be used once to set a new password.
+// Only the hash of the token is saved, like the refresh token.
+type PasswordResets struct {
+	users  repo.UserRepository
+	resets repo.PasswordResetRepository
+	mailer mail.Mailer
+	conf   PasswordResetConfig
+	now    func() time.Time
+}
+
+// NewPasswordResets creates the password reset using the repositories and the mailer.
+func NewPasswordResets(users repo.UserRepository, resets repo.PasswordResetRepository, mailer mail.Mailer, conf PasswordResetConfig) *PasswordResets {
+	return &PasswordResets{
+		users:  users,
+		resets: resets,
+		mailer: mailer,
+		conf:   conf,
+		now:    time.Now,
+	}
+}
+
+// Request sends the reset token to the email. Unknown email is not an error,
+// so the caller can't tell whether the email is registered.
+func (p *PasswordResets) Request(ctx context.Context, email string) error {
+	User, err := p.users.FindByEmail(ctx, email)
+	if err == db.ErrNoRows {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	token, err := randomString(32)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := p.now().Add(p.conf.Lifetime)
+	if _, err := p.resets.Create(ctx, User.ID, HashRefreshToken(token), expiresAt); err != nil {
+		return err
+	}
+
+	return p.mailer.Send(ctx, mail.Message{
+		To:      *User.Email,
+		Subject: "Reset your password",
+		Body:    p.body(User, token),
+	})
+}
+
+// Verify returns the reset token when it can still be used, so the new password can be checked before the token is consumed.
+func (p *PasswordResets) Verify(ctx context.Context, token string) (*model.PasswordResetToken, error) {
+	PasswordResetToken, err := p.resets.FindByTokenHash(ctx, HashRefreshToken(token))
+	if err == db.ErrNoRows {
+		return nil, ErrPasswordResetTokenInvalid
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if PasswordResetToken.UsedAt != nil || !p.now().Before(PasswordResetToken.ExpiresAt) {
+		return nil, ErrPasswordResetTokenInvalid
+	}
+
+	return PasswordResetToken, nil
+}
+
+// Consume marks the reset token as used, along with the other tokens of the same user.
+// The update only succeeds once, so two requests using the same token at the same time can't both reset the password.
+func (p *PasswordResets) Consume(ctx context.Context, PasswordResetToken *model.PasswordResetToken) error {
+	now := p.now()
+	_, err := p.resets.Consume(ctx, PasswordResetToken.ID, now)
+	if err == db.ErrNoRows {
+		return ErrPasswordResetTokenInvalid
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return p.resets.ConsumeByUserID(ctx, PasswordResetToken.UserID, now)
+}
+
+func (p *PasswordResets) body(User *model.User, token string) string {
+	lifetime := p.conf.Lifetime.String()
+	if p.conf.URL == "" {
+		return fmt.Sprintf("Hi %s,\n\nUse this token to reset your password within %s:\n\n%s\n\n"+
+			"Ignore this email if you didn't ask to reset your password.\n", User.Username, lifetime, token)
+	}
+
+	link, err := url.Parse(p.conf.URL)
+	if err != nil {
+		link = &url.URL{Path: p.conf.URL}
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return fmt.Sprintf("Hi %s,\n\nOpen this link to reset your password within %s:\n\n%s\n\n"+
+		"Ignore this email if you didn't ask to reset your password.\n", User.Username, lifetime, link.String())
+}
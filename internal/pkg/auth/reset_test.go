@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/passwordreset"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/user"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/mail"
+)
+
+var resetLinkPattern = regexp.MustCompile(`https://\S+`)
+
+func TestPasswordResets(t *testing.T) {
+	convey.Convey("Test PasswordResets", t, func() {
+		ctx := context.Background()
+		dbConn, err := db.NewMemoryConnection()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(conn.MigrateUp(ctx, dbConn), convey.ShouldBeNil)
+		defer dbConn.Close()
+
+		email := "John@Example.com"
+		User, err := user.NewRepository(dbConn).Create(ctx, "john_doe", "secret", &email)
+		convey.So(err, convey.ShouldBeNil)
+
+		mailer := mail.NewMemoryMailer()
+		resets := NewPasswordResets(user.NewRepository(dbConn), passwordreset.NewRepository(dbConn), mailer, PasswordResetConfig{
+			Lifetime: time.Hour,
+			URL:      "https://example.com/reset?lang=en",
+		})
+
+		// token returns the token in the link of the last sent email
+		token := func() string {
+			messages := mailer.Messages()
+			link, err := url.Parse(resetLinkPattern.FindString(messages[len(messages)-1].Body))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(link.Query().Get("lang"), convey.ShouldEqual, "en")
+			return link.Query().Get("token")
+		}
+
+		convey.Convey("Unknown email doesn't send anything", func() {
+			convey.So(resets.Request(ctx, "nobody@example.com"), convey.ShouldBeNil)
+			convey.So(mailer.Messages(), convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("Token is sent to the email regardless of the case", func() {
+			convey.So(resets.Request(ctx, "john@example.com"), convey.ShouldBeNil)
+			convey.So(mailer.Messages(), convey.ShouldHaveLength, 1)
+			convey.So(mailer.Messages()[0].To, convey.ShouldEqual, email)
+
+			PasswordResetToken, err := resets.Verify(ctx, token())
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(PasswordResetToken.UserID, convey.ShouldEqual, User.ID)
+
+			convey.Convey("Token can only be used once", func() {
+				convey.So(resets.Consume(ctx, PasswordResetToken), convey.ShouldBeNil)
+				convey.So(resets.Consume(ctx, PasswordResetToken), convey.ShouldEqual, ErrPasswordResetTokenInvalid)
+
+				_, err := resets.Verify(ctx, token())
+				convey.So(err, convey.ShouldEqual, ErrPasswordResetTokenInvalid)
+			})
+
+			convey.Convey("Using a token invalidates the other tokens of the user", func() {
+				first := token()
+				convey.So(resets.Request(ctx, email), convey.ShouldBeNil)
+
+				PasswordResetToken, err := resets.Verify(ctx, token())
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resets.Consume(ctx, PasswordResetToken), convey.ShouldBeNil)
+
+				_, err = resets.Verify(ctx, first)
+				convey.So(err, convey.ShouldEqual, ErrPasswordResetTokenInvalid)
+			})
+
+			convey.Convey("Expired token is rejected", func() {
+				resets.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+				_, err := resets.Verify(ctx, token())
+				convey.So(err, convey.ShouldEqual, ErrPasswordResetTokenInvalid)
+				convey.So(resets.Consume(ctx, PasswordResetToken), convey.ShouldEqual, ErrPasswordResetTokenInvalid)
+			})
+		})
+
+		convey.Convey("Unknown token is rejected", func() {
+			_, err := resets.Verify(ctx, "unknown")
+			convey.So(err, convey.ShouldEqual, ErrPasswordResetTokenInvalid)
+		})
+	})
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"sort"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+)
+
+// Roles loads the roles of the user and the permissions they grant.
+type Roles struct {
+	roles repo.RoleRepository
+}
+
+// NewRoles creates the loader using the repository.
+func NewRoles(roles repo.RoleRepository) *Roles {
+	return &Roles{
+		roles: roles,
+	}
+}
+
+// Load sets the roles and the permissions of the user. Every user has the "user" role,
+// and the permission granted by more than one role is only listed once.
+func (r *Roles) Load(ctx context.Context, User *model.User) error {
+	UserRoles, err := r.roles.GetByUserID(ctx, User.ID)
+	if err != nil {
+		return err
+	}
+
+	roles := []string{model.RoleUser}
+	for _, UserRole := range UserRoles {
+		if UserRole.Role != model.RoleUser {
+			roles = append(roles, UserRole.Role)
+		}
+	}
+
+	seen := map[string]bool{}
+	permissions := []string{}
+	for _, role := range roles {
+		RolePermissions, err := r.roles.GetPermissionsByRole(ctx, role)
+		if err != nil {
+			return err
+		}
+
+		for _, RolePermission := range RolePermissions {
+			if !seen[RolePermission.Permission] {
+				seen[RolePermission.Permission] = true
+				permissions = append(permissions, RolePermission.Permission)
+			}
+		}
+	}
+
+	sort.Strings(permissions)
+	User.Roles = roles
+	User.Permissions = permissions
+	return nil
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/role"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/user"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+func TestRoles(t *testing.T) {
+	convey.Convey("Test Roles", t, func() {
+		ctx := context.Background()
+		dbConn, err := db.NewMemoryConnection()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(conn.MigrateUp(ctx, dbConn), convey.ShouldBeNil)
+		defer dbConn.Close()
+
+		users := user.NewRepository(dbConn)
+		User, err := users.Create(ctx, "john_doe", "secret", nil)
+		convey.So(err, convey.ShouldBeNil)
+
+		roleRepo := role.NewRepository(dbConn)
+		roles := NewRoles(roleRepo)
+
+		convey.Convey("Every user has the user role", func() {
+			convey.So(roles.Load(ctx, User), convey.ShouldBeNil)
+			convey.So(User.Roles, convey.ShouldResemble, []string{model.RoleUser})
+			convey.So(User.Permissions, convey.ShouldResemble, []string{model.PermissionTaxRead, model.PermissionTaxWrite})
+			convey.So(User.HasPermission(model.PermissionUserRead), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Permissions of every role are merged", func() {
+			convey.So(roleRepo.Assign(ctx, User.ID, model.RoleAuditor), convey.ShouldBeNil)
+			convey.So(roleRepo.Assign(ctx, User.ID, model.RoleAdmin), convey.ShouldBeNil)
+			convey.So(roleRepo.Assign(ctx, User.ID, model.RoleAdmin), convey.ShouldBeNil)
+
+			convey.So(roles.Load(ctx, User), convey.ShouldBeNil)
+			convey.So(User.Roles, convey.ShouldResemble, []string{model.RoleUser, model.RoleAdmin, model.RoleAuditor})
+			convey.So(User.Permissions, convey.ShouldResemble, []string{
+				model.PermissionTaxRead, model.PermissionTaxReadAny, model.PermissionTaxWrite,
+				model.PermissionUserDisable, model.PermissionUserRead,
+			})
+		})
+
+		convey.Convey("Unknown role can't be assigned", func() {
+			convey.So(roleRepo.Assign(ctx, User.ID, "superuser"), convey.ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+var (
+	// ErrRefreshTokenInvalid is returned when the refresh token is unknown, expired or revoked.
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid, expired or revoked")
+	// ErrRefreshTokenReused is returned when the refresh token is already exchanged, every token in its family is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token is already used, every session from the same login is revoked")
+)
+
+// Session is the access token and the refresh token issued to the user.
+type Session struct {
+	UserID       int64
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration // lifetime of the access token
+}
+
+// Sessions issues the short-lived access token along with the rotating refresh token.
+// Every refresh token can be exchanged once, the new refresh token belongs to the same family as the old one.
+// When a used refresh token is presented again, either the legit user or the thief has a copy of it,
+// so the whole family is revoked and both of them must login again.
+type Sessions struct {
+	tokens        *Tokens
+	refreshTokens repo.RefreshTokenRepository
+	now           func() time.Time
+}
+
+// NewSessions creates the session issuer, the refresh token lifetime is taken from the Tokens configuration.
+func NewSessions(tokens *Tokens, refreshTokens repo.RefreshTokenRepository) *Sessions {
+	return &Sessions{
+		tokens:        tokens,
+		refreshTokens: refreshTokens,
+		now:           time.Now,
+	}
+}
+
+// Create starts a new session of the user after login or register.
+func (s *Sessions) Create(ctx context.Context, userID int64) (*Session, error) {
+	familyID, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(ctx, userID, familyID)
+}
+
+// UserID returns the owner of the refresh token without using it, so the user can be checked before Refresh.
+// The token is not validated here, Refresh still does it.
+func (s *Sessions) UserID(ctx context.Context, refreshToken string) (int64, error) {
+	RefreshToken, err := s.refreshTokens.FindByTokenHash(ctx, HashRefreshToken(refreshToken))
+	if err == db.ErrNoRows {
+		return 0, ErrRefreshTokenInvalid
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return RefreshToken.UserID, nil
+}
+
+// Refresh exchanges the refresh token with a new session from the same family.
+func (s *Sessions) Refresh(ctx context.Context, refreshToken string) (*Session, error) {
+	RefreshToken, err := s.refreshTokens.FindByTokenHash(ctx, HashRefreshToken(refreshToken))
+	if err == db.ErrNoRows {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if RefreshToken.UsedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, RefreshToken.FamilyID)
+	}
+
+	now := s.now()
+	if RefreshToken.RevokedAt != nil || !now.Before(RefreshToken.ExpiresAt) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	// the update only succeeds once, so two requests using the same token at the same time are treated as reuse
+	_, err = s.refreshTokens.MarkUsed(ctx, RefreshToken.ID, now)
+	if err == db.ErrNoRows {
+		return nil, s.revokeReusedFamily(ctx, RefreshToken.FamilyID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(ctx, RefreshToken.UserID, RefreshToken.FamilyID)
+}
+
+// Revoke logs out the session of the refresh token, it must belong to the user.
+func (s *Sessions) Revoke(ctx context.Context, userID int64, refreshToken string) error {
+	RefreshToken, err := s.refreshTokens.FindByTokenHash(ctx, HashRefreshToken(refreshToken))
+	if err == db.ErrNoRows {
+		return ErrRefreshTokenInvalid
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if RefreshToken.UserID != userID {
+		return ErrRefreshTokenInvalid
+	}
+
+	return s.refreshTokens.RevokeFamily(ctx, RefreshToken.FamilyID, s.now())
+}
+
+// RevokeAll logs out every session of the user.
+func (s *Sessions) RevokeAll(ctx context.Context, userID int64) error {
+	return s.refreshTokens.RevokeByUserID(ctx, userID, s.now())
+}
+
+func (s *Sessions) issue(ctx context.Context, userID int64, familyID string) (*Session, error) {
+	accessToken, err := s.tokens.GenerateJWTToken(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := s.now().Add(s.tokens.refreshLifetime)
+	if _, err := s.refreshTokens.Create(ctx, userID, familyID, HashRefreshToken(refreshToken), expiresAt); err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		UserID:       userID,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.tokens.lifetime,
+	}, nil
+}
+
+func (s *Sessions) revokeReusedFamily(ctx context.Context, familyID string) error {
+	if err := s.refreshTokens.RevokeFamily(ctx, familyID, s.now()); err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+// HashRefreshToken returns the hash of refresh token saved in the database.
+// SHA-256 is enough since the token is random, unlike password which needs bcrypt.
+func HashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
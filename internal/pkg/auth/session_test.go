@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/refreshtoken"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/user"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+func TestSessions(t *testing.T) {
+	convey.Convey("Test Sessions", t, func() {
+		ctx := context.Background()
+		dbConn, err := db.NewMemoryConnection()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(conn.MigrateUp(ctx, dbConn), convey.ShouldBeNil)
+		defer dbConn.Close()
+
+		User, err := user.NewRepository(dbConn).Create(ctx, "john_doe", "secret", nil)
+		convey.So(err, convey.ShouldBeNil)
+
+		tokens := newTokens(t, newSecretKey(t, "", secretKey))
+		sessions := NewSessions(tokens, refreshtoken.NewRepository(dbConn))
+
+		session, err := sessions.Create(ctx, User.ID)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(session.ExpiresIn, convey.ShouldEqual, time.Hour)
+
+		claims, err := tokens.ValidateJWTToken(ctx, session.AccessToken)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(claims.UserID, convey.ShouldEqual, User.ID)
+
+		convey.Convey("Refresh token is rotated", func() {
+			refreshed, err := sessions.Refresh(ctx, session.RefreshToken)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(refreshed.UserID, convey.ShouldEqual, User.ID)
+			convey.So(refreshed.RefreshToken, convey.ShouldNotEqual, session.RefreshToken)
+
+			convey.Convey("Reusing the old refresh token revokes the family", func() {
+				_, err := sessions.Refresh(ctx, session.RefreshToken)
+				convey.So(err, convey.ShouldEqual, ErrRefreshTokenReused)
+
+				_, err = sessions.Refresh(ctx, refreshed.RefreshToken)
+				convey.So(err, convey.ShouldEqual, ErrRefreshTokenInvalid)
+			})
+
+			convey.Convey("Other session is not revoked by the reuse", func() {
+				other, err := sessions.Create(ctx, User.ID)
+				convey.So(err, convey.ShouldBeNil)
+
+				_, err = sessions.Refresh(ctx, session.RefreshToken)
+				convey.So(err, convey.ShouldEqual, ErrRefreshTokenReused)
+
+				_, err = sessions.Refresh(ctx, other.RefreshToken)
+				convey.So(err, convey.ShouldBeNil)
+			})
+		})
+
+		convey.Convey("Unknown refresh token is invalid", func() {
+			_, err := sessions.Refresh(ctx, "unknown")
+			convey.So(err, convey.ShouldEqual, ErrRefreshTokenInvalid)
+		})
+
+		convey.Convey("Expired refresh token is invalid", func() {
+			sessions.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+			_, err := sessions.Refresh(ctx, session.RefreshToken)
+			convey.So(err, convey.ShouldEqual, ErrRefreshTokenInvalid)
+		})
+
+		convey.Convey("Revoked refresh token is invalid", func() {
+			convey.So(sessions.Revoke(ctx, User.ID+1, session.RefreshToken), convey.ShouldEqual, ErrRefreshTokenInvalid)
+			convey.So(sessions.Revoke(ctx, User.ID, session.RefreshToken), convey.ShouldBeNil)
+
+			_, err := sessions.Refresh(ctx, session.RefreshToken)
+			convey.So(err, convey.ShouldEqual, ErrRefreshTokenInvalid)
+		})
+
+		convey.Convey("Revoke all logs out every session", func() {
+			other, err := sessions.Create(ctx, User.ID)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(sessions.RevokeAll(ctx, User.ID), convey.ShouldBeNil)
+
+			_, err = sessions.Refresh(ctx, session.RefreshToken)
+			convey.So(err, convey.ShouldEqual, ErrRefreshTokenInvalid)
+
+			_, err = sessions.Refresh(ctx, other.RefreshToken)
+			convey.So(err, convey.ShouldEqual, ErrRefreshTokenInvalid)
+		})
+	})
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginThrottleConfig is the configuration of the login brute-force protection.
+type LoginThrottleConfig struct {
+	// MaxFailures is the number of failed login of a username before it's locked, 0 to disable the protection.
+	MaxFailures int
+
+	// MaxFailuresPerIP is the number of failed login from an IP address before it's locked. It should be higher than
+	// MaxFailures since many users may share the IP address behind NAT. 0 means 4 times MaxFailures.
+	MaxFailuresPerIP int
+
+	// BaseDelay is how long to wait after the first failure, it's doubled on every failure until MaxDelay.
+	BaseDelay time.Duration
+
+	// MaxDelay is the longest wait between failures before the lock.
+	MaxDelay time.Duration
+
+	// LockDuration is how long the username or IP address is locked after too many failures.
+	// The failures are forgotten when there is no failure within this duration.
+	LockDuration time.Duration
+}
+
+// LoginThrottle counts the failed login per username and per IP address. After every failure the next attempt must wait
+// with exponential backoff, and after too many failures the username or IP address is locked temporarily.
+// The username is counted even when it doesn't exist, so the lock doesn't tell whether the username exists.
+// The counters are kept in memory, so every instance of the server counts on its own.
+type LoginThrottle struct {
+	conf LoginThrottleConfig
+	now  func() time.Time
+
+	sync.Mutex
+	attempts map[string]*loginAttempt
+	sweptAt  time.Time
+}
+
+type loginAttempt struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// NewLoginThrottle creates the throttle.
+func NewLoginThrottle(conf LoginThrottleConfig) *LoginThrottle {
+	if conf.MaxFailuresPerIP <= 0 {
+		conf.MaxFailuresPerIP = 4 * conf.MaxFailures
+	}
+
+	return &LoginThrottle{
+		conf:     conf,
+		now:      time.Now,
+		attempts: map[string]*loginAttempt{},
+	}
+}
+
+// Check returns how long the login of the username from the IP address must wait, 0 when it's allowed now.
+func (l *LoginThrottle) Check(username, ip string) time.Duration {
+	if l.conf.MaxFailures <= 0 {
+		return 0
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.now()
+	var wait time.Duration
+	for _, key := range l.keys(username, ip) {
+		if attempt, ok := l.attempts[key]; ok && attempt.blockedUntil.After(now) {
+			if d := attempt.blockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+
+	return wait
+}
+
+// Fail counts the failed login, it returns true when the username or the IP address is locked because of this failure.
+func (l *LoginThrottle) Fail(username, ip string) (locked bool) {
+	if l.conf.MaxFailures <= 0 {
+		return false
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.now()
+	l.forgetExpired(now)
+
+	keys := l.keys(username, ip)
+	limits := []int{l.conf.MaxFailures, l.conf.MaxFailuresPerIP}
+	for i, key := range keys {
+		attempt, ok := l.attempts[key]
+		if !ok || now.Sub(attempt.lastFailure) >= l.conf.LockDuration {
+			attempt = &loginAttempt{}
+			l.attempts[key] = attempt
+		}
+
+		attempt.failures++
+		attempt.lastFailure = now
+
+		if attempt.failures >= limits[i] {
+			locked = true
+			attempt.blockedUntil = now.Add(l.conf.LockDuration)
+			continue
+		}
+
+		delay := l.conf.BaseDelay << uint(attempt.failures-1)
+		if delay > l.conf.MaxDelay || delay <= 0 {
+			delay = l.conf.MaxDelay
+		}
+
+		attempt.blockedUntil = now.Add(delay)
+	}
+
+	return locked
+}
+
+// Succeed forgets the failures of the username. The failures of the IP address are kept,
+// otherwise an attacker could reset them by logging in using his own account.
+func (l *LoginThrottle) Succeed(username string) {
+	l.Lock()
+	defer l.Unlock()
+
+	delete(l.attempts, usernameKey(username))
+}
+
+func (l *LoginThrottle) keys(username, ip string) []string {
+	return []string{usernameKey(username), "ip:" + ip}
+}
+
+// forgetExpired removes the counters without failure within the lock duration once a minute, so the map doesn't grow forever.
+func (l *LoginThrottle) forgetExpired(now time.Time) {
+	if now.Sub(l.sweptAt) < time.Minute {
+		return
+	}
+
+	l.sweptAt = now
+	for key, attempt := range l.attempts {
+		if now.Sub(attempt.lastFailure) > l.conf.LockDuration && !attempt.blockedUntil.After(now) {
+			delete(l.attempts, key)
+		}
+	}
+}
+
+func usernameKey(username string) string {
+	return "username:" + strings.ToLower(username)
+}
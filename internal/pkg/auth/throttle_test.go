@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	now := time.Now()
+	throttle := NewLoginThrottle(LoginThrottleConfig{
+		MaxFailures:  3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockDuration: 15 * time.Minute,
+	})
+	throttle.now = func() time.Time { return now }
+
+	if wait := throttle.Check("john_doe", "10.0.0.1"); wait != 0 {
+		t.Errorf("first attempt must be allowed, got wait %s", wait)
+	}
+
+	// the delay is doubled on every failure
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second} {
+		if throttle.Fail("john_doe", "10.0.0.1") {
+			t.Errorf("failure %d must not lock", i+1)
+		}
+
+		if wait := throttle.Check("JOHN_DOE", "10.0.0.2"); wait != expected {
+			t.Errorf("failure %d: expected wait %s, got %s", i+1, expected, wait)
+		}
+
+		now = now.Add(expected)
+	}
+
+	if !throttle.Fail("john_doe", "10.0.0.1") {
+		t.Error("third failure must lock the username")
+	}
+
+	if wait := throttle.Check("john_doe", "10.0.0.3"); wait != 15*time.Minute {
+		t.Errorf("expected locked for 15m, got %s", wait)
+	}
+
+	// other username from the same IP is only delayed by the IP counter
+	if wait := throttle.Check("jane_doe", "10.0.0.4"); wait != 0 {
+		t.Errorf("other username must be allowed, got wait %s", wait)
+	}
+
+	// the failures are forgotten after the lock
+	now = now.Add(15 * time.Minute)
+	if wait := throttle.Check("john_doe", "10.0.0.3"); wait != 0 {
+		t.Errorf("lock must be expired, got wait %s", wait)
+	}
+
+	if throttle.Fail("john_doe", "10.0.0.3") {
+		t.Error("failure after the lock must start counting again")
+	}
+
+	throttle.Succeed("john_doe")
+	if wait := throttle.Check("john_doe", "10.0.0.5"); wait != 0 {
+		t.Errorf("success must reset the username, got wait %s", wait)
+	}
+}
+
+func TestLoginThrottlePerIP(t *testing.T) {
+	now := time.Now()
+	throttle := NewLoginThrottle(LoginThrottleConfig{
+		MaxFailures:      3,
+		MaxFailuresPerIP: 5,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+		LockDuration:     15 * time.Minute,
+	})
+	throttle.now = func() time.Time { return now }
+
+	// different username on every attempt is still locked by the IP counter
+	locked := false
+	for _, username := range []string{"a", "b", "c", "d", "e"} {
+		locked = throttle.Fail(username, "10.0.0.1")
+		now = now.Add(time.Minute)
+	}
+
+	if !locked {
+		t.Error("fifth failure must lock the IP")
+	}
+
+	if wait := throttle.Check("f", "10.0.0.1"); wait == 0 {
+		t.Error("locked IP must wait")
+	}
+}
+
+func TestLoginThrottleDisabled(t *testing.T) {
+	throttle := NewLoginThrottle(LoginThrottleConfig{})
+	for i := 0; i < 10; i++ {
+		if throttle.Fail("john_doe", "10.0.0.1") {
+			t.Error("disabled throttle must not lock")
+		}
+	}
+
+	if wait := throttle.Check("john_doe", "10.0.0.1"); wait != 0 {
+		t.Errorf("disabled throttle must not wait, got %s", wait)
+	}
+}
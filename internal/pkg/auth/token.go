@@ -2,8 +2,12 @@ package auth
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gbrlsnchs/jwt"
@@ -12,9 +16,136 @@ import (
 const issuer = "tax-calculator-example"
 const audience = "user"
 
+// twoFactorAudience is the audience of the token issued after the password is correct but the TOTP code is still needed.
+// It's rejected wherever the authentication token is needed, since its audience is different.
+const twoFactorAudience = "2fa"
+
+// TwoFactorTokenLifetime is how long the user has to enter the TOTP code after the password is correct.
+const TwoFactorTokenLifetime = 5 * time.Minute
+
+var (
+	// ErrUnknownKeyID is returned when the "kid" header of the token is not one of the verification keys.
+	ErrUnknownKeyID = errors.New("jwt: kid is unknown")
+	// ErrAlgorithmMismatch is returned when the "alg" header of the token is not the algorithm of its key.
+	ErrAlgorithmMismatch = errors.New("jwt: alg doesn't match the key")
+)
+
+// TokenConfig is the configuration of Tokens.
+type TokenConfig struct {
+	// SigningKey signs the new token, it's always used to verify the token too.
+	SigningKey *Key
+
+	// VerificationKeys are the other keys accepted when verifying the token, such as the previous signing key.
+	// When the signing key is rotated, the previous key stays here until every token signed by it is expired.
+	VerificationKeys []*Key
+
+	// Lifetime is how long the access token is valid, it should be short since the access token can't be revoked.
+	Lifetime time.Duration
+
+	// RefreshLifetime is how long the refresh token is valid, the session ends when it's not refreshed within this duration.
+	RefreshLifetime time.Duration
+
+	// Issuers are the external OpenID Connect issuers whose token is accepted, chosen by the "iss" claim of the token.
+	Issuers []*OIDCIssuer
+}
+
+// Claims is the verified claims of the token.
+type Claims struct {
+	Issuer  string
+	Subject string
+
+	// UserID is the local user id, it's only set for token issued by this server.
+	UserID int64
+
+	// Username is the "preferred_username" claim of token from external issuer.
+	Username string
+
+	// IssuedAt is the "iat" claim, it's zero when the external token doesn't have it.
+	IssuedAt time.Time
+}
+
+// External returns true when the token is issued by the external issuer, so it has no local user id.
+func (c *Claims) External() bool {
+	return c.Issuer != issuer
+}
+
+// tokenHeader is the header of the token needed to choose the key.
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// Tokens generates and validates the JWT token of the user.
+type Tokens struct {
+	signingKey       *Key
+	verificationKeys []*Key
+	keys             map[string]*Key
+	lifetime         time.Duration
+	issuers          map[string]*OIDCIssuer
+
+	refreshLifetime time.Duration
+}
+
+// NewTokens validates the configuration and returns the Tokens.
+func NewTokens(conf TokenConfig) (*Tokens, error) {
+	if conf.SigningKey == nil {
+		return nil, fmt.Errorf("signing key is required")
+	}
+
+	if !conf.SigningKey.canSign {
+		return nil, fmt.Errorf("signing key %s is a public key, private key is required", conf.SigningKey.ID)
+	}
+
+	if conf.Lifetime <= 0 {
+		return nil, fmt.Errorf("token lifetime must be positive, got %s", conf.Lifetime)
+	}
+
+	if conf.RefreshLifetime <= 0 {
+		return nil, fmt.Errorf("refresh token lifetime must be positive, got %s", conf.RefreshLifetime)
+	}
+
+	keys := map[string]*Key{}
+	for _, key := range append([]*Key{conf.SigningKey}, conf.VerificationKeys...) {
+		if _, exist := keys[key.ID]; exist {
+			return nil, fmt.Errorf("duplicate key id %s", key.ID)
+		}
+
+		keys[key.ID] = key
+	}
+
+	issuers := map[string]*OIDCIssuer{}
+	for _, external := range conf.Issuers {
+		if external.conf.IssuerURL == issuer {
+			return nil, fmt.Errorf("oidc issuer url must not be %s", issuer)
+		}
+
+		issuers[external.conf.IssuerURL] = external
+	}
+
+	return &Tokens{
+		signingKey:       conf.SigningKey,
+		verificationKeys: conf.VerificationKeys,
+		keys:             keys,
+		lifetime:         conf.Lifetime,
+		issuers:          issuers,
+
+		refreshLifetime: conf.RefreshLifetime,
+	}, nil
+}
+
 // GenerateJWTToken will generate JWT token based on input user.
 // Returns token, error.
-func GenerateJWTToken(parent context.Context, secretKey string, userId int64) (string, error) {
+func (t *Tokens) GenerateJWTToken(parent context.Context, userId int64) (string, error) {
+	return t.generate(parent, userId, audience, t.lifetime)
+}
+
+// GenerateTwoFactorToken generates the token which can only be exchanged with the authentication token
+// using the TOTP code, it's valid for TwoFactorTokenLifetime.
+func (t *Tokens) GenerateTwoFactorToken(parent context.Context, userId int64) (string, error) {
+	return t.generate(parent, userId, twoFactorAudience, TwoFactorTokenLifetime)
+}
+
+func (t *Tokens) generate(parent context.Context, userId int64, aud string, lifetime time.Duration) (string, error) {
 	_, cancel := context.WithTimeout(parent, time.Duration(1)*time.Second)
 	defer cancel()
 
@@ -23,25 +154,24 @@ func GenerateJWTToken(parent context.Context, secretKey string, userId int64) (s
 
 	userIdStr := fmt.Sprintf("%d", userId)
 
-	// Define a signer.
-	hs256 := jwt.NewHS256(secretKey)
 	jot := &jwt.JWT{
 		Issuer:         issuer,
 		Subject:        userIdStr,
-		Audience:       audience,
-		ExpirationTime: now.Add(24 * 30 * 12 * time.Hour).Unix(), // token is valid for 1 year
-		NotBefore:      now.Unix(),                               // token can be used right now once it generated
+		Audience:       aud,
+		ExpirationTime: now.Add(lifetime).Unix(),
+		NotBefore:      now.Unix(), // token can be used right now once it generated
 		IssuedAt:       now.Unix(),
 		ID:             userIdStr,
 	}
 
-	jot.SetAlgorithm(hs256)
+	jot.SetAlgorithm(t.signingKey.Signer)
+	jot.SetKeyID(t.signingKey.ID)
 	payload, err := jwt.Marshal(jot)
 	if err != nil {
 		return "", err
 	}
 
-	tokenBytes, err := hs256.Sign(payload)
+	tokenBytes, err := t.signingKey.Signer.Sign(payload)
 	if err != nil {
 		return "", err
 	}
@@ -49,44 +179,114 @@ func GenerateJWTToken(parent context.Context, secretKey string, userId int64) (s
 	return string(tokenBytes), nil
 }
 
-// ValidateJWTToken will return user model if it success.
-func ValidateJWTToken(parent context.Context, secretKey, token string) (userId int64, err error) {
-	_, cancel := context.WithTimeout(parent, time.Duration(1)*time.Second)
+// ValidateJWTToken will return the claims if it success.
+// Token issued by this server is verified using the key of its "kid" header, token without kid is verified using the signing key.
+// Token which "iss" claim is one of the external issuers is verified using the keys published by the issuer.
+func (t *Tokens) ValidateJWTToken(parent context.Context, token string) (*Claims, error) {
+	return t.validate(parent, token, audience, true)
+}
+
+// ValidateTwoFactorToken returns the claims of the token generated by GenerateTwoFactorToken.
+// The authentication token and the token of external issuer are rejected.
+func (t *Tokens) ValidateTwoFactorToken(parent context.Context, token string) (*Claims, error) {
+	return t.validate(parent, token, twoFactorAudience, false)
+}
+
+func (t *Tokens) validate(parent context.Context, token, aud string, acceptExternal bool) (*Claims, error) {
+	ctx, cancel := context.WithTimeout(parent, time.Duration(5)*time.Second)
 	defer cancel()
 
 	now := time.Now()
-	hs256 := jwt.NewHS256(secretKey) // Define a signer.
 
 	// First, extract the payload and signature.
-	// This enables un-marshaling the JWT first and verifying it later or vice versa.
+	// The header and the issuer are needed to choose the key, so the JWT is decoded first and verified later.
 	payload, sig, err := jwt.Parse(token)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	if err = hs256.Verify(payload, sig); err != nil {
-		return 0, err
+	header, rawClaims, err := decodePayload(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	iss := struct {
+		Issuer string `json:"iss"`
+	}{}
+	if err = json.Unmarshal(rawClaims, &iss); err != nil {
+		return nil, err
+	}
+
+	if external, ok := t.issuers[iss.Issuer]; ok && acceptExternal {
+		return external.verify(ctx, header, payload, sig, rawClaims)
+	}
+
+	key := t.signingKey
+	if header.KeyID != "" {
+		var ok bool
+		if key, ok = t.keys[header.KeyID]; !ok {
+			return nil, ErrUnknownKeyID
+		}
+	}
+
+	// the algorithm is chosen by the key, never by the token, so RS256 public key can't be used as HS256 secret
+	if header.Algorithm != key.Algorithm() {
+		return nil, ErrAlgorithmMismatch
+	}
+
+	if err = key.Signer.Verify(payload, sig); err != nil {
+		return nil, err
 	}
 
 	var jot jwt.JWT
 	if err = jwt.Unmarshal(payload, &jot); err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	// Validate fields.
 	iatValidator := jwt.IssuedAtValidator(now)
 	expValidator := jwt.ExpirationTimeValidator(now)
-	audValidator := jwt.AudienceValidator(audience)
+	audValidator := jwt.AudienceValidator(aud)
 	issValidator := jwt.IssuerValidator(issuer)
 	err = jot.Validate(iatValidator, expValidator, audValidator, issValidator)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	userIdInt, err := strconv.Atoi(jot.Subject)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	return &Claims{
+		Issuer:   jot.Issuer,
+		Subject:  jot.Subject,
+		UserID:   int64(userIdInt),
+		IssuedAt: time.Unix(jot.IssuedAt, 0),
+	}, nil
+}
+
+// decodePayload decodes the header and the claims part of the token.
+func decodePayload(payload []byte) (*tokenHeader, []byte, error) {
+	parts := strings.Split(string(payload), ".")
+	if len(parts) != 2 {
+		return nil, nil, jwt.ErrMalformed
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := &tokenHeader{}
+	if err = json.Unmarshal(rawHeader, header); err != nil {
+		return nil, nil, err
+	}
+
+	rawClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return int64(userIdInt), nil
+	return header, rawClaims, nil
 }
@@ -2,15 +2,85 @@ package auth
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"testing"
+	"time"
+
+	"github.com/gbrlsnchs/jwt"
 )
 
-const secretKey = "mysecret"
+const secretKey = "my-secret-which-is-at-least-32-bytes"
 const userID = 1
 
+func newTokens(t *testing.T, signingKey *Key, verificationKeys ...*Key) *Tokens {
+	tokens, err := NewTokens(TokenConfig{
+		SigningKey:       signingKey,
+		VerificationKeys: verificationKeys,
+		Lifetime:         time.Hour,
+		RefreshLifetime:  time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tokens
+}
+
+func newSecretKey(t *testing.T, id, secret string) *Key {
+	key, err := NewKey(id, jwt.MethodHS256, []byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+// newKeyPair generates the private key of the algorithm, returns the PEM encoded private and public key.
+func newKeyPair(t *testing.T, algorithm string) (private, public []byte) {
+	var priv, pub interface{}
+	var err error
+	switch algorithm {
+	case jwt.MethodRS256:
+		var key *rsa.PrivateKey
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		priv, pub = key, &key.PublicKey
+	case jwt.MethodES256:
+		var key *ecdsa.PrivateKey
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		priv, pub = key, &key.PublicKey
+	case MethodEdDSA:
+		pub, priv, err = ed25519.GenerateKey(rand.Reader)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	private = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER})
+	public = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+	return private, public
+}
+
 func TestGenerateJWTToken(t *testing.T) {
-	_, err := GenerateJWTToken(context.Background(), secretKey, userID)
+	tokens := newTokens(t, newSecretKey(t, "", secretKey))
+	_, err := tokens.GenerateJWTToken(context.Background(), userID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -18,17 +88,172 @@ func TestGenerateJWTToken(t *testing.T) {
 
 func TestValidateJWTToken(t *testing.T) {
 	ctx := context.Background()
-	token, err := GenerateJWTToken(ctx, secretKey, userID)
+	tokens := newTokens(t, newSecretKey(t, "", secretKey))
+	token, err := tokens.GenerateJWTToken(ctx, userID)
 	if err != nil {
 		t.Error(err)
 	}
 
-	userIDFromToken, err := ValidateJWTToken(ctx, secretKey, token)
+	claims, err := tokens.ValidateJWTToken(ctx, token)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	if userID != userIDFromToken {
+	if userID != claims.UserID || claims.External() {
 		t.Error(fmt.Errorf("error user id in token != generated"))
 	}
 }
+
+func TestValidateTwoFactorToken(t *testing.T) {
+	ctx := context.Background()
+	tokens := newTokens(t, newSecretKey(t, "", secretKey))
+	token, err := tokens.GenerateTwoFactorToken(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := tokens.ValidateTwoFactorToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if userID != claims.UserID {
+		t.Error("error user id in token != generated")
+	}
+
+	// the pending token is not an authentication token, and vice versa
+	if _, err := tokens.ValidateJWTToken(ctx, token); err != jwt.ErrAudValidation {
+		t.Errorf("expected %v, got %v", jwt.ErrAudValidation, err)
+	}
+
+	authToken, err := tokens.GenerateJWTToken(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tokens.ValidateTwoFactorToken(ctx, authToken); err != jwt.ErrAudValidation {
+		t.Errorf("expected %v, got %v", jwt.ErrAudValidation, err)
+	}
+}
+
+func TestValidateJWTTokenAlgorithms(t *testing.T) {
+	ctx := context.Background()
+	for _, algorithm := range []string{jwt.MethodRS256, jwt.MethodES256, MethodEdDSA} {
+		private, public := newKeyPair(t, algorithm)
+		signingKey, err := NewKey("", algorithm, private)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := newTokens(t, signingKey).GenerateJWTToken(ctx, userID)
+		if err != nil {
+			t.Errorf("%s: %s", algorithm, err)
+			continue
+		}
+
+		// another instance only knows the public key, but it signs using another key
+		publicKey, err := NewKey("", algorithm, public)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if publicKey.ID != signingKey.ID {
+			t.Errorf("%s: kid of public key %s != kid of private key %s", algorithm, publicKey.ID, signingKey.ID)
+		}
+
+		verifier := newTokens(t, newSecretKey(t, "", secretKey), publicKey)
+		claims, err := verifier.ValidateJWTToken(ctx, token)
+		if err != nil {
+			t.Errorf("%s: %s", algorithm, err)
+			continue
+		}
+
+		if userID != claims.UserID {
+			t.Errorf("%s: error user id in token != generated", algorithm)
+		}
+
+		if _, err := NewTokens(TokenConfig{SigningKey: publicKey, Lifetime: time.Hour, RefreshLifetime: time.Hour}); err == nil {
+			t.Errorf("%s: public key must not be used as signing key", algorithm)
+		}
+	}
+}
+
+func TestValidateJWTTokenRotation(t *testing.T) {
+	ctx := context.Background()
+	oldKey := newSecretKey(t, "old", "old-secret-which-is-at-least-32-bytes")
+	newKey := newSecretKey(t, "new", "new-secret-which-is-at-least-32-bytes")
+
+	oldToken, err := newTokens(t, oldKey).GenerateJWTToken(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the old key is still accepted after the signing key is rotated
+	rotated := newTokens(t, newKey, oldKey)
+	if _, err := rotated.ValidateJWTToken(ctx, oldToken); err != nil {
+		t.Error(err)
+	}
+
+	// and rejected once it's removed
+	if _, err := newTokens(t, newKey).ValidateJWTToken(ctx, oldToken); err != ErrUnknownKeyID {
+		t.Errorf("expected %v, got %v", ErrUnknownKeyID, err)
+	}
+}
+
+func TestValidateJWTTokenAlgorithmMismatch(t *testing.T) {
+	ctx := context.Background()
+	_, public := newKeyPair(t, jwt.MethodRS256)
+	publicKey, err := NewKey("rsa", jwt.MethodRS256, public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// HS256 token signed using the public key as the secret, with the kid of the RS256 key
+	forged := newTokens(t, newSecretKey(t, "rsa", string(public)))
+	token, err := forged.GenerateJWTToken(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := newTokens(t, newSecretKey(t, "", secretKey), publicKey)
+	if _, err := verifier.ValidateJWTToken(ctx, token); err != ErrAlgorithmMismatch {
+		t.Errorf("expected %v, got %v", ErrAlgorithmMismatch, err)
+	}
+}
+
+func TestValidateJWTTokenExpired(t *testing.T) {
+	ctx := context.Background()
+	tokens, err := NewTokens(TokenConfig{SigningKey: newSecretKey(t, "", secretKey), Lifetime: time.Nanosecond, RefreshLifetime: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := tokens.GenerateJWTToken(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Second)
+	if _, err := tokens.ValidateJWTToken(ctx, token); err != jwt.ErrExpValidation {
+		t.Errorf("expected %v, got %v", jwt.ErrExpValidation, err)
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	if _, err := NewKey("", jwt.MethodHS256, []byte("short")); err == nil {
+		t.Error("short secret must be rejected")
+	}
+
+	_, public := newKeyPair(t, jwt.MethodES256)
+	if _, err := NewKey("", jwt.MethodRS256, public); err == nil {
+		t.Error("ES256 key must not be used for RS256")
+	}
+
+	if _, err := NewKey("", "none", public); err == nil {
+		t.Error("unsupported algorithm must be rejected")
+	}
+
+	if _, err := NewKey("", jwt.MethodES256, []byte("not a PEM")); err == nil {
+		t.Error("non PEM key must be rejected")
+	}
+}
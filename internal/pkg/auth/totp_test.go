@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 secret of the test vectors in RFC 6238 appendix B, "12345678901234567890" in ASCII.
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestHOTP(t *testing.T) {
+	// the last 6 digits of the 8 digits test vectors
+	vectors := map[int64]string{
+		59 / totpPeriod:         "287082",
+		1111111109 / totpPeriod: "081804",
+		1111111111 / totpPeriod: "050471",
+		1234567890 / totpPeriod: "005924",
+		2000000000 / totpPeriod: "279037",
+	}
+
+	for step, expected := range vectors {
+		if code := hotp([]byte("12345678901234567890"), step); code != expected {
+			t.Errorf("step %d: expected %s, got %s", step, expected, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+
+	if got, ok := ValidateTOTP(rfc6238Secret, "050471", now, 0); !ok || got != step {
+		t.Errorf("current code must be accepted, got step %d ok %v", got, ok)
+	}
+
+	if _, ok := ValidateTOTP(rfc6238Secret, "050471", now.Add(totpPeriod*time.Second), 0); !ok {
+		t.Error("code of the previous period must be accepted")
+	}
+
+	if _, ok := ValidateTOTP(rfc6238Secret, "050471", now.Add(2*totpPeriod*time.Second), 0); ok {
+		t.Error("code older than the skew must be rejected")
+	}
+
+	if _, ok := ValidateTOTP(rfc6238Secret, "050471", now, step); ok {
+		t.Error("code of the used step must be rejected")
+	}
+
+	if _, ok := ValidateTOTP(rfc6238Secret, "000000", now, 0); ok {
+		t.Error("wrong code must be rejected")
+	}
+
+	if code, err := GenerateTOTPCode(rfc6238Secret, now); err != nil || code != "050471" {
+		t.Errorf("expected generated code 050471, got %s %v", code, err)
+	}
+
+	if _, ok := ValidateTOTP("not base32!", "050471", now, 0); ok {
+		t.Error("invalid secret must be rejected")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(key) != totpSecretSize {
+		t.Errorf("secret must be %d bytes in base32, got %q", totpSecretSize, secret)
+	}
+
+	uri, err := url.Parse(TOTPURI("Tax Calculator", "john_doe", secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Tax Calculator:john_doe" {
+		t.Errorf("unexpected otpauth uri %s", uri)
+	}
+
+	if uri.Query().Get("secret") != secret || uri.Query().Get("issuer") != "Tax Calculator" {
+		t.Errorf("unexpected otpauth query %s", uri.RawQuery)
+	}
+}
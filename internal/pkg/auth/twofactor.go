@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+// recoveryCodeCount is the number of recovery codes given when 2FA is enabled.
+const recoveryCodeCount = 10
+
+var (
+	// ErrTwoFactorAlreadyEnabled is returned when the user enrolls or confirms 2FA which is already enabled.
+	ErrTwoFactorAlreadyEnabled = errors.New("2FA is already enabled, disable it first")
+	// ErrTwoFactorNotEnrolled is returned when the user confirms 2FA before enrolling it.
+	ErrTwoFactorNotEnrolled = errors.New("2FA is not enrolled, enroll it first")
+	// ErrTwoFactorNotEnabled is returned when the code is checked for the user without 2FA.
+	ErrTwoFactorNotEnabled = errors.New("2FA is not enabled")
+	// ErrTwoFactorCodeInvalid is returned when the TOTP code or the recovery code is wrong or already used.
+	ErrTwoFactorCodeInvalid = errors.New("2FA code is wrong or already used")
+)
+
+// TwoFactor manages the TOTP (RFC 6238) second factor of the user. The user enrolls to get the secret,
+// then confirms it using the code from the authenticator app, which also gives the one-time recovery codes.
+// The secret is saved as is since it's needed to compute the code, only the hash of the recovery code is saved.
+type TwoFactor struct {
+	users         repo.UserRepository
+	recoveryCodes repo.RecoveryCodeRepository
+	issuer        string
+	now           func() time.Time
+}
+
+// NewTwoFactor creates the 2FA manager, issuer is the name shown in the authenticator app.
+func NewTwoFactor(users repo.UserRepository, recoveryCodes repo.RecoveryCodeRepository, issuer string) *TwoFactor {
+	return &TwoFactor{
+		users:         users,
+		recoveryCodes: recoveryCodes,
+		issuer:        issuer,
+		now:           time.Now,
+	}
+}
+
+// Enroll generates a new secret for the user, it's not required to login until it's confirmed.
+// Enrolling again before confirming it replaces the secret.
+func (f *TwoFactor) Enroll(ctx context.Context, User *model.User) (secret, uri string, err error) {
+	if User.TwoFactorEnabled() {
+		return "", "", ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err = GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err = f.users.UpdateTOTP(ctx, User.ID, &secret, nil, f.now()); err != nil {
+		return "", "", err
+	}
+
+	return secret, TOTPURI(f.issuer, User.Username, secret), nil
+}
+
+// Confirm enables 2FA when the code matches the enrolled secret, and returns the recovery codes.
+// The recovery codes are only shown here, they can't be read again.
+func (f *TwoFactor) Confirm(ctx context.Context, User *model.User, code string) ([]string, error) {
+	if User.TwoFactorEnabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	if User.TOTPSecret == nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	now := f.now()
+	step, ok := ValidateTOTP(*User.TOTPSecret, normalizeTwoFactorCode(code), now, 0)
+	if !ok {
+		return nil, ErrTwoFactorCodeInvalid
+	}
+
+	if _, err := f.users.UpdateTOTP(ctx, User.ID, User.TOTPSecret, &now, now); err != nil {
+		return nil, err
+	}
+
+	if _, err := f.users.UseTOTPStep(ctx, User.ID, step, now); err != nil {
+		return nil, err
+	}
+
+	return f.generateRecoveryCodes(ctx, User.ID)
+}
+
+// Verify checks the TOTP code or one of the recovery codes, both can only be used once.
+func (f *TwoFactor) Verify(ctx context.Context, User *model.User, code string) error {
+	if !User.TwoFactorEnabled() {
+		return ErrTwoFactorNotEnabled
+	}
+
+	now := f.now()
+	code = normalizeTwoFactorCode(code)
+	if len(code) != totpDigits {
+		_, err := f.recoveryCodes.Use(ctx, User.ID, hashRecoveryCode(code), now)
+		if err == db.ErrNoRows {
+			return ErrTwoFactorCodeInvalid
+		}
+
+		return err
+	}
+
+	var lastStep int64
+	if User.TOTPLastStep != nil {
+		lastStep = *User.TOTPLastStep
+	}
+
+	step, ok := ValidateTOTP(*User.TOTPSecret, code, now, lastStep)
+	if !ok {
+		return ErrTwoFactorCodeInvalid
+	}
+
+	// the update only succeeds once, so the same code used by two requests at the same time is rejected once
+	_, err := f.users.UseTOTPStep(ctx, User.ID, step, now)
+	if err == db.ErrNoRows {
+		return ErrTwoFactorCodeInvalid
+	}
+
+	return err
+}
+
+// Disable removes the secret and the recovery codes of the user, the code is required so the stolen session can't do it.
+func (f *TwoFactor) Disable(ctx context.Context, User *model.User, code string) error {
+	if err := f.Verify(ctx, User, code); err != nil {
+		return err
+	}
+
+	if _, err := f.users.UpdateTOTP(ctx, User.ID, nil, nil, f.now()); err != nil {
+		return err
+	}
+
+	return f.recoveryCodes.DeleteByUserID(ctx, User.ID)
+}
+
+// generateRecoveryCodes replaces the recovery codes of the user, the code looks like abcd-efgh-ijkl-mnop.
+func (f *TwoFactor) generateRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	if err := f.recoveryCodes.DeleteByUserID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(encoding.EncodeToString(b))
+		if _, err := f.recoveryCodes.Create(ctx, userID, hashRecoveryCode(code)); err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code[0:4]+"-"+code[4:8]+"-"+code[8:12]+"-"+code[12:16])
+	}
+
+	return codes, nil
+}
+
+// normalizeTwoFactorCode removes the spaces and the dashes, and makes the recovery code lower case as it's generated.
+func normalizeTwoFactorCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+// hashRecoveryCode returns the hash of the normalized recovery code saved in the database.
+// SHA-256 is enough since the code has 80 random bits, like the refresh token.
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
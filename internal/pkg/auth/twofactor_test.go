@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/recoverycode"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/user"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+func TestTwoFactor(t *testing.T) {
+	convey.Convey("Test TwoFactor", t, func() {
+		ctx := context.Background()
+		dbConn, err := db.NewMemoryConnection()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(conn.MigrateUp(ctx, dbConn), convey.ShouldBeNil)
+		defer dbConn.Close()
+
+		users := user.NewRepository(dbConn)
+		User, err := users.Create(ctx, "john_doe", "secret", nil)
+		convey.So(err, convey.ShouldBeNil)
+
+		now := time.Now()
+		twoFactor := NewTwoFactor(users, recoverycode.NewRepository(dbConn), "Tax Calculator")
+		twoFactor.now = func() time.Time { return now }
+
+		// code returns the TOTP code of the secret at now
+		code := func(secret string) string {
+			key, err := totpEncoding.DecodeString(secret)
+			convey.So(err, convey.ShouldBeNil)
+			return hotp(key, now.Unix()/totpPeriod)
+		}
+
+		// reload returns the user as it's saved in the database
+		reload := func() {
+			User, err = users.FindByID(ctx, User.ID)
+			convey.So(err, convey.ShouldBeNil)
+		}
+
+		secret, uri, err := twoFactor.Enroll(ctx, User)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(uri, convey.ShouldStartWith, "otpauth://totp/Tax%20Calculator:john_doe?")
+		reload()
+		convey.So(User.TwoFactorEnabled(), convey.ShouldBeFalse)
+
+		convey.Convey("Wrong code doesn't enable 2FA", func() {
+			_, err := twoFactor.Confirm(ctx, User, "000000")
+			convey.So(err, convey.ShouldEqual, ErrTwoFactorCodeInvalid)
+		})
+
+		convey.Convey("Confirmed code enables 2FA", func() {
+			codes, err := twoFactor.Confirm(ctx, User, code(secret))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(codes, convey.ShouldHaveLength, recoveryCodeCount)
+			reload()
+			convey.So(User.TwoFactorEnabled(), convey.ShouldBeTrue)
+
+			_, _, err = twoFactor.Enroll(ctx, User)
+			convey.So(err, convey.ShouldEqual, ErrTwoFactorAlreadyEnabled)
+
+			convey.Convey("The code used to confirm can't be used again", func() {
+				convey.So(twoFactor.Verify(ctx, User, code(secret)), convey.ShouldEqual, ErrTwoFactorCodeInvalid)
+
+				now = now.Add(totpPeriod * time.Second)
+				convey.So(twoFactor.Verify(ctx, User, code(secret)), convey.ShouldBeNil)
+				reload()
+				convey.So(twoFactor.Verify(ctx, User, code(secret)), convey.ShouldEqual, ErrTwoFactorCodeInvalid)
+			})
+
+			convey.Convey("Recovery code can be used once, regardless of the case and dashes", func() {
+				convey.So(twoFactor.Verify(ctx, User, " "+strings.ToUpper(codes[0])+" "), convey.ShouldBeNil)
+				convey.So(twoFactor.Verify(ctx, User, codes[0]), convey.ShouldEqual, ErrTwoFactorCodeInvalid)
+				convey.So(twoFactor.Verify(ctx, User, strings.Replace(codes[1], "-", "", -1)), convey.ShouldBeNil)
+			})
+
+			convey.Convey("Disable requires the code and removes the recovery codes", func() {
+				convey.So(twoFactor.Disable(ctx, User, "wrong-code"), convey.ShouldEqual, ErrTwoFactorCodeInvalid)
+				convey.So(twoFactor.Disable(ctx, User, codes[0]), convey.ShouldBeNil)
+				reload()
+				convey.So(User.TwoFactorEnabled(), convey.ShouldBeFalse)
+				convey.So(User.TOTPSecret, convey.ShouldBeNil)
+				convey.So(twoFactor.Verify(ctx, User, codes[1]), convey.ShouldEqual, ErrTwoFactorNotEnabled)
+			})
+		})
+
+		convey.Convey("Confirm requires enrollment", func() {
+			other, err := users.Create(ctx, "jane_doe", "secret", nil)
+			convey.So(err, convey.ShouldBeNil)
+
+			_, err = twoFactor.Confirm(ctx, other, "000000")
+			convey.So(err, convey.ShouldEqual, ErrTwoFactorNotEnrolled)
+		})
+	})
+}
@@ -0,0 +1,145 @@
+package conn
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+
+	"github.com/rubenv/sql-migrate"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+var sqlCreateChecksumTable = `CREATE TABLE IF NOT EXISTS migration_checksums (
+  id VARCHAR(255) NOT NULL PRIMARY KEY,
+  checksum VARCHAR(64) NOT NULL
+);`
+
+var sqlSelectChecksums = `SELECT id, checksum FROM migration_checksums;`
+
+var sqlCountChecksumTable = db.DialectQuery{
+	db.DialectPostgres: `SELECT COUNT(*) FROM pg_tables WHERE schemaname = current_schema() AND tablename = 'migration_checksums';`,
+	db.DialectSQLite:   `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'migration_checksums';`,
+}
+
+// The migrator uses *sql.DB directly, so the placeholder is not rebound by the db package.
+var sqlInsertChecksum = db.DialectQuery{
+	db.DialectPostgres: `INSERT INTO migration_checksums (id, checksum) VALUES ($1, $2);`,
+	db.DialectSQLite:   `INSERT INTO migration_checksums (id, checksum) VALUES (?, ?);`,
+}
+
+var sqlDeleteChecksum = db.DialectQuery{
+	db.DialectPostgres: `DELETE FROM migration_checksums WHERE id = $1;`,
+	db.DialectSQLite:   `DELETE FROM migration_checksums WHERE id = ?;`,
+}
+
+// checksumDiff is the difference between the applied migrations and the recorded checksums.
+type checksumDiff struct {
+	missing map[string]string // checksum of applied migration which is not recorded yet, by id
+	removed []string          // id of recorded migration which is not applied anymore
+}
+
+// compareChecksums returns error when the file of an applied migration is changed since it was applied,
+// because the change will never be applied to the database which already run the migration.
+// It only reads the database, so it's safe for the dry run. Applied migration which file doesn't exist is skipped,
+// since it is applied by newer binary.
+func compareChecksums(sqlDB *sql.DB, dialect db.Dialect) (*checksumDiff, error) {
+	migrate.SetTable("migrations")
+	records, err := migrate.GetMigrationRecords(sqlDB, string(dialect))
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := map[string]string{}
+	var exists int
+	if err := sqlDB.QueryRow(sqlCountChecksumTable.For(dialect)).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	// the table is created by the first recordChecksums
+	if exists > 0 {
+		if checksums, err = selectChecksums(sqlDB); err != nil {
+			return nil, err
+		}
+	}
+
+	diff := &checksumDiff{missing: map[string]string{}}
+	files := migrationFS(dialect)
+	for _, record := range records {
+		stored, ok := checksums[record.Id]
+		delete(checksums, record.Id)
+
+		content, err := fs.ReadFile(files, record.Id)
+		if err != nil {
+			continue
+		}
+
+		sum := sha256.Sum256(content)
+		checksum := hex.EncodeToString(sum[:])
+
+		if !ok {
+			diff.missing[record.Id] = checksum
+			continue
+		}
+
+		if stored != checksum {
+			return nil, fmt.Errorf("migration %s is changed after it was applied, revert the change and create new migration instead", record.Id)
+		}
+	}
+
+	// the rest are rolled back
+	for id := range checksums {
+		diff.removed = append(diff.removed, id)
+	}
+
+	return diff, nil
+}
+
+// recordChecksums verifies the checksums like compareChecksums, then records the checksum of applied migration
+// which is not recorded yet, such as the one applied before checksum is introduced, using the current file.
+// The checksum of migration which is not applied anymore is removed. It must be called while holding the migration lock.
+func recordChecksums(sqlDB *sql.DB, dialect db.Dialect) error {
+	if _, err := sqlDB.Exec(sqlCreateChecksumTable); err != nil {
+		return err
+	}
+
+	diff, err := compareChecksums(sqlDB, dialect)
+	if err != nil {
+		return err
+	}
+
+	for id, checksum := range diff.missing {
+		if _, err := sqlDB.Exec(sqlInsertChecksum.For(dialect), id, checksum); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range diff.removed {
+		if _, err := sqlDB.Exec(sqlDeleteChecksum.For(dialect), id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func selectChecksums(sqlDB *sql.DB) (map[string]string, error) {
+	rows, err := sqlDB.Query(sqlSelectChecksums)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checksums := map[string]string{}
+	for rows.Next() {
+		var id, checksum string
+		if err := rows.Scan(&id, &checksum); err != nil {
+			return nil, err
+		}
+
+		checksums[id] = checksum
+	}
+
+	return checksums, rows.Err()
+}
@@ -0,0 +1,80 @@
+package conn
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/rubenv/sql-migrate"
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+func TestMigrationChecksum(t *testing.T) {
+	convey.Convey("Test Migration Checksum", t, func() {
+		dir, err := ioutil.TempDir("", "checksum")
+		convey.So(err, convey.ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		dbConn, err := db.NewConnection(&db.Config{
+			Driver: db.DriverSQLite,
+			Master: &db.Conf{URL: filepath.Join(dir, "checksum.db")},
+		})
+		convey.So(err, convey.ShouldBeNil)
+		defer dbConn.Close()
+
+		files := fstest.MapFS{
+			"sqlite/1_create_a.sql": {Data: []byte("-- +migrate Up\nCREATE TABLE a (id INTEGER);\n-- +migrate Down\nDROP TABLE a;\n")},
+			"sqlite/2_create_b.sql": {Data: []byte("-- +migrate Up\nCREATE TABLE b (id INTEGER);\n-- +migrate Down\nDROP TABLE b;\n")},
+		}
+
+		original := migrationFiles
+		migrationFiles = files
+		defer func() {
+			migrationFiles = original
+		}()
+
+		ctx := context.Background()
+		convey.So(MigrateSync(ctx, dbConn, ""), convey.ShouldBeNil)
+
+		convey.Convey("Changed applied migration is refused", func() {
+			files["sqlite/1_create_a.sql"] = &fstest.MapFile{Data: []byte("-- +migrate Up\nCREATE TABLE a (id INTEGER, name TEXT);\n-- +migrate Down\nDROP TABLE a;\n")}
+
+			err := MigrateSync(ctx, dbConn, "")
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "migration 1_create_a.sql is changed after it was applied")
+
+			_, err = MigratePlan(dbConn, "", migrate.Up, 0)
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Plan doesn't record the checksums", func() {
+			masterDB := dbConn.(db.StdSQL).MasterDB()
+			_, err := masterDB.Exec("DELETE FROM migration_checksums;")
+			convey.So(err, convey.ShouldBeNil)
+
+			_, err = MigratePlan(dbConn, "", migrate.Down, 0)
+			convey.So(err, convey.ShouldBeNil)
+
+			var count int
+			convey.So(masterDB.QueryRow("SELECT COUNT(*) FROM migration_checksums;").Scan(&count), convey.ShouldBeNil)
+			convey.So(count, convey.ShouldEqual, 0)
+
+			convey.So(MigrateSync(ctx, dbConn, ""), convey.ShouldBeNil)
+			convey.So(masterDB.QueryRow("SELECT COUNT(*) FROM migration_checksums;").Scan(&count), convey.ShouldBeNil)
+			convey.So(count, convey.ShouldEqual, 2)
+		})
+
+		convey.Convey("Rolled back migration can be changed", func() {
+			_, err := MigrateExec(ctx, dbConn, "", migrate.Down, 1)
+			convey.So(err, convey.ShouldBeNil)
+
+			files["sqlite/2_create_b.sql"] = &fstest.MapFile{Data: []byte("-- +migrate Up\nCREATE TABLE b (id INTEGER, name TEXT);\n-- +migrate Down\nDROP TABLE b;\n")}
+			convey.So(MigrateSync(ctx, dbConn, ""), convey.ShouldBeNil)
+			convey.So(MigrateSync(ctx, dbConn, ""), convey.ShouldBeNil)
+		})
+	})
+}
@@ -1,92 +1,298 @@
 package conn
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io/fs"
+	"net/http"
+	"sort"
 	"strings"
+	"time"
 
-	"github.com/go-pg/pg"
-	"github.com/gobuffalo/packr"
 	"github.com/rubenv/sql-migrate"
+	"github.com/yusufsyaifudin/tax-calculator-example/assets/migrations"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
 
 	// is a driver needed by sql.Open to connect to postgresql.
 	_ "github.com/lib/pq"
 )
 
 // MigrateSync will sync all database structure from migration file to postgres.
-// This uses lib/pq because sql-migrate only support sql.DB struct.
-// Actually we can create struct that implements all method like sql.DB do, but it takes time.
-func MigrateSync(url string) error {
-	opts, err := pg.ParseURL(url)
+// sql-migrate only support sql.DB struct, so when the connection is created using database/sql driver
+// we reuse its master connection. Otherwise, a new connection using lib/pq is opened from the url.
+// Only one instance can migrate at a time, the other instances wait for the lock until ctx is done.
+func MigrateSync(ctx context.Context, dbConn db.SQL, url string) error {
+	_, err := MigrateExec(ctx, dbConn, url, migrate.Up, 0)
+	return err
+}
+
+// MigrateReset will do migration down.
+func MigrateReset(ctx context.Context, dbConn db.SQL, url string) error {
+	_, err := MigrateExec(ctx, dbConn, url, migrate.Down, 0)
+	return err
+}
+
+// MigrateExec applies at most max migrations in the given direction, 0 means no limit.
+// It returns the number of applied migrations. The migration lock is held while applying them, see lockMigration.
+func MigrateExec(ctx context.Context, dbConn db.SQL, url string, direction migrate.MigrationDirection, max int) (int, error) {
+	sqlDB, closeDB, err := migrationDB(dbConn, url)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	defer closeDB()
+
+	dialect := db.DialectOf(dbConn)
+
+	unlock, err := lockMigration(ctx, sqlDB, dialect)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	if err := recordChecksums(sqlDB, dialect); err != nil {
+		return 0, err
+	}
+
+	migrate.SetTable("migrations")
+	applied, err := migrate.ExecMax(sqlDB, string(dialect), migrationSource(dialect), direction, max)
+
+	// record the checksum of the applied migration, and remove the one which is rolled back
+	if checksumErr := recordChecksums(sqlDB, dialect); err == nil {
+		err = checksumErr
+	}
+
+	return applied, err
+}
+
+// migrationLockKey is the PostgreSQL advisory lock key held while migrating, it must be the same in every instance.
+const migrationLockKey int64 = 1539104678
+
+// migrationLockRetry is the interval of trying to take the migration lock held by another instance.
+var migrationLockRetry = 500 * time.Millisecond
+
+// lockMigration takes the PostgreSQL session advisory lock using a dedicated connection from sqlDB,
+// so instances which start at the same time don't run the same migration concurrently.
+// It retries until the lock is taken or ctx is done. The returned function releases the lock.
+// SQLite has no advisory lock and the database file is written by one process only, so it is not locked.
+func lockMigration(ctx context.Context, sqlDB *sql.DB, dialect db.Dialect) (func(), error) {
+	if dialect != db.DialectPostgres {
+		return func() {}, nil
+	}
+
+	lockConn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	host := "localhost"
-	port := "5432"
+	for {
+		var locked bool
+		err := lockConn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockKey).Scan(&locked)
+		if err != nil {
+			lockConn.Close()
+			return nil, err
+		}
 
-	// splitting by :, because addr is hostname:port, and we must split that
-	addr := strings.Split(opts.Addr, ":")
-	if len(addr) == 2 {
-		if strings.TrimSpace(addr[0]) != "" {
-			host = addr[0]
+		if locked {
+			break
 		}
 
-		port = addr[1]
+		select {
+		case <-ctx.Done():
+			lockConn.Close()
+			return nil, fmt.Errorf("migration is locked by another instance: %s", ctx.Err().Error())
+		case <-time.After(migrationLockRetry):
+		}
 	}
 
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, opts.User, opts.Password, opts.Database)
+	return func() {
+		// use new context, because ctx may be done while migrating
+		lockConn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+		lockConn.Close()
+	}, nil
+}
 
-	db, err := sql.Open("postgres", psqlInfo)
-	defer db.Close()
+// CheckSchema returns error when there is migration which is not applied yet,
+// which means the database schema is older than the one expected by this binary.
+// Migration applied by newer binary is allowed, so the old instances can still serve while rolling out.
+// It opens a new connection when dbConn has no *sql.DB, use NewSchemaChecker to check repeatedly.
+func CheckSchema(dbConn db.SQL, url string) error {
+	check, closeDB, err := NewSchemaChecker(dbConn, url)
 	if err != nil {
 		return err
 	}
+	defer closeDB()
+
+	return check()
+}
+
+// NewSchemaChecker returns CheckSchema which reuses one connection pool for every check, such as the readiness probe.
+// The returned close function closes that pool, call it after the last check.
+func NewSchemaChecker(dbConn db.SQL, url string) (func() error, func() error, error) {
+	sqlDB, closeDB, err := migrationDB(dbConn, url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dialect := db.DialectOf(dbConn)
+	check := func() error {
+		statuses, err := migrateStatus(sqlDB, dialect)
+		if err != nil {
+			return err
+		}
+
+		var pending []string
+		for _, status := range statuses {
+			if status.AppliedAt == nil {
+				pending = append(pending, status.ID)
+			}
+		}
 
-	migrations := &migrate.PackrMigrationSource{
-		Box: packr.NewBox("./../../../assets/migrations"),
+		if len(pending) > 0 {
+			return fmt.Errorf("database schema is older than expected, %d pending migration: %s", len(pending), strings.Join(pending, ", "))
+		}
+
+		return nil
+	}
+
+	return check, closeDB, nil
+}
+
+// MigratePlan returns the migrations that MigrateExec would apply with the same arguments, without applying them.
+// The checksums are verified but not recorded, so it doesn't take the migration lock.
+func MigratePlan(dbConn db.SQL, url string, direction migrate.MigrationDirection, max int) ([]*migrate.PlannedMigration, error) {
+	sqlDB, closeDB, err := migrationDB(dbConn, url)
+	if err != nil {
+		return nil, err
+	}
+	defer closeDB()
+
+	dialect := db.DialectOf(dbConn)
+	if _, err := compareChecksums(sqlDB, dialect); err != nil {
+		return nil, err
 	}
 
 	migrate.SetTable("migrations")
-	_, err = migrate.Exec(db, "postgres", migrations, migrate.Up)
-	return err
+	planned, _, err := migrate.PlanMigration(sqlDB, string(dialect), migrationSource(dialect), direction, max)
+	return planned, err
 }
 
-// MigrateReset will do migration down.
-func MigrateReset(url string) error {
-	opts, err := pg.ParseURL(url)
+// MigrationStatus is a migration and whether it is already applied to the database.
+type MigrationStatus struct {
+	ID        string
+	AppliedAt *time.Time // nil when the migration is still pending
+	Unknown   bool       // applied to the database, but the migration file doesn't exist
+}
+
+// MigrateStatus returns every migration file and every migration recorded in the migrations table, sorted by id.
+func MigrateStatus(dbConn db.SQL, url string) ([]MigrationStatus, error) {
+	sqlDB, closeDB, err := migrationDB(dbConn, url)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer closeDB()
+
+	return migrateStatus(sqlDB, db.DialectOf(dbConn))
+}
+
+func migrateStatus(sqlDB *sql.DB, dialect db.Dialect) ([]MigrationStatus, error) {
+	migrations, err := migrationSource(dialect).FindMigrations()
+	if err != nil {
+		return nil, err
 	}
 
-	host := "localhost"
-	port := "5432"
+	migrate.SetTable("migrations")
+	records, err := migrate.GetMigrationRecords(sqlDB, string(dialect))
+	if err != nil {
+		return nil, err
+	}
 
-	// splitting by :, because addr is hostname:port, and we must split that
-	addr := strings.Split(opts.Addr, ":")
-	if len(addr) == 2 {
-		if strings.TrimSpace(addr[0]) != "" {
-			host = addr[0]
+	appliedAt := make(map[string]time.Time, len(records))
+	for _, record := range records {
+		appliedAt[record.Id] = record.AppliedAt
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{ID: migration.Id}
+		if t, ok := appliedAt[migration.Id]; ok {
+			status.AppliedAt = &t
+			delete(appliedAt, migration.Id)
 		}
 
-		port = addr[1]
+		statuses = append(statuses, status)
 	}
 
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, opts.User, opts.Password, opts.Database)
+	// the rest are applied by newer binary or the file is removed
+	for _, record := range records {
+		if _, ok := appliedAt[record.Id]; !ok {
+			continue
+		}
+
+		t := record.AppliedAt
+		statuses = append(statuses, MigrationStatus{ID: record.Id, AppliedAt: &t, Unknown: true})
+	}
 
-	db, err := sql.Open("postgres", psqlInfo)
-	defer db.Close()
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return migrate.Migration{Id: statuses[i].ID}.Less(&migrate.Migration{Id: statuses[j].ID})
+	})
+
+	return statuses, nil
+}
+
+// migrationFiles contains the migration files of all dialects, it is embedded into the binary.
+var migrationFiles fs.FS = migrations.FS
+
+// migrationFS returns the migration files for the dialect.
+// PostgreSQL migration is in the root of assets/migrations, and the other dialect is in its own directory.
+func migrationFS(dialect db.Dialect) fs.FS {
+	if dialect == db.DialectSQLite {
+		files, _ := fs.Sub(migrationFiles, "sqlite") // only fails when the directory name is not valid path
+		return files
+	}
+
+	return migrationFiles
+}
+
+// migrationSource returns the migration source for the dialect.
+func migrationSource(dialect db.Dialect) migrate.MigrationSource {
+	return migrate.HttpFileSystemMigrationSource{
+		FileSystem: http.FS(migrationFS(dialect)),
+	}
+}
+
+// migrationDB returns the master *sql.DB of dbConn if it has one, so we don't need to open another connection.
+// Otherwise, the url is opened using lib/pq as is, so its sslmode is used too.
+// The returned close function only closes the connection that is opened here.
+func migrationDB(dbConn db.SQL, url string) (*sql.DB, func() error, error) {
+	if stdConn, ok := dbConn.(db.StdSQL); ok {
+		return stdConn.MasterDB(), func() error { return nil }, nil
+	}
+
+	sqlDB, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sqlDB, sqlDB.Close, nil
+}
+
+// MigrateUp will run the 'Up' section of every migration file directly using the given connection.
+// Unlike MigrateSync, it doesn't record the applied migration, so it must be used in a fresh database only,
+// such as the in-memory database created by db.NewMemoryConnection in test.
+func MigrateUp(ctx context.Context, dbConn db.SQL) error {
+	migrations, err := migrationSource(db.DialectOf(dbConn)).FindMigrations()
 	if err != nil {
 		return err
 	}
 
-	migrations := &migrate.PackrMigrationSource{
-		Box: packr.NewBox("./../../../assets/migrations"),
+	for _, migration := range migrations {
+		for _, query := range migration.Up {
+			if err := dbConn.Writer().Exec(ctx, query); err != nil {
+				return fmt.Errorf("error on migration %s: %s", migration.Id, err.Error())
+			}
+		}
 	}
 
-	migrate.SetTable("migrations")
-	_, err = migrate.Exec(db, "postgres", migrations, migrate.Down)
-	return err
+	return nil
 }
@@ -0,0 +1,162 @@
+// Package export writes every data of the user into a zip archive, so the user can download a copy of their data
+// before deleting the account. The archive has the profile as JSON, and the taxes as both JSON and CSV.
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+)
+
+// files in the archive
+const (
+	FileProfile   = "profile.json"
+	FileTaxesJSON = "taxes.json"
+	FileTaxesCSV  = "taxes.csv"
+)
+
+type (
+	// Profile is the content of profile.json. The password hash and the TOTP secret are not exported,
+	// since they are credentials instead of the data of the user.
+	Profile struct {
+		ID               int64      `json:"id"`
+		Username         string     `json:"username"`
+		Email            *string    `json:"email"`
+		DisplayName      *string    `json:"display_name"`
+		Locale           *string    `json:"locale"`
+		DefaultCurrency  *string    `json:"default_currency"`
+		Jurisdiction     *string    `json:"jurisdiction"`
+		TwoFactorEnabled bool       `json:"two_factor_enabled"`
+		Roles            []string   `json:"roles"`
+		CreatedAt        time.Time  `json:"created_at"`
+		UpdatedAt        time.Time  `json:"updated_at"`
+		PasswordChanged  *time.Time `json:"password_changed_at"`
+		ExportedAt       time.Time  `json:"exported_at"`
+	}
+
+	// TaxRow is a row of taxes.json and taxes.csv, OrgID is set when the tax is added to the organization.
+	TaxRow struct {
+		ID         int64     `json:"id"`
+		OrgID      *int64    `json:"org_id"`
+		Name       string    `json:"name"`
+		TaxCode    int       `json:"tax_code"`
+		Type       string    `json:"type"`
+		Price      int64     `json:"price"`
+		Tax        float64   `json:"tax"`
+		Amount     float64   `json:"amount"`
+		Refundable bool      `json:"refundable"`
+		CreatedAt  time.Time `json:"created_at"`
+	}
+)
+
+// csvHeader is the first row of taxes.csv, in the same order as the json fields.
+var csvHeader = []string{"id", "org_id", "name", "tax_code", "type", "price", "tax", "amount", "refundable", "created_at"}
+
+// Write writes the archive of the user and the taxes they added into w.
+func Write(w io.Writer, User *model.User, Taxes []*model.Tax, exportedAt time.Time) error {
+	archive := zip.NewWriter(w)
+
+	err := writeJSON(archive, FileProfile, Profile{
+		ID:               User.ID,
+		Username:         User.Username,
+		Email:            User.Email,
+		DisplayName:      User.DisplayName,
+		Locale:           User.Locale,
+		DefaultCurrency:  User.DefaultCurrency,
+		Jurisdiction:     User.Jurisdiction,
+		TwoFactorEnabled: User.TwoFactorEnabled(),
+		Roles:            User.Roles,
+		CreatedAt:        User.CreatedAt,
+		UpdatedAt:        User.UpdatedAt,
+		PasswordChanged:  User.PasswordChangedAt,
+		ExportedAt:       exportedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	taxes := []TaxRow{}
+	for _, Tax := range Taxes {
+		taxes = append(taxes, newTaxRow(Tax))
+	}
+
+	if err = writeJSON(archive, FileTaxesJSON, taxes); err != nil {
+		return err
+	}
+
+	if err = writeCSV(archive, FileTaxesCSV, taxes); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+func newTaxRow(Tax *model.Tax) TaxRow {
+	return TaxRow{
+		ID:         Tax.ID,
+		OrgID:      Tax.OrgID,
+		Name:       Tax.Name,
+		TaxCode:    int(Tax.TaxCode),
+		Type:       Tax.GetTaxCodeString(),
+		Price:      Tax.Price,
+		Tax:        Tax.GetTaxValue(),
+		Amount:     Tax.GetAmount(),
+		Refundable: Tax.IsRefundable(),
+		CreatedAt:  Tax.CreatedAt,
+	}
+}
+
+func writeJSON(archive *zip.Writer, name string, data interface{}) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+func writeCSV(archive *zip.Writer, name string, taxes []TaxRow) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	if err = w.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, tax := range taxes {
+		orgID := ""
+		if tax.OrgID != nil {
+			orgID = strconv.FormatInt(*tax.OrgID, 10)
+		}
+
+		err = w.Write([]string{
+			strconv.FormatInt(tax.ID, 10),
+			orgID,
+			tax.Name,
+			strconv.Itoa(tax.TaxCode),
+			tax.Type,
+			strconv.FormatInt(tax.Price, 10),
+			fmt.Sprintf("%f", tax.Tax),
+			fmt.Sprintf("%f", tax.Amount),
+			strconv.FormatBool(tax.Refundable),
+			tax.CreatedAt.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
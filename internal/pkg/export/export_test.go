@@ -0,0 +1,78 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+)
+
+func TestWrite(t *testing.T) {
+	convey.Convey("Test Write", t, func() {
+		email := "john@example.com"
+		secret := "JBSWY3DPEHPK3PXP"
+		orgID := int64(7)
+		now := time.Date(2019, 10, 19, 11, 32, 5, 0, time.UTC)
+
+		User := &model.User{ID: 1, Username: "john_doe", Password: "hash", Email: &email, TOTPSecret: &secret, Roles: []string{model.RoleUser}}
+		Taxes := []*model.Tax{
+			{ID: 2, OrgID: &orgID, Name: "Lucky Stretch", TaxCode: model.TaxCodeTobacco, Price: 1000, CreatedAt: now},
+			{ID: 1, UserID: &User.ID, Name: "Big Mac, large", TaxCode: model.TaxCodeFood, Price: 1000, CreatedAt: now},
+		}
+
+		buf := &bytes.Buffer{}
+		convey.So(Write(buf, User, Taxes, now), convey.ShouldBeNil)
+
+		archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		convey.So(err, convey.ShouldBeNil)
+
+		files := map[string][]byte{}
+		for _, f := range archive.File {
+			r, err := f.Open()
+			convey.So(err, convey.ShouldBeNil)
+
+			files[f.Name], err = ioutil.ReadAll(r)
+			convey.So(err, convey.ShouldBeNil)
+			r.Close()
+		}
+
+		convey.So(files, convey.ShouldContainKey, FileProfile)
+		convey.So(files, convey.ShouldContainKey, FileTaxesJSON)
+		convey.So(files, convey.ShouldContainKey, FileTaxesCSV)
+
+		convey.Convey("Profile doesn't contain the credentials", func() {
+			profile := map[string]interface{}{}
+			convey.So(json.Unmarshal(files[FileProfile], &profile), convey.ShouldBeNil)
+			convey.So(profile["username"], convey.ShouldEqual, "john_doe")
+			convey.So(profile["email"], convey.ShouldEqual, email)
+			convey.So(profile["two_factor_enabled"], convey.ShouldBeFalse)
+			convey.So(profile, convey.ShouldNotContainKey, "password")
+
+			convey.So(string(files[FileProfile]), convey.ShouldNotContainSubstring, "hash")
+			convey.So(string(files[FileProfile]), convey.ShouldNotContainSubstring, secret)
+		})
+
+		convey.Convey("Taxes are the same in JSON and CSV", func() {
+			var taxes []TaxRow
+			convey.So(json.Unmarshal(files[FileTaxesJSON], &taxes), convey.ShouldBeNil)
+			convey.So(taxes, convey.ShouldHaveLength, 2)
+			convey.So(*taxes[0].OrgID, convey.ShouldEqual, orgID)
+			convey.So(taxes[1].OrgID, convey.ShouldBeNil)
+			convey.So(taxes[1].Amount, convey.ShouldEqual, 1100)
+
+			rows, err := csv.NewReader(bytes.NewReader(files[FileTaxesCSV])).ReadAll()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(rows, convey.ShouldHaveLength, 3)
+			convey.So(rows[0], convey.ShouldResemble, csvHeader)
+			convey.So(rows[1], convey.ShouldResemble, []string{"2", "7", "Lucky Stretch", "2", "Tobacco", "1000", "30.000000", "1030.000000", "false", "2019-10-19T11:32:05Z"})
+			convey.So(rows[2][1], convey.ShouldEqual, "")
+			convey.So(rows[2][2], convey.ShouldEqual, "Big Mac, large")
+		})
+	})
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKey represent data structure on database in table api_keys.
+// Scopes is separated by space, use ScopeList or HasScope to read it.
+type APIKey struct {
+	ID         int64
+	UserID     int64
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     string
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// ScopeList returns the scopes of the key.
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// HasScope returns true when the key is granted the scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+const (
+	// AuthEventLoginSucceeded is logged when the user logs in.
+	AuthEventLoginSucceeded = "login_succeeded"
+
+	// AuthEventLoginFailed is logged when the username doesn't exist or the password is wrong.
+	AuthEventLoginFailed = "login_failed"
+
+	// AuthEventLoginLocked is logged when the username or the IP address is locked after too many failures.
+	AuthEventLoginLocked = "login_locked"
+
+	// AuthEventLoginDisabled is logged when the password is correct but the account is disabled.
+	AuthEventLoginDisabled = "login_disabled"
+
+	// AuthEventTwoFactorFailed is logged when the TOTP code or the recovery code is wrong.
+	AuthEventTwoFactorFailed = "2fa_failed"
+
+	// AuthEventPasswordReset is logged when the user sets a new password using the reset token.
+	AuthEventPasswordReset = "password_reset"
+
+	// AuthEventUserDisabled is logged when the admin disables the account, the user and username are the disabled one.
+	AuthEventUserDisabled = "user_disabled"
+
+	// AuthEventUserDeleted is logged when the user deletes their own account, the user is nil since it doesn't exist anymore.
+	AuthEventUserDeleted = "user_deleted"
+)
+
+// AuthEvent represent data structure on database in table auth_events.
+// UserID is nil when the username doesn't exist.
+type AuthEvent struct {
+	ID        int64
+	UserID    *int64
+	Username  string
+	IP        string
+	Event     string
+	CreatedAt time.Time
+}
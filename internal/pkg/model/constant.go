@@ -14,3 +14,48 @@ const (
 	// TaxCodeEntertainment is a tax code for Entertainment type.
 	TaxCodeEntertainment TaxCode = 3
 )
+
+// TaxCodes is the list of all known tax codes.
+var TaxCodes = []TaxCode{TaxCodeFood, TaxCodeTobacco, TaxCodeEntertainment}
+
+const (
+	// ScopeTaxRead allows the API key to list the taxes.
+	ScopeTaxRead = "tax:read"
+
+	// ScopeTaxWrite allows the API key to create the tax.
+	ScopeTaxWrite = "tax:write"
+)
+
+// Scopes is the list of all scopes which can be granted to the API key.
+var Scopes = []string{ScopeTaxRead, ScopeTaxWrite}
+
+const (
+	// RoleUser is the role of every user.
+	RoleUser = "user"
+
+	// RoleAuditor can view every user and their taxes.
+	RoleAuditor = "auditor"
+
+	// RoleAdmin can view every user and their taxes, and disable the account.
+	RoleAdmin = "admin"
+)
+
+// Roles is the list of all roles which can be granted to the user.
+var Roles = []string{RoleUser, RoleAuditor, RoleAdmin}
+
+const (
+	// PermissionTaxRead allows the user to list their own taxes.
+	PermissionTaxRead = "tax:read"
+
+	// PermissionTaxWrite allows the user to create their own tax.
+	PermissionTaxWrite = "tax:write"
+
+	// PermissionUserRead allows the user to list every user.
+	PermissionUserRead = "user:read"
+
+	// PermissionTaxReadAny allows the user to list the taxes of any user.
+	PermissionTaxReadAny = "tax:read:any"
+
+	// PermissionUserDisable allows the user to disable the account of any user.
+	PermissionUserDisable = "user:disable"
+)
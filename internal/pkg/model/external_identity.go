@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// ExternalIdentity represent data structure on database in table external_identities.
+// It links the subject of the external OpenID Connect issuer to the local user.
+type ExternalIdentity struct {
+	ID        int64
+	UserID    int64
+	Issuer    string
+	Subject   string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+const (
+	// OrgRoleOwner can add the taxes, and manage the members and the invitations of the organization.
+	OrgRoleOwner = "owner"
+
+	// OrgRoleMember can view and add the taxes of the organization.
+	OrgRoleMember = "member"
+
+	// OrgRoleViewer can only view the taxes of the organization.
+	OrgRoleViewer = "viewer"
+)
+
+// OrgRoles is the list of all roles of the organization member.
+var OrgRoles = []string{OrgRoleOwner, OrgRoleMember, OrgRoleViewer}
+
+// Organization represent data structure on database in table organizations.
+type Organization struct {
+	ID        int64
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// OrganizationMember represent data structure on database in table organization_members.
+type OrganizationMember struct {
+	ID        int64
+	OrgID     int64
+	UserID    int64
+	Role      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// CanWriteTax returns true when the member can add the tax of the organization.
+func (m *OrganizationMember) CanWriteTax() bool {
+	return m.Role == OrgRoleOwner || m.Role == OrgRoleMember
+}
+
+// CanManage returns true when the member can manage the members and the invitations of the organization.
+func (m *OrganizationMember) CanManage() bool {
+	return m.Role == OrgRoleOwner
+}
+
+// OrganizationInvitation represent data structure on database in table organization_invitations.
+// InvitedBy and AcceptedBy are nil when the user is deleted.
+type OrganizationInvitation struct {
+	ID         int64
+	OrgID      int64
+	Email      string
+	Role       string
+	TokenHash  string
+	InvitedBy  *int64
+	AcceptedBy *int64
+	ExpiresAt  time.Time
+	AcceptedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// PasswordResetToken represent data structure on database in table password_reset_tokens.
+// UsedAt is set when the password is reset using the token, or when a newer token of the same user is used.
+type PasswordResetToken struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
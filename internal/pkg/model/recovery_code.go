@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// RecoveryCode represent data structure on database in table recovery_codes.
+// It's used instead of the TOTP code when the user loses the authenticator app, UsedAt is set once it's used.
+type RecoveryCode struct {
+	ID        int64
+	UserID    int64
+	CodeHash  string
+	UsedAt    *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
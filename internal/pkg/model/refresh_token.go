@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// RefreshToken represent data structure on database in table refresh_tokens.
+// UsedAt is set when the token is exchanged for a new one, RevokedAt is set when the session is logged out.
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
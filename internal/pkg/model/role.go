@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// UserRole represent data structure on database in table user_roles.
+// The "user" role is not saved, since every user has it.
+type UserRole struct {
+	ID        int64
+	UserID    int64
+	Role      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// RolePermission represent data structure on database in table role_permissions.
+type RolePermission struct {
+	Role       string
+	Permission string
+	CreatedAt  time.Time
+}
@@ -3,9 +3,13 @@ package model
 import "time"
 
 // Tax represent data structure on database in table taxes.
+// The tax is owned by either the user (UserID is set) or the organization (OrgID is set).
+// CreatedBy is the user who added the tax, it's nil when the user is deleted.
 type Tax struct {
 	ID        int64
-	UserID    int64
+	UserID    *int64
+	OrgID     *int64
+	CreatedBy *int64
 	Name      string
 	TaxCode   TaxCode
 	Price     int64
@@ -3,10 +3,71 @@ package model
 import "time"
 
 // User is represent data structure in database.
+// PasswordChangedAt is nil when the password is never changed since the user registered.
+// Email is nil when the user doesn't set it, the password can't be reset without it.
+// TOTPSecret is set when the user enrolls 2FA, and the TOTP code is only required once TOTPEnabledAt is set,
+// TOTPEnabledAt is never set without TOTPSecret.
+// DisplayName, Locale, DefaultCurrency and Jurisdiction are the profile set by the user, they are nil when it's not set.
+// Roles and Permissions are not columns of users table, they are loaded by the authentication middleware.
 type User struct {
-	ID        int64
-	Username  string
-	Password  string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID                int64
+	Username          string
+	Password          string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	PasswordChangedAt *time.Time
+	Email             *string
+	TOTPSecret        *string
+	TOTPEnabledAt     *time.Time
+	TOTPLastStep      *int64
+	DisabledAt        *time.Time
+	DisplayName       *string
+	Locale            *string
+	DefaultCurrency   *string
+	Jurisdiction      *string
+
+	Roles       []string `sql:"-"`
+	Permissions []string `sql:"-"`
+}
+
+// TwoFactorEnabled returns true when the TOTP code is required to login.
+// It doesn't need TOTPSecret, so it also works for the cached user which doesn't have the secret.
+func (u *User) TwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// Disabled returns true when the account is disabled by the admin.
+func (u *User) Disabled() bool {
+	return u.DisabledAt != nil
+}
+
+// HasPermission returns true when one of the user roles grants the permission.
+func (u *User) HasPermission(permission string) bool {
+	for _, p := range u.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UserProfile is the part of the user which the user can change in their profile.
+type UserProfile struct {
+	Email           *string
+	DisplayName     *string
+	Locale          *string
+	DefaultCurrency *string
+	Jurisdiction    *string
+}
+
+// Profile returns the current profile of the user.
+func (u *User) Profile() UserProfile {
+	return UserProfile{
+		Email:           u.Email,
+		DisplayName:     u.DisplayName,
+		Locale:          u.Locale,
+		DefaultCurrency: u.DefaultCurrency,
+		Jurisdiction:    u.Jurisdiction,
+	}
 }
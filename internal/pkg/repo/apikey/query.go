@@ -0,0 +1,55 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+type repository struct {
+	dbConn db.SQL
+}
+
+// NewRepository returns repo.APIKeyRepository which uses the given database connection.
+// The key is looked up using the writer, so the revoked key can't be used from lagging replica.
+func NewRepository(dbConn db.SQL) repo.APIKeyRepository {
+	return &repository{
+		dbConn: dbConn,
+	}
+}
+
+// Create will insert new API key of the user.
+func (r *repository) Create(parent context.Context, userID int64, name, prefix, keyHash, scopes string) (APIKey *model.APIKey, err error) {
+	APIKey = &model.APIKey{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "api_key_create"), APIKey, sqlInsertAPIKey, userID, name, prefix, keyHash, scopes)
+	return
+}
+
+// FindByKeyHash will looking for API key by its hash.
+func (r *repository) FindByKeyHash(parent context.Context, keyHash string) (APIKey *model.APIKey, err error) {
+	APIKey = &model.APIKey{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "api_key_find_by_hash"), APIKey, sqlFindAPIKeyByHash, keyHash)
+	return
+}
+
+// GetByUserID get API keys of the user, including the revoked one.
+func (r *repository) GetByUserID(parent context.Context, userID int64) (APIKeys []*model.APIKey, err error) {
+	APIKeys = []*model.APIKey{}
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "api_key_get_by_user_id"), &APIKeys, sqlGetAPIKeysByUserID, userID)
+	return
+}
+
+// Revoke revokes the API key of the user.
+func (r *repository) Revoke(parent context.Context, id, userID int64, revokedAt time.Time) (APIKey *model.APIKey, err error) {
+	APIKey = &model.APIKey{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "api_key_revoke"), APIKey, sqlRevokeAPIKey, revokedAt, revokedAt, id, userID)
+	return
+}
+
+// Touch sets the last used time of the API key.
+func (r *repository) Touch(parent context.Context, id int64, lastUsedAt time.Time) error {
+	return r.dbConn.Writer().Exec(db.WithQueryName(parent, "api_key_touch"), sqlUpdateAPIKeyLastUse, lastUsedAt, id)
+}
@@ -0,0 +1,10 @@
+package apikey
+
+// The time is passed from the application instead of using now(), since SQLite doesn't have it.
+var (
+	sqlInsertAPIKey        = `INSERT INTO api_keys(user_id, name, prefix, key_hash, scopes) VALUES(?, ?, ?, ?, ?) RETURNING *;`
+	sqlFindAPIKeyByHash    = `SELECT * FROM api_keys WHERE key_hash = ?;`
+	sqlGetAPIKeysByUserID  = `SELECT * FROM api_keys WHERE user_id = ? ORDER BY id DESC;`
+	sqlRevokeAPIKey        = `UPDATE api_keys SET revoked_at = ?, updated_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL RETURNING *;`
+	sqlUpdateAPIKeyLastUse = `UPDATE api_keys SET last_used_at = ? WHERE id = ?;`
+)
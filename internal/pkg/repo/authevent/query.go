@@ -0,0 +1,27 @@
+package authevent
+
+import (
+	"context"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+type repository struct {
+	dbConn db.SQL
+}
+
+// NewRepository returns repo.AuthEventRepository which uses the given database connection.
+func NewRepository(dbConn db.SQL) repo.AuthEventRepository {
+	return &repository{
+		dbConn: dbConn,
+	}
+}
+
+// Create will insert new event.
+func (r *repository) Create(parent context.Context, event string, userID *int64, username, ip string) (AuthEvent *model.AuthEvent, err error) {
+	AuthEvent = &model.AuthEvent{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "auth_event_create"), AuthEvent, sqlInsertAuthEvent, event, userID, username, ip)
+	return
+}
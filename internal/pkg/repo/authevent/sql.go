@@ -0,0 +1,5 @@
+package authevent
+
+var (
+	sqlInsertAuthEvent = `INSERT INTO auth_events(event, user_id, username, ip) VALUES(?, ?, ?, ?) RETURNING *;`
+)
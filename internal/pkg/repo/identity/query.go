@@ -0,0 +1,34 @@
+package identity
+
+import (
+	"context"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+type repository struct {
+	dbConn db.SQL
+}
+
+// NewRepository returns repo.IdentityRepository which uses the given database connection.
+func NewRepository(dbConn db.SQL) repo.IdentityRepository {
+	return &repository{
+		dbConn: dbConn,
+	}
+}
+
+// Create links the subject of the issuer to the user.
+func (r *repository) Create(parent context.Context, userID int64, issuer, subject string) (Identity *model.ExternalIdentity, err error) {
+	Identity = &model.ExternalIdentity{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "identity_create"), Identity, sqlInsertIdentity, userID, issuer, subject)
+	return
+}
+
+// FindByIssuerSubject will looking for the identity by the issuer and the subject.
+func (r *repository) FindByIssuerSubject(parent context.Context, issuer, subject string) (Identity *model.ExternalIdentity, err error) {
+	Identity = &model.ExternalIdentity{}
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "identity_find_by_issuer_subject"), Identity, sqlFindIdentityByIssuerSubject, issuer, subject)
+	return
+}
@@ -0,0 +1,6 @@
+package identity
+
+var (
+	sqlInsertIdentity              = `INSERT INTO external_identities(user_id, issuer, subject) VALUES(?, ?, ?) RETURNING *;`
+	sqlFindIdentityByIssuerSubject = `SELECT * FROM external_identities WHERE issuer = ? AND subject = ?;`
+)
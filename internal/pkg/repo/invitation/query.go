@@ -0,0 +1,43 @@
+package invitation
+
+import (
+	"context"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+type repository struct {
+	dbConn db.SQL
+}
+
+// NewRepository returns repo.InvitationRepository which uses the given database connection.
+func NewRepository(dbConn db.SQL) repo.InvitationRepository {
+	return &repository{
+		dbConn: dbConn,
+	}
+}
+
+// Create will insert new invitation to the organization.
+func (r *repository) Create(parent context.Context, orgID int64, email, role, tokenHash string, invitedBy int64, expiresAt time.Time) (OrganizationInvitation *model.OrganizationInvitation, err error) {
+	OrganizationInvitation = &model.OrganizationInvitation{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "invitation_create"), OrganizationInvitation, sqlInsertInvitation, orgID, email, role, tokenHash, invitedBy, expiresAt)
+	return
+}
+
+// FindByTokenHash will looking for invitation by its token hash.
+// It uses the writer, since the invitation is usually accepted right after it's sent.
+func (r *repository) FindByTokenHash(parent context.Context, tokenHash string) (OrganizationInvitation *model.OrganizationInvitation, err error) {
+	OrganizationInvitation = &model.OrganizationInvitation{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "invitation_find_by_token_hash"), OrganizationInvitation, sqlFindInvitationByTokenHash, tokenHash)
+	return
+}
+
+// Accept sets the user who accepts the invitation, it returns db.ErrNoRows when the invitation is already accepted or expired.
+func (r *repository) Accept(parent context.Context, id, acceptedBy int64, acceptedAt time.Time) (OrganizationInvitation *model.OrganizationInvitation, err error) {
+	OrganizationInvitation = &model.OrganizationInvitation{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "invitation_accept"), OrganizationInvitation, sqlAcceptInvitation, acceptedBy, acceptedAt, acceptedAt, id, acceptedAt)
+	return
+}
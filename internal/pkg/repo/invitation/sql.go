@@ -0,0 +1,10 @@
+package invitation
+
+// The time is passed from the application instead of using now(), since SQLite doesn't have it.
+var (
+	sqlInsertInvitation          = `INSERT INTO organization_invitations(org_id, email, role, token_hash, invited_by, expires_at) VALUES(?, ?, ?, ?, ?, ?) RETURNING *;`
+	sqlFindInvitationByTokenHash = `SELECT * FROM organization_invitations WHERE token_hash = ?;`
+
+	// the condition makes the invitation can only be accepted once, even by two requests at the same time
+	sqlAcceptInvitation = `UPDATE organization_invitations SET accepted_by = ?, accepted_at = ?, updated_at = ? WHERE id = ? AND accepted_at IS NULL AND expires_at > ? RETURNING *;`
+)
@@ -0,0 +1,86 @@
+package organization
+
+import (
+	"context"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+type repository struct {
+	dbConn db.SQL
+}
+
+// NewRepository returns repo.OrganizationRepository which uses the given database connection.
+func NewRepository(dbConn db.SQL) repo.OrganizationRepository {
+	return &repository{
+		dbConn: dbConn,
+	}
+}
+
+// Create will insert new organization, it has no member yet.
+func (r *repository) Create(parent context.Context, name string) (Organization *model.Organization, err error) {
+	Organization = &model.Organization{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "organization_create"), Organization, sqlInsertOrganization, name)
+	return
+}
+
+// FindByID will looking for organization by primary id.
+func (r *repository) FindByID(parent context.Context, id int64) (Organization *model.Organization, err error) {
+	Organization = &model.Organization{}
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "organization_find_by_id"), Organization, sqlFindOrganizationByID, id)
+	return
+}
+
+// AddMember will insert the user as the member of the organization.
+func (r *repository) AddMember(parent context.Context, orgID, userID int64, role string) (OrganizationMember *model.OrganizationMember, err error) {
+	OrganizationMember = &model.OrganizationMember{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "organization_add_member"), OrganizationMember, sqlInsertMember, orgID, userID, role)
+	return
+}
+
+// FindMember will looking for the membership of the user in the organization.
+// It uses the writer, so the member which is just removed can't access the organization from lagging replica.
+func (r *repository) FindMember(parent context.Context, orgID, userID int64) (OrganizationMember *model.OrganizationMember, err error) {
+	OrganizationMember = &model.OrganizationMember{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "organization_find_member"), OrganizationMember, sqlFindMember, orgID, userID)
+	return
+}
+
+// GetMembers get members of the organization.
+func (r *repository) GetMembers(parent context.Context, orgID int64) (OrganizationMembers []*model.OrganizationMember, err error) {
+	OrganizationMembers = []*model.OrganizationMember{}
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "organization_get_members"), &OrganizationMembers, sqlGetMembersByOrgID, orgID)
+	return
+}
+
+// LockMembers get members of the organization and locks them until the transaction ends.
+// It uses the writer, since the rows are locked in the master.
+func (r *repository) LockMembers(parent context.Context, orgID int64) (OrganizationMembers []*model.OrganizationMember, err error) {
+	OrganizationMembers = []*model.OrganizationMember{}
+	query := sqlLockMembersByOrgID.For(db.DialectOf(r.dbConn))
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "organization_lock_members"), &OrganizationMembers, query, orgID)
+	return
+}
+
+// GetMembershipsByUserID get the membership of every organization the user belongs to.
+func (r *repository) GetMembershipsByUserID(parent context.Context, userID int64) (OrganizationMembers []*model.OrganizationMember, err error) {
+	OrganizationMembers = []*model.OrganizationMember{}
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "organization_get_memberships_by_user_id"), &OrganizationMembers, sqlGetMembershipsByUserID, userID)
+	return
+}
+
+// RemoveMember deletes the membership, it returns db.ErrNoRows when the user is not the member.
+func (r *repository) RemoveMember(parent context.Context, orgID, userID int64) (OrganizationMember *model.OrganizationMember, err error) {
+	OrganizationMember = &model.OrganizationMember{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "organization_remove_member"), OrganizationMember, sqlDeleteMemberByOrgIDUserID, orgID, userID)
+	return
+}
+
+// Delete deletes the organization along with its members, invitations and taxes.
+func (r *repository) Delete(parent context.Context, id int64) (Organization *model.Organization, err error) {
+	Organization = &model.Organization{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "organization_delete"), Organization, sqlDeleteOrganization, id)
+	return
+}
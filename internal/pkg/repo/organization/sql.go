@@ -0,0 +1,20 @@
+package organization
+
+import "github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+
+var (
+	sqlInsertOrganization        = `INSERT INTO organizations(name) VALUES(?) RETURNING *;`
+	sqlFindOrganizationByID      = `SELECT * FROM organizations WHERE id = ?;`
+	sqlInsertMember              = `INSERT INTO organization_members(org_id, user_id, role) VALUES(?, ?, ?) RETURNING *;`
+	sqlFindMember                = `SELECT * FROM organization_members WHERE org_id = ? AND user_id = ?;`
+	sqlGetMembersByOrgID         = `SELECT * FROM organization_members WHERE org_id = ? ORDER BY id;`
+	sqlGetMembershipsByUserID    = `SELECT * FROM organization_members WHERE user_id = ? ORDER BY org_id;`
+	sqlDeleteMemberByOrgIDUserID = `DELETE FROM organization_members WHERE org_id = ? AND user_id = ? RETURNING *;`
+	sqlDeleteOrganization        = `DELETE FROM organizations WHERE id = ? RETURNING *;`
+)
+
+// SQLite has no FOR UPDATE, the transaction is already run alone there since the connection is not shared.
+var sqlLockMembersByOrgID = db.DialectQuery{
+	db.DialectPostgres: `SELECT * FROM organization_members WHERE org_id = ? ORDER BY id FOR UPDATE;`,
+	db.DialectSQLite:   `SELECT * FROM organization_members WHERE org_id = ? ORDER BY id;`,
+}
@@ -0,0 +1,48 @@
+package passwordreset
+
+import (
+	"context"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+type repository struct {
+	dbConn db.SQL
+}
+
+// NewRepository returns repo.PasswordResetRepository which uses the given database connection.
+// Every query uses the writer, since the token is usually used right after it's created.
+func NewRepository(dbConn db.SQL) repo.PasswordResetRepository {
+	return &repository{
+		dbConn: dbConn,
+	}
+}
+
+// Create will insert new reset token of the user.
+func (r *repository) Create(parent context.Context, userID int64, tokenHash string, expiresAt time.Time) (PasswordResetToken *model.PasswordResetToken, err error) {
+	PasswordResetToken = &model.PasswordResetToken{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "password_reset_create"), PasswordResetToken, sqlInsertPasswordResetToken, userID, tokenHash, expiresAt)
+	return
+}
+
+// FindByTokenHash will looking for reset token by its hash.
+func (r *repository) FindByTokenHash(parent context.Context, tokenHash string) (PasswordResetToken *model.PasswordResetToken, err error) {
+	PasswordResetToken = &model.PasswordResetToken{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "password_reset_find_by_hash"), PasswordResetToken, sqlFindPasswordResetTokenByHash, tokenHash)
+	return
+}
+
+// Consume sets the used time of the reset token.
+func (r *repository) Consume(parent context.Context, id int64, usedAt time.Time) (PasswordResetToken *model.PasswordResetToken, err error) {
+	PasswordResetToken = &model.PasswordResetToken{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "password_reset_consume"), PasswordResetToken, sqlConsumePasswordResetToken, usedAt, usedAt, id, usedAt)
+	return
+}
+
+// ConsumeByUserID sets the used time of every unused reset token of the user.
+func (r *repository) ConsumeByUserID(parent context.Context, userID int64, usedAt time.Time) error {
+	return r.dbConn.Writer().Exec(db.WithQueryName(parent, "password_reset_consume_by_user_id"), sqlConsumePasswordResetTokenByUser, usedAt, usedAt, userID)
+}
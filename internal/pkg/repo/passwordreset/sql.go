@@ -0,0 +1,9 @@
+package passwordreset
+
+// The time is passed from the application instead of using now(), since SQLite doesn't have it.
+var (
+	sqlInsertPasswordResetToken        = `INSERT INTO password_reset_tokens(user_id, token_hash, expires_at) VALUES(?, ?, ?) RETURNING *;`
+	sqlFindPasswordResetTokenByHash    = `SELECT * FROM password_reset_tokens WHERE token_hash = ?;`
+	sqlConsumePasswordResetToken       = `UPDATE password_reset_tokens SET used_at = ?, updated_at = ? WHERE id = ? AND used_at IS NULL AND expires_at > ? RETURNING *;`
+	sqlConsumePasswordResetTokenByUser = `UPDATE password_reset_tokens SET used_at = ?, updated_at = ? WHERE user_id = ? AND used_at IS NULL;`
+)
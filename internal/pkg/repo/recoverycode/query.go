@@ -0,0 +1,41 @@
+package recoverycode
+
+import (
+	"context"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+type repository struct {
+	dbConn db.SQL
+}
+
+// NewRepository returns repo.RecoveryCodeRepository which uses the given database connection.
+// Every query uses the writer, since reading a code which is just used from lagging replica would allow it to be used twice.
+func NewRepository(dbConn db.SQL) repo.RecoveryCodeRepository {
+	return &repository{
+		dbConn: dbConn,
+	}
+}
+
+// Create will insert new recovery code of the user.
+func (r *repository) Create(parent context.Context, userID int64, codeHash string) (RecoveryCode *model.RecoveryCode, err error) {
+	RecoveryCode = &model.RecoveryCode{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "recovery_code_create"), RecoveryCode, sqlInsertRecoveryCode, userID, codeHash)
+	return
+}
+
+// Use sets the used time of the recovery code.
+func (r *repository) Use(parent context.Context, userID int64, codeHash string, usedAt time.Time) (RecoveryCode *model.RecoveryCode, err error) {
+	RecoveryCode = &model.RecoveryCode{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "recovery_code_use"), RecoveryCode, sqlUseRecoveryCode, usedAt, usedAt, userID, codeHash)
+	return
+}
+
+// DeleteByUserID deletes every recovery code of the user.
+func (r *repository) DeleteByUserID(parent context.Context, userID int64) error {
+	return r.dbConn.Writer().Exec(db.WithQueryName(parent, "recovery_code_delete_by_user_id"), sqlDeleteRecoveryCodeByUser, userID)
+}
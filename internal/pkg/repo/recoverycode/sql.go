@@ -0,0 +1,8 @@
+package recoverycode
+
+// The time is passed from the application instead of using now(), since SQLite doesn't have it.
+var (
+	sqlInsertRecoveryCode       = `INSERT INTO recovery_codes(user_id, code_hash) VALUES(?, ?) RETURNING *;`
+	sqlUseRecoveryCode          = `UPDATE recovery_codes SET used_at = ?, updated_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL RETURNING *;`
+	sqlDeleteRecoveryCodeByUser = `DELETE FROM recovery_codes WHERE user_id = ?;`
+)
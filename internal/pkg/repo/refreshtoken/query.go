@@ -0,0 +1,53 @@
+package refreshtoken
+
+import (
+	"context"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+type repository struct {
+	dbConn db.SQL
+}
+
+// NewRepository returns repo.RefreshTokenRepository which uses the given database connection.
+// Every query uses the writer, since reading a token which is just rotated from lagging replica would allow it to be used twice.
+func NewRepository(dbConn db.SQL) repo.RefreshTokenRepository {
+	return &repository{
+		dbConn: dbConn,
+	}
+}
+
+// Create will insert new refresh token of the user.
+func (r *repository) Create(parent context.Context, userID int64, familyID, tokenHash string, expiresAt time.Time) (RefreshToken *model.RefreshToken, err error) {
+	RefreshToken = &model.RefreshToken{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "refresh_token_create"), RefreshToken, sqlInsertRefreshToken, userID, familyID, tokenHash, expiresAt)
+	return
+}
+
+// FindByTokenHash will looking for refresh token by its hash.
+func (r *repository) FindByTokenHash(parent context.Context, tokenHash string) (RefreshToken *model.RefreshToken, err error) {
+	RefreshToken = &model.RefreshToken{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "refresh_token_find_by_hash"), RefreshToken, sqlFindRefreshTokenByHash, tokenHash)
+	return
+}
+
+// MarkUsed sets the used time of the refresh token.
+func (r *repository) MarkUsed(parent context.Context, id int64, usedAt time.Time) (RefreshToken *model.RefreshToken, err error) {
+	RefreshToken = &model.RefreshToken{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "refresh_token_mark_used"), RefreshToken, sqlMarkRefreshTokenUsed, usedAt, usedAt, id)
+	return
+}
+
+// RevokeFamily revokes every refresh token in the family.
+func (r *repository) RevokeFamily(parent context.Context, familyID string, revokedAt time.Time) error {
+	return r.dbConn.Writer().Exec(db.WithQueryName(parent, "refresh_token_revoke_family"), sqlRevokeRefreshTokenFamily, revokedAt, revokedAt, familyID)
+}
+
+// RevokeByUserID revokes every refresh token of the user.
+func (r *repository) RevokeByUserID(parent context.Context, userID int64, revokedAt time.Time) error {
+	return r.dbConn.Writer().Exec(db.WithQueryName(parent, "refresh_token_revoke_by_user_id"), sqlRevokeRefreshTokenByUser, revokedAt, revokedAt, userID)
+}
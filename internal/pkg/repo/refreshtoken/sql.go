@@ -0,0 +1,10 @@
+package refreshtoken
+
+// The time is passed from the application instead of using now(), since SQLite doesn't have it.
+var (
+	sqlInsertRefreshToken       = `INSERT INTO refresh_tokens(user_id, family_id, token_hash, expires_at) VALUES(?, ?, ?, ?) RETURNING *;`
+	sqlFindRefreshTokenByHash   = `SELECT * FROM refresh_tokens WHERE token_hash = ?;`
+	sqlMarkRefreshTokenUsed     = `UPDATE refresh_tokens SET used_at = ?, updated_at = ? WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL RETURNING *;`
+	sqlRevokeRefreshTokenFamily = `UPDATE refresh_tokens SET revoked_at = ?, updated_at = ? WHERE family_id = ? AND revoked_at IS NULL;`
+	sqlRevokeRefreshTokenByUser = `UPDATE refresh_tokens SET revoked_at = ?, updated_at = ? WHERE user_id = ? AND revoked_at IS NULL;`
+)
@@ -0,0 +1,207 @@
+// Package repo defines the data sources used by the application. The implementation is in the sub-package
+// of each entity, and it receives the database connection from its constructor, so the caller decides which
+// connection it uses and the handler can be tested using fake repository.
+//
+// Queries are written for PostgreSQL, and also work in SQLite since RETURNING is supported from SQLite 3.35
+// (go-sqlite3 bundles a newer version). Use db.DialectQuery when a query needs different syntax for each dialect.
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+)
+
+// UserRepository is the data source of users.
+type UserRepository interface {
+	// Create will insert a new record in database, email is nil when the user doesn't set it.
+	Create(parent context.Context, username, password string, email *string) (*model.User, error)
+
+	// FindByID will looking for user by primary id. The password hash and the TOTP secret are empty
+	// when the user is cached, use FindCredentialsByID when they're needed.
+	FindByID(parent context.Context, id int64) (*model.User, error)
+
+	// FindCredentialsByID will looking for user by primary id including the password hash and the TOTP secret,
+	// it's never cached.
+	FindCredentialsByID(parent context.Context, id int64) (*model.User, error)
+
+	// FindByUsername will looking for user by username, the case is ignored.
+	FindByUsername(parent context.Context, username string) (*model.User, error)
+
+	// FindByEmail will looking for user by email, the case is ignored.
+	FindByEmail(parent context.Context, email string) (*model.User, error)
+
+	// UpdatePassword replaces the password hash of the user, and sets the time it's changed.
+	UpdatePassword(parent context.Context, id int64, password string, changedAt time.Time) (*model.User, error)
+
+	// UpdateTOTP sets the TOTP secret and the time 2FA is enabled, both are nil to disable it. The last used time step is reset.
+	UpdateTOTP(parent context.Context, id int64, secret *string, enabledAt *time.Time, updatedAt time.Time) (*model.User, error)
+
+	// UseTOTPStep sets the time step of the accepted TOTP code, it returns db.ErrNoRows when the step is not after the last one.
+	UseTOTPStep(parent context.Context, id int64, step int64, updatedAt time.Time) (*model.User, error)
+
+	// List get users ordered by id, including the disabled one.
+	List(parent context.Context, limit, offset int) ([]*model.User, error)
+
+	// Disable sets the time the user is disabled, the time is not changed when the user is already disabled.
+	Disable(parent context.Context, id int64, disabledAt time.Time) (*model.User, error)
+
+	// UpdateProfile replaces the email and the profile of the user, nil removes the value.
+	UpdateProfile(parent context.Context, id int64, profile model.UserProfile, updatedAt time.Time) (*model.User, error)
+
+	// Delete deletes the user along with the personal taxes, sessions and API keys.
+	// The taxes added to the organizations are kept without the creator.
+	Delete(parent context.Context, id int64) (*model.User, error)
+}
+
+// RoleRepository is the data source of the roles granted to the users and the permissions of each role.
+type RoleRepository interface {
+	// Assign grants the role to the user, it does nothing when the user already has the role.
+	Assign(parent context.Context, userID int64, role string) error
+
+	// GetByUserID get roles granted to the user, it doesn't include the "user" role which every user has.
+	GetByUserID(parent context.Context, userID int64) ([]*model.UserRole, error)
+
+	// GetPermissionsByRole get permissions granted by the role.
+	GetPermissionsByRole(parent context.Context, role string) ([]*model.RolePermission, error)
+}
+
+// PasswordResetRepository is the data source of password reset tokens.
+type PasswordResetRepository interface {
+	// Create will insert new reset token of the user.
+	Create(parent context.Context, userID int64, tokenHash string, expiresAt time.Time) (*model.PasswordResetToken, error)
+
+	// FindByTokenHash will looking for reset token by its hash.
+	FindByTokenHash(parent context.Context, tokenHash string) (*model.PasswordResetToken, error)
+
+	// Consume sets the used time of the reset token, it returns db.ErrNoRows when the token is already used or expired.
+	Consume(parent context.Context, id int64, usedAt time.Time) (*model.PasswordResetToken, error)
+
+	// ConsumeByUserID sets the used time of every unused reset token of the user.
+	ConsumeByUserID(parent context.Context, userID int64, usedAt time.Time) error
+}
+
+// RecoveryCodeRepository is the data source of 2FA recovery codes.
+type RecoveryCodeRepository interface {
+	// Create will insert new recovery code of the user.
+	Create(parent context.Context, userID int64, codeHash string) (*model.RecoveryCode, error)
+
+	// Use sets the used time of the recovery code, it returns db.ErrNoRows when the code is unknown or already used.
+	Use(parent context.Context, userID int64, codeHash string, usedAt time.Time) (*model.RecoveryCode, error)
+
+	// DeleteByUserID deletes every recovery code of the user.
+	DeleteByUserID(parent context.Context, userID int64) error
+}
+
+// TaxRepository is the data source of taxes.
+type TaxRepository interface {
+	// Create will insert new tax related to the specific user id.
+	Create(parent context.Context, userID int64, name string, code int, price int64) (*model.Tax, error)
+
+	// CreateForOrganization will insert new tax owned by the organization, createdBy is the member who adds it.
+	CreateForOrganization(parent context.Context, orgID, createdBy int64, name string, code int, price int64) (*model.Tax, error)
+
+	// GetTaxesByUserID get taxes by user ID, it doesn't include the taxes of the organizations the user belongs to.
+	GetTaxesByUserID(parent context.Context, userID int64) ([]*model.Tax, error)
+
+	// GetTaxesByOrganizationID get taxes owned by the organization.
+	GetTaxesByOrganizationID(parent context.Context, orgID int64) ([]*model.Tax, error)
+
+	// GetTaxesByCreatorID get taxes added by the user, including the one added to the organizations.
+	GetTaxesByCreatorID(parent context.Context, userID int64) ([]*model.Tax, error)
+}
+
+// OrganizationRepository is the data source of organizations and their members.
+type OrganizationRepository interface {
+	// Create will insert new organization, it has no member yet.
+	Create(parent context.Context, name string) (*model.Organization, error)
+
+	// FindByID will looking for organization by primary id.
+	FindByID(parent context.Context, id int64) (*model.Organization, error)
+
+	// AddMember will insert the user as the member of the organization.
+	AddMember(parent context.Context, orgID, userID int64, role string) (*model.OrganizationMember, error)
+
+	// FindMember will looking for the membership of the user in the organization.
+	FindMember(parent context.Context, orgID, userID int64) (*model.OrganizationMember, error)
+
+	// GetMembers get members of the organization.
+	GetMembers(parent context.Context, orgID int64) ([]*model.OrganizationMember, error)
+
+	// LockMembers get members of the organization and locks them until the transaction ends,
+	// so the members are not changed by another transaction at the same time.
+	LockMembers(parent context.Context, orgID int64) ([]*model.OrganizationMember, error)
+
+	// GetMembershipsByUserID get the membership of every organization the user belongs to.
+	GetMembershipsByUserID(parent context.Context, userID int64) ([]*model.OrganizationMember, error)
+
+	// RemoveMember deletes the membership, it returns db.ErrNoRows when the user is not the member.
+	RemoveMember(parent context.Context, orgID, userID int64) (*model.OrganizationMember, error)
+
+	// Delete deletes the organization along with its members, invitations and taxes.
+	Delete(parent context.Context, id int64) (*model.Organization, error)
+}
+
+// InvitationRepository is the data source of organization invitations.
+type InvitationRepository interface {
+	// Create will insert new invitation to the organization.
+	Create(parent context.Context, orgID int64, email, role, tokenHash string, invitedBy int64, expiresAt time.Time) (*model.OrganizationInvitation, error)
+
+	// FindByTokenHash will looking for invitation by its token hash.
+	FindByTokenHash(parent context.Context, tokenHash string) (*model.OrganizationInvitation, error)
+
+	// Accept sets the user who accepts the invitation, it returns db.ErrNoRows when the invitation is already accepted or expired.
+	Accept(parent context.Context, id, acceptedBy int64, acceptedAt time.Time) (*model.OrganizationInvitation, error)
+}
+
+// RefreshTokenRepository is the data source of refresh tokens.
+type RefreshTokenRepository interface {
+	// Create will insert new refresh token of the user.
+	Create(parent context.Context, userID int64, familyID, tokenHash string, expiresAt time.Time) (*model.RefreshToken, error)
+
+	// FindByTokenHash will looking for refresh token by its hash.
+	FindByTokenHash(parent context.Context, tokenHash string) (*model.RefreshToken, error)
+
+	// MarkUsed sets the used time of the refresh token, it returns db.ErrNoRows when the token is already used or revoked.
+	MarkUsed(parent context.Context, id int64, usedAt time.Time) (*model.RefreshToken, error)
+
+	// RevokeFamily revokes every refresh token in the family.
+	RevokeFamily(parent context.Context, familyID string, revokedAt time.Time) error
+
+	// RevokeByUserID revokes every refresh token of the user.
+	RevokeByUserID(parent context.Context, userID int64, revokedAt time.Time) error
+}
+
+// IdentityRepository is the data source of the external identities.
+type IdentityRepository interface {
+	// Create links the subject of the issuer to the user.
+	Create(parent context.Context, userID int64, issuer, subject string) (*model.ExternalIdentity, error)
+
+	// FindByIssuerSubject will looking for the identity by the issuer and the subject.
+	FindByIssuerSubject(parent context.Context, issuer, subject string) (*model.ExternalIdentity, error)
+}
+
+// APIKeyRepository is the data source of API keys.
+type APIKeyRepository interface {
+	// Create will insert new API key of the user.
+	Create(parent context.Context, userID int64, name, prefix, keyHash, scopes string) (*model.APIKey, error)
+
+	// FindByKeyHash will looking for API key by its hash.
+	FindByKeyHash(parent context.Context, keyHash string) (*model.APIKey, error)
+
+	// GetByUserID get API keys of the user, including the revoked one.
+	GetByUserID(parent context.Context, userID int64) ([]*model.APIKey, error)
+
+	// Revoke revokes the API key of the user, it returns db.ErrNoRows when the key is not found or already revoked.
+	Revoke(parent context.Context, id, userID int64, revokedAt time.Time) (*model.APIKey, error)
+
+	// Touch sets the last used time of the API key.
+	Touch(parent context.Context, id int64, lastUsedAt time.Time) error
+}
+
+// AuthEventRepository is the data source of the auth event log.
+type AuthEventRepository interface {
+	// Create will insert new event, userID is nil when the username doesn't exist.
+	Create(parent context.Context, event string, userID *int64, username, ip string) (*model.AuthEvent, error)
+}
@@ -0,0 +1,39 @@
+package role
+
+import (
+	"context"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+type repository struct {
+	dbConn db.SQL
+}
+
+// NewRepository returns repo.RoleRepository which uses the given database connection.
+func NewRepository(dbConn db.SQL) repo.RoleRepository {
+	return &repository{
+		dbConn: dbConn,
+	}
+}
+
+// Assign grants the role to the user, it does nothing when the user already has the role.
+func (r *repository) Assign(parent context.Context, userID int64, role string) error {
+	return r.dbConn.Writer().Exec(db.WithQueryName(parent, "role_assign"), sqlAssignRole, userID, role)
+}
+
+// GetByUserID get roles granted to the user.
+func (r *repository) GetByUserID(parent context.Context, userID int64) (UserRoles []*model.UserRole, err error) {
+	UserRoles = []*model.UserRole{}
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "role_get_by_user_id"), &UserRoles, sqlGetRolesByUserID, userID)
+	return
+}
+
+// GetPermissionsByRole get permissions granted by the role.
+func (r *repository) GetPermissionsByRole(parent context.Context, role string) (RolePermissions []*model.RolePermission, err error) {
+	RolePermissions = []*model.RolePermission{}
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "role_get_permissions_by_role"), &RolePermissions, sqlGetPermissionsByRole, role)
+	return
+}
@@ -0,0 +1,8 @@
+package role
+
+// The user and the role is unique, so assigning the same role twice does nothing.
+var (
+	sqlAssignRole           = `INSERT INTO user_roles(user_id, role) VALUES(?, ?) ON CONFLICT (user_id, role) DO NOTHING;`
+	sqlGetRolesByUserID     = `SELECT * FROM user_roles WHERE user_id = ? ORDER BY role;`
+	sqlGetPermissionsByRole = `SELECT * FROM role_permissions WHERE role = ? ORDER BY permission;`
+)
@@ -3,20 +3,53 @@ package tax
 import (
 	"context"
 
-	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
 	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
 )
 
+type repository struct {
+	dbConn db.SQL
+}
+
+// NewRepository returns repo.TaxRepository which uses the given database connection.
+func NewRepository(dbConn db.SQL) repo.TaxRepository {
+	return &repository{
+		dbConn: dbConn,
+	}
+}
+
 // Create will insert new tax related to the specific user id.
-func Create(parent context.Context, userID int64, name string, code int, price int64) (Tax *model.Tax, err error) {
+func (r *repository) Create(parent context.Context, userID int64, name string, code int, price int64) (Tax *model.Tax, err error) {
+	Tax = &model.Tax{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "tax_create"), Tax, sqlInsertTax, userID, userID, name, code, price)
+	return
+}
+
+// CreateForOrganization will insert new tax owned by the organization, createdBy is the member who adds it.
+func (r *repository) CreateForOrganization(parent context.Context, orgID, createdBy int64, name string, code int, price int64) (Tax *model.Tax, err error) {
 	Tax = &model.Tax{}
-	err = conn.GetDBConnection().Writer().Query(parent, Tax, sqlInsertTax, userID, name, code, price)
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "tax_create_for_organization"), Tax, sqlInsertOrganizationTax, orgID, createdBy, name, code, price)
+	return
+}
+
+// GetTaxesByUserID get taxes by user ID, it doesn't include the taxes of the organizations the user belongs to.
+func (r *repository) GetTaxesByUserID(parent context.Context, userID int64) (Taxes []*model.Tax, err error) {
+	Taxes = []*model.Tax{}
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "tax_get_by_user_id"), &Taxes, sqlGetTaxesByUserId, userID)
+	return
+}
+
+// GetTaxesByOrganizationID get taxes owned by the organization.
+func (r *repository) GetTaxesByOrganizationID(parent context.Context, orgID int64) (Taxes []*model.Tax, err error) {
+	Taxes = []*model.Tax{}
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "tax_get_by_organization_id"), &Taxes, sqlGetTaxesByOrganizationID, orgID)
 	return
 }
 
-// GetTaxesByUserID get taxes by user ID.
-func GetTaxesByUserID(parent context.Context, userID int64) (Taxes []*model.Tax, err error) {
+// GetTaxesByCreatorID get taxes added by the user, including the one added to the organizations.
+func (r *repository) GetTaxesByCreatorID(parent context.Context, userID int64) (Taxes []*model.Tax, err error) {
 	Taxes = []*model.Tax{}
-	err = conn.GetDBConnection().Reader().Query(parent, &Taxes, sqlGetTaxesByUserId, userID)
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "tax_get_by_creator_id"), &Taxes, sqlGetTaxesByCreatorID, userID)
 	return
 }
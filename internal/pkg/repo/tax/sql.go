@@ -1,6 +1,9 @@
 package tax
 
 var (
-	sqlInsertTax        = `INSERT INTO taxes(user_id, name, tax_code, price) VALUES(?, ?, ?, ?) RETURNING *;`
-	sqlGetTaxesByUserId = `SELECT * FROM taxes WHERE user_id = ? ORDER BY id DESC;`
+	sqlInsertTax                = `INSERT INTO taxes(user_id, created_by, name, tax_code, price) VALUES(?, ?, ?, ?, ?) RETURNING *;`
+	sqlInsertOrganizationTax    = `INSERT INTO taxes(org_id, created_by, name, tax_code, price) VALUES(?, ?, ?, ?, ?) RETURNING *;`
+	sqlGetTaxesByUserId         = `SELECT * FROM taxes WHERE user_id = ? ORDER BY id DESC;`
+	sqlGetTaxesByOrganizationID = `SELECT * FROM taxes WHERE org_id = ? ORDER BY id DESC;`
+	sqlGetTaxesByCreatorID      = `SELECT * FROM taxes WHERE created_by = ? ORDER BY id DESC;`
 )
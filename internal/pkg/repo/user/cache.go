@@ -0,0 +1,173 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/cache"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+var logger = log.With().Str("pkg", "user").Caller().Logger()
+
+type cachedRepository struct {
+	repo.UserRepository
+	dbConn db.SQL
+	cache  cache.Cache
+	ttl    time.Duration
+}
+
+// NewCachedRepository returns repo.UserRepository which keeps the user found by FindByID in the cache for ttl,
+// so the authenticated request doesn't query the database every time. The cached user is removed when
+// it's updated, disabled or deleted through the returned repository. When dbConn is a transaction of db.RunInTransaction,
+// it's removed after the commit, so the lookup running before the commit can't put the old user back.
+// The cache error is only logged, and the database is used instead.
+// The password hash and the TOTP secret are never cached, FindCredentialsByID reads them from the database.
+func NewCachedRepository(dbConn db.SQL, next repo.UserRepository, c cache.Cache, ttl time.Duration) repo.UserRepository {
+	return &cachedRepository{
+		UserRepository: next,
+		dbConn:         dbConn,
+		cache:          c,
+		ttl:            ttl,
+	}
+}
+
+// cachedUser is the part of model.User kept in the cache, it has no credential so the leaked cache can't be used to login.
+// The roles are loaded on every request, so they're not cached either.
+type cachedUser struct {
+	ID                int64
+	Username          string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	PasswordChangedAt *time.Time
+	Email             *string
+	TOTPEnabledAt     *time.Time
+	DisabledAt        *time.Time
+	DisplayName       *string
+	Locale            *string
+	DefaultCurrency   *string
+	Jurisdiction      *string
+}
+
+func newCachedUser(User *model.User) cachedUser {
+	return cachedUser{
+		ID:                User.ID,
+		Username:          User.Username,
+		CreatedAt:         User.CreatedAt,
+		UpdatedAt:         User.UpdatedAt,
+		PasswordChangedAt: User.PasswordChangedAt,
+		Email:             User.Email,
+		TOTPEnabledAt:     User.TOTPEnabledAt,
+		DisabledAt:        User.DisabledAt,
+		DisplayName:       User.DisplayName,
+		Locale:            User.Locale,
+		DefaultCurrency:   User.DefaultCurrency,
+		Jurisdiction:      User.Jurisdiction,
+	}
+}
+
+func (u cachedUser) user() *model.User {
+	return &model.User{
+		ID:                u.ID,
+		Username:          u.Username,
+		CreatedAt:         u.CreatedAt,
+		UpdatedAt:         u.UpdatedAt,
+		PasswordChangedAt: u.PasswordChangedAt,
+		Email:             u.Email,
+		TOTPEnabledAt:     u.TOTPEnabledAt,
+		DisabledAt:        u.DisabledAt,
+		DisplayName:       u.DisplayName,
+		Locale:            u.Locale,
+		DefaultCurrency:   u.DefaultCurrency,
+		Jurisdiction:      u.Jurisdiction,
+	}
+}
+
+// FindByID will looking for user by primary id, the cache is used first.
+// The returned user never has the password hash and the TOTP secret, even when it's read from the database,
+// so the caller doesn't depend on whether it's cached.
+func (r *cachedRepository) FindByID(parent context.Context, id int64) (*model.User, error) {
+	key := cacheKey(id)
+	value, err := r.cache.Get(parent, key)
+	if err == nil {
+		cached := cachedUser{}
+		if err = json.Unmarshal(value, &cached); err == nil {
+			return cached.user(), nil
+		}
+	}
+
+	if err != cache.ErrNotFound {
+		logger.Error().Err(err).Int64("userID", id).Msg("fail get user from cache")
+	}
+
+	User, err := r.UserRepository.FindByID(parent, id)
+	if err != nil || User == nil || User.ID == 0 {
+		return User, err
+	}
+
+	cached := newCachedUser(User)
+	if value, err = json.Marshal(cached); err == nil {
+		err = r.cache.Set(parent, key, value, r.ttl)
+	}
+
+	if err != nil {
+		logger.Error().Err(err).Int64("userID", id).Msg("fail set user to cache")
+	}
+
+	return cached.user(), nil
+}
+
+// UpdatePassword replaces the password hash of the user, and sets the time it's changed.
+func (r *cachedRepository) UpdatePassword(parent context.Context, id int64, password string, changedAt time.Time) (*model.User, error) {
+	defer r.invalidate(parent, id)
+	return r.UserRepository.UpdatePassword(parent, id, password, changedAt)
+}
+
+// UpdateTOTP sets the TOTP secret and the time 2FA is enabled, both are nil to disable it. The last used time step is reset.
+func (r *cachedRepository) UpdateTOTP(parent context.Context, id int64, secret *string, enabledAt *time.Time, updatedAt time.Time) (*model.User, error) {
+	defer r.invalidate(parent, id)
+	return r.UserRepository.UpdateTOTP(parent, id, secret, enabledAt, updatedAt)
+}
+
+// UseTOTPStep sets the time step of the accepted TOTP code, it returns db.ErrNoRows when the step is not after the last one.
+func (r *cachedRepository) UseTOTPStep(parent context.Context, id int64, step int64, updatedAt time.Time) (*model.User, error) {
+	defer r.invalidate(parent, id)
+	return r.UserRepository.UseTOTPStep(parent, id, step, updatedAt)
+}
+
+// Disable sets the time the user is disabled, the time is not changed when the user is already disabled.
+func (r *cachedRepository) Disable(parent context.Context, id int64, disabledAt time.Time) (*model.User, error) {
+	defer r.invalidate(parent, id)
+	return r.UserRepository.Disable(parent, id, disabledAt)
+}
+
+// UpdateProfile replaces the email and the profile of the user, nil removes the value.
+func (r *cachedRepository) UpdateProfile(parent context.Context, id int64, profile model.UserProfile, updatedAt time.Time) (*model.User, error) {
+	defer r.invalidate(parent, id)
+	return r.UserRepository.UpdateProfile(parent, id, profile, updatedAt)
+}
+
+// Delete deletes the user along with the personal taxes, sessions and API keys.
+func (r *cachedRepository) Delete(parent context.Context, id int64) (*model.User, error) {
+	defer r.invalidate(parent, id)
+	return r.UserRepository.Delete(parent, id)
+}
+
+// invalidate removes the user from the cache after the change is committed,
+// it's done even when the query fails since the row may be changed anyway.
+func (r *cachedRepository) invalidate(parent context.Context, id int64) {
+	db.AfterCommit(r.dbConn, func() {
+		if err := r.cache.Delete(parent, cacheKey(id)); err != nil {
+			logger.Error().Err(err).Int64("userID", id).Msg("fail delete user from cache")
+		}
+	})
+}
+
+func cacheKey(id int64) string {
+	return fmt.Sprintf("user:%d", id)
+}
@@ -0,0 +1,95 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/cache"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+func TestCachedRepository(t *testing.T) {
+	convey.Convey("Test Cached Repository", t, func() {
+		ctx := context.Background()
+		dbConn, err := db.NewMemoryConnection()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(conn.MigrateUp(ctx, dbConn), convey.ShouldBeNil)
+
+		c := cache.NewLRU(10)
+		users := NewCachedRepository(dbConn, NewRepository(dbConn), c, time.Minute)
+
+		User, err := users.Create(ctx, "alice", "password-hash", nil)
+		convey.So(err, convey.ShouldBeNil)
+
+		secret, now := "totp-secret", time.Now()
+		_, err = users.UpdateTOTP(ctx, User.ID, &secret, &now, now)
+		convey.So(err, convey.ShouldBeNil)
+
+		convey.Convey("Credentials are not cached", func() {
+			for i := 0; i < 2; i++ {
+				cached, err := users.FindByID(ctx, User.ID)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(cached.Username, convey.ShouldEqual, "alice")
+				convey.So(cached.Password, convey.ShouldBeEmpty)
+				convey.So(cached.TOTPSecret, convey.ShouldBeNil)
+				convey.So(cached.TwoFactorEnabled(), convey.ShouldBeTrue)
+			}
+
+			value, err := c.Get(ctx, cacheKey(User.ID))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(strings.Contains(string(value), "password-hash"), convey.ShouldBeFalse)
+			convey.So(strings.Contains(string(value), secret), convey.ShouldBeFalse)
+
+			withCredentials, err := users.FindCredentialsByID(ctx, User.ID)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(withCredentials.Password, convey.ShouldEqual, "password-hash")
+			convey.So(*withCredentials.TOTPSecret, convey.ShouldEqual, secret)
+		})
+
+		convey.Convey("User disabled in transaction is removed from the cache after commit", func() {
+			err := db.RunInTransaction(ctx, dbConn, func(tx db.SQL) error {
+				txUsers := NewCachedRepository(tx, NewRepository(tx), c, time.Minute)
+				if _, err := txUsers.Disable(ctx, User.ID, time.Now()); err != nil {
+					return err
+				}
+
+				// another request reads the user before the commit, and puts the user which is not disabled yet
+				// into the cache (the read can't be done here, SQLite has only one connection)
+				value, err := json.Marshal(newCachedUser(User))
+				if err != nil {
+					return err
+				}
+
+				return c.Set(ctx, cacheKey(User.ID), value, time.Minute)
+			})
+			convey.So(err, convey.ShouldBeNil)
+
+			_, err = c.Get(ctx, cacheKey(User.ID))
+			convey.So(err, convey.ShouldEqual, cache.ErrNotFound)
+
+			disabled, err := users.FindByID(ctx, User.ID)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(disabled.Disabled(), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Disabled user is removed from the cache", func() {
+			_, err := users.FindByID(ctx, User.ID)
+			convey.So(err, convey.ShouldBeNil)
+
+			_, err = users.Disable(ctx, User.ID, time.Now())
+			convey.So(err, convey.ShouldBeNil)
+
+			_, err = c.Get(ctx, cacheKey(User.ID))
+			convey.So(err, convey.ShouldEqual, cache.ErrNotFound)
+
+			disabled, err := users.FindByID(ctx, User.ID)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(disabled.Disabled(), convey.ShouldBeTrue)
+		})
+	})
+}
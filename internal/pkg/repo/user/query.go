@@ -2,28 +2,105 @@ package user
 
 import (
 	"context"
+	"time"
 
-	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
 	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
 )
 
-// Create will insert a new record in database.
-func Create(parent context.Context, username, password string) (User *model.User, err error) {
+type repository struct {
+	dbConn db.SQL
+}
+
+// NewRepository returns repo.UserRepository which uses the given database connection.
+func NewRepository(dbConn db.SQL) repo.UserRepository {
+	return &repository{
+		dbConn: dbConn,
+	}
+}
+
+// Create will insert a new record in database, email is nil when the user doesn't set it.
+func (r *repository) Create(parent context.Context, username, password string, email *string) (User *model.User, err error) {
 	User = &model.User{}
-	err = conn.GetDBConnection().Writer().Query(parent, User, sqlInsertUser, username, password)
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "user_create"), User, sqlInsertUser, username, password, email)
 	return
 }
 
 // FindByID will looking for user by primary id
-func FindByID(parent context.Context, id int64) (User *model.User, err error) {
+func (r *repository) FindByID(parent context.Context, id int64) (User *model.User, err error) {
+	User = &model.User{}
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "user_find_by_id"), User, sqlFindUserByID, id)
+	return
+}
+
+// FindCredentialsByID will looking for user by primary id including the password hash and the TOTP secret.
+func (r *repository) FindCredentialsByID(parent context.Context, id int64) (User *model.User, err error) {
+	User = &model.User{}
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "user_find_credentials_by_id"), User, sqlFindUserByID, id)
+	return
+}
+
+// FindByUsername will looking for user by username, the case is ignored.
+func (r *repository) FindByUsername(parent context.Context, username string) (User *model.User, err error) {
+	User = &model.User{}
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "user_find_by_username"), User, sqlFindUserByUsername, username)
+	return
+}
+
+// FindByEmail will looking for user by email, the case is ignored.
+func (r *repository) FindByEmail(parent context.Context, email string) (User *model.User, err error) {
+	User = &model.User{}
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "user_find_by_email"), User, sqlFindUserByEmail, email)
+	return
+}
+
+// UpdatePassword replaces the password hash of the user, and sets the time it's changed.
+func (r *repository) UpdatePassword(parent context.Context, id int64, password string, changedAt time.Time) (User *model.User, err error) {
+	User = &model.User{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "user_update_password"), User, sqlUpdateUserPassword, password, changedAt, changedAt, id)
+	return
+}
+
+// UpdateTOTP sets the TOTP secret and the time 2FA is enabled, both are nil to disable it. The last used time step is reset.
+func (r *repository) UpdateTOTP(parent context.Context, id int64, secret *string, enabledAt *time.Time, updatedAt time.Time) (User *model.User, err error) {
+	User = &model.User{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "user_update_totp"), User, sqlUpdateUserTOTP, secret, enabledAt, updatedAt, id)
+	return
+}
+
+// UseTOTPStep sets the time step of the accepted TOTP code, it returns db.ErrNoRows when the step is not after the last one.
+func (r *repository) UseTOTPStep(parent context.Context, id int64, step int64, updatedAt time.Time) (User *model.User, err error) {
+	User = &model.User{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "user_use_totp_step"), User, sqlUseUserTOTPStep, step, updatedAt, id, step)
+	return
+}
+
+// List get users ordered by id, including the disabled one.
+func (r *repository) List(parent context.Context, limit, offset int) (Users []*model.User, err error) {
+	Users = []*model.User{}
+	err = r.dbConn.Reader().Query(db.WithQueryName(parent, "user_list"), &Users, sqlListUsers, limit, offset)
+	return
+}
+
+// Disable sets the time the user is disabled, the time is not changed when the user is already disabled.
+func (r *repository) Disable(parent context.Context, id int64, disabledAt time.Time) (User *model.User, err error) {
+	User = &model.User{}
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "user_disable"), User, sqlDisableUser, disabledAt, disabledAt, id)
+	return
+}
+
+// UpdateProfile replaces the email and the profile of the user, nil removes the value.
+func (r *repository) UpdateProfile(parent context.Context, id int64, profile model.UserProfile, updatedAt time.Time) (User *model.User, err error) {
 	User = &model.User{}
-	err = conn.GetDBConnection().Reader().Query(parent, User, sqlFindUserByID, id)
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "user_update_profile"), User, sqlUpdateUserProfile,
+		profile.Email, profile.DisplayName, profile.Locale, profile.DefaultCurrency, profile.Jurisdiction, updatedAt, id)
 	return
 }
 
-// FindByUsername will looking for user by username.
-func FindByUsername(parent context.Context, username string) (User *model.User, err error) {
+// Delete deletes the user, the other tables referencing the user are handled by the foreign key.
+func (r *repository) Delete(parent context.Context, id int64) (User *model.User, err error) {
 	User = &model.User{}
-	err = conn.GetDBConnection().Reader().Query(parent, User, sqlFindUserByUsername, username)
+	err = r.dbConn.Writer().Query(db.WithQueryName(parent, "user_delete"), User, sqlDeleteUser, id)
 	return
 }
@@ -1,7 +1,17 @@
 package user
 
 var (
-	sqlInsertUser         = `INSERT INTO users(username, password) VALUES(?, ?) RETURNING *;`
+	sqlInsertUser         = `INSERT INTO users(username, password, email) VALUES(?, ?, ?) RETURNING *;`
 	sqlFindUserByID       = `SELECT * FROM users WHERE id = ?;`
-	sqlFindUserByUsername = `SELECT * FROM users WHERE username = ?;`
+	sqlFindUserByUsername = `SELECT * FROM users WHERE lower(username) = lower(?);`
+	sqlFindUserByEmail    = `SELECT * FROM users WHERE lower(email) = lower(?);`
+	sqlUpdateUserPassword = `UPDATE users SET password = ?, password_changed_at = ?, updated_at = ? WHERE id = ? RETURNING *;`
+	sqlUpdateUserTOTP     = `UPDATE users SET totp_secret = ?, totp_enabled_at = ?, totp_last_step = NULL, updated_at = ? WHERE id = ? RETURNING *;`
+	sqlListUsers          = `SELECT * FROM users ORDER BY id LIMIT ? OFFSET ?;`
+	sqlDisableUser        = `UPDATE users SET disabled_at = coalesce(disabled_at, ?), updated_at = ? WHERE id = ? RETURNING *;`
+	sqlUpdateUserProfile  = `UPDATE users SET email = ?, display_name = ?, locale = ?, default_currency = ?, jurisdiction = ?, updated_at = ? WHERE id = ? RETURNING *;`
+	sqlDeleteUser         = `DELETE FROM users WHERE id = ? RETURNING *;`
+
+	// the condition makes the same code can't be used by two requests at the same time
+	sqlUseUserTOTPStep = `UPDATE users SET totp_last_step = ?, updated_at = ? WHERE id = ? AND (totp_last_step IS NULL OR totp_last_step < ?) RETURNING *;`
 )
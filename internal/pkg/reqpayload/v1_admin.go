@@ -0,0 +1,7 @@
+package reqpayload
+
+// ListUsers is a query parameter of GET /api/v1/admin/users, limit is 20 when it's not set.
+type ListUsers struct {
+	Limit  int `json:"limit" form:"limit" validate:"omitempty,min=1,max=100" example:"20"`
+	Offset int `json:"offset" form:"offset" validate:"min=0" example:"0"`
+}
@@ -0,0 +1,7 @@
+package reqpayload
+
+// CreateAPIKey is a payload required when user creates an API key for server-to-server integration.
+type CreateAPIKey struct {
+	Name   string   `json:"name" form:"name" validate:"required" example:"accounting"`
+	Scopes []string `json:"scopes" form:"scopes" validate:"required" example:"tax:read,tax:write"`
+}
@@ -0,0 +1,19 @@
+package reqpayload
+
+type (
+	// CreateOrganization is a payload required when user creates an organization, the user becomes its owner.
+	CreateOrganization struct {
+		Name string `json:"name" form:"name" validate:"required,max=100" example:"Finance Department"`
+	}
+
+	// InviteMember is a payload required when the owner invites a user into the organization.
+	InviteMember struct {
+		Email string `json:"email" form:"email" validate:"required,email" example:"jane@example.com"`
+		Role  string `json:"role" form:"role" validate:"required,oneof=owner member viewer" example:"member"`
+	}
+
+	// AcceptInvitation is a payload required when user accepts the invitation using the token from the email.
+	AcceptInvitation struct {
+		Token string `json:"token" form:"token" validate:"required" example:"c2VjcmV0"`
+	}
+)
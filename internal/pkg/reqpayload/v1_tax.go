@@ -1,8 +1,15 @@
 package reqpayload
 
 // CreateNewTax is a payload required when create a new Tax record in POST /api/v1/tax.
+// OrgID is set to add the tax into the organization ledger instead of the personal one.
 type CreateNewTax struct {
 	Name    string `json:"name" form:"name" validate:"required" example:"Big Mac"`
 	TaxCode int    `json:"tax_code" form:"tax_code" validate:"required" example:"1"`
 	Price   int64  `json:"price" form:"price" validate:"required,min=0" example:"1000"`
+	OrgID   int64  `json:"org_id" form:"org_id" validate:"min=0" example:"0"`
+}
+
+// GetTaxes is a query parameter of GET /api/v1/tax, OrgID is set to get the taxes of the organization.
+type GetTaxes struct {
+	OrgID int64 `json:"org_id" form:"org_id" validate:"min=0" example:"0"`
 }
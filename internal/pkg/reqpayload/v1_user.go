@@ -5,6 +5,7 @@ type (
 	Register struct {
 		Username string `json:"username" form:"username" validate:"required" example:"john_doe"`
 		Password string `json:"password" form:"password" validate:"required" example:"secret"`
+		Email    string `json:"email" form:"email" validate:"omitempty,email" example:"john@example.com"`
 	}
 
 	// Login is a payload required when user login to this system.
@@ -12,4 +13,68 @@ type (
 		Username string `json:"username" form:"username" validate:"required" example:"john_doe"`
 		Password string `json:"password" form:"password" validate:"required" example:"secret"`
 	}
+
+	// RefreshToken is a payload required when user exchanges the refresh token with a new access token.
+	RefreshToken struct {
+		RefreshToken string `json:"refresh_token" form:"refresh_token" validate:"required" example:"c2VjcmV0"`
+	}
+
+	// ChangePassword is a payload required when user changes the password.
+	ChangePassword struct {
+		CurrentPassword string `json:"current_password" form:"current_password" validate:"required" example:"secret"`
+		NewPassword     string `json:"new_password" form:"new_password" validate:"required" example:"correct horse battery staple"`
+	}
+
+	// ForgotPassword is a payload required when user asks the password reset token.
+	ForgotPassword struct {
+		Email string `json:"email" form:"email" validate:"required,email" example:"john@example.com"`
+	}
+
+	// ResetPassword is a payload required when user sets a new password using the reset token.
+	ResetPassword struct {
+		Token       string `json:"token" form:"token" validate:"required" example:"c2VjcmV0"`
+		NewPassword string `json:"new_password" form:"new_password" validate:"required" example:"correct horse battery staple"`
+	}
+
+	// EnrollTwoFactor is a payload required when user enrolls 2FA.
+	EnrollTwoFactor struct {
+		Password string `json:"password" form:"password" validate:"required" example:"secret"`
+	}
+
+	// ConfirmTwoFactor is a payload required when user confirms the enrolled 2FA.
+	ConfirmTwoFactor struct {
+		Code string `json:"code" form:"code" validate:"required" example:"123456"`
+	}
+
+	// DisableTwoFactor is a payload required when user disables 2FA.
+	DisableTwoFactor struct {
+		Password string `json:"password" form:"password" validate:"required" example:"secret"`
+		Code     string `json:"code" form:"code" validate:"required" example:"123456"`
+	}
+
+	// LoginTwoFactor is a payload required when user completes the login using the TOTP code or a recovery code.
+	LoginTwoFactor struct {
+		TwoFactorToken string `json:"two_factor_token" form:"two_factor_token" validate:"required" example:"abc"`
+		Code           string `json:"code" form:"code" validate:"required" example:"123456"`
+	}
+
+	// Logout is a payload required when user logs out the session of the refresh token.
+	Logout struct {
+		RefreshToken string `json:"refresh_token" form:"refresh_token" validate:"required" example:"c2VjcmV0"`
+	}
+
+	// UpdateProfile is a payload required when user updates the profile, only the sent field is changed and empty value removes it.
+	UpdateProfile struct {
+		Email           *string `json:"email" form:"email" validate:"omitempty,email" example:"john@example.com"`
+		DisplayName     *string `json:"display_name" form:"display_name" validate:"omitempty,max=100" example:"John Doe"`
+		Locale          *string `json:"locale" form:"locale" validate:"omitempty,max=35" example:"id-ID"`
+		DefaultCurrency *string `json:"default_currency" form:"default_currency" validate:"omitempty,len=3,alpha" example:"IDR"`
+		Jurisdiction    *string `json:"jurisdiction" form:"jurisdiction" validate:"omitempty,max=6" example:"ID"`
+	}
+
+	// DeleteAccount is a payload required when user deletes their account, the code is only required when 2FA is enabled.
+	DeleteAccount struct {
+		Password string `json:"password" form:"password" validate:"required" example:"secret"`
+		Code     string `json:"code" form:"code" example:"123456"`
+	}
 )
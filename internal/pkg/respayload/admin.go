@@ -0,0 +1,23 @@
+package respayload
+
+import "time"
+
+// AdminUser is the user entity shown to the admin, it has the account state which is not shown in User.
+type AdminUser struct {
+	User
+	CreatedAt  time.Time  `json:"created_at" example:"2018-10-09T17:04:38Z"`
+	DisabledAt *time.Time `json:"disabled_at" example:"2018-10-10T08:00:00Z"` // null when the account is active
+}
+
+// AdminUsers is the response model when the admin lists the users.
+type AdminUsers struct {
+	Users  []*AdminUser `json:"users"`
+	Limit  int          `json:"limit" example:"20"`
+	Offset int          `json:"offset" example:"0"`
+}
+
+// DisableUser is the response model when the admin disables the account.
+// Every session of the user is logged out, and their API keys can't be used anymore.
+type DisableUser struct {
+	User *AdminUser `json:"user"`
+}
@@ -0,0 +1,26 @@
+package respayload
+
+import "time"
+
+// APIKey is the entity model which the API key should look in http response, the key itself is never returned here.
+type APIKey struct {
+	ID         int64      `json:"id" example:"1"`
+	Name       string     `json:"name" example:"accounting"`
+	Prefix     string     `json:"prefix" example:"tce_AbCd1234"`
+	Scopes     []string   `json:"scopes" example:"tax:read"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateAPIKey is the response model when user success to create an API key.
+// The key is only shown in this response, it can't be retrieved later.
+type CreateAPIKey struct {
+	Key    string  `json:"key" example:"tce_AbCd1234..."`
+	APIKey *APIKey `json:"api_key"`
+}
+
+// APIKeys is the response model when user lists the API keys.
+type APIKeys struct {
+	APIKeys []*APIKey `json:"api_keys"`
+}
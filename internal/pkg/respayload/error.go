@@ -7,19 +7,47 @@ type ErrorCode string
 // System Level Error = 0
 // User = 1
 // Tax = 2
+// API Key = 3
+// Organization = 4
 // after that, follow the underscore and the sequence number of the error code.
 // This to make grouping and debugging error much easier.
 const (
 	ErrorGeneralValidationError ErrorCode = "0_0001"
 	ErrorBindingBodyRequest     ErrorCode = "0_0002"
+	ErrorDatabaseUnavailable    ErrorCode = "0_0003"
 
-	ErrorCodeUserCantBeCreated  ErrorCode = "1_0001"
-	ErrorCodeUserCantBeFound    ErrorCode = "1_0002"
-	ErrorCodeUserWrongPassword  ErrorCode = "1_0003"
-	ErrorCodeUserWrongAuthToken ErrorCode = "1_0004"
+	ErrorCodeUserCantBeCreated      ErrorCode = "1_0001"
+	ErrorCodeUserCantBeFound        ErrorCode = "1_0002"
+	ErrorCodeUserWrongPassword      ErrorCode = "1_0003"
+	ErrorCodeUserWrongAuthToken     ErrorCode = "1_0004"
+	ErrorCodeUserWrongRefreshToken  ErrorCode = "1_0005"
+	ErrorCodeUserRefreshTokenReused ErrorCode = "1_0006"
+	ErrorCodeUserSessionDBError     ErrorCode = "1_0007"
+	ErrorCodeUserLoginThrottled     ErrorCode = "1_0008"
+	ErrorCodeUserWrongResetToken    ErrorCode = "1_0009"
+	ErrorCodeUserWrongTwoFactorCode ErrorCode = "1_0010"
+	ErrorCodeUserWrongTwoFactorAuth ErrorCode = "1_0011"
+	ErrorCodeUserTwoFactorState     ErrorCode = "1_0012"
+	ErrorCodeUserDisabled           ErrorCode = "1_0013"
+	ErrorCodeUserPermissionDenied   ErrorCode = "1_0014"
+	ErrorCodeUserCantBeUpdated      ErrorCode = "1_0015"
+	ErrorCodeUserCantBeDeleted      ErrorCode = "1_0016"
 
 	ErrorCodeTaxCantBeCreated ErrorCode = "2_0001"
 	ErrorCodeTaxDBError       ErrorCode = "2_0002"
+
+	ErrorCodeAPIKeyCantBeCreated ErrorCode = "3_0001"
+	ErrorCodeAPIKeyCantBeFound   ErrorCode = "3_0002"
+	ErrorCodeAPIKeyDBError       ErrorCode = "3_0003"
+	ErrorCodeAPIKeyScopeDenied   ErrorCode = "3_0004"
+
+	ErrorCodeOrgCantBeCreated     ErrorCode = "4_0001"
+	ErrorCodeOrgCantBeFound       ErrorCode = "4_0002"
+	ErrorCodeOrgDBError           ErrorCode = "4_0003"
+	ErrorCodeOrgPermissionDenied  ErrorCode = "4_0004"
+	ErrorCodeOrgWrongInvitation   ErrorCode = "4_0005"
+	ErrorCodeOrgAlreadyMember     ErrorCode = "4_0006"
+	ErrorCodeOrgLastOwnerRequired ErrorCode = "4_0007"
 )
 
 // Error is a response structure when the server cannot fulfill the request (non 200 http status).
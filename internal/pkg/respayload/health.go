@@ -0,0 +1,17 @@
+package respayload
+
+// Health is the response model of the health endpoints, Status is "ok" when every required check passes.
+type Health struct {
+	Status string         `json:"status" example:"ok"`
+	Checks []*HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck is the state of a dependency. The server is not ready when the required one is down,
+// the others are only reported since the server still works without them.
+type HealthCheck struct {
+	Name      string  `json:"name" example:"db_master"`
+	Status    string  `json:"status" example:"ok"`
+	Required  bool    `json:"required" example:"true"`
+	LatencyMs float64 `json:"latency_ms" example:"0.52"`
+	Error     string  `json:"error,omitempty"`
+}
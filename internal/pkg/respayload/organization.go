@@ -0,0 +1,41 @@
+package respayload
+
+import "time"
+
+// Organization is the entity model of the organization, Role is the role of the current user in it.
+type Organization struct {
+	ID        int64     `json:"id" example:"1"`
+	Name      string    `json:"name" example:"Finance Department"`
+	Role      string    `json:"role" example:"owner"`
+	CreatedAt time.Time `json:"created_at" example:"2019-10-19T11:32:05.049Z"`
+}
+
+// Organizations is the response model when user lists the organizations they belong to.
+type Organizations struct {
+	Organizations []*Organization `json:"organizations"`
+}
+
+// OrganizationMember is the entity model of the organization member.
+type OrganizationMember struct {
+	User     *User     `json:"user"`
+	Role     string    `json:"role" example:"member"`
+	JoinedAt time.Time `json:"joined_at" example:"2019-10-19T11:32:05.049Z"`
+}
+
+// OrganizationMembers is the response model when user lists the members of the organization.
+type OrganizationMembers struct {
+	Members []*OrganizationMember `json:"members"`
+}
+
+// InviteMember is the response model when the owner invites a user, the token is only sent to the email.
+type InviteMember struct {
+	ID        int64     `json:"id" example:"1"`
+	Email     string    `json:"email" example:"jane@example.com"`
+	Role      string    `json:"role" example:"member"`
+	ExpiresAt time.Time `json:"expires_at" example:"2019-10-26T11:32:05.049Z"`
+}
+
+// RemoveMember is the response model when the member is removed from the organization.
+type RemoveMember struct {
+	Message string `json:"message" example:"member is removed"`
+}
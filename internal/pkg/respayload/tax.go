@@ -9,9 +9,10 @@ type Tax struct {
 	Tax        string `json:"tax" example:"100"`
 	Amount     string `json:"amount" example:"1100"`
 	Refundable bool   `json:"refundable" example:"false"`
+	OrgID      *int64 `json:"org_id,omitempty" example:"1"` // only set for the tax of the organization
 }
 
-// TaxesForCurrentUser is the model to return when user request the list of their bills.
+// TaxesForCurrentUser is the model to return when user request the list of their bills, or the bills of the organization.
 type TaxesForCurrentUser struct {
 	PriceSubTotal int64  `json:"price_sub_total" example:"2150"`
 	TaxSubTotal   string `json:"tax_sub_total" example:"120.5"`
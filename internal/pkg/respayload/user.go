@@ -1,21 +1,104 @@
 package respayload
 
+import "time"
+
 // Login is the response model when user success to login.
 // I separate this model with Register model even it looks similar to ensure that
 // if we need to add or remove some property here, it don't affecting register response.
 type Login struct {
 	AuthenticationToken string `json:"authentication_token" example:"abc"`
+	RefreshToken        string `json:"refresh_token" example:"c2VjcmV0"`
+	ExpiresIn           int64  `json:"expires_in" example:"900"` // lifetime of the authentication token in seconds
 	User                *User  `json:"user"`
 }
 
+// LoginTwoFactorRequired is the response model when the password is correct but the user has 2FA enabled.
+// Exchange the two_factor_token with the authentication token using the TOTP code in POST /login/2fa.
+type LoginTwoFactorRequired struct {
+	TwoFactorRequired bool   `json:"two_factor_required" example:"true"`
+	TwoFactorToken    string `json:"two_factor_token" example:"abc"`
+	ExpiresIn         int64  `json:"expires_in" example:"300"` // lifetime of the two_factor_token in seconds
+}
+
+// EnrollTwoFactor is the response model when user enrolls 2FA.
+// Add the secret into the authenticator app, usually by scanning otpauth_uri as QR code, then confirm it using the code.
+type EnrollTwoFactor struct {
+	Secret     string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	OTPAuthURI string `json:"otpauth_uri" example:"otpauth://totp/Tax%20Calculator:john_doe?secret=JBSWY3DPEHPK3PXP"`
+}
+
+// ConfirmTwoFactor is the response model when user confirms 2FA.
+// Every recovery code can be used once instead of the TOTP code, they are only shown once.
+type ConfirmTwoFactor struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"abcd-efgh-ijkl-mnop"`
+}
+
+// DisableTwoFactor is the response model when user disables 2FA.
+type DisableTwoFactor struct {
+	Message string `json:"message" example:"2FA is disabled"`
+}
+
 // Register is the response model when user success to register.
 // I separate this model with Login model even it looks similar to ensure that
 // if we need to add or remove some property here, it don't affecting login response.
 type Register struct {
 	AuthenticationToken string `json:"authentication_token" example:"abc"`
+	RefreshToken        string `json:"refresh_token" example:"c2VjcmV0"`
+	ExpiresIn           int64  `json:"expires_in" example:"900"` // lifetime of the authentication token in seconds
 	User                *User  `json:"user"`
 }
 
+// RefreshToken is the response model when user success to exchange the refresh token.
+// The refresh token in the request can't be used anymore, use the new one in this response.
+type RefreshToken struct {
+	AuthenticationToken string `json:"authentication_token" example:"abc"`
+	RefreshToken        string `json:"refresh_token" example:"c2VjcmV0"`
+	ExpiresIn           int64  `json:"expires_in" example:"900"` // lifetime of the authentication token in seconds
+	User                *User  `json:"user"`
+}
+
+// ChangePassword is the response model when user success to change the password.
+// Every other session is logged out, so this response has the new session of the current client.
+type ChangePassword struct {
+	AuthenticationToken string `json:"authentication_token" example:"abc"`
+	RefreshToken        string `json:"refresh_token" example:"c2VjcmV0"`
+	ExpiresIn           int64  `json:"expires_in" example:"900"` // lifetime of the authentication token in seconds
+	User                *User  `json:"user"`
+}
+
+// ForgotPassword is the response model when user asks the password reset token.
+// It's the same whether the email is registered or not.
+type ForgotPassword struct {
+	Message string `json:"message" example:"if the email is registered, the password reset link is sent to it"`
+}
+
+// ResetPassword is the response model when user success to reset the password.
+type ResetPassword struct {
+	Message string `json:"message" example:"password is reset, login using the new password"`
+}
+
+// Logout is the response model when user success to logout.
+type Logout struct {
+	Message string `json:"message" example:"logged out"`
+}
+
+// Profile is the response model of the profile of current user, the unset field is null.
+type Profile struct {
+	User
+	DisplayName      *string   `json:"display_name" example:"John Doe"`
+	Locale           *string   `json:"locale" example:"id-ID"`
+	DefaultCurrency  *string   `json:"default_currency" example:"IDR"`
+	Jurisdiction     *string   `json:"jurisdiction" example:"ID"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled" example:"false"`
+	Roles            []string  `json:"roles" example:"user"`
+	CreatedAt        time.Time `json:"created_at" example:"2019-10-19T11:32:05.049Z"`
+}
+
+// DeleteAccount is the response model when user deletes their account.
+type DeleteAccount struct {
+	Message string `json:"message" example:"account is deleted"`
+}
+
 // User is the entity model which the user entity should look in http response.
 // This to make every User object is consistent in every response, since I know that it is painful in the client side,
 // if we return inconsistent structure for the same object.
@@ -24,4 +107,5 @@ type Register struct {
 type User struct {
 	ID       int64  `json:"id" example:"1"`
 	Username string `json:"username" example:"john_doe"`
+	Email    string `json:"email,omitempty" example:"john@example.com"`
 }
@@ -0,0 +1,52 @@
+package seed
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+)
+
+// itemNames is the sample item name for each tax code.
+var itemNames = map[model.TaxCode][]string{
+	model.TaxCodeFood:          {"Big Mac", "Lucky Stretch Coffee", "Nasi Goreng", "Orange Juice", "Pizza"},
+	model.TaxCodeTobacco:       {"Lucky Stretch", "Marlboro", "Sampoerna", "Cigar"},
+	model.TaxCodeEntertainment: {"Movie", "Concert Ticket", "Theme Park", "Karaoke", "Bowling"},
+}
+
+// maxGeneratedTaxes is the maximum number of taxes of a generated user.
+const maxGeneratedTaxes = 10
+
+// Generate creates n synthetic users named demo_user_1 until demo_user_n, all of them use the same password.
+// Each user has random tax items across all tax codes.
+// The items of each user only depend on the seed and the user number,
+// so loading it again with the same seed doesn't create anything, even when n is bigger.
+func Generate(n int, seed int64, password string) *Fixture {
+	fixture := &Fixture{
+		Users: make([]User, n),
+	}
+
+	for i := range fixture.Users {
+		random := rand.New(rand.NewSource(seed + int64(i)))
+
+		taxes := make([]Tax, random.Intn(maxGeneratedTaxes)+1)
+		for j := range taxes {
+			taxCode := model.TaxCodes[random.Intn(len(model.TaxCodes))]
+			names := itemNames[taxCode]
+
+			taxes[j] = Tax{
+				Name:    names[random.Intn(len(names))],
+				TaxCode: int(taxCode),
+				Price:   int64(random.Intn(5000) + 1),
+			}
+		}
+
+		fixture.Users[i] = User{
+			Username: fmt.Sprintf("demo_user_%d", i+1),
+			Password: password,
+			Taxes:    taxes,
+		}
+	}
+
+	return fixture
+}
@@ -0,0 +1,189 @@
+// Package seed loads fixtures of users and their taxes into the database, for demo and test environment.
+// Every fixture is inserted through the repositories, so the data is the same as the one created using the REST API.
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/validator"
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// Fixture is the content of a fixture file.
+	Fixture struct {
+		Users []User `json:"users" yaml:"users" validate:"dive"`
+	}
+
+	// User is a user fixture, the password is in plain text and hashed before it's saved.
+	// Roles are granted on top of the "user" role which every user has.
+	User struct {
+		Username string   `json:"username" yaml:"username" validate:"required"`
+		Password string   `json:"password" yaml:"password" validate:"required"`
+		Email    string   `json:"email" yaml:"email" validate:"omitempty,email"`
+		Roles    []string `json:"roles" yaml:"roles" validate:"dive,oneof=user auditor admin"`
+		Taxes    []Tax    `json:"taxes" yaml:"taxes" validate:"dive"`
+	}
+
+	// Tax is a tax item fixture of the user.
+	Tax struct {
+		Name    string `json:"name" yaml:"name" validate:"required"`
+		TaxCode int    `json:"tax_code" yaml:"tax_code" validate:"required,oneof=1 2 3"`
+		Price   int64  `json:"price" yaml:"price" validate:"min=0"`
+	}
+)
+
+// Result is the number of created and skipped (already exist) rows.
+type Result struct {
+	UsersCreated int
+	UsersSkipped int
+	TaxesCreated int
+	TaxesSkipped int
+}
+
+// ReadFile reads the fixture from YAML (.yaml or .yml) or JSON (.json) file.
+func ReadFile(path string) (*Fixture, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := &Fixture{}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(content, fixture)
+	case ".json":
+		err = json.Unmarshal(content, fixture)
+	default:
+		return nil, fmt.Errorf("fixture %s must be a .yaml, .yml or .json file", path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse fixture %s: %s", path, err.Error())
+	}
+
+	if errs := validator.Validate(fixture); errs != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %s", path, errs.String())
+	}
+
+	return fixture, nil
+}
+
+// Loader inserts the fixture using the repositories.
+type Loader struct {
+	users repo.UserRepository
+	taxes repo.TaxRepository
+	roles repo.RoleRepository
+}
+
+// NewLoader creates a loader which uses the given repositories.
+func NewLoader(users repo.UserRepository, taxes repo.TaxRepository, roles repo.RoleRepository) *Loader {
+	return &Loader{
+		users: users,
+		taxes: taxes,
+		roles: roles,
+	}
+}
+
+// Load inserts the fixture into the database, it can be run many times.
+// User which username already exists is not changed, only its missing roles and taxes are inserted.
+// A tax is missing when the user doesn't have a tax with the same name, tax code and price.
+func (l *Loader) Load(ctx context.Context, fixture *Fixture) (*Result, error) {
+	result := &Result{}
+
+	// generated users share the same password, so it's hashed only once
+	hashes := map[string]string{}
+
+	for _, fixtureUser := range fixture.Users {
+		// the same rules as the registration, otherwise the user can't login using the REST API
+		if err := auth.ValidateUsername(fixtureUser.Username); err != nil {
+			return result, fmt.Errorf("invalid username %s: %s", fixtureUser.Username, err.Error())
+		}
+
+		User, err := l.users.FindByUsername(ctx, fixtureUser.Username)
+		switch {
+		case err == nil:
+			result.UsersSkipped++
+		case err == db.ErrNoRows:
+			hash, ok := hashes[fixtureUser.Password]
+			if !ok {
+				hash, err = auth.HashPassword(fixtureUser.Password)
+				if err != nil {
+					return result, err
+				}
+
+				hashes[fixtureUser.Password] = hash
+			}
+
+			var email *string
+			if fixtureUser.Email != "" {
+				email = &fixtureUser.Email
+			}
+
+			User, err = l.users.Create(ctx, fixtureUser.Username, hash, email)
+			if err != nil {
+				return result, fmt.Errorf("cannot create user %s: %s", fixtureUser.Username, err.Error())
+			}
+
+			result.UsersCreated++
+		default:
+			return result, err
+		}
+
+		for _, role := range fixtureUser.Roles {
+			if err := l.roles.Assign(ctx, User.ID, role); err != nil {
+				return result, fmt.Errorf("cannot grant role %s to user %s: %s", role, fixtureUser.Username, err.Error())
+			}
+		}
+
+		if err := l.loadTaxes(ctx, User, fixtureUser.Taxes, result); err != nil {
+			return result, fmt.Errorf("cannot create tax of user %s: %s", fixtureUser.Username, err.Error())
+		}
+	}
+
+	return result, nil
+}
+
+type taxKey struct {
+	name    string
+	taxCode model.TaxCode
+	price   int64
+}
+
+func (l *Loader) loadTaxes(ctx context.Context, User *model.User, fixtureTaxes []Tax, result *Result) error {
+	Taxes, err := l.taxes.GetTaxesByUserID(ctx, User.ID)
+	if err != nil {
+		return err
+	}
+
+	// counted, so the same item can be bought more than once
+	existing := map[taxKey]int{}
+	for _, Tax := range Taxes {
+		existing[taxKey{Tax.Name, Tax.TaxCode, Tax.Price}]++
+	}
+
+	for _, fixtureTax := range fixtureTaxes {
+		key := taxKey{fixtureTax.Name, model.TaxCode(fixtureTax.TaxCode), fixtureTax.Price}
+		if existing[key] > 0 {
+			existing[key]--
+			result.TaxesSkipped++
+			continue
+		}
+
+		if _, err := l.taxes.Create(ctx, User.ID, fixtureTax.Name, fixtureTax.TaxCode, fixtureTax.Price); err != nil {
+			return err
+		}
+
+		result.TaxesCreated++
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package seed
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/auth"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/conn"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/model"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/role"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/tax"
+	"github.com/yusufsyaifudin/tax-calculator-example/internal/pkg/repo/user"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/db"
+)
+
+func TestReadFile(t *testing.T) {
+	convey.Convey("Test Read Fixture File", t, func() {
+		dir, err := ioutil.TempDir("", "seed")
+		convey.So(err, convey.ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		write := func(name, content string) string {
+			path := filepath.Join(dir, name)
+			convey.So(ioutil.WriteFile(path, []byte(content), 0644), convey.ShouldBeNil)
+			return path
+		}
+
+		convey.Convey("Demo fixture is valid", func() {
+			fixture, err := ReadFile("../../../assets/fixtures/demo.yaml")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(fixture.Users, convey.ShouldHaveLength, 3)
+			convey.So(fixture.Users[2].Roles, convey.ShouldResemble, []string{model.RoleAdmin})
+			convey.So(fixture.Users[0].Taxes[0], convey.ShouldResemble, Tax{Name: "Big Mac", TaxCode: 1, Price: 1000})
+		})
+
+		convey.Convey("JSON fixture is read", func() {
+			fixture, err := ReadFile(write("users.json", `{"users": [{"username": "john_doe", "password": "secret", "taxes": [{"name": "Movie", "tax_code": 3, "price": 150}]}]}`))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(fixture.Users[0].Username, convey.ShouldEqual, "john_doe")
+			convey.So(fixture.Users[0].Taxes[0].TaxCode, convey.ShouldEqual, 3)
+		})
+
+		convey.Convey("Unknown tax code is invalid", func() {
+			_, err := ReadFile(write("users.yml", "users:\n  - username: john_doe\n    password: secret\n    taxes:\n      - name: Movie\n        tax_code: 4\n        price: 150\n"))
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "tax_code: oneof")
+		})
+
+		convey.Convey("Unknown role is invalid", func() {
+			_, err := ReadFile(write("users.yaml", "users:\n  - username: john_doe\n    password: secret\n    roles: [superuser]\n"))
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "oneof")
+		})
+
+		convey.Convey("Unknown field is invalid", func() {
+			_, err := ReadFile(write("users.yaml", "users:\n  - username: john_doe\n    pasword: secret\n"))
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Other extension is not supported", func() {
+			_, err := ReadFile(write("users.txt", ""))
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
+
+func TestLoad(t *testing.T) {
+	convey.Convey("Test Load Fixture", t, func() {
+		dbConn, err := db.NewMemoryConnection()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(conn.MigrateUp(context.Background(), dbConn), convey.ShouldBeNil)
+		defer dbConn.Close()
+
+		users := user.NewRepository(dbConn)
+		taxes := tax.NewRepository(dbConn)
+		roles := role.NewRepository(dbConn)
+		loader := NewLoader(users, taxes, roles)
+
+		ctx := context.Background()
+		fixture := &Fixture{
+			Users: []User{
+				{
+					Username: "john_doe",
+					Password: "secret",
+					Taxes: []Tax{
+						{Name: "Big Mac", TaxCode: 1, Price: 1000},
+						{Name: "Big Mac", TaxCode: 1, Price: 1000},
+					},
+				},
+			},
+		}
+
+		result, err := loader.Load(ctx, fixture)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(result, convey.ShouldResemble, &Result{UsersCreated: 1, TaxesCreated: 2})
+
+		User, err := users.FindByUsername(ctx, "john_doe")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(auth.CheckPasswordHash("secret", User.Password), convey.ShouldBeTrue)
+
+		convey.Convey("Loading the same fixture again creates nothing", func() {
+			result, err := loader.Load(ctx, fixture)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, &Result{UsersSkipped: 1, TaxesSkipped: 2})
+		})
+
+		convey.Convey("Only the missing tax of existing user is created", func() {
+			fixture.Users[0].Password = "changed"
+			fixture.Users[0].Taxes = append(fixture.Users[0].Taxes, Tax{Name: "Big Mac", TaxCode: 1, Price: 1000})
+
+			result, err := loader.Load(ctx, fixture)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result, convey.ShouldResemble, &Result{UsersSkipped: 1, TaxesCreated: 1, TaxesSkipped: 2})
+
+			Taxes, err := taxes.GetTaxesByUserID(ctx, User.ID)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(Taxes, convey.ShouldHaveLength, 3)
+
+			User, err := users.FindByUsername(ctx, "john_doe")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(auth.CheckPasswordHash("secret", User.Password), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Role is granted to existing user once", func() {
+			fixture.Users[0].Roles = []string{model.RoleAuditor}
+			for i := 0; i < 2; i++ {
+				_, err := loader.Load(ctx, fixture)
+				convey.So(err, convey.ShouldBeNil)
+			}
+
+			UserRoles, err := roles.GetByUserID(ctx, User.ID)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(UserRoles, convey.ShouldHaveLength, 1)
+			convey.So(UserRoles[0].Role, convey.ShouldEqual, model.RoleAuditor)
+		})
+
+		convey.Convey("User with invalid username is not created", func() {
+			fixture.Users[0].Username = "1 bad name"
+			result, err := loader.Load(ctx, fixture)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(result, convey.ShouldResemble, &Result{})
+
+			_, err = users.FindByUsername(ctx, "1 bad name")
+			convey.So(err, convey.ShouldEqual, db.ErrNoRows)
+		})
+
+		convey.Convey("Generated users can be loaded many times", func() {
+			result, err := loader.Load(ctx, Generate(3, 1, "password"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result.UsersCreated, convey.ShouldEqual, 3)
+
+			result, err = loader.Load(ctx, Generate(5, 1, "password"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(result.UsersCreated, convey.ShouldEqual, 2)
+			convey.So(result.UsersSkipped, convey.ShouldEqual, 3)
+		})
+	})
+}
+
+func TestGenerate(t *testing.T) {
+	convey.Convey("Test Generate Users", t, func() {
+		fixture := Generate(20, 42, "password")
+		convey.So(fixture.Users, convey.ShouldHaveLength, 20)
+		convey.So(fixture.Users[0].Username, convey.ShouldEqual, "demo_user_1")
+		convey.So(fixture.Users[19].Username, convey.ShouldEqual, "demo_user_20")
+
+		taxCodes := map[int]bool{}
+		for _, User := range fixture.Users {
+			convey.So(len(User.Taxes), convey.ShouldBeBetweenOrEqual, 1, maxGeneratedTaxes)
+			for _, Tax := range User.Taxes {
+				taxCodes[Tax.TaxCode] = true
+			}
+		}
+
+		convey.So(taxCodes, convey.ShouldResemble, map[int]bool{1: true, 2: true, 3: true})
+		convey.So(Generate(20, 42, "password"), convey.ShouldResemble, fixture)
+	})
+}
@@ -0,0 +1,24 @@
+// Package cache stores short-lived values through the Cache interface. LRU keeps them in the process memory,
+// and Redis shares them between the instances of the application.
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when the key is not in the cache or it's expired.
+var ErrNotFound = errors.New("cache: key not found")
+
+// Cache stores the value of the key until the TTL passes.
+type Cache interface {
+	// Get returns the value of the key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Set replaces the value of the key, it's removed after ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the key, it's not an error when the key doesn't exist.
+	Delete(ctx context.Context, key string) error
+}
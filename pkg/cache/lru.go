@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRU keeps the values in memory, the least recently used one is removed when the cache is full.
+// The value is only visible to this process, so it can be stale in the other instances until the TTL passes.
+type LRU struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+	now   func() time.Time
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewLRU creates the cache which keeps at most size keys.
+func NewLRU(size int) *LRU {
+	if size < 1 {
+		size = 1
+	}
+
+	return &LRU{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+		now:   time.Now,
+	}
+}
+
+// Get returns the value of the key, the expired key is removed.
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.remove(elem)
+		return nil, ErrNotFound
+	}
+
+	c.ll.MoveToFront(elem)
+	return entry.value, nil
+}
+
+// Set replaces the value of the key, the least recently used key is removed when the cache is full.
+func (c *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(ttl)
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		c.ll.MoveToFront(elem)
+		return nil
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	if c.ll.Len() > c.size {
+		c.remove(c.ll.Back())
+	}
+
+	return nil
+}
+
+// Delete removes the key.
+func (c *LRU) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+
+	return nil
+}
+
+// Len returns the number of keys, including the expired one which is not removed yet.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+func (c *LRU) remove(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*lruEntry).key)
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestLRU(t *testing.T) {
+	convey.Convey("Test LRU", t, func() {
+		ctx := context.Background()
+		now := time.Date(2019, 10, 19, 11, 32, 5, 0, time.UTC)
+
+		c := NewLRU(2)
+		c.now = func() time.Time { return now }
+
+		convey.So(c.Set(ctx, "a", []byte("1"), time.Minute), convey.ShouldBeNil)
+		convey.So(c.Set(ctx, "b", []byte("2"), time.Minute), convey.ShouldBeNil)
+
+		convey.Convey("Get the value", func() {
+			value, err := c.Get(ctx, "a")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(string(value), convey.ShouldEqual, "1")
+
+			_, err = c.Get(ctx, "c")
+			convey.So(err, convey.ShouldEqual, ErrNotFound)
+		})
+
+		convey.Convey("Least recently used key is removed when the cache is full", func() {
+			_, err := c.Get(ctx, "a")
+			convey.So(err, convey.ShouldBeNil)
+
+			convey.So(c.Set(ctx, "c", []byte("3"), time.Minute), convey.ShouldBeNil)
+			convey.So(c.Len(), convey.ShouldEqual, 2)
+
+			_, err = c.Get(ctx, "b")
+			convey.So(err, convey.ShouldEqual, ErrNotFound)
+
+			_, err = c.Get(ctx, "a")
+			convey.So(err, convey.ShouldBeNil)
+		})
+
+		convey.Convey("Set replaces the value without removing the other key", func() {
+			convey.So(c.Set(ctx, "a", []byte("10"), time.Minute), convey.ShouldBeNil)
+			convey.So(c.Len(), convey.ShouldEqual, 2)
+
+			value, err := c.Get(ctx, "a")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(string(value), convey.ShouldEqual, "10")
+		})
+
+		convey.Convey("Expired key is not returned", func() {
+			now = now.Add(time.Minute)
+
+			_, err := c.Get(ctx, "a")
+			convey.So(err, convey.ShouldEqual, ErrNotFound)
+			convey.So(c.Len(), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("Deleted key is not returned", func() {
+			convey.So(c.Delete(ctx, "a"), convey.ShouldBeNil)
+			convey.So(c.Delete(ctx, "c"), convey.ShouldBeNil)
+
+			_, err := c.Get(ctx, "a")
+			convey.So(err, convey.ShouldEqual, ErrNotFound)
+			convey.So(c.Len(), convey.ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisConfig is the configuration of the Redis server.
+type RedisConfig struct {
+	Address  string        // host:port of the Redis server
+	Password string        // AUTH is sent when it's not empty
+	DB       int           // database number selected after connecting
+	Timeout  time.Duration // dial, read and write timeout when the context has no deadline
+	PoolSize int           // maximum idle connections kept for the next command
+}
+
+// Redis stores the values in the Redis server, so every instance of the application sees the same value
+// and the deleted key is gone from all of them. It only speaks the commands this package needs, GET, SET and DEL.
+type Redis struct {
+	conf RedisConfig
+
+	mu     sync.Mutex
+	idle   []*redisConn
+	closed bool
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+var errRedisClosed = errors.New("redis: cache is closed")
+
+// redisError is the error reply of the Redis server.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// NewRedis creates the cache using the Redis server, the connection is opened on the first command.
+func NewRedis(conf RedisConfig) (*Redis, error) {
+	if _, _, err := net.SplitHostPort(conf.Address); err != nil {
+		return nil, fmt.Errorf("redis address must be host:port: %s", err.Error())
+	}
+
+	if conf.Timeout <= 0 {
+		conf.Timeout = time.Second
+	}
+
+	if conf.PoolSize <= 0 {
+		conf.PoolSize = 10
+	}
+
+	return &Redis{conf: conf}, nil
+}
+
+// Get returns the value of the key, or ErrNotFound.
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return nil, err
+	}
+
+	if reply == nil {
+		return nil, ErrNotFound
+	}
+
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected reply of GET %T", reply)
+	}
+
+	return value, nil
+}
+
+// Set replaces the value of the key, Redis removes it after ttl.
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ms := ttl.Nanoseconds() / int64(time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+
+	_, err := c.do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(ms, 10))
+	return err
+}
+
+// Delete removes the key.
+func (c *Redis) Delete(ctx context.Context, key string) error {
+	_, err := c.do(ctx, "DEL", key)
+	return err
+}
+
+// Ping checks the connection to the Redis server.
+func (c *Redis) Ping(ctx context.Context) error {
+	_, err := c.do(ctx, "PING")
+	return err
+}
+
+// Close closes the idle connections, the cache can't be used after it's closed.
+func (c *Redis) Close() error {
+	c.mu.Lock()
+	idle := c.idle
+	c.idle, c.closed = nil, true
+	c.mu.Unlock()
+
+	for _, conn := range idle {
+		conn.Close()
+	}
+
+	return nil
+}
+
+// do sends the command and reads its reply. The connection is only reused when the whole reply is read,
+// so the next command never reads the rest of the previous reply.
+func (c *Redis) do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := c.command(ctx, conn, args...)
+	if _, ok := err.(redisError); err != nil && !ok {
+		conn.Close()
+		return nil, err
+	}
+
+	c.put(conn)
+	return reply, err
+}
+
+func (c *Redis) get(ctx context.Context) (*redisConn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errRedisClosed
+	}
+
+	if n := len(c.idle); n > 0 {
+		conn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return conn, nil
+	}
+	c.mu.Unlock()
+
+	dialer := &net.Dialer{Timeout: c.conf.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.conf.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &redisConn{Conn: netConn, r: bufio.NewReader(netConn)}
+	if c.conf.Password != "" {
+		if _, err = c.command(ctx, conn, "AUTH", c.conf.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if c.conf.DB != 0 {
+		if _, err = c.command(ctx, conn, "SELECT", strconv.Itoa(c.conf.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (c *Redis) put(conn *redisConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || len(c.idle) >= c.conf.PoolSize {
+		conn.Close()
+		return
+	}
+
+	c.idle = append(c.idle, conn)
+}
+
+func (c *Redis) command(ctx context.Context, conn *redisConn, args ...string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.conf.Timeout)
+	}
+
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	buf := []byte(fmt.Sprintf("*%d\r\n", len(args)))
+	for _, arg := range args {
+		buf = append(buf, fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)...)
+	}
+
+	if _, err := conn.Write(buf); err != nil {
+		return nil, err
+	}
+
+	return readReply(conn.r)
+}
+
+// readReply reads a RESP reply. Bulk string is returned as []byte, and nil bulk string as nil.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redis: invalid reply")
+	}
+
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, redisError(line)
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+
+		data := make([]byte, n+2)
+		if _, err = io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+
+		replies := make([]interface{}, n)
+		for i := range replies {
+			if replies[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+
+		return replies, nil
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+	}
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/cache/redistest"
+)
+
+func TestRedis(t *testing.T) {
+	convey.Convey("Test Redis", t, func() {
+		ctx := context.Background()
+
+		server, err := redistest.NewServer("secret")
+		convey.So(err, convey.ShouldBeNil)
+		defer server.Close()
+
+		convey.Convey("Address must be host:port", func() {
+			_, err := NewRedis(RedisConfig{Address: "localhost"})
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Wrong password is rejected", func() {
+			c, err := NewRedis(RedisConfig{Address: server.Addr, Password: "wrong"})
+			convey.So(err, convey.ShouldBeNil)
+			defer c.Close()
+
+			convey.So(c.Ping(ctx), convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("With the right password", func() {
+			c, err := NewRedis(RedisConfig{Address: server.Addr, Password: "secret", DB: 1})
+			convey.So(err, convey.ShouldBeNil)
+			defer c.Close()
+
+			convey.So(c.Ping(ctx), convey.ShouldBeNil)
+			convey.So(server.Commands(), convey.ShouldResemble, []string{"SELECT", "PING"})
+
+			convey.Convey("Missing key is not found", func() {
+				_, err := c.Get(ctx, "user:1")
+				convey.So(err, convey.ShouldEqual, ErrNotFound)
+			})
+
+			convey.Convey("Value is returned until it's expired", func() {
+				convey.So(c.Set(ctx, "user:1", []byte("john\r\ndoe"), time.Minute), convey.ShouldBeNil)
+
+				value, err := c.Get(ctx, "user:1")
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(string(value), convey.ShouldEqual, "john\r\ndoe")
+
+				server.FastForward(time.Minute)
+				_, err = c.Get(ctx, "user:1")
+				convey.So(err, convey.ShouldEqual, ErrNotFound)
+			})
+
+			convey.Convey("Deleted key is not found", func() {
+				convey.So(c.Set(ctx, "user:1", []byte("john"), time.Minute), convey.ShouldBeNil)
+				convey.So(c.Delete(ctx, "user:1"), convey.ShouldBeNil)
+				convey.So(c.Delete(ctx, "user:1"), convey.ShouldBeNil)
+
+				_, err := c.Get(ctx, "user:1")
+				convey.So(err, convey.ShouldEqual, ErrNotFound)
+				convey.So(server.Keys(), convey.ShouldEqual, 0)
+			})
+
+			convey.Convey("Connection is reused", func() {
+				for i := 0; i < 3; i++ {
+					convey.So(c.Ping(ctx), convey.ShouldBeNil)
+				}
+
+				convey.So(server.Commands(), convey.ShouldResemble, []string{"SELECT", "PING", "PING", "PING", "PING"})
+			})
+		})
+
+		convey.Convey("Closed cache can't be used", func() {
+			c, err := NewRedis(RedisConfig{Address: server.Addr, Password: "secret"})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(c.Close(), convey.ShouldBeNil)
+
+			convey.So(c.Ping(ctx), convey.ShouldNotBeNil)
+		})
+	})
+}
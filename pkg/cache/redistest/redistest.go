@@ -0,0 +1,252 @@
+// Package redistest runs an in-process Redis stand-in, so the Redis cache can be tested without a real server.
+// It's like net/http/httptest, it must only be used in test. Only the commands used by the cache package are supported.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is the Redis stand-in listening on a random local port.
+type Server struct {
+	// Addr is the host:port of the server.
+	Addr string
+
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	values   map[string]value
+	commands []string
+	now      func() time.Time
+}
+
+type value struct {
+	data      string
+	expiresAt time.Time // zero means the key never expires
+}
+
+// NewServer starts the stand-in, the client must send AUTH first when the password is not empty. Call Close when it's done.
+func NewServer(password string) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		listener: listener,
+		password: password,
+		values:   make(map[string]value),
+		now:      time.Now,
+	}
+
+	go s.serve()
+	return s, nil
+}
+
+// Close stops accepting the connection.
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// Commands returns the name of every received command, the oldest first.
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.commands...)
+}
+
+// Keys returns the number of keys which are not expired.
+func (s *Server) Keys() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for key := range s.values {
+		if _, ok := s.lookup(key); ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+// FastForward moves the clock of the server, so the key expires without waiting.
+func (s *Server) FastForward(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.now = func() time.Time { return now.Add(d) }
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	authenticated := s.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		name := strings.ToUpper(args[0])
+		var reply string
+		switch {
+		case name == "AUTH":
+			authenticated = len(args) == 2 && args[1] == s.password
+			reply = "+OK\r\n"
+			if !authenticated {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		default:
+			reply = s.execute(name, args[1:])
+		}
+
+		if _, err = io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) execute(name string, args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands = append(s.commands, name)
+	switch {
+	case name == "PING":
+		return "+PONG\r\n"
+	case name == "SELECT" && len(args) == 1:
+		return "+OK\r\n"
+	case name == "GET" && len(args) == 1:
+		v, ok := s.lookup(args[0])
+		if !ok {
+			return "$-1\r\n"
+		}
+
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v.data), v.data)
+	case name == "SET" && (len(args) == 2 || len(args) == 4):
+		v := value{data: args[1]}
+		if len(args) == 4 {
+			n, err := strconv.ParseInt(args[3], 10, 64)
+			if err != nil || n <= 0 {
+				return "-ERR invalid expire time in 'set' command\r\n"
+			}
+
+			switch strings.ToUpper(args[2]) {
+			case "EX":
+				v.expiresAt = s.now().Add(time.Duration(n) * time.Second)
+			case "PX":
+				v.expiresAt = s.now().Add(time.Duration(n) * time.Millisecond)
+			default:
+				return "-ERR syntax error\r\n"
+			}
+		}
+
+		s.values[args[0]] = v
+		return "+OK\r\n"
+	case name == "DEL" && len(args) > 0:
+		deleted := 0
+		for _, key := range args {
+			if _, ok := s.lookup(key); ok {
+				deleted++
+			}
+
+			delete(s.values, key)
+		}
+
+		return fmt.Sprintf(":%d\r\n", deleted)
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", name)
+	}
+}
+
+// lookup returns the value which is not expired, the caller must hold the lock.
+func (s *Server) lookup(key string) (value, bool) {
+	v, ok := s.values[key]
+	if !ok {
+		return v, false
+	}
+
+	if !v.expiresAt.IsZero() && !s.now().Before(v.expiresAt) {
+		delete(s.values, key)
+		return v, false
+	}
+
+	return v, true
+}
+
+// readCommand reads the command sent as RESP array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 2 || line[0] != '*' {
+		return nil, fmt.Errorf("redistest: expected array, got %q", line)
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("redistest: invalid array length %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err = readLine(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(line) < 2 || line[0] != '$' {
+			return nil, fmt.Errorf("redistest: expected bulk string, got %q", line)
+		}
+
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("redistest: invalid bulk string length %q", line)
+		}
+
+		data := make([]byte, size+2)
+		if _, err = io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+
+		args[i] = string(data[:size])
+	}
+
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
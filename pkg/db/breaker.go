@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without touching the database when the circuit breaker of the node is open.
+var ErrCircuitOpen = errors.New("db: circuit breaker is open, database is unavailable")
+
+// BreakerState is the state of the circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every query through, this is the normal state.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen rejects every query with ErrCircuitOpen until BreakerConfig.OpenTimeout is passed.
+	BreakerOpen
+
+	// BreakerHalfOpen lets one trial query through. It is closed when the trial succeed, or opened again when failed.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerConfig configures the circuit breaker of each database node.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive connection failure to open the breaker.
+	// Zero disables the circuit breaker.
+	FailureThreshold int
+
+	// OpenTimeout is how long the breaker stays open before it lets a trial query through.
+	OpenTimeout time.Duration
+}
+
+// breaker is a circuit breaker of one database node. Nil breaker is always closed.
+type breaker struct {
+	sync.Mutex
+
+	conf     BreakerConfig
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trial    bool // whether the trial query in half-open state is running
+	now      func() time.Time
+}
+
+// newBreaker returns nil when the breaker is disabled.
+func newBreaker(conf BreakerConfig) *breaker {
+	if conf.FailureThreshold <= 0 {
+		return nil
+	}
+
+	return &breaker{
+		conf: conf,
+		now:  time.Now,
+	}
+}
+
+// State returns the current state, open breaker becomes half-open after the timeout is passed.
+func (b *breaker) State() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.expire()
+	return b.state
+}
+
+// expire moves open state into half-open when the timeout is passed. It must be called with lock held.
+func (b *breaker) expire() {
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.conf.OpenTimeout {
+		b.state = BreakerHalfOpen
+		b.trial = false
+	}
+}
+
+// allow returns ErrCircuitOpen when the query must not be sent to the database.
+// Every allowed query must be followed by record.
+func (b *breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.expire()
+	switch b.state {
+	case BreakerOpen:
+		return ErrCircuitOpen
+	case BreakerHalfOpen:
+		if b.trial {
+			return ErrCircuitOpen
+		}
+
+		b.trial = true
+	}
+
+	return nil
+}
+
+// record updates the state using the query result.
+func (b *breaker) record(err error) {
+	if b == nil {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	if !isConnectionError(err) {
+		b.state = BreakerClosed
+		b.failures = 0
+		b.trial = false
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.conf.FailureThreshold {
+		if b.state != BreakerOpen {
+			logger.Error().Err(err).Msgf("circuit breaker is open after %d failures", b.failures)
+		}
+
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+		b.trial = false
+	}
+}
+
+// isConnectionError returns true when the error means the database cannot be reached.
+// Other error, such as constraint violation or scanning error, means the database is still up.
+// The cancelled or timed out context is not counted either, it's the caller which gives up, not the database.
+func isConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF, driver.ErrBadConn, sql.ErrConnDone:
+		return true
+	}
+
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+
+	// go-pg and database/sql don't export these errors
+	switch err.Error() {
+	case "pg: connection pool timeout", "pg: database is closed", "sql: database is closed":
+		return true
+	}
+
+	return false
+}
+
+// unavailableExecutor fails every query, it is used when there is no database node which can be used.
+type unavailableExecutor struct {
+	err error
+}
+
+func (e *unavailableExecutor) Query(ctx context.Context, out interface{}, query string, args ...interface{}) error {
+	return e.err
+}
+
+func (e *unavailableExecutor) Exec(ctx context.Context, query string, args ...interface{}) error {
+	return e.err
+}
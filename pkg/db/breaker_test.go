@@ -0,0 +1,173 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestBreaker(t *testing.T) {
+	convey.Convey("Test circuit breaker state", t, func() {
+		now := time.Now()
+		b := newBreaker(BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute})
+		b.now = func() time.Time { return now }
+
+		convey.Convey("Disabled breaker is always closed", func() {
+			var disabled = newBreaker(BreakerConfig{})
+			convey.So(disabled, convey.ShouldBeNil)
+			convey.So(disabled.allow(), convey.ShouldBeNil)
+			disabled.record(io.EOF)
+			convey.So(disabled.State(), convey.ShouldEqual, BreakerClosed)
+		})
+
+		convey.Convey("Server error doesn't open the breaker", func() {
+			for i := 0; i < 3; i++ {
+				convey.So(b.allow(), convey.ShouldBeNil)
+				b.record(errors.New("duplicate key value violates unique constraint"))
+			}
+
+			convey.So(b.State(), convey.ShouldEqual, BreakerClosed)
+		})
+
+		convey.Convey("Cancelled or timed out query doesn't open the breaker", func() {
+			for _, err := range []error{context.DeadlineExceeded, context.Canceled, fmt.Errorf("query: %w", context.DeadlineExceeded)} {
+				convey.So(b.allow(), convey.ShouldBeNil)
+				b.record(err)
+			}
+
+			convey.So(b.State(), convey.ShouldEqual, BreakerClosed)
+		})
+
+		convey.Convey("Consecutive connection failures open the breaker", func() {
+			convey.So(b.allow(), convey.ShouldBeNil)
+			b.record(io.EOF)
+			convey.So(b.State(), convey.ShouldEqual, BreakerClosed)
+
+			convey.So(b.allow(), convey.ShouldBeNil)
+			b.record(io.EOF)
+			convey.So(b.State(), convey.ShouldEqual, BreakerOpen)
+			convey.So(b.allow(), convey.ShouldEqual, ErrCircuitOpen)
+
+			convey.Convey("Only one trial is allowed after timeout", func() {
+				now = now.Add(time.Minute)
+				convey.So(b.State(), convey.ShouldEqual, BreakerHalfOpen)
+				convey.So(b.allow(), convey.ShouldBeNil)
+				convey.So(b.allow(), convey.ShouldEqual, ErrCircuitOpen)
+
+				convey.Convey("Succeed trial closes the breaker", func() {
+					b.record(nil)
+					convey.So(b.State(), convey.ShouldEqual, BreakerClosed)
+					convey.So(b.allow(), convey.ShouldBeNil)
+				})
+
+				convey.Convey("Failed trial opens the breaker again", func() {
+					b.record(io.EOF)
+					convey.So(b.State(), convey.ShouldEqual, BreakerOpen)
+					convey.So(b.allow(), convey.ShouldEqual, ErrCircuitOpen)
+				})
+			})
+		})
+
+		convey.Convey("Success resets the failure count", func() {
+			b.record(io.EOF)
+			b.record(nil)
+			b.record(io.EOF)
+			convey.So(b.State(), convey.ShouldEqual, BreakerClosed)
+		})
+	})
+}
+
+func TestIsConnectionError(t *testing.T) {
+	convey.Convey("Test connection error", t, func() {
+		convey.Convey("Driver and network errors are connection errors", func() {
+			convey.So(isConnectionError(io.EOF), convey.ShouldBeTrue)
+			convey.So(isConnectionError(driver.ErrBadConn), convey.ShouldBeTrue)
+			convey.So(isConnectionError(sql.ErrConnDone), convey.ShouldBeTrue)
+			convey.So(isConnectionError(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}), convey.ShouldBeTrue)
+			convey.So(isConnectionError(errors.New("pg: connection pool timeout")), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Context and server errors are not connection errors", func() {
+			convey.So(isConnectionError(nil), convey.ShouldBeFalse)
+			convey.So(isConnectionError(context.DeadlineExceeded), convey.ShouldBeFalse)
+			convey.So(isConnectionError(context.Canceled), convey.ShouldBeFalse)
+			convey.So(isConnectionError(fmt.Errorf("query: %w", context.Canceled)), convey.ShouldBeFalse)
+			convey.So(isConnectionError(errors.New("duplicate key value violates unique constraint")), convey.ShouldBeFalse)
+		})
+	})
+}
+
+func TestDatabaseSQL_Breaker(t *testing.T) {
+	convey.Convey("Test circuit breaker in database/sql connection", t, func() {
+		ctx := context.Background()
+		conf := BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute}
+
+		// nothing listen on port 1, so the query fails with connection refused
+		down, err := sql.Open("postgres", "host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1")
+		convey.So(err, convey.ShouldBeNil)
+		defer down.Close()
+
+		up, err := NewConnection(&Config{Driver: DriverSQLite, Master: &Conf{URL: "file::memory:"}})
+		convey.So(err, convey.ShouldBeNil)
+		defer up.Close()
+		upDB := up.(StdSQL).MasterDB()
+
+		convey.Convey("Master fails fast after the breaker is open", func() {
+			c := &databaseSQL{master: down, masterBreaker: newBreaker(conf)}
+
+			err := c.Writer().Exec(ctx, `SELECT 1`)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err, convey.ShouldNotEqual, ErrCircuitOpen)
+
+			err = c.Writer().Exec(ctx, `SELECT 1`)
+			convey.So(err, convey.ShouldEqual, ErrCircuitOpen)
+
+			_, err = c.NewTransaction(ctx)
+			convey.So(err, convey.ShouldEqual, ErrCircuitOpen)
+		})
+
+		convey.Convey("Reader falls back to master when the slave is down and master breaker is closed", func() {
+			c := &databaseSQL{
+				dialect:       DialectSQLite,
+				master:        upDB,
+				masterBreaker: newBreaker(conf),
+				slaves:        []*sql.DB{down},
+				slaveBreakers: []*breaker{newBreaker(conf)},
+				slaveChecks:   newReplicaChecks(1),
+			}
+
+			var one int
+			convey.So(c.Reader().Query(ctx, &one, `SELECT 1`), convey.ShouldBeNil)
+			convey.So(one, convey.ShouldEqual, 1)
+			convey.So(c.slaveBreakers[0].State(), convey.ShouldEqual, BreakerOpen)
+			convey.So(c.slaves[0], convey.ShouldNotBeNil) // the slave is kept, so it can be used again after recovered
+
+			convey.Convey("But not when master breaker is not closed", func() {
+				c.masterBreaker.record(io.EOF)
+				convey.So(c.Reader().Query(ctx, &one, `SELECT 1`), convey.ShouldEqual, ErrCircuitOpen)
+			})
+		})
+
+		convey.Convey("Unresponsive slave is taken out when breaker is disabled", func() {
+			c := &databaseSQL{
+				dialect:       DialectSQLite,
+				master:        upDB,
+				slaves:        []*sql.DB{down},
+				slaveBreakers: []*breaker{nil},
+				slaveChecks:   newReplicaChecks(1),
+			}
+
+			var one int
+			convey.So(c.Reader().Query(ctx, &one, `SELECT 1`), convey.ShouldBeNil)
+			convey.So(c.slaves[0], convey.ShouldBeNil)
+		})
+	})
+}
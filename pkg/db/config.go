@@ -2,6 +2,18 @@ package db
 
 import "time"
 
+// Driver names which can be used in Config.Driver.
+const (
+	// DriverGoPg uses github.com/go-pg/pg, this is the default driver.
+	DriverGoPg = "go-pg"
+
+	// DriverPostgres uses database/sql with github.com/lib/pq.
+	DriverPostgres = "postgres"
+
+	// DriverSQLite uses database/sql with github.com/mattn/go-sqlite3, the URL is the database file path.
+	DriverSQLite = "sqlite"
+)
+
 // Conf represents a database connection configuration
 type Conf struct {
 	Debug        bool
@@ -13,6 +25,14 @@ type Conf struct {
 
 // Config represents a configuration for this package
 type Config struct {
+	Driver string // one of Driver* constant, empty means DriverGoPg
 	Master *Conf
 	Slaves []*Conf
+
+	// SlowQueryThreshold logs query which takes longer than this duration, with its arguments redacted.
+	// Zero disables the slow query log.
+	SlowQueryThreshold time.Duration
+
+	// Breaker configures the circuit breaker of master and each slave, zero value disables it.
+	Breaker BreakerConfig
 }
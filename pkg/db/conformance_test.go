@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+// runConformanceTests runs the same behaviour tests against every SQL implementation,
+// so that switching the driver doesn't change how the repository layer works.
+// newConnection must return a connection to the same database on every call.
+func runConformanceTests(t *testing.T, newConnection func() (SQL, error)) {
+	c, err := newConnection()
+	if err != nil {
+		t.Fatalf("error creating connection %s", err.Error())
+	}
+
+	err = c.Writer().Exec(context.Background(), createTable.For(DialectOf(c)))
+	c.Close()
+	if err != nil {
+		t.Fatalf("error init table %s", err.Error())
+	}
+
+	convey.Convey("Test Writer/Master connection only", t, func() {
+		c, err := newConnection()
+		convey.So(err, convey.ShouldBeNil)
+		defer c.Close()
+
+		convey.Convey("Insert using writer connection must be success", func() {
+			err = c.Writer().Exec(context.Background(), insertQuery, myUser.Username, myUser.Password)
+			convey.So(err, convey.ShouldBeNil)
+		})
+
+		convey.Convey("Get using writer connection", func() {
+			// get user after insert
+			u := &user{}
+			err = c.Writer().Query(context.Background(), u, getQuery, myUser.Username)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(u.Username, convey.ShouldEqual, myUser.Username)
+			convey.So(u.Password, convey.ShouldEqual, myUser.Password)
+		})
+
+		convey.Convey("Insert the same username should be error", func() {
+			err = c.Writer().Exec(context.Background(), insertQuery, myUser.Username, myUser.Password)
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Upsert the same username return the existing row", func() {
+			u := &user{}
+			err = c.Writer().Query(context.Background(), u, upsertQuery, myUser.Username, "other")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(u.Password, convey.ShouldEqual, myUser.Password)
+		})
+	})
+
+	convey.Convey("Test Reader/Slave connection only", t, func() {
+		c, err := newConnection()
+		convey.So(err, convey.ShouldBeNil)
+		defer c.Close()
+
+		convey.Convey("Get using reader connection", func() {
+			u := &user{}
+			err = c.Reader().Query(context.Background(), u, getQuery, myUser.Username)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(u.Username, convey.ShouldEqual, myUser.Username)
+		})
+
+		convey.Convey("Get not existing row into struct returns ErrNoRows", func() {
+			u := &user{}
+			err = c.Reader().Query(context.Background(), u, getQuery, "not_exist")
+			convey.So(err, convey.ShouldEqual, ErrNoRows)
+		})
+
+		convey.Convey("Get not existing row into slice is not an error", func() {
+			var users []user
+			err = c.Reader().Query(context.Background(), &users, `SELECT * FROM users WHERE username = ?;`, "not_exist")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(users), convey.ShouldEqual, 0)
+		})
+
+	})
+
+	convey.Convey("Test Write to master and read to slave", t, func() {
+		c, err := newConnection()
+		convey.So(err, convey.ShouldBeNil)
+		defer c.Close()
+
+		convey.Convey("Insert using writer connection", func() {
+			err = c.Writer().Exec(context.Background(), insertQuery, myUser4.Username, myUser4.Password)
+			convey.So(err, convey.ShouldBeNil)
+		})
+
+		convey.Convey("Fetch using reader connection", func() {
+			u := &user{}
+			err = c.Reader().Query(context.Background(), u, getQuery, myUser4.Username)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(u.Username, convey.ShouldEqual, myUser4.Username)
+			convey.So(u.Password, convey.ShouldEqual, myUser4.Password)
+		})
+	})
+
+	convey.Convey("Test transaction", t, func() {
+		c, err := newConnection()
+		convey.So(err, convey.ShouldBeNil)
+		defer c.Close()
+
+		convey.Convey("Commit makes the row visible", func() {
+			tx, err := c.NewTransaction(context.Background())
+			convey.So(err, convey.ShouldBeNil)
+
+			err = tx.Exec(context.Background(), insertQuery, myUser2.Username, myUser2.Password)
+			convey.So(err, convey.ShouldBeNil)
+
+			u := &user{}
+			err = tx.Query(context.Background(), u, getQuery, myUser2.Username)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(u.Username, convey.ShouldEqual, myUser2.Username)
+
+			err = tx.Commit(context.Background())
+			convey.So(err, convey.ShouldBeNil)
+
+			err = c.Writer().Query(context.Background(), u, getQuery, myUser2.Username)
+			convey.So(err, convey.ShouldBeNil)
+
+			err = tx.Rollback(context.Background())
+			convey.So(err, convey.ShouldNotBeNil) // because we already commit, so cannot be rollback
+		})
+
+		convey.Convey("Rollback discards the row", func() {
+			tx, err := c.NewTransaction(context.Background())
+			convey.So(err, convey.ShouldBeNil)
+
+			err = tx.Exec(context.Background(), insertQuery, myUser3.Username, myUser3.Password)
+			convey.So(err, convey.ShouldBeNil)
+
+			err = tx.Rollback(context.Background())
+			convey.So(err, convey.ShouldBeNil)
+
+			err = c.Writer().Query(context.Background(), &user{}, getQuery, myUser3.Username)
+			convey.So(err, convey.ShouldEqual, ErrNoRows)
+		})
+	})
+}
+
+// runConcurrentTransactionTests checks that more than one transaction can be active at the same time.
+// It's not a part of runConformanceTests, since SQLite uses only one connection, so the second transaction waits forever.
+func runConcurrentTransactionTests(t *testing.T, newConnection func() (SQL, error)) {
+	convey.Convey("Test concurrent transaction", t, func() {
+		c, err := newConnection()
+		convey.So(err, convey.ShouldBeNil)
+		defer c.Close()
+
+		convey.Convey("Begin transaction if there are active transaction", func() {
+			tx1, err := c.NewTransaction(context.Background())
+			convey.So(err, convey.ShouldBeNil)
+
+			// since each NewTransaction uses its own session, so we can expect it can be called more than one
+			tx2, err := c.NewTransaction(context.Background())
+			convey.So(err, convey.ShouldBeNil)
+
+			u := &user{}
+			err = tx1.Query(context.Background(), u, getQuery, myUser.Username)
+			convey.So(err, convey.ShouldBeNil)
+
+			err = tx2.Query(context.Background(), u, getQuery, myUser.Username)
+			convey.So(err, convey.ShouldBeNil)
+
+			err = tx1.Commit(context.Background())
+			convey.So(err, convey.ShouldBeNil)
+
+			err = tx2.Commit(context.Background())
+			convey.So(err, convey.ShouldBeNil)
+		})
+	})
+}
@@ -7,13 +7,29 @@ import (
 	"time"
 
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 	"github.com/rs/zerolog/log"
 )
 
 var logger = log.With().Caller().Str("pkg", "db").Logger()
 
 // NewConnection will create new connection for selected package.
+// The package is selected using Config.Driver, default to go-pg.
 func NewConnection(config *Config) (sql SQL, err error) {
+	switch config.Driver {
+	case "", DriverGoPg:
+		return newGoPgSQL(config)
+	case DriverPostgres:
+		return newDatabaseSQL(DriverPostgres, config)
+	case DriverSQLite:
+		return newSQLite(config)
+	default:
+		return &goPgSQL{}, fmt.Errorf("unknown database driver %q", config.Driver)
+	}
+}
+
+// newGoPgSQL will create master and slaves connection using go-pg.
+func newGoPgSQL(config *Config) (sql SQL, err error) {
 	masterConnection, err := createConnection(config.Master)
 	if err != nil {
 		return &goPgSQL{}, err
@@ -33,7 +49,7 @@ func NewConnection(config *Config) (sql SQL, err error) {
 		slaveConnections[i] = slaveConn
 	}
 
-	return newGoPgConnection(masterConnection, slaveConnections)
+	return newGoPgConnection(config, masterConnection, slaveConnections)
 }
 
 // createConnection create connection using go-pg
@@ -77,18 +93,90 @@ func createConnection(conf *Conf) (*pg.DB, error) {
 }
 
 // newGoPgConnection will create database connection using go-pg
-func newGoPgConnection(master *pg.DB, slaves []*pg.DB) (sql SQL, err error) {
+func newGoPgConnection(config *Config, master *pg.DB, slaves []*pg.DB) (sql SQL, err error) {
+	slaveBreakers := make([]*breaker, len(slaves))
+	for i := range slaves {
+		slaveBreakers[i] = newBreaker(config.Breaker)
+	}
+
 	return &goPgSQL{
-		master: master,
-		slaves: slaves,
+		master:        master,
+		slaves:        slaves,
+		slowQuery:     config.SlowQueryThreshold,
+		masterBreaker: newBreaker(config.Breaker),
+		slaveBreakers: slaveBreakers,
+		slaveChecks:   newReplicaChecks(len(slaves)),
 	}, nil
 }
 
 type goPgSQL struct {
 	sync.RWMutex
 
-	master *pg.DB
-	slaves []*pg.DB
+	master    *pg.DB
+	slaves    []*pg.DB
+	slowQuery time.Duration
+
+	masterBreaker *breaker
+	slaveBreakers []*breaker      // has the same index as slaves
+	slaveChecks   []*replicaCheck // has the same index as slaves
+}
+
+// PoolStats returns the connection pool stats of master and all active slaves.
+func (g *goPgSQL) PoolStats() []PoolStats {
+	g.RLock()
+	defer g.RUnlock()
+
+	var stats []PoolStats
+	if g.master != nil {
+		stats = append(stats, goPgPoolStats(RoleMaster, 0, g.master))
+	}
+
+	for i, slave := range g.slaves {
+		if slave == nil {
+			continue
+		}
+
+		stats = append(stats, goPgPoolStats(RoleReplica, i, slave))
+	}
+
+	return stats
+}
+
+// Ping checks the master and every replica, including the one taken out by Reader.
+func (g *goPgSQL) Ping(ctx context.Context) []NodeHealth {
+	g.RLock()
+	master, slaves := g.master, append([]*pg.DB{}, g.slaves...)
+	g.RUnlock()
+
+	health := []NodeHealth{pingNode(RoleMaster, 0, func() error {
+		return goPgPing(ctx, master, g.masterBreaker)
+	})}
+
+	for i, slave := range slaves {
+		if slave == nil {
+			health = append(health, NodeHealth{Role: RoleReplica, Node: i, Err: ErrReplicaTakenOut})
+			continue
+		}
+
+		health = append(health, pingNode(RoleReplica, i, func() error {
+			return goPgPing(ctx, slave, g.slaveBreakers[i])
+		}))
+	}
+
+	return health
+}
+
+func goPgPoolStats(role string, node int, db *pg.DB) PoolStats {
+	stats := db.PoolStats()
+	return PoolStats{
+		Role:     role,
+		Node:     node,
+		Hits:     stats.Hits,
+		Misses:   stats.Misses,
+		Timeouts: stats.Timeouts,
+		Idle:     stats.IdleConns,
+		Total:    stats.TotalConns,
+	}
 }
 
 func (g *goPgSQL) Close() error {
@@ -120,105 +208,222 @@ func (g *goPgSQL) Close() error {
 // Writer always use the master.
 func (g *goPgSQL) Writer() SQLExecutor {
 	return &goPgSQLWriter{
-		master: g.master,
+		master:   g.master,
+		observer: queryObserver{role: RoleMaster, slowQuery: g.slowQuery},
+		breaker:  g.masterBreaker,
 	}
 }
 
 // Reader will select the read replica based on highest idle connection.
 // This guarantee that return value will always use the same host.
+// Slave which circuit breaker is open is skipped. When circuit breaker is disabled, unresponsive slave is taken out.
+// The health of each slave is checked at most once per replicaCheckInterval, not on every call.
 // If slave is empty, it will use master connection as the default db connection.
-// As a result, you must ensure that master never shutdown.
+// But when all slaves are unavailable, it only fall back to master if the master circuit breaker is closed,
+// so the read traffic doesn't slow down the recovering master.
 func (g *goPgSQL) Reader() SQLExecutor {
-	g.RLock() // lock the reader when election occurred
+	g.RLock()
 
-	var newSlaves = make([]*pg.DB, len(g.slaves))
+	var conn *pg.DB
+	var connBreaker *breaker
+	var hasSlave bool
+	var takenOut []int
 
-	conn := g.master
 	currentIdleConnection := uint32(0)
 	for i, slave := range g.slaves {
 		if slave == nil {
 			continue
 		}
 
-		// also check and take out the unresponsive slave
-		_, err := slave.Exec("SELECT 1")
-		if err != nil {
-			logger.Error().Err(err).Msgf("error occurred on db: %s", slave.Options().Addr)
+		hasSlave = true
+		if g.slaveBreakers[i].State() == BreakerOpen {
 			continue
 		}
 
-		newSlaves[i] = slave
+		// also check the unresponsive slave, the result is reused for replicaCheckInterval
+		err := g.slaveChecks[i].check(func() error {
+			err := goPgPing(context.Background(), slave, g.slaveBreakers[i])
+			if err != nil {
+				logger.Error().Err(err).Msgf("error occurred on db: %s", slave.Options().Addr)
+			}
+
+			return err
+		})
+
+		if err != nil {
+			if g.slaveBreakers[i] == nil {
+				takenOut = append(takenOut, i)
+			}
+
+			continue
+		}
 
 		idleConn := slave.PoolStats().Hits
-		if idleConn >= currentIdleConnection {
+		if conn == nil || idleConn >= currentIdleConnection {
 			conn = slave
+			connBreaker = g.slaveBreakers[i]
+			currentIdleConnection = idleConn
+		}
+	}
+
+	g.RUnlock()
+
+	if len(takenOut) > 0 {
+		g.Lock() // only lock when election occurred, since unresponsive slave is taken out
+		for _, i := range takenOut {
+			g.slaves[i] = nil
+		}
+		g.Unlock()
+	}
+
+	if conn != nil {
+		return &goPgSQLReader{
+			slave:    conn,
+			observer: queryObserver{role: RoleReplica, slowQuery: g.slowQuery},
+			breaker:  connBreaker,
 		}
 	}
 
-	// assign new active slave connection list
-	g.slaves = newSlaves
-	g.RUnlock() // and unlock the RW
+	if hasSlave && g.masterBreaker.State() != BreakerClosed {
+		return &unavailableExecutor{err: ErrCircuitOpen}
+	}
 
 	return &goPgSQLReader{
-		slave: conn,
+		slave:    g.master,
+		observer: queryObserver{role: RoleMaster, slowQuery: g.slowQuery},
+		breaker:  g.masterBreaker,
 	}
 }
 
 // NewTransaction will always use the master node.
 func (g *goPgSQL) NewTransaction(ctx context.Context) (Transaction, error) {
+	if err := g.masterBreaker.allow(); err != nil {
+		return nil, err
+	}
+
 	tx, err := g.master.Begin()
+	g.masterBreaker.record(err)
 	if err != nil {
 		return nil, err
 	}
 
 	return &transaction{
-		conn: tx,
+		conn:     tx,
+		observer: queryObserver{role: RoleMaster, slowQuery: g.slowQuery},
+		breaker:  g.masterBreaker,
 	}, nil
 }
 
+// goPgPing checks whether the node is reachable through its circuit breaker.
+func goPgPing(ctx context.Context, db *pg.DB, b *breaker) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	_, err := db.WithContext(ctx).Exec("SELECT 1")
+	b.record(err)
+	return err
+}
+
+// goPgError translates go-pg specific error into this package error, so the caller doesn't depend on go-pg.
+func goPgError(err error) error {
+	switch err {
+	case pg.ErrNoRows:
+		return ErrNoRows
+	case pg.ErrMultiRows:
+		return ErrMultiRows
+	default:
+		return err
+	}
+}
+
+// goPgRun runs the query through the circuit breaker and records the query metrics.
+func goPgRun(ctx context.Context, b *breaker, o queryObserver, query string, args []interface{}, run func() (orm.Result, error)) error {
+	start := time.Now()
+	if err := b.allow(); err != nil {
+		o.observe(ctx, start, query, args, -1, err)
+		return err
+	}
+
+	res, err := run()
+	err = goPgError(err)
+	b.record(err)
+	o.observe(ctx, start, query, args, goPgRows(res, err), err)
+	return err
+}
+
+// goPgRows returns the number of rows returned by the query, or affected by the statement when it returns nothing.
+// The result is not used when err is not nil, since go-pg may return a typed nil result.
+func goPgRows(res orm.Result, err error) int {
+	switch {
+	case err == ErrNoRows:
+		return 0
+	case err != nil || res == nil:
+		return -1
+	}
+
+	if res.RowsReturned() > 0 {
+		return res.RowsReturned()
+	}
+
+	return res.RowsAffected()
+}
+
 // ====================== WRITER
 type goPgSQLWriter struct {
-	master *pg.DB
+	master   *pg.DB
+	observer queryObserver
+	breaker  *breaker
 }
 
 func (w *goPgSQLWriter) Query(ctx context.Context, out interface{}, query string, args ...interface{}) error {
-	_, err := w.master.Query(out, query, args...)
-	return err
+	return goPgRun(ctx, w.breaker, w.observer, query, args, func() (orm.Result, error) {
+		return w.master.Query(out, query, args...)
+	})
 }
 
 func (w *goPgSQLWriter) Exec(ctx context.Context, query string, args ...interface{}) error {
-	_, err := w.master.Exec(query, args...)
-	return err
+	return goPgRun(ctx, w.breaker, w.observer, query, args, func() (orm.Result, error) {
+		return w.master.Exec(query, args...)
+	})
 }
 
 // ====================== READER
 type goPgSQLReader struct {
-	slave *pg.DB
+	slave    *pg.DB
+	observer queryObserver
+	breaker  *breaker
 }
 
 func (r *goPgSQLReader) Query(ctx context.Context, out interface{}, query string, args ...interface{}) error {
-	_, err := r.slave.Query(out, query, args...)
-	return err
+	return goPgRun(ctx, r.breaker, r.observer, query, args, func() (orm.Result, error) {
+		return r.slave.Query(out, query, args...)
+	})
 }
 
 func (r *goPgSQLReader) Exec(ctx context.Context, query string, args ...interface{}) error {
-	_, err := r.slave.Exec(query, args...)
-	return err
+	return goPgRun(ctx, r.breaker, r.observer, query, args, func() (orm.Result, error) {
+		return r.slave.Exec(query, args...)
+	})
 }
 
 // ====================== TRANSACTION
 type transaction struct {
-	conn *pg.Tx
+	conn     *pg.Tx
+	observer queryObserver
+	breaker  *breaker
 }
 
 func (t *transaction) Query(ctx context.Context, out interface{}, query string, args ...interface{}) error {
-	_, err := t.conn.Query(out, query, args...)
-	return err
+	return goPgRun(ctx, t.breaker, t.observer, query, args, func() (orm.Result, error) {
+		return t.conn.Query(out, query, args...)
+	})
 }
 
 func (t *transaction) Exec(ctx context.Context, query string, args ...interface{}) error {
-	_, err := t.conn.Exec(query, args...)
-	return err
+	return goPgRun(ctx, t.breaker, t.observer, query, args, func() (orm.Result, error) {
+		return t.conn.Exec(query, args...)
+	})
 }
 
 func (t *transaction) Commit(ctx context.Context) error {
@@ -0,0 +1,484 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	// is a driver needed by sql.Open to connect to postgresql.
+	_ "github.com/lib/pq"
+)
+
+// StdSQL is implemented by SQL which is built on top of database/sql.
+// This is useful when we need to pass the connection into library that only supports *sql.DB, such as the migrator.
+type StdSQL interface {
+	SQL
+	MasterDB() *sql.DB
+}
+
+// newDatabaseSQL will create database connection using database/sql with the registered driverName.
+func newDatabaseSQL(driverName string, config *Config) (SQL, error) {
+	masterConnection, err := createStdConnection(driverName, config.Master)
+	if err != nil {
+		return &databaseSQL{}, err
+	}
+
+	var slaveConnections = make([]*sql.DB, len(config.Slaves))
+	var slaveBreakers = make([]*breaker, len(config.Slaves))
+	for i, conf := range config.Slaves {
+		slaveBreakers[i] = newBreaker(config.Breaker)
+
+		slaveConn, err := createStdConnection(driverName, conf)
+		if err != nil {
+			if conf.Debug {
+				logger.Error().Err(err).Msgf("error when creating slave connection on: %s", conf.URL)
+			}
+
+			continue
+		}
+
+		slaveConnections[i] = slaveConn
+	}
+
+	return &databaseSQL{
+		dialect:       DialectPostgres,
+		debug:         config.Master.Debug,
+		slowQuery:     config.SlowQueryThreshold,
+		master:        masterConnection,
+		slaves:        slaveConnections,
+		masterBreaker: newBreaker(config.Breaker),
+		slaveBreakers: slaveBreakers,
+		slaveChecks:   newReplicaChecks(len(slaveConnections)),
+	}, nil
+}
+
+// createStdConnection create connection using database/sql
+func createStdConnection(driverName string, conf *Conf) (*sql.DB, error) {
+	db, err := sql.Open(driverName, conf.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	// use the same unit as go-pg connection
+	db.SetMaxOpenConns(conf.PoolSize)
+	db.SetConnMaxIdleTime(time.Duration(conf.IdleTimeout) * time.Second)
+	db.SetConnMaxLifetime(time.Duration(conf.ConnLifetime) * time.Second)
+
+	// go-pg keeps every connection of the pool when it is idle, zero keeps the database/sql default
+	if conf.PoolSize > 0 {
+		db.SetMaxIdleConns(conf.PoolSize)
+	}
+
+	_, err = db.Exec("SELECT 1")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if conf.Debug {
+		logger.Debug().Msgf("connected to database using %s", driverName)
+	}
+
+	return db, nil
+}
+
+type databaseSQL struct {
+	sync.RWMutex
+
+	dialect   Dialect
+	debug     bool
+	slowQuery time.Duration
+	master    *sql.DB
+	slaves    []*sql.DB
+
+	masterBreaker *breaker
+	slaveBreakers []*breaker      // has the same index as slaves
+	slaveChecks   []*replicaCheck // has the same index as slaves
+}
+
+// PoolStats returns the connection pool stats of master and all active slaves.
+func (d *databaseSQL) PoolStats() []PoolStats {
+	d.RLock()
+	defer d.RUnlock()
+
+	var stats []PoolStats
+	if d.master != nil {
+		stats = append(stats, stdPoolStats(RoleMaster, 0, d.master))
+	}
+
+	for i, slave := range d.slaves {
+		if slave == nil {
+			continue
+		}
+
+		stats = append(stats, stdPoolStats(RoleReplica, i, slave))
+	}
+
+	return stats
+}
+
+// Ping checks the master and every replica, including the one taken out by Reader.
+func (d *databaseSQL) Ping(ctx context.Context) []NodeHealth {
+	d.RLock()
+	master, slaves := d.master, append([]*sql.DB{}, d.slaves...)
+	d.RUnlock()
+
+	health := []NodeHealth{pingNode(RoleMaster, 0, func() error {
+		return stdPing(ctx, master, d.masterBreaker)
+	})}
+
+	for i, slave := range slaves {
+		if slave == nil {
+			health = append(health, NodeHealth{Role: RoleReplica, Node: i, Err: ErrReplicaTakenOut})
+			continue
+		}
+
+		health = append(health, pingNode(RoleReplica, i, func() error {
+			return stdPing(ctx, slave, d.slaveBreakers[i])
+		}))
+	}
+
+	return health
+}
+
+func stdPoolStats(role string, node int, db *sql.DB) PoolStats {
+	stats := db.Stats()
+	return PoolStats{
+		Role:  role,
+		Node:  node,
+		Idle:  uint32(stats.Idle),
+		Total: uint32(stats.OpenConnections),
+	}
+}
+
+// Dialect returns the SQL dialect of the underlying database.
+func (d *databaseSQL) Dialect() Dialect {
+	return d.dialect
+}
+
+// MasterDB returns the master connection.
+func (d *databaseSQL) MasterDB() *sql.DB {
+	return d.master
+}
+
+func (d *databaseSQL) Close() error {
+	var err, lastError error
+
+	if d.master == nil {
+		return fmt.Errorf("master connection is not exist, hence cannot be closed")
+	}
+
+	lastError = d.master.Close()
+	if lastError != nil {
+		err = lastError
+	}
+
+	for _, slave := range d.slaves {
+		if slave == nil {
+			continue
+		}
+
+		lastError = slave.Close()
+		if lastError != nil {
+			err = lastError
+		}
+	}
+
+	return err
+}
+
+// Writer always use the master.
+func (d *databaseSQL) Writer() SQLExecutor {
+	return d.executor(d.master, RoleMaster, d.masterBreaker)
+}
+
+// Reader will select the read replica based on highest idle connection, the same way as go-pg implementation.
+// Slave which circuit breaker is open is skipped. When circuit breaker is disabled, unresponsive slave is taken out.
+// The health of each slave is checked at most once per replicaCheckInterval, not on every call.
+// If slave is empty, it will use master connection as the default db connection.
+// But when all slaves are unavailable, it only fall back to master if the master circuit breaker is closed.
+func (d *databaseSQL) Reader() SQLExecutor {
+	d.RLock()
+
+	var conn *sql.DB
+	var connBreaker *breaker
+	var hasSlave bool
+	var takenOut []int
+
+	currentIdleConnection := 0
+	for i, slave := range d.slaves {
+		if slave == nil {
+			continue
+		}
+
+		hasSlave = true
+		if d.slaveBreakers[i].State() == BreakerOpen {
+			continue
+		}
+
+		// also check the unresponsive slave, the result is reused for replicaCheckInterval
+		err := d.slaveChecks[i].check(func() error {
+			err := stdPing(context.Background(), slave, d.slaveBreakers[i])
+			if err != nil {
+				logger.Error().Err(err).Msg("error occurred on slave db")
+			}
+
+			return err
+		})
+
+		if err != nil {
+			if d.slaveBreakers[i] == nil {
+				takenOut = append(takenOut, i)
+			}
+
+			continue
+		}
+
+		idleConn := slave.Stats().Idle
+		if conn == nil || idleConn >= currentIdleConnection {
+			conn = slave
+			connBreaker = d.slaveBreakers[i]
+			currentIdleConnection = idleConn
+		}
+	}
+
+	d.RUnlock()
+
+	if len(takenOut) > 0 {
+		d.Lock() // only lock when election occurred, since unresponsive slave is taken out
+		for _, i := range takenOut {
+			d.slaves[i] = nil
+		}
+		d.Unlock()
+	}
+
+	if conn != nil {
+		return d.executor(conn, RoleReplica, connBreaker)
+	}
+
+	if hasSlave && d.masterBreaker.State() != BreakerClosed {
+		return &unavailableExecutor{err: ErrCircuitOpen}
+	}
+
+	return d.executor(d.master, RoleMaster, d.masterBreaker)
+}
+
+// NewTransaction will always use the master node.
+func (d *databaseSQL) NewTransaction(ctx context.Context) (Transaction, error) {
+	if err := d.masterBreaker.allow(); err != nil {
+		return nil, err
+	}
+
+	tx, err := d.master.BeginTx(ctx, nil)
+	d.masterBreaker.record(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stdTransaction{
+		stdExecutor: *d.executor(tx, RoleMaster, d.masterBreaker),
+		tx:          tx,
+	}, nil
+}
+
+func (d *databaseSQL) executor(conn stdConn, role string, b *breaker) *stdExecutor {
+	return &stdExecutor{
+		conn:     conn,
+		dialect:  d.dialect,
+		debug:    d.debug,
+		observer: queryObserver{role: role, slowQuery: d.slowQuery},
+		breaker:  b,
+	}
+}
+
+// stdPing checks whether the node is reachable through its circuit breaker.
+func stdPing(ctx context.Context, db *sql.DB, b *breaker) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	_, err := db.ExecContext(ctx, "SELECT 1")
+	b.record(err)
+	return err
+}
+
+// stdConn is implemented by both *sql.DB and *sql.Tx.
+type stdConn interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// ====================== EXECUTOR
+type stdExecutor struct {
+	conn     stdConn
+	dialect  Dialect
+	debug    bool
+	observer queryObserver
+	breaker  *breaker
+}
+
+// bind converts the "?" placeholder into the one understood by the database.
+// SQLite already understands "?", so only PostgreSQL query need to be rebound.
+func (e *stdExecutor) bind(query string) string {
+	if e.dialect == DialectSQLite {
+		return query
+	}
+
+	return rebindQuery(query)
+}
+
+func (e *stdExecutor) Query(ctx context.Context, out interface{}, query string, args ...interface{}) (err error) {
+	start := time.Now()
+	defer e.logQuery(start, query)
+
+	rowCount := -1
+	defer func() {
+		e.observer.observe(ctx, start, query, args, rowCount, err)
+	}()
+
+	if err := e.breaker.allow(); err != nil {
+		return err
+	}
+
+	result, err := e.query(ctx, query, args)
+	e.breaker.record(err)
+	if err != nil {
+		return err
+	}
+
+	rowCount = len(result.rows)
+	return scanResult(out, result)
+}
+
+// query reads all rows, so the connection error while reading the rows can be recorded in the circuit breaker.
+func (e *stdExecutor) query(ctx context.Context, query string, args []interface{}) (*queryResult, error) {
+	rows, err := e.conn.QueryContext(ctx, e.bind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return readRows(rows)
+}
+
+func (e *stdExecutor) Exec(ctx context.Context, query string, args ...interface{}) error {
+	start := time.Now()
+	defer e.logQuery(start, query)
+
+	if err := e.breaker.allow(); err != nil {
+		e.observer.observe(ctx, start, query, args, -1, err)
+		return err
+	}
+
+	res, err := e.conn.ExecContext(ctx, e.bind(query), args...)
+	e.breaker.record(err)
+
+	rowCount := -1
+	if err == nil {
+		if affected, err := res.RowsAffected(); err == nil {
+			rowCount = int(affected)
+		}
+	}
+
+	e.observer.observe(ctx, start, query, args, rowCount, err)
+	return err
+}
+
+func (e *stdExecutor) logQuery(start time.Time, query string) {
+	if !e.debug {
+		return
+	}
+
+	elapsedTime := float64(time.Since(start).Nanoseconds()) / float64(1000000)
+	logger.Debug().
+		Str("elapsedTime", fmt.Sprintf("%0.2f ms", elapsedTime)).
+		Str("query", query).
+		Msg("")
+}
+
+// readRows reads all rows into memory.
+func readRows(rows *sql.Rows) (*queryResult, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &queryResult{columns: columns}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		result.rows = append(result.rows, values)
+	}
+
+	return result, rows.Err()
+}
+
+// rebindQuery replaces go-pg placeholder "?" into PostgreSQL "$1", "$2", and so on.
+// Question mark inside quoted string, quoted identifier or comment is not replaced.
+func rebindQuery(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var sb strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				sb.WriteString(query[i:])
+				return sb.String()
+			}
+
+			sb.WriteString(query[i : i+end+2])
+			i += end + 1
+
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				sb.WriteString(query[i:])
+				return sb.String()
+			}
+
+			sb.WriteString(query[i : i+end+1])
+			i += end
+
+		case c == '?':
+			n++
+			fmt.Fprintf(&sb, "$%d", n)
+
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return sb.String()
+}
+
+// ====================== TRANSACTION
+type stdTransaction struct {
+	stdExecutor
+	tx *sql.Tx
+}
+
+func (t *stdTransaction) Commit(ctx context.Context) error {
+	return t.tx.Commit()
+}
+
+func (t *stdTransaction) Rollback(ctx context.Context) error {
+	return t.tx.Rollback()
+}
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRebindQuery(t *testing.T) {
+	convey.Convey("Test rebind placeholder", t, func() {
+		convey.Convey("Query without placeholder is unchanged", func() {
+			convey.So(rebindQuery(`SELECT 1;`), convey.ShouldEqual, `SELECT 1;`)
+		})
+
+		convey.Convey("Placeholder is numbered in order", func() {
+			q := rebindQuery(`INSERT INTO users(username, password) VALUES(?, ?) RETURNING *;`)
+			convey.So(q, convey.ShouldEqual, `INSERT INTO users(username, password) VALUES($1, $2) RETURNING *;`)
+		})
+
+		convey.Convey("Question mark in string, identifier and comment is not replaced", func() {
+			q := rebindQuery("SELECT '?', \"a?\" FROM t -- why?\nWHERE id = ?;")
+			convey.So(q, convey.ShouldEqual, "SELECT '?', \"a?\" FROM t -- why?\nWHERE id = $1;")
+		})
+	})
+}
@@ -0,0 +1,35 @@
+package db
+
+// Dialect is the SQL flavour understood by the database. The value is the same as the dialect name in sql-migrate.
+type Dialect string
+
+const (
+	// DialectPostgres is used by go-pg and database/sql with lib/pq.
+	DialectPostgres Dialect = "postgres"
+
+	// DialectSQLite is used by SQLite database, including the in-memory database.
+	DialectSQLite Dialect = "sqlite3"
+)
+
+// DialectOf returns the dialect of the connection.
+// Connection which doesn't tell its dialect is assumed to speak PostgreSQL.
+func DialectOf(conn SQL) Dialect {
+	if d, ok := conn.(interface{ Dialect() Dialect }); ok {
+		return d.Dialect()
+	}
+
+	return DialectPostgres
+}
+
+// DialectQuery holds the same query written for different dialect.
+// Dialect which is not in the map will use the PostgreSQL query.
+type DialectQuery map[Dialect]string
+
+// For returns the query for the given dialect.
+func (q DialectQuery) For(dialect Dialect) string {
+	if query, ok := q[dialect]; ok {
+		return query
+	}
+
+	return q[DialectPostgres]
+}
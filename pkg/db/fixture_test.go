@@ -0,0 +1,73 @@
+package db
+
+import "context"
+
+type user struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+var myUser = &user{
+	Username: "test_user",
+	Password: "test_password",
+}
+
+var myUser2 = &user{
+	Username: "test_user2",
+	Password: "test_password2",
+}
+
+var myUser3 = &user{
+	Username: "test_user3",
+	Password: "test_password3",
+}
+
+var myUser4 = &user{
+	Username: "test_user4",
+	Password: "test_password4",
+}
+
+// do an insert
+var upsertQuery = `INSERT INTO users(username, password) VALUES(?, ?) ON CONFLICT(username) DO UPDATE SET username = EXCLUDED.username RETURNING *;`
+var insertQuery = `INSERT INTO users(username, password) VALUES(?, ?) RETURNING *;`
+var getQuery = `SELECT * FROM users WHERE username = ? LIMIT 1;`
+
+var createTable = DialectQuery{
+	DialectPostgres: `
+		DROP TABLE IF EXISTS users;
+		CREATE TABLE IF NOT EXISTS users (
+			id BIGSERIAL NOT NULL,
+			username VARCHAR NOT NULL,
+			password VARCHAR NOT NULL
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS users_username_unique_idx ON users(username);
+
+		TRUNCATE users RESTART IDENTITY;
+	`,
+	DialectSQLite: `
+		DROP TABLE IF EXISTS users;
+		CREATE TABLE IF NOT EXISTS users (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			username VARCHAR NOT NULL,
+			password VARCHAR NOT NULL
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS users_username_unique_idx ON users(username);
+	`,
+}
+
+// newMemoryTestConnection returns the in-memory database with the users table.
+func newMemoryTestConnection() SQL {
+	c, err := NewMemoryConnection()
+	if err != nil {
+		panic(err)
+	}
+
+	if err = c.Writer().Exec(context.Background(), createTable.For(DialectOf(c))); err != nil {
+		panic(err)
+	}
+
+	return c
+}
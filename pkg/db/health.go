@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrReplicaTakenOut is reported for the replica which is taken out by Reader after it was unresponsive,
+// it only happens when the circuit breaker is disabled.
+var ErrReplicaTakenOut = errors.New("db: replica is taken out after it was unresponsive")
+
+// NodeHealth is the result of pinging a database node.
+type NodeHealth struct {
+	Role    string // RoleMaster or RoleReplica
+	Node    int    // index of the node in Config.Slaves, always 0 for master
+	Latency time.Duration
+	Err     error // nil when the node answers
+}
+
+// Pinger is implemented by SQL which can ping each of its nodes.
+type Pinger interface {
+	Ping(ctx context.Context) []NodeHealth
+}
+
+// Ping checks the master and every replica of conn. The ping goes through the circuit breaker of the node,
+// so the open breaker is reported as ErrCircuitOpen, and the ping is the trial query once it's half-open.
+// SQL which doesn't implement Pinger is checked by running SELECT 1 on its writer.
+func Ping(ctx context.Context, conn SQL) []NodeHealth {
+	if pinger, ok := conn.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return []NodeHealth{pingNode(RoleMaster, 0, func() error {
+		return conn.Writer().Exec(WithQueryName(ctx, "ping"), "SELECT 1")
+	})}
+}
+
+func pingNode(role string, node int, ping func() error) NodeHealth {
+	start := time.Now()
+	err := ping()
+	return NodeHealth{Role: role, Node: node, Latency: time.Since(start), Err: err}
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestPing(t *testing.T) {
+	convey.Convey("Test Ping", t, func() {
+		ctx := context.Background()
+
+		convey.Convey("Memory database is only down when it's closed", func() {
+			c, err := NewMemoryConnection()
+			convey.So(err, convey.ShouldBeNil)
+
+			health := Ping(ctx, c)
+			convey.So(health, convey.ShouldHaveLength, 1)
+			convey.So(health[0].Role, convey.ShouldEqual, RoleMaster)
+			convey.So(health[0].Err, convey.ShouldBeNil)
+
+			convey.So(c.Close(), convey.ShouldBeNil)
+			convey.So(Ping(ctx, c)[0].Err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Every replica is reported", func() {
+			conf := BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute}
+
+			// nothing listen on port 1, so the ping fails with connection refused
+			down, err := sql.Open("postgres", "host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1")
+			convey.So(err, convey.ShouldBeNil)
+			defer down.Close()
+
+			up, err := NewConnection(&Config{Driver: DriverSQLite, Master: &Conf{URL: "file::memory:"}})
+			convey.So(err, convey.ShouldBeNil)
+			defer up.Close()
+			upDB := up.(StdSQL).MasterDB()
+
+			c := &databaseSQL{
+				dialect:       DialectSQLite,
+				master:        upDB,
+				masterBreaker: newBreaker(conf),
+				slaves:        []*sql.DB{upDB, down, nil},
+				slaveBreakers: []*breaker{newBreaker(conf), newBreaker(conf), nil},
+			}
+
+			health := Ping(ctx, c)
+			convey.So(health, convey.ShouldHaveLength, 4)
+			convey.So(health[0].Role, convey.ShouldEqual, RoleMaster)
+			convey.So(health[0].Err, convey.ShouldBeNil)
+
+			convey.So(health[1].Role, convey.ShouldEqual, RoleReplica)
+			convey.So(health[1].Node, convey.ShouldEqual, 0)
+			convey.So(health[1].Err, convey.ShouldBeNil)
+
+			convey.So(health[2].Node, convey.ShouldEqual, 1)
+			convey.So(health[2].Err, convey.ShouldNotBeNil)
+			convey.So(health[3].Err, convey.ShouldEqual, ErrReplicaTakenOut)
+
+			convey.Convey("Open breaker is reported without touching the node", func() {
+				convey.So(Ping(ctx, c)[2].Err, convey.ShouldEqual, ErrCircuitOpen)
+			})
+		})
+	})
+}
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/metrics"
+)
+
+// Role of the database node, used as label in the metrics.
+const (
+	RoleMaster  = "master"
+	RoleReplica = "replica"
+)
+
+var (
+	queryDuration = metrics.NewHistogramVec(
+		"db_query_duration_seconds",
+		"Database query latency in seconds.",
+		nil,
+		"query", "role",
+	)
+
+	queryRows = metrics.NewHistogramVec(
+		"db_query_rows",
+		"Number of rows returned by the query, or affected by the statement when it returns nothing.",
+		[]float64{0, 1, 5, 10, 50, 100, 500, 1000},
+		"query", "role",
+	)
+
+	queryErrors = metrics.NewCounterVec(
+		"db_query_errors_total",
+		"Total of failed database query, not including query which returns no rows.",
+		"query", "role",
+	)
+)
+
+// PoolStats is the connection pool statistic of a database node.
+// Hits, Misses and Timeouts are only reported by go-pg, the other driver will leave them zero.
+type PoolStats struct {
+	Role     string // RoleMaster or RoleReplica
+	Node     int    // index of the node in Config.Slaves, always 0 for master
+	Hits     uint32 // number of times free connection was found in the pool
+	Misses   uint32 // number of times free connection was NOT found in the pool
+	Timeouts uint32 // number of times a wait timeout occurred
+	Idle     uint32 // number of idle connections in the pool
+	Total    uint32 // number of total connections in the pool
+}
+
+// PoolStatser is implemented by SQL which has connection pool.
+type PoolStatser interface {
+	PoolStats() []PoolStats
+}
+
+type queryNameKey struct{}
+
+// WithQueryName returns a copy of ctx which names the query executed using it.
+// The name is used as the "query" label in the metrics, so it must have low cardinality.
+// Query without name is labeled using its statement and table, for example "select_users".
+func WithQueryName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, queryNameKey{}, name)
+}
+
+// queryName returns the name set by WithQueryName or derives it from the query.
+func queryName(ctx context.Context, query string) string {
+	if name, ok := ctx.Value(queryNameKey{}).(string); ok && name != "" {
+		return name
+	}
+
+	words := strings.Fields(strings.ToLower(strings.Replace(query, "(", " ", -1)))
+	if len(words) == 0 {
+		return "unknown"
+	}
+
+	statement := words[0]
+	var tableAfter string
+	switch statement {
+	case "select", "delete":
+		tableAfter = "from"
+	case "insert":
+		tableAfter = "into"
+	case "update":
+		return statement + "_" + strings.Trim(wordAt(words, 1), `";`)
+	default:
+		return statement
+	}
+
+	for i, word := range words {
+		if word == tableAfter {
+			return statement + "_" + strings.Trim(wordAt(words, i+1), `";`)
+		}
+	}
+
+	return statement
+}
+
+func wordAt(words []string, i int) string {
+	if i < len(words) {
+		return words[i]
+	}
+
+	return ""
+}
+
+// queryObserver records the metrics and logs the slow query of one database node.
+type queryObserver struct {
+	role      string
+	slowQuery time.Duration // zero disables the slow query log
+}
+
+func (o queryObserver) observe(ctx context.Context, start time.Time, query string, args []interface{}, rows int, err error) {
+	elapsed := time.Since(start)
+	name := queryName(ctx, query)
+
+	queryDuration.WithLabelValues(name, o.role).Observe(elapsed.Seconds())
+	if rows >= 0 {
+		queryRows.WithLabelValues(name, o.role).Observe(float64(rows))
+	}
+
+	if err != nil && err != ErrNoRows {
+		queryErrors.WithLabelValues(name, o.role).Inc()
+	}
+
+	if o.slowQuery > 0 && elapsed >= o.slowQuery {
+		logger.Warn().
+			Str("queryName", name).
+			Str("role", o.role).
+			Str("elapsedTime", fmt.Sprintf("%0.2f ms", float64(elapsed.Nanoseconds())/float64(1000000))).
+			Str("query", query).
+			Strs("args", redactArgs(args)).
+			Msg("slow query")
+	}
+}
+
+// redactArgs replaces the query arguments with their type, so the slow query log never contains user data.
+func redactArgs(args []interface{}) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			redacted[i] = "NULL"
+			continue
+		}
+
+		redacted[i] = fmt.Sprintf("<%T>", arg)
+	}
+
+	return redacted
+}
+
+// Metrics returns a collector of the query metrics and the connection pool stats of conn.
+// The query metrics are shared by all connections, so register only one collector to a registry.
+func Metrics(conn SQL) metrics.Collector {
+	return metrics.CollectorFunc(func() []*metrics.Family {
+		families := append(queryDuration.Collect(), queryRows.Collect()...)
+		families = append(families, queryErrors.Collect()...)
+
+		statser, ok := conn.(PoolStatser)
+		if !ok {
+			return families
+		}
+
+		hits := &metrics.Family{Name: "db_pool_hits_total", Help: "Number of times free connection was found in the pool.", Type: metrics.TypeCounter}
+		misses := &metrics.Family{Name: "db_pool_misses_total", Help: "Number of times free connection was NOT found in the pool.", Type: metrics.TypeCounter}
+		timeouts := &metrics.Family{Name: "db_pool_timeouts_total", Help: "Number of times a wait timeout occurred.", Type: metrics.TypeCounter}
+		idle := &metrics.Family{Name: "db_pool_idle_connections", Help: "Number of idle connections in the pool.", Type: metrics.TypeGauge}
+		total := &metrics.Family{Name: "db_pool_total_connections", Help: "Number of total connections in the pool.", Type: metrics.TypeGauge}
+
+		for _, stats := range statser.PoolStats() {
+			labels := []metrics.Label{
+				{Name: "role", Value: stats.Role},
+				{Name: "node", Value: strconv.Itoa(stats.Node)},
+			}
+
+			hits.Samples = append(hits.Samples, metrics.Sample{Name: hits.Name, Labels: labels, Value: float64(stats.Hits)})
+			misses.Samples = append(misses.Samples, metrics.Sample{Name: misses.Name, Labels: labels, Value: float64(stats.Misses)})
+			timeouts.Samples = append(timeouts.Samples, metrics.Sample{Name: timeouts.Name, Labels: labels, Value: float64(stats.Timeouts)})
+			idle.Samples = append(idle.Samples, metrics.Sample{Name: idle.Name, Labels: labels, Value: float64(stats.Idle)})
+			total.Samples = append(total.Samples, metrics.Sample{Name: total.Name, Labels: labels, Value: float64(stats.Total)})
+		}
+
+		return append(families, hits, misses, timeouts, idle, total)
+	})
+}
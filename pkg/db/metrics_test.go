@@ -0,0 +1,81 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+	"github.com/yusufsyaifudin/tax-calculator-example/pkg/metrics"
+)
+
+func TestQueryName(t *testing.T) {
+	convey.Convey("Test query name used in the metrics", t, func() {
+		ctx := context.Background()
+
+		convey.So(queryName(ctx, insertQuery), convey.ShouldEqual, "insert_users")
+		convey.So(queryName(ctx, getQuery), convey.ShouldEqual, "select_users")
+		convey.So(queryName(ctx, `UPDATE "users" SET password = ?;`), convey.ShouldEqual, "update_users")
+		convey.So(queryName(ctx, `DELETE FROM users;`), convey.ShouldEqual, "delete_users")
+		convey.So(queryName(ctx, `SELECT 1`), convey.ShouldEqual, "select")
+		convey.So(queryName(ctx, createTable[DialectPostgres]), convey.ShouldEqual, "drop")
+		convey.So(queryName(ctx, ""), convey.ShouldEqual, "unknown")
+		convey.So(queryName(WithQueryName(ctx, "user_create"), insertQuery), convey.ShouldEqual, "user_create")
+	})
+}
+
+func TestRedactArgs(t *testing.T) {
+	convey.Convey("Test arguments are redacted", t, func() {
+		args := redactArgs([]interface{}{"secret", int64(1), nil, time.Time{}})
+		convey.So(args, convey.ShouldResemble, []string{"<string>", "<int64>", "NULL", "<time.Time>"})
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	convey.Convey("Test query metrics", t, func() {
+		ctx := context.Background()
+		c := newMemoryTestConnection()
+		defer c.Close()
+
+		registry := metrics.NewRegistry()
+		registry.Register(Metrics(c))
+
+		err := c.Writer().Exec(WithQueryName(ctx, "test_insert"), insertQuery, myUser.Username, myUser.Password)
+		convey.So(err, convey.ShouldBeNil)
+
+		err = c.Writer().Exec(WithQueryName(ctx, "test_insert"), insertQuery, myUser.Username, myUser.Password)
+		convey.So(err, convey.ShouldNotBeNil)
+
+		err = c.Reader().Query(WithQueryName(ctx, "test_get"), &user{}, getQuery, "not_exist")
+		convey.So(err, convey.ShouldEqual, ErrNoRows)
+
+		buf := &bytes.Buffer{}
+		convey.So(registry.WriteText(buf), convey.ShouldBeNil)
+
+		out := buf.String()
+		convey.So(out, convey.ShouldContainSubstring, `db_query_duration_seconds_count{query="test_insert",role="master"} 2`)
+		convey.So(out, convey.ShouldContainSubstring, `db_query_rows_bucket{query="test_insert",role="master",le="1"} 1`)
+		convey.So(out, convey.ShouldContainSubstring, `db_query_errors_total{query="test_insert",role="master"} 1`)
+		convey.So(out, convey.ShouldContainSubstring, `db_query_rows_bucket{query="test_get",role="master",le="0"} 1`) // SQLite has no replica, so the reader uses the master
+		convey.So(out, convey.ShouldNotContainSubstring, `db_query_errors_total{query="test_get"`)
+	})
+
+	convey.Convey("Test pool stats metrics", t, func() {
+		c, err := NewConnection(&Config{
+			Driver: DriverSQLite,
+			Master: &Conf{
+				URL: "file::memory:",
+			},
+		})
+		convey.So(err, convey.ShouldBeNil)
+		defer c.Close()
+
+		registry := metrics.NewRegistry()
+		registry.Register(Metrics(c))
+
+		buf := &bytes.Buffer{}
+		convey.So(registry.WriteText(buf), convey.ShouldBeNil)
+		convey.So(buf.String(), convey.ShouldContainSubstring, `db_pool_total_connections{role="master",node="0"} 1`)
+	})
+}
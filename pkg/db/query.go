@@ -2,6 +2,15 @@ package db
 
 import (
 	"context"
+	"errors"
+)
+
+var (
+	// ErrNoRows is returned by Query when the destination is a single struct but the query returns no rows.
+	ErrNoRows = errors.New("db: no rows in result set")
+
+	// ErrMultiRows is returned by Query when the destination is a single struct but the query returns more than one row.
+	ErrMultiRows = errors.New("db: multiple rows in result set")
 )
 
 // SQL can be use writer or read replica only.
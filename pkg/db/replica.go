@@ -0,0 +1,53 @@
+package db
+
+import (
+	"sync"
+	"time"
+)
+
+// replicaCheckInterval is how long Reader reuses the health check result of a slave,
+// so it doesn't ping every slave on each call.
+const replicaCheckInterval = time.Second
+
+// replicaCheck caches the last health check result of one slave.
+type replicaCheck struct {
+	sync.Mutex
+
+	checkedAt time.Time
+	checking  bool // whether a caller is pinging the slave
+	err       error
+	now       func() time.Time
+}
+
+func newReplicaChecks(n int) []*replicaCheck {
+	checks := make([]*replicaCheck, n)
+	for i := range checks {
+		checks[i] = &replicaCheck{now: time.Now}
+	}
+
+	return checks
+}
+
+// check returns the last result of ping, and only pings the slave again when the result is older than
+// replicaCheckInterval. Only one caller pings at a time, the others use the last result meanwhile.
+func (c *replicaCheck) check(ping func() error) error {
+	c.Lock()
+	if c.checking || c.now().Sub(c.checkedAt) < replicaCheckInterval {
+		err := c.err
+		c.Unlock()
+		return err
+	}
+
+	c.checking = true
+	c.Unlock()
+
+	err := ping()
+
+	c.Lock()
+	c.checking = false
+	c.checkedAt = c.now()
+	c.err = err
+	c.Unlock()
+
+	return err
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestReplicaCheck(t *testing.T) {
+	convey.Convey("Test health check of the slave", t, func() {
+		now := time.Now()
+		c := newReplicaChecks(1)[0]
+		c.now = func() time.Time { return now }
+
+		pings := 0
+		ping := func(err error) func() error {
+			return func() error {
+				pings++
+				return err
+			}
+		}
+
+		convey.So(c.check(ping(nil)), convey.ShouldBeNil)
+		convey.So(pings, convey.ShouldEqual, 1)
+
+		convey.Convey("Result is reused until the interval is passed", func() {
+			convey.So(c.check(ping(io.EOF)), convey.ShouldBeNil)
+			convey.So(pings, convey.ShouldEqual, 1)
+
+			now = now.Add(replicaCheckInterval)
+			convey.So(c.check(ping(io.EOF)), convey.ShouldEqual, io.EOF)
+			convey.So(pings, convey.ShouldEqual, 2)
+
+			convey.So(c.check(ping(nil)), convey.ShouldEqual, io.EOF)
+			convey.So(pings, convey.ShouldEqual, 2)
+		})
+
+		convey.Convey("Only one caller pings at a time", func() {
+			now = now.Add(replicaCheckInterval)
+			err := c.check(func() error {
+				convey.So(c.check(ping(io.EOF)), convey.ShouldBeNil) // the last result
+				return nil
+			})
+
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(pings, convey.ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,276 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryResult is the rows returned by a query, used by SQL implementation which doesn't have its own model mapping.
+type queryResult struct {
+	columns []string
+	rows    [][]interface{}
+}
+
+// scanResult scans the result into out, following go-pg behaviour:
+// struct expects exactly one row, while slice can receive zero or more rows.
+func scanResult(out interface{}, result *queryResult) error {
+	if out == nil || result == nil {
+		return nil
+	}
+
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("db: destination must be a non-nil pointer, got %T", out)
+	}
+
+	v := rv.Elem()
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		elemType := v.Type().Elem()
+		slice := reflect.MakeSlice(v.Type(), 0, len(result.rows))
+		for _, row := range result.rows {
+			item := reflect.New(indirectType(elemType)).Elem()
+			if err := scanRow(item, result.columns, row); err != nil {
+				return err
+			}
+
+			if elemType.Kind() == reflect.Ptr {
+				item = item.Addr()
+			}
+
+			slice = reflect.Append(slice, item)
+		}
+
+		v.Set(slice)
+		return nil
+	}
+
+	switch len(result.rows) {
+	case 0:
+		return ErrNoRows
+	case 1:
+		return scanRow(v, result.columns, result.rows[0])
+	default:
+		return ErrMultiRows
+	}
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+
+	return t
+}
+
+// scanRow scans one row into struct (matching column name with the field) or scalar destination.
+func scanRow(dest reflect.Value, columns []string, row []interface{}) error {
+	if dest.Kind() == reflect.Ptr && indirectType(dest.Type()).Kind() == reflect.Struct {
+		if dest.IsNil() {
+			dest.Set(reflect.New(dest.Type().Elem()))
+		}
+		dest = dest.Elem()
+	}
+
+	if dest.Kind() != reflect.Struct || dest.Type() == reflect.TypeOf(time.Time{}) {
+		if len(columns) != 1 {
+			return fmt.Errorf("db: can't scan %d columns into %s", len(columns), dest.Type())
+		}
+
+		return assignValue(dest, row[0])
+	}
+
+	fields := structFields(dest.Type())
+	for i, column := range columns {
+		index, ok := fields[column]
+		if !ok {
+			return fmt.Errorf("db: can't find column=%s in model=%s", column, dest.Type().Name())
+		}
+
+		if err := assignValue(dest.FieldByIndex(index), row[i]); err != nil {
+			return fmt.Errorf("db: column %s: %s", column, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// structFields maps column name into the struct field index. Column name is taken from `sql` tag,
+// or the snake_case version of the field name, the same rule as go-pg.
+func structFields(t reflect.Type) map[string][]int {
+	fields := map[string][]int{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("sql"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+
+		// embedded struct, embedded pointer is not supported since FieldByIndex panics when it is nil
+		if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+			for name, index := range structFields(field.Type) {
+				fields[name] = append([]int{i}, index...)
+			}
+			continue
+		}
+
+		name := tag
+		if name == "" {
+			name = underscore(field.Name)
+		}
+
+		fields[name] = []int{i}
+	}
+
+	return fields
+}
+
+// underscore converts "CamelCasedString" to "camel_cased_string", copied from go-pg internal package.
+func underscore(s string) string {
+	r := make([]byte, 0, len(s)+5)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' {
+			if i > 0 && i+1 < len(s) && (isLower(s[i-1]) || isLower(s[i+1])) {
+				r = append(r, '_', c+32)
+			} else {
+				r = append(r, c+32)
+			}
+		} else {
+			r = append(r, c)
+		}
+	}
+
+	return string(r)
+}
+
+func isLower(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+func assignValue(dest reflect.Value, value interface{}) error {
+	if value == nil {
+		dest.Set(reflect.Zero(dest.Type()))
+		return nil
+	}
+
+	if dest.CanAddr() {
+		if scanner, ok := dest.Addr().Interface().(sql.Scanner); ok {
+			return scanner.Scan(value)
+		}
+	}
+
+	switch dest.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(dest.Type().Elem())
+		if err := assignValue(elem.Elem(), value); err != nil {
+			return err
+		}
+
+		dest.Set(elem)
+		return nil
+
+	case reflect.Interface:
+		dest.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	switch v := value.(type) {
+	case int64:
+		switch dest.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			dest.SetInt(v)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			dest.SetUint(uint64(v))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			dest.SetFloat(float64(v))
+			return nil
+		case reflect.String:
+			dest.SetString(strconv.FormatInt(v, 10))
+			return nil
+		}
+
+	case float64:
+		switch dest.Kind() {
+		case reflect.Float32, reflect.Float64:
+			dest.SetFloat(v)
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			dest.SetInt(int64(v))
+			return nil
+		case reflect.String:
+			dest.SetString(strconv.FormatFloat(v, 'f', -1, 64))
+			return nil
+		}
+
+	case string:
+		switch dest.Kind() {
+		case reflect.String:
+			dest.SetString(v)
+			return nil
+		case reflect.Slice:
+			if dest.Type().Elem().Kind() == reflect.Uint8 {
+				dest.SetBytes([]byte(v))
+				return nil
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return err
+			}
+			dest.SetInt(i)
+			return nil
+		case reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return err
+			}
+			dest.SetFloat(f)
+			return nil
+		case reflect.Bool:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			dest.SetBool(b)
+			return nil
+		}
+
+	case bool:
+		if dest.Kind() == reflect.Bool {
+			dest.SetBool(v)
+			return nil
+		}
+
+	case time.Time:
+		switch {
+		case dest.Type() == reflect.TypeOf(time.Time{}):
+			dest.Set(reflect.ValueOf(v))
+			return nil
+		case dest.Kind() == reflect.String:
+			dest.SetString(v.Format(time.RFC3339Nano))
+			return nil
+		}
+
+	case []byte:
+		switch {
+		case dest.Kind() == reflect.Slice && dest.Type().Elem().Kind() == reflect.Uint8:
+			dest.SetBytes(append([]byte{}, v...))
+			return nil
+		default:
+			// database/sql driver returns some types as text, such as numeric
+			return assignValue(dest, string(v))
+		}
+	}
+
+	return fmt.Errorf("can't scan %T into %s", value, dest.Type())
+}
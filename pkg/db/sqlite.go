@@ -0,0 +1,71 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	// is a driver needed by sql.Open to connect to sqlite.
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newSQLite will create connection to SQLite database file using database/sql with github.com/mattn/go-sqlite3.
+// SQLite has no read replica, so config.Slaves is ignored and Reader will use the same connection as the Writer.
+func newSQLite(config *Config) (SQL, error) {
+	conf := *config.Master
+	conf.URL = sqliteDSN(conf.URL)
+
+	// SQLite only allows one writer at a time, using more than one connection only makes "database is locked" error.
+	conf.PoolSize = 1
+
+	masterConnection, err := createStdConnection("sqlite3", &conf)
+	if err != nil {
+		return &databaseSQL{}, err
+	}
+
+	return &databaseSQL{
+		dialect:       DialectSQLite,
+		debug:         conf.Debug,
+		slowQuery:     config.SlowQueryThreshold,
+		master:        masterConnection,
+		masterBreaker: newBreaker(config.Breaker),
+	}, nil
+}
+
+// memoryDatabases counts the in-memory databases, so each of them has its own name.
+var memoryDatabases int64
+
+// NewMemoryConnection will create a new empty in-memory SQLite database. It doesn't need any running database server,
+// so it is useful to run the test quickly. The database is gone when the connection is closed.
+func NewMemoryConnection() (SQL, error) {
+	name := fmt.Sprintf("memory-%d", atomic.AddInt64(&memoryDatabases, 1))
+	return newSQLite(&Config{
+		Master: &Conf{
+			URL: fmt.Sprintf("file:%s?mode=memory&cache=shared", name),
+		},
+	})
+}
+
+// sqliteDSN turns on foreign key check (which is off by default in SQLite) and busy timeout,
+// unless it already set in the url.
+func sqliteDSN(url string) string {
+	var params []string
+	if !strings.Contains(url, "_foreign_keys=") && !strings.Contains(url, "_fk=") {
+		params = append(params, "_foreign_keys=1")
+	}
+
+	if !strings.Contains(url, "_busy_timeout=") && !strings.Contains(url, "_timeout=") {
+		params = append(params, "_busy_timeout=5000")
+	}
+
+	if len(params) == 0 {
+		return url
+	}
+
+	separator := "?"
+	if strings.Contains(url, "?") {
+		separator = "&"
+	}
+
+	return url + separator + strings.Join(params, "&")
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestSQLite_Conformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	runConformanceTests(t, func() (SQL, error) {
+		return NewConnection(&Config{
+			Driver: DriverSQLite,
+			Master: &Conf{
+				URL: filepath.Join(dir, "test.db"),
+			},
+		})
+	})
+}
+
+func TestSQLite_Dialect(t *testing.T) {
+	convey.Convey("Test SQLite dialect", t, func() {
+		c, err := NewConnection(&Config{
+			Driver: DriverSQLite,
+			Master: &Conf{
+				URL: "file::memory:",
+			},
+		})
+		convey.So(err, convey.ShouldBeNil)
+		defer c.Close()
+
+		convey.So(DialectOf(c), convey.ShouldEqual, DialectSQLite)
+
+		convey.Convey("Foreign key is checked", func() {
+			err = c.Writer().Exec(context.Background(), `
+				CREATE TABLE parents (id INTEGER NOT NULL PRIMARY KEY);
+				CREATE TABLE children (id INTEGER NOT NULL PRIMARY KEY, parent_id INTEGER NOT NULL REFERENCES parents(id));
+			`)
+			convey.So(err, convey.ShouldBeNil)
+
+			err = c.Writer().Exec(context.Background(), `INSERT INTO children(parent_id) VALUES(?);`, 1)
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	convey.Convey("Test SQLite DSN", t, func() {
+		convey.So(sqliteDSN("test.db"), convey.ShouldEqual, "test.db?_foreign_keys=1&_busy_timeout=5000")
+		convey.So(sqliteDSN("file:test.db?mode=rwc"), convey.ShouldEqual, "file:test.db?mode=rwc&_foreign_keys=1&_busy_timeout=5000")
+		convey.So(sqliteDSN("test.db?_fk=0&_timeout=10"), convey.ShouldEqual, "test.db?_fk=0&_timeout=10")
+	})
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrNestedTransaction is returned by NewTransaction of the connection given by RunInTransaction,
+// since the transaction can't be started inside another transaction.
+var ErrNestedTransaction = errors.New("db: transaction is already started")
+
+// RunInTransaction runs fn in a new transaction of conn. The connection given to fn runs every query in that transaction,
+// so the repositories created from it share the transaction. The transaction is committed when fn returns nil,
+// otherwise it's rolled back and the error of fn is returned.
+func RunInTransaction(ctx context.Context, conn SQL, fn func(tx SQL) error) error {
+	tx, err := conn.NewTransaction(ctx)
+	if err != nil {
+		return err
+	}
+
+	t := &txSQL{conn: conn, tx: tx}
+	if err = fn(t); err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	err = tx.Commit(ctx)
+
+	// the hooks are run even when commit fails, since the transaction may be committed before the connection is lost
+	t.runAfterCommit()
+	return err
+}
+
+// AfterCommit runs fn after the transaction is committed when conn is given by RunInTransaction,
+// fn is not run when the transaction is rolled back. Otherwise, fn is run right away since the query is already committed.
+// It's used to update the state kept outside of the database, such as the cache, after the new rows can be read.
+func AfterCommit(conn SQL, fn func()) {
+	if t, ok := conn.(*txSQL); ok {
+		t.Lock()
+		t.afterCommit = append(t.afterCommit, fn)
+		t.Unlock()
+		return
+	}
+
+	fn()
+}
+
+// txSQL is the SQL which Writer and Reader are the transaction, the dialect is still read from the connection.
+type txSQL struct {
+	sync.Mutex
+
+	conn        SQL
+	tx          Transaction
+	afterCommit []func()
+}
+
+func (t *txSQL) runAfterCommit() {
+	t.Lock()
+	hooks := t.afterCommit
+	t.afterCommit = nil
+	t.Unlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// Close does nothing, the transaction is finished by RunInTransaction.
+func (t *txSQL) Close() error {
+	return nil
+}
+
+func (t *txSQL) Writer() SQLExecutor {
+	return t.tx
+}
+
+// Reader returns the transaction too, so the rows written in the transaction can be read.
+func (t *txSQL) Reader() SQLExecutor {
+	return t.tx
+}
+
+func (t *txSQL) NewTransaction(ctx context.Context) (Transaction, error) {
+	return nil, ErrNestedTransaction
+}
+
+func (t *txSQL) Dialect() Dialect {
+	return DialectOf(t.conn)
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRunInTransaction(t *testing.T) {
+	convey.Convey("Test RunInTransaction", t, func() {
+		ctx := context.Background()
+		c := newMemoryTestConnection()
+		defer c.Close()
+
+		countUsers := func() int {
+			users := []*user{}
+			convey.So(c.Reader().Query(ctx, &users, `SELECT * FROM users;`), convey.ShouldBeNil)
+			return len(users)
+		}
+
+		convey.Convey("Commit when fn succeeds, the insert is readable in the transaction", func() {
+			err := RunInTransaction(ctx, c, func(tx SQL) error {
+				if err := tx.Writer().Exec(ctx, `INSERT INTO users (username, password) VALUES (?, ?);`, "alice", "secret"); err != nil {
+					return err
+				}
+
+				u := &user{}
+				if err := tx.Reader().Query(ctx, u, `SELECT * FROM users WHERE username = ?;`, "alice"); err != nil {
+					return err
+				}
+
+				convey.So(u.ID, convey.ShouldBeGreaterThan, 0)
+				return nil
+			})
+
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(countUsers(), convey.ShouldEqual, 1)
+		})
+
+		convey.Convey("Rollback when fn fails", func() {
+			fnErr := errors.New("fn error")
+			err := RunInTransaction(ctx, c, func(tx SQL) error {
+				if err := tx.Writer().Exec(ctx, `INSERT INTO users (username, password) VALUES (?, ?);`, "alice", "secret"); err != nil {
+					return err
+				}
+
+				return fnErr
+			})
+
+			convey.So(err, convey.ShouldEqual, fnErr)
+			convey.So(countUsers(), convey.ShouldEqual, 0)
+		})
+
+		convey.Convey("AfterCommit runs after commit only", func() {
+			committed := 0
+			err := RunInTransaction(ctx, c, func(tx SQL) error {
+				AfterCommit(tx, func() { committed++ })
+				convey.So(committed, convey.ShouldEqual, 0)
+				return nil
+			})
+
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(committed, convey.ShouldEqual, 1)
+
+			err = RunInTransaction(ctx, c, func(tx SQL) error {
+				AfterCommit(tx, func() { committed++ })
+				return errors.New("fn error")
+			})
+
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(committed, convey.ShouldEqual, 1)
+
+			// outside of the transaction, it's run right away
+			AfterCommit(c, func() { committed++ })
+			convey.So(committed, convey.ShouldEqual, 2)
+		})
+
+		convey.Convey("Nested transaction is refused", func() {
+			err := RunInTransaction(ctx, c, func(tx SQL) error {
+				_, err := tx.NewTransaction(ctx)
+				return err
+			})
+
+			convey.So(err, convey.ShouldEqual, ErrNestedTransaction)
+			convey.So(DialectOf(&txSQL{conn: c}), convey.ShouldEqual, DialectSQLite)
+		})
+	})
+}
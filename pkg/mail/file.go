@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes every email into a .eml file in the directory, so it can be opened using mail client in local development.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer creates the directory when it doesn't exist.
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// Send writes the message into <unix nano>-<random>.eml file.
+func (f *FileMailer) Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = f.from
+	}
+
+	now := time.Now()
+	data, err := msg.bytes(now)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", now.UnixNano(), hex.EncodeToString(suffix))
+	return ioutil.WriteFile(filepath.Join(f.dir, name), data, 0600)
+}
@@ -0,0 +1,49 @@
+// Package mail sends plain text email through the Mailer interface. SMTPMailer delivers it to the SMTP server,
+// FileMailer writes it into a directory for local development and MemoryMailer keeps it for the test.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// ErrInvalidHeader is returned when the address or the subject contains line break, which would inject another header.
+var ErrInvalidHeader = errors.New("mail: header must not contain line break")
+
+// Message is a plain text email.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends the email.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// bytes formats the message in RFC 5322 format, the subject is encoded so it can contain non-ASCII characters.
+func (m Message) bytes(now time.Time) ([]byte, error) {
+	for _, header := range []string{m.From, m.To, m.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, ErrInvalidHeader
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "From: %s\r\n", m.From)
+	fmt.Fprintf(buf, "To: %s\r\n", m.To)
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.Replace(strings.Replace(m.Body, "\r\n", "\n", -1), "\n", "\r\n", -1))
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,129 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+// fakeSMTPServer accepts one message without auth and sends the DATA through the channel.
+func fakeSMTPServer(t *testing.T) (string, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make(chan string, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		write := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		write("220 localhost ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			switch command := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				write("250 localhost")
+			case command == "DATA":
+				write("354 end with .")
+				body := []string{}
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+
+					if line == ".\r\n" {
+						break
+					}
+
+					body = append(body, line)
+				}
+
+				data <- strings.Join(body, "")
+				write("250 queued")
+			case command == "QUIT":
+				write("221 bye")
+				return
+			default:
+				write("250 ok")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), data
+}
+
+func TestMailer(t *testing.T) {
+	convey.Convey("Test mailer", t, func() {
+		ctx := context.Background()
+		msg := Message{To: "user@example.com", Subject: "Reset password", Body: "line 1\nline 2"}
+
+		convey.Convey("Memory mailer keeps the message", func() {
+			mailer := NewMemoryMailer()
+			convey.So(mailer.Send(ctx, msg), convey.ShouldBeNil)
+			convey.So(mailer.Messages(), convey.ShouldResemble, []Message{msg})
+		})
+
+		convey.Convey("Header with line break is rejected", func() {
+			mailer := NewMemoryMailer()
+			msg.To = "user@example.com\r\nBcc: other@example.com"
+			convey.So(mailer.Send(ctx, msg), convey.ShouldEqual, ErrInvalidHeader)
+			convey.So(mailer.Messages(), convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("File mailer writes the message into the directory", func() {
+			dir, err := ioutil.TempDir("", "mail")
+			convey.So(err, convey.ShouldBeNil)
+
+			mailer, err := NewFileMailer(filepath.Join(dir, "outbox"), "noreply@example.com")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(mailer.Send(ctx, msg), convey.ShouldBeNil)
+
+			files, err := filepath.Glob(filepath.Join(dir, "outbox", "*.eml"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(files, convey.ShouldHaveLength, 1)
+
+			content, err := ioutil.ReadFile(files[0])
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(string(content), convey.ShouldContainSubstring, "From: noreply@example.com\r\n")
+			convey.So(string(content), convey.ShouldContainSubstring, "To: user@example.com\r\n")
+			convey.So(string(content), convey.ShouldEndWith, "\r\n\r\nline 1\r\nline 2")
+		})
+
+		convey.Convey("SMTP mailer delivers the message to the server", func() {
+			address, data := fakeSMTPServer(t)
+			mailer, err := NewSMTPMailer(SMTPConfig{Address: address, From: "noreply@example.com"})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(mailer.Send(ctx, msg), convey.ShouldBeNil)
+
+			body := <-data
+			convey.So(body, convey.ShouldContainSubstring, "Subject: Reset password\r\n")
+			convey.So(body, convey.ShouldContainSubstring, "line 1\r\nline 2")
+		})
+
+		convey.Convey("SMTP mailer requires the address and the sender", func() {
+			_, err := NewSMTPMailer(SMTPConfig{Address: "localhost", From: "noreply@example.com"})
+			convey.So(err, convey.ShouldNotBeNil)
+
+			_, err = NewSMTPMailer(SMTPConfig{Address: "localhost:25"})
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
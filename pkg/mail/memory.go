@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryMailer keeps the sent email in memory, it's used in the test.
+type MemoryMailer struct {
+	sync.Mutex
+	messages []Message
+}
+
+// NewMemoryMailer creates the in-memory mailer.
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Send keeps the message, it still rejects the header with line break like the other mailers.
+func (m *MemoryMailer) Send(ctx context.Context, msg Message) error {
+	if _, err := msg.bytes(time.Now()); err != nil {
+		return err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the sent email, the oldest first.
+func (m *MemoryMailer) Messages() []Message {
+	m.Lock()
+	defer m.Unlock()
+
+	return append([]Message{}, m.messages...)
+}
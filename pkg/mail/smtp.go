@@ -0,0 +1,65 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPConfig is the configuration of the SMTP server.
+type SMTPConfig struct {
+	Address  string // host:port of the SMTP server
+	Username string // PLAIN auth is used when it's not empty, the server must support STARTTLS unless it's localhost
+	Password string
+	From     string // sender address used when the message has no From
+}
+
+// SMTPMailer delivers the email to the SMTP server, STARTTLS is used when the server supports it.
+type SMTPMailer struct {
+	conf SMTPConfig
+}
+
+// NewSMTPMailer creates the SMTP mailer.
+func NewSMTPMailer(conf SMTPConfig) (*SMTPMailer, error) {
+	if _, _, err := net.SplitHostPort(conf.Address); err != nil {
+		return nil, fmt.Errorf("smtp address must be host:port: %s", err.Error())
+	}
+
+	if conf.From == "" {
+		return nil, fmt.Errorf("smtp sender address is required")
+	}
+
+	return &SMTPMailer{conf: conf}, nil
+}
+
+// Send delivers the message, net/smtp doesn't support context so only the deadline of ctx is used as timeout.
+func (s *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = s.conf.From
+	}
+
+	data, err := msg.bytes(time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.conf.Username != "" {
+		host, _, _ := net.SplitHostPort(s.conf.Address)
+		auth = smtp.PlainAuth("", s.conf.Username, s.conf.Password, host)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- smtp.SendMail(s.conf.Address, auth, msg.From, []string{msg.To}, data)
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
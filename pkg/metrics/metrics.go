@@ -0,0 +1,203 @@
+// Package metrics is a small metric registry which writes the Prometheus text exposition format.
+// It only implements what this project needs: counter, gauge and histogram with labels.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric types as written in the "# TYPE" line.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// DefBuckets is the default histogram buckets in seconds, the same as the Prometheus client.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultRegistry is the registry served by the application metrics endpoint.
+var DefaultRegistry = NewRegistry()
+
+// Label is a name and value pair of a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single value in the exposition.
+type Sample struct {
+	Name   string // full sample name, including the _bucket, _sum or _count suffix for histogram
+	Labels []Label
+	Value  float64
+}
+
+// Family is a group of samples with the same metric name.
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Collector returns the current value of its metrics.
+type Collector interface {
+	Collect() []*Family
+}
+
+// CollectorFunc is an adapter to use an ordinary function as Collector.
+type CollectorFunc func() []*Family
+
+// Collect calls f().
+func (f CollectorFunc) Collect() []*Family {
+	return f()
+}
+
+// Registry holds the registered collectors.
+type Registry struct {
+	sync.Mutex
+
+	collectors []Collector
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds the collectors into the registry.
+func (r *Registry) Register(collectors ...Collector) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// Unregister removes the collector from the registry.
+func (r *Registry) Unregister(collector Collector) {
+	r.Lock()
+	defer r.Unlock()
+
+	for i, c := range r.collectors {
+		if c == collector {
+			r.collectors = append(r.collectors[:i], r.collectors[i+1:]...)
+			return
+		}
+	}
+}
+
+// Gather collects all families sorted by name. Families with the same name are merged.
+func (r *Registry) Gather() []*Family {
+	r.Lock()
+	collectors := append([]Collector{}, r.collectors...)
+	r.Unlock()
+
+	byName := map[string]*Family{}
+	for _, collector := range collectors {
+		for _, family := range collector.Collect() {
+			if existing, ok := byName[family.Name]; ok {
+				existing.Samples = append(existing.Samples, family.Samples...)
+				continue
+			}
+
+			copied := *family
+			byName[family.Name] = &copied
+		}
+	}
+
+	families := make([]*Family, 0, len(byName))
+	for _, family := range byName {
+		families = append(families, family)
+	}
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].Name < families[j].Name
+	})
+
+	return families
+}
+
+// WriteText writes all metrics in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, family := range r.Gather() {
+		if len(family.Samples) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(bw, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", family.Name, family.Type)
+		for _, sample := range family.Samples {
+			bw.WriteString(sample.Name)
+			writeLabels(bw, sample.Labels)
+			bw.WriteByte(' ')
+			bw.WriteString(formatFloat(sample.Value))
+			bw.WriteByte('\n')
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Handler returns http.Handler which serves the metrics in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.WriteText(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func writeLabels(bw *bufio.Writer, labels []Label) {
+	if len(labels) == 0 {
+		return
+	}
+
+	bw.WriteByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+
+		bw.WriteString(label.Name)
+		bw.WriteString(`="`)
+		bw.WriteString(escapeLabelValue(label.Value))
+		bw.WriteByte('"')
+	}
+	bw.WriteByte('}')
+}
+
+var (
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	convey.Convey("Test write metrics in text format", t, func() {
+		registry := NewRegistry()
+
+		counter := NewCounterVec("test_requests_total", "Total request.", "method")
+		gauge := NewGaugeVec("test_in_flight", "Request in flight.")
+		histogram := NewHistogramVec("test_duration_seconds", "Request duration.", []float64{0.1, 1}, "path")
+		registry.Register(histogram, counter, gauge)
+
+		convey.Convey("Metric without sample is not written", func() {
+			buf := &bytes.Buffer{}
+			convey.So(registry.WriteText(buf), convey.ShouldBeNil)
+			convey.So(buf.String(), convey.ShouldEqual, "")
+		})
+
+		convey.Convey("Families are sorted by name and label value is escaped", func() {
+			counter.WithLabelValues("GET").Inc()
+			counter.WithLabelValues("GET").Add(2)
+			counter.WithLabelValues(`"POST"`).Inc()
+			gauge.WithLabelValues().Set(3)
+			gauge.WithLabelValues().Dec()
+			histogram.WithLabelValues("/tax").Observe(0.05)
+			histogram.WithLabelValues("/tax").Observe(0.5)
+			histogram.WithLabelValues("/tax").Observe(5)
+
+			buf := &bytes.Buffer{}
+			convey.So(registry.WriteText(buf), convey.ShouldBeNil)
+			convey.So(buf.String(), convey.ShouldEqual, `# HELP test_duration_seconds Request duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{path="/tax",le="0.1"} 1
+test_duration_seconds_bucket{path="/tax",le="1"} 2
+test_duration_seconds_bucket{path="/tax",le="+Inf"} 3
+test_duration_seconds_sum{path="/tax"} 5.55
+test_duration_seconds_count{path="/tax"} 3
+# HELP test_in_flight Request in flight.
+# TYPE test_in_flight gauge
+test_in_flight 2
+# HELP test_requests_total Total request.
+# TYPE test_requests_total counter
+test_requests_total{method="\"POST\""} 1
+test_requests_total{method="GET"} 3
+`)
+		})
+
+		convey.Convey("Wrong number of label values is panic", func() {
+			convey.So(func() { counter.WithLabelValues() }, convey.ShouldPanic)
+			convey.So(func() { counter.WithLabelValues("GET").Add(-1) }, convey.ShouldPanic)
+		})
+
+		convey.Convey("Unregistered collector is not written", func() {
+			counter.WithLabelValues("GET").Inc()
+			registry.Unregister(counter)
+
+			buf := &bytes.Buffer{}
+			convey.So(registry.WriteText(buf), convey.ShouldBeNil)
+			convey.So(buf.String(), convey.ShouldNotContainSubstring, "test_requests_total")
+		})
+
+		convey.Convey("Handler serves text format", func() {
+			gauge.WithLabelValues().Set(1)
+
+			rec := httptest.NewRecorder()
+			registry.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+			convey.So(rec.Code, convey.ShouldEqual, 200)
+			convey.So(rec.Header().Get("Content-Type"), convey.ShouldStartWith, "text/plain; version=0.0.4")
+			convey.So(rec.Body.String(), convey.ShouldContainSubstring, "test_in_flight 1\n")
+		})
+	})
+}
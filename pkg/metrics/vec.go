@@ -0,0 +1,277 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// vec keeps one metric value per combination of label values.
+type vec struct {
+	sync.Mutex
+
+	name       string
+	help       string
+	labelNames []string
+	values     map[string]interface{}
+}
+
+func newVec(name, help string, labelNames []string) vec {
+	return vec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		values:     map[string]interface{}{},
+	}
+}
+
+// get returns the value for the label values, or creates it using newValue.
+func (v *vec) get(labelValues []string, newValue func(labels []Label) interface{}) interface{} {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	v.Lock()
+	defer v.Unlock()
+
+	if value, ok := v.values[key]; ok {
+		return value
+	}
+
+	labels := make([]Label, len(labelValues))
+	for i, value := range labelValues {
+		labels[i] = Label{Name: v.labelNames[i], Value: value}
+	}
+
+	value := newValue(labels)
+	v.values[key] = value
+	return value
+}
+
+// sortedKeys returns the keys so the output is stable between scrapes.
+func (v *vec) sortedKeys() []string {
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// ====================== COUNTER
+
+// Counter is a value that only goes up.
+type Counter struct {
+	sync.Mutex
+
+	labels []Label
+	value  float64
+}
+
+// Inc adds 1 to the counter.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds the given value to the counter. It panics if the value is negative.
+func (c *Counter) Add(value float64) {
+	if value < 0 {
+		panic("metrics: counter cannot decrease in value")
+	}
+
+	c.Lock()
+	c.value += value
+	c.Unlock()
+}
+
+// CounterVec is a Counter partitioned by label values.
+type CounterVec struct {
+	vec
+}
+
+// NewCounterVec creates a new CounterVec.
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{vec: newVec(name, help, labelNames)}
+}
+
+// WithLabelValues returns the Counter for the label values, in the same order as the label names.
+func (c *CounterVec) WithLabelValues(labelValues ...string) *Counter {
+	return c.get(labelValues, func(labels []Label) interface{} {
+		return &Counter{labels: labels}
+	}).(*Counter)
+}
+
+// Collect implements Collector.
+func (c *CounterVec) Collect() []*Family {
+	c.Lock()
+	defer c.Unlock()
+
+	family := &Family{Name: c.name, Help: c.help, Type: TypeCounter}
+	for _, key := range c.sortedKeys() {
+		counter := c.values[key].(*Counter)
+		counter.Lock()
+		family.Samples = append(family.Samples, Sample{Name: c.name, Labels: counter.labels, Value: counter.value})
+		counter.Unlock()
+	}
+
+	return []*Family{family}
+}
+
+// ====================== GAUGE
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	sync.Mutex
+
+	labels []Label
+	value  float64
+}
+
+// Set sets the gauge into the given value.
+func (g *Gauge) Set(value float64) {
+	g.Lock()
+	g.value = value
+	g.Unlock()
+}
+
+// Add adds the given value to the gauge, use negative value to subtract.
+func (g *Gauge) Add(value float64) {
+	g.Lock()
+	g.value += value
+	g.Unlock()
+}
+
+// Inc adds 1 to the gauge.
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+// Dec subtracts 1 from the gauge.
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+// GaugeVec is a Gauge partitioned by label values.
+type GaugeVec struct {
+	vec
+}
+
+// NewGaugeVec creates a new GaugeVec.
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{vec: newVec(name, help, labelNames)}
+}
+
+// WithLabelValues returns the Gauge for the label values, in the same order as the label names.
+func (g *GaugeVec) WithLabelValues(labelValues ...string) *Gauge {
+	return g.get(labelValues, func(labels []Label) interface{} {
+		return &Gauge{labels: labels}
+	}).(*Gauge)
+}
+
+// Collect implements Collector.
+func (g *GaugeVec) Collect() []*Family {
+	g.Lock()
+	defer g.Unlock()
+
+	family := &Family{Name: g.name, Help: g.help, Type: TypeGauge}
+	for _, key := range g.sortedKeys() {
+		gauge := g.values[key].(*Gauge)
+		gauge.Lock()
+		family.Samples = append(family.Samples, Sample{Name: g.name, Labels: gauge.labels, Value: gauge.value})
+		gauge.Unlock()
+	}
+
+	return []*Family{family}
+}
+
+// ====================== HISTOGRAM
+
+// Histogram counts observed values in buckets.
+type Histogram struct {
+	sync.Mutex
+
+	labels  []Label
+	buckets []float64
+	counts  []uint64 // not cumulative, counts[len(buckets)] is the +Inf bucket
+	sum     float64
+	count   uint64
+}
+
+// Observe adds a single observation into the histogram.
+func (h *Histogram) Observe(value float64) {
+	i := sort.SearchFloat64s(h.buckets, value)
+
+	h.Lock()
+	h.counts[i]++
+	h.sum += value
+	h.count++
+	h.Unlock()
+}
+
+// HistogramVec is a Histogram partitioned by label values.
+type HistogramVec struct {
+	vec
+	buckets     []float64
+	upperBounds []float64 // buckets and +Inf
+}
+
+// NewHistogramVec creates a new HistogramVec. Buckets must be sorted in increasing order, use DefBuckets if it's nil.
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: %s buckets must be sorted", name))
+	}
+
+	return &HistogramVec{
+		vec:         newVec(name, help, labelNames),
+		buckets:     buckets,
+		upperBounds: append(append([]float64{}, buckets...), math.Inf(1)),
+	}
+}
+
+// WithLabelValues returns the Histogram for the label values, in the same order as the label names.
+func (h *HistogramVec) WithLabelValues(labelValues ...string) *Histogram {
+	return h.get(labelValues, func(labels []Label) interface{} {
+		return &Histogram{
+			labels:  labels,
+			buckets: h.buckets,
+			counts:  make([]uint64, len(h.buckets)+1),
+		}
+	}).(*Histogram)
+}
+
+// Collect implements Collector.
+func (h *HistogramVec) Collect() []*Family {
+	h.Lock()
+	defer h.Unlock()
+
+	family := &Family{Name: h.name, Help: h.help, Type: TypeHistogram}
+	for _, key := range h.sortedKeys() {
+		histogram := h.values[key].(*Histogram)
+		histogram.Lock()
+
+		var cumulative uint64
+		for i, upperBound := range h.upperBounds {
+			cumulative += histogram.counts[i]
+			labels := append(append([]Label{}, histogram.labels...), Label{Name: "le", Value: formatFloat(upperBound)})
+			family.Samples = append(family.Samples, Sample{Name: h.name + "_bucket", Labels: labels, Value: float64(cumulative)})
+		}
+
+		family.Samples = append(family.Samples,
+			Sample{Name: h.name + "_sum", Labels: histogram.labels, Value: histogram.sum},
+			Sample{Name: h.name + "_count", Labels: histogram.labels, Value: float64(histogram.count)},
+		)
+
+		histogram.Unlock()
+	}
+
+	return []*Family{family}
+}